@@ -1,26 +1,56 @@
 package main
 
 import (
-	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	// Packages
-
+	server "github.com/mutablelogic/go-server"
+	ctx "github.com/mutablelogic/go-server/pkg/context"
 	"github.com/mutablelogic/go-server/pkg/provider"
 )
 
+const (
+	cmdRun      = "run"
+	cmdSchema   = "schema"
+	cmdValidate = "validate"
+)
+
 func main() {
-	var pluginPath string
+	var pluginPath, configPath string
+	var jsonOutput bool
 	name := filepath.Base(os.Args[0])
 	flags := flag.NewFlagSet(name, flag.ContinueOnError)
 	flags.StringVar(&pluginPath, "plugin", "*.plugin", "Path to plugins")
+	flags.StringVar(&configPath, "config", "", "Path to JSON or HCL configuration file")
+	flags.BoolVar(&jsonOutput, "json", false, "Output plugin schema as JSON Schema")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [flags] [%s|%s|%s]\n", name, cmdRun, cmdSchema, cmdValidate)
+		fmt.Fprintf(flags.Output(), "  %-10s Run tasks from the configuration file (default)\n", cmdRun)
+		fmt.Fprintf(flags.Output(), "  %-10s Print the configuration schema for each plugin\n", cmdSchema)
+		fmt.Fprintf(flags.Output(), "  %-10s Check the configuration file without running any tasks\n", cmdValidate)
+		fmt.Fprintln(flags.Output(), "Flags:")
+		flags.PrintDefaults()
+	}
 	if err := flags.Parse(os.Args[1:]); err != nil {
 		os.Exit(1)
 	}
+	cmd := cmdRun
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(1)
+	} else if flags.NArg() == 1 {
+		cmd = flags.Arg(0)
+	}
+	if configPath == "" && cmd != cmdSchema {
+		fmt.Fprintln(os.Stderr, "Missing -config flag")
+		os.Exit(1)
+	}
 
 	// If the path is relative, then make it absolute to either the binary path
 	// or the current working directory
@@ -34,58 +64,100 @@ func main() {
 		}
 	}
 
-	// Create a new provider, load plugins
-	provider, err := provider.New()
+	// Load plugins
+	plugins, err := provider.New()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-	if err := provider.LoadPluginsForPattern(pluginPath); err != nil {
+	if err := plugins.LoadPluginsForPattern(pluginPath); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	// Create configurations
-	var result error
-	for _, plugin := range []string{"logger", "httpserver", "router", "router-frontend", "nginx-handler", "auth-handler", "tokenjar-handler"} {
-		// Create a new configuration for the plugin
-		if _, err := provider.New(plugin); err != nil {
-			result = errors.Join(result, err)
+	// Print the schema, or validate the configuration
+	switch cmd {
+	case cmdRun:
+		// Fallthrough to run the tasks
+	case cmdSchema:
+		if err := schema(os.Stdout, plugins.Plugins(), jsonOutput); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-	}
-	if result != nil {
-		fmt.Fprintln(os.Stderr, result)
+		return
+	case cmdValidate:
+		if err := validate(configPath, plugins.Plugins()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(configPath + ": OK")
+		return
+	default:
+		flags.Usage()
 		os.Exit(1)
 	}
 
-	// Create configuration manually
-	// SET does not actually set it:
-	// - checks the field exists
-	// - updates the dependencies between fields
-	// - marks that the value should be set later
-	// - there is a binding stage later which actually sets the value
-	// - this also ensures we set things in the right order
-	// GET will:
-	// - check the field exists
-	// - return a reference to the value if not applied yet
-	provider.Set("logger.flags", []string{"default", "prefix"})
+	// Create a provider which parses the configuration file when it is run,
+	// and when it is reloaded
+	provider := provider.NewProviderWithLoader(func() (*provider.Parser, error) {
+		return parse(configPath, plugins.Plugins())
+	})
 
-	provider.Set("nginx.binary", "/usr/local/bin/nginx")
-	provider.Set("nginx.data", "/var/run/nginx")
-	provider.Set("nginx.group", "www-data")
+	// Create context which cancels on interrupt
+	ctx := ctx.ContextForSignal(os.Interrupt, syscall.SIGQUIT)
 
-	provider.Set("httpserver.listen", "run/go-server.sock")
-	provider.Set("httpserver.group", "www-data")
-	provider.Set("httpserver.router", provider.Get("router"))
-
-	provider.Set("auth.tokenjar", provider.Get("tokenjar"))
-	provider.Set("auth.tokenbytes", 16)
-	provider.Set("auth.bearer", true)
+	// Reload the configuration on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if err := provider.Reload(ctx); err != nil {
+					provider.Print(ctx, "Reload: ", err)
+				}
+			}
+		}
+	}()
 
-	provider.Set("tokenjar.data", "run")
-	provider.Set("tokenjar.writeinterval", "30s")
+	// Run until we receive an interrupt
+	if err := provider.Run(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
 
-/*
+// Parse and bind a configuration file
+func parse(path string, plugins []server.Plugin) (*provider.Parser, error) {
+	r, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
 
- */
+	// Parse the configuration
+	parser, err := provider.NewParser(plugins...)
+	if err != nil {
+		return nil, err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		if err := parser.ParseJSON(r); err != nil {
+			return nil, err
+		}
+	case ".hcl":
+		if err := parser.ParseHCL(r); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported configuration file type: %q", ext)
+	}
+	if err := parser.Bind(); err != nil {
+		return nil, err
+	}
+
+	// Return success
+	return parser, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+
+	// Packages
+	tablewriter "github.com/djthorpe/go-tablewriter"
+	server "github.com/mutablelogic/go-server"
+	provider "github.com/mutablelogic/go-server/pkg/provider"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// A row in the table of plugin fields
+type schemaRow struct {
+	Plugin      string `json:"plugin" writer:",width:16"`
+	Field       string `json:"field" writer:",width:30"`
+	Type        string `json:"type" writer:",width:16"`
+	Required    string `json:"required" writer:",width:8"`
+	Description string `json:"description" writer:",width:50,wrap"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Write the configuration schema for the plugins, either as a table or as
+// a JSON Schema
+func schema(w io.Writer, plugins []server.Plugin, asJSON bool) error {
+	metas := make([]*provider.PluginMeta, 0, len(plugins))
+	for _, plugin := range plugins {
+		meta, err := provider.NewPluginMeta(plugin)
+		if err != nil {
+			return err
+		}
+		metas = append(metas, meta)
+	}
+
+	// Output the JSON Schema
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(provider.NewSchema(metas...))
+	}
+
+	// Output the table of fields
+	var rows []schemaRow
+	for _, meta := range metas {
+		rows = append(rows, schemaRow{Plugin: meta.Name, Description: meta.Description})
+		rows = schemaRows(rows, meta.Name, "", meta.Schema())
+	}
+	return tablewriter.New(w, tablewriter.OptHeader(), tablewriter.OptOutputText()).Write(rows)
+}
+
+// Append rows for the properties of a schema, sorted by field name
+func schemaRows(rows []schemaRow, plugin, prefix string, schema *provider.Schema) []schemaRow {
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		property := schema.Properties[name]
+		row := schemaRow{
+			Plugin:      plugin,
+			Field:       prefix + name,
+			Type:        schemaType(property),
+			Description: property.Description,
+		}
+		if slices.Contains(schema.Required, name) {
+			row.Required = "yes"
+		}
+		rows = append(rows, row)
+		if property.Type == "object" && len(property.Properties) > 0 {
+			rows = schemaRows(rows, plugin, prefix+name+".", property)
+		}
+	}
+	return rows
+}
+
+// Return a human-readable type for a schema
+func schemaType(schema *provider.Schema) string {
+	switch {
+	case schema.Format != "":
+		return schema.Format
+	case schema.Type == "array" && schema.Items != nil:
+		return "[]" + schemaType(schema.Items)
+	case schema.Type == "object" && len(schema.Properties) > 0:
+		return "block"
+	case schema.Type == "object":
+		if elem, ok := schema.AdditionalProperties.(*provider.Schema); ok {
+			return "map[" + schemaType(elem) + "]"
+		}
+		return "map"
+	case schema.Type == "":
+		return "any"
+	default:
+		return strings.ToLower(schema.Type)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	// Packages
+	server "github.com/mutablelogic/go-server"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Parse and bind a configuration file, and check each configuration without
+// creating any tasks
+func validate(path string, plugins []server.Plugin) error {
+	parser, err := parse(path, plugins)
+	if err != nil {
+		return err
+	}
+	return parser.Validate()
+}
@@ -0,0 +1,43 @@
+# Variables
+var {
+  nginx-binary = "/usr/local/bin/nginx"
+  nginx-data   = default(env.NGINX_DATA, "/var/run/nginx")
+  nginx-group  = "nginx"
+}
+
+logger "main" {
+  flags = ["default", "prefix"]
+}
+
+nginx-handler "main" {
+  binary_path = var.nginx-binary
+  data        = "${var.nginx-data}"
+}
+
+tokenjar-handler "main" {
+  datapath       = "run"
+  write-interval = "30s"
+}
+
+auth-handler "main" {
+  token_jar   = tokenjar-handler.main
+  token_bytes = 16
+  bearer      = true
+}
+
+router "main" {
+  services "nginx" {
+    service    = nginx-handler.main
+    middleware = [logger.main, auth-handler.main]
+  }
+  services "auth" {
+    service    = auth-handler.main
+    middleware = [logger.main, auth-handler.main]
+  }
+}
+
+httpserver {
+  listen = "run/go-server.sock"
+  group  = var.nginx-group
+  router = router.main
+}
@@ -6,6 +6,7 @@ package client
 import (
 
 	// Packages
+	"net/http"
 	"time"
 
 	"github.com/mutablelogic/go-client"
@@ -78,3 +79,36 @@ func (c *Client) Create(name string, expires_in time.Duration, scopes ...string)
 	// Return success
 	return response, nil
 }
+
+// Exchange the client token for a signed JSON Web Token
+func (c *Client) JWT() (auth.TokenJWT, error) {
+	var response auth.TokenJWT
+	if err := c.Do(client.NewRequestEx(http.MethodPost, client.ContentTypeAny), &response, client.OptPath("-", "jwt")); err != nil {
+		return auth.TokenJWT{}, err
+	}
+	return response, nil
+}
+
+// Update the name, duration or scopes of a token, and return the token
+func (c *Client) Update(name string, patch auth.TokenPatch) (auth.Token, error) {
+	var response auth.Token
+
+	// Request->Response
+	if payload, err := client.NewJSONRequestEx(http.MethodPatch, patch, client.ContentTypeAny); err != nil {
+		return auth.Token{}, err
+	} else if err := c.Do(payload, &response, client.OptPath(name)); err != nil {
+		return auth.Token{}, err
+	}
+
+	// Return success
+	return response, nil
+}
+
+// Issue a new value for a token, and return the token with the new value
+func (c *Client) Rotate(name string) (auth.Token, error) {
+	var response auth.Token
+	if err := c.Do(client.NewRequestEx(http.MethodPost, client.ContentTypeAny), &response, client.OptPath(name, "rotate")); err != nil {
+		return auth.Token{}, err
+	}
+	return response, nil
+}
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"time"
+
 	// Packages
 	server "github.com/mutablelogic/go-server"
 )
@@ -12,6 +14,21 @@ type Config struct {
 	TokenJar   TokenJar `hcl:"token_jar" description:"Persistent storage for tokens"`
 	TokenBytes int      `hcl:"token_bytes" description:"Number of bytes in a token"`
 	Bearer     bool     `hcl:"bearer" description:"Use bearer token for authorization"`
+	JWT        struct {
+		Algorithm string        `hcl:"algorithm" description:"Algorithm for signing JSON Web Tokens (HS256 or ES256), or empty to disable"`
+		Secret    string        `hcl:"secret" description:"Secret for HS256 signing, or empty to generate a secret on startup"`
+		Key       string        `hcl:"key" description:"Path to P-256 private key for ES256 signing, or empty to generate a key on startup"`
+		Expiry    time.Duration `hcl:"expiry" description:"Lifetime of an issued JSON Web Token (default 1h)"`
+		Cookie    string        `hcl:"cookie" description:"Name of the cookie which carries a JSON Web Token, or empty to disable cookies"`
+		Insecure  bool          `hcl:"insecure" description:"Send the cookie without TLS, when neither the server nor a reverse proxy in front of it uses TLS"`
+	} `hcl:"jwt"`
+	LDAP struct {
+		Service LDAP                `hcl:"service" description:"LDAP service for authenticating users with a password, or empty to disable"`
+		Groups  map[string][]string `hcl:"groups" description:"Scopes for the members of each LDAP group"`
+		Expiry  time.Duration       `hcl:"expiry" description:"Lifetime of a token issued on login (default 1h)"`
+		Basic   bool                `hcl:"basic" description:"Accept HTTP Basic authentication with an LDAP user and password"`
+		Cache   time.Duration       `hcl:"cache" description:"Time to cache a user verified with HTTP Basic authentication (default 30s), or negative to disable"`
+	} `hcl:"ldap"`
 }
 
 // Check interfaces are satisfied
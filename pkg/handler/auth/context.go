@@ -16,13 +16,17 @@ const (
 	_ authContextKey = iota
 	contextName
 	contextScope
+	contextPublic
+	contextRecorder
 )
 
 ////////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
-// WithTokenName returns a context with the given auth token name
+// WithTokenName returns a context with the given auth token name, and
+// records the name if the context has a token recorder
 func WithTokenName(ctx context.Context, token Token) context.Context {
+	recordToken(ctx, token)
 	return context.WithValue(ctx, contextName, token.Name)
 }
 
@@ -55,9 +59,42 @@ func TokenScope(ctx context.Context) []string {
 	return nil
 }
 
+// WithTokenRecorder returns a context which records the name of the token
+// when a request is authenticated (when the token name is set on a context
+// derived from this one), and a function which returns the name.
+// Middleware which runs before authentication can use this to read the
+// token name after the request has been handled
+func WithTokenRecorder(ctx context.Context) (context.Context, func() string) {
+	name := new(string)
+	return context.WithValue(ctx, contextRecorder, name), func() string {
+		return *name
+	}
+}
+
+// WithPublic returns a context for adding endpoints which do not require
+// a token, such as endpoints which are read by clients which cannot
+// authenticate
+func WithPublic(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextPublic, true)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// recordToken records the token name, if the context has a recorder
+func recordToken(ctx context.Context, token Token) {
+	if name, ok := ctx.Value(contextRecorder).(*string); ok {
+		*name = token.Name
+	}
+}
+
+// isPublic returns true if the context is for an endpoint which does not
+// require a token
+func isPublic(ctx context.Context) bool {
+	value, ok := ctx.Value(contextPublic).(bool)
+	return ok && value
+}
+
 func str(ctx context.Context, key authContextKey) string {
 	if value, ok := ctx.Value(key).(string); ok {
 		return value
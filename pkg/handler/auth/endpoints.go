@@ -2,9 +2,9 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"regexp"
-	"slices"
 	"strings"
 	"time"
 
@@ -14,6 +14,9 @@ import (
 	httprequest "github.com/mutablelogic/go-server/pkg/httprequest"
 	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
 	types "github.com/mutablelogic/go-server/pkg/types"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -30,8 +33,11 @@ const (
 )
 
 var (
-	reRoot  = regexp.MustCompile(`^/?$`)
-	reToken = regexp.MustCompile(`^/(` + types.ReIdentifier + `)/?$`)
+	reRoot   = regexp.MustCompile(`^/?$`)
+	reJWT    = regexp.MustCompile(`^/-/jwt/?$`)
+	reLogin  = regexp.MustCompile(`^/-/login/?$`)
+	reToken  = regexp.MustCompile(`^/(?P<name>` + types.ReIdentifier + `)/?$`)
+	reRotate = regexp.MustCompile(`^/(?P<name>` + types.ReIdentifier + `)/rotate/?$`)
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -42,31 +48,63 @@ func (service *auth) AddEndpoints(ctx context.Context, r server.Router) {
 	// Path: /
 	// Methods: GET
 	// Scopes: read
-	// Description: Get current set of tokens and groups
 	r.AddHandlerFuncRe(ctx, reRoot, service.ListTokens, http.MethodGet).(router.Route).
-		SetScope(service.ScopeRead()...)
+		SetScope(service.ScopeRead()...).
+		SetSummary("Get current set of tokens and groups").
+		SetResponse(http.StatusOK, []Token{})
 
 	// Path: /
 	// Methods: POST
 	// Scopes: write
-	// Description: Create a new token
 	r.AddHandlerFuncRe(ctx, reRoot, service.CreateToken, http.MethodPost).(router.Route).
-		SetScope(service.ScopeWrite()...)
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Create a new token").
+		SetRequest(TokenCreate{}).
+		SetResponse(http.StatusCreated, Token{})
 
 	// Path: /<token-name>
 	// Methods: GET
 	// Scopes: read
-	// Description: Get a token
 	r.AddHandlerFuncRe(ctx, reToken, service.GetToken, http.MethodGet).(router.Route).
-		SetScope(service.ScopeRead()...)
+		SetScope(service.ScopeRead()...).
+		SetSummary("Get a token").
+		SetResponse(http.StatusOK, Token{})
 
 	// Path: /<token-name>
 	// Methods: DELETE, PATCH
 	// Scopes: write
-	// Description: Delete or update a token
 	r.AddHandlerFuncRe(ctx, reToken, service.UpdateToken, http.MethodDelete, http.MethodPatch).(router.Route).
-		SetScope(service.ScopeWrite()...)
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Delete or update a token").
+		SetRequest(TokenPatch{}).
+		SetResponse(http.StatusOK, Token{})
+
+	// Path: /<token-name>/rotate
+	// Methods: POST
+	// Scopes: write
+	r.AddHandlerFuncRe(ctx, reRotate, service.RotateToken, http.MethodPost).(router.Route).
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Issue a new value for a token, keeping the name, expiry and scopes").
+		SetResponse(http.StatusCreated, Token{})
+
+	// Path: /-/jwt
+	// Methods: POST, DELETE
+	// Scopes: (none)
+	if service.jwt != nil {
+		r.AddHandlerFuncRe(ctx, reJWT, service.CreateJWT, http.MethodPost, http.MethodDelete).(router.Route).
+			SetSummary("Exchange the requestor's token for a signed JWT, or remove the JWT cookie").
+			SetResponse(http.StatusCreated, TokenJWT{})
+	}
 
+	// Path: /-/login
+	// Methods: POST
+	// Scopes: (none, and no token is required)
+	if service.ldap != nil {
+		r.AddHandlerFuncRe(WithPublic(ctx), reLogin, service.Login, http.MethodPost).(router.Route).
+			SetSummary("Exchange an LDAP user and password for a token").
+			SetRequest(TokenLogin{}).
+			SetResponse(http.StatusCreated, Token{})
+	}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -115,6 +153,9 @@ func (service *auth) CreateToken(w http.ResponseWriter, r *http.Request) {
 	if !types.IsIdentifier(req.Name) {
 		httpresponse.Error(w, http.StatusBadRequest, "invalid 'name'")
 		return
+	} else if isLDAPToken(req.Name) {
+		httpresponse.Error(w, http.StatusBadRequest, "reserved 'name'")
+		return
 	} else if token := service.jar.GetWithName(req.Name); !token.IsZero() {
 		httpresponse.Error(w, http.StatusConflict, "duplicate 'name'")
 		return
@@ -129,12 +170,10 @@ func (service *auth) CreateToken(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Should not be able to add the root scope unless you have the root scope
-	if slices.Contains(req.Scope, ScopeRoot) {
-		requestorScopes := TokenScope(r.Context())
-		if !slices.Contains(requestorScopes, ScopeRoot) {
-			httpresponse.Error(w, http.StatusForbidden, "Cannot create a token with root scope")
-		}
+	// The requestor cannot grant scopes which it does not hold
+	if err := canGrant(TokenScope(r.Context()), req.Scope...); err != nil {
+		httpresponse.Error(w, http.StatusForbidden, err.Error())
+		return
 	}
 
 	// Create the token
@@ -143,6 +182,8 @@ func (service *auth) CreateToken(w http.ResponseWriter, r *http.Request) {
 		httpresponse.Error(w, http.StatusInternalServerError)
 		return
 	}
+	token.Modified = time.Now()
+	token.Modifier = TokenName(r.Context())
 
 	// Add the token to the jar
 	if err := service.jar.Create(token); err != nil {
@@ -173,16 +214,80 @@ func (service *auth) UpdateToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The requestor needs to hold all the scopes of the token in order
+	// to change it
+	if err := canGrant(TokenScope(r.Context()), token.Scope...); err != nil {
+		httpresponse.Error(w, http.StatusForbidden, err.Error())
+		return
+	}
+
 	switch r.Method {
 	case http.MethodDelete:
 		if err := service.jar.Delete(token.Value); err != nil {
 			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+
+		// Record the deletion, which cannot be recorded on the token
+		service.logf(r.Context(), "Token %q deleted by %q", token.Name, requestor)
+	case http.MethodPatch:
+		var req TokenPatch
+
+		// Get the request
+		if err := httprequest.Body(&req, r); err != nil {
+			httpresponse.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Change the name
+		if name := strings.TrimSpace(req.Name); name != "" && name != token.Name {
+			if !types.IsIdentifier(name) {
+				httpresponse.Error(w, http.StatusBadRequest, "invalid 'name'")
+				return
+			} else if isLDAPToken(name) {
+				httpresponse.Error(w, http.StatusBadRequest, "reserved 'name'")
+				return
+			} else if other := service.jar.GetWithName(name); !other.IsZero() {
+				httpresponse.Error(w, http.StatusConflict, "duplicate 'name'")
+				return
+			}
+			token.Name = name
+		}
+
+		// Change the expiry, where a zero duration means no expiry
+		if req.Duration != nil {
+			if duration := req.Duration.Duration; duration == 0 {
+				token.Expire = time.Time{}
+			} else if duration = duration.Truncate(time.Minute); duration < time.Minute {
+				httpresponse.Error(w, http.StatusBadRequest, "invalid 'duration'")
+				return
+			} else {
+				token.Expire = time.Now().Add(duration)
+			}
+		}
+
+		// Change the scopes, which the requestor needs to hold
+		if req.Scope != nil {
+			if err := canGrant(TokenScope(r.Context()), *req.Scope...); err != nil {
+				httpresponse.Error(w, http.StatusForbidden, err.Error())
+				return
+			}
+			token.Scope = *req.Scope
+		}
+
+		// Update the token
+		token.Modified = time.Now()
+		token.Modifier = requestor
+		if err := service.jar.Update(token); err != nil {
+			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Return the token, without the value
+		token.Value = ""
+		httpresponse.JSON(w, token, http.StatusOK, jsonIndent)
+		return
 	default:
-		// TODO: PATCH
-		// Patch can be with name, expire_time, scopes
-		// TODO: Should not be able to add the root scope unless you have the root scope
 		httpresponse.Error(w, http.StatusMethodNotAllowed)
 		return
 	}
@@ -190,3 +295,131 @@ func (service *auth) UpdateToken(w http.ResponseWriter, r *http.Request) {
 	// Respond with no content
 	httpresponse.Empty(w, http.StatusOK)
 }
+
+// Issue a new value for an existing token, keeping the name, expiry and
+// scopes. The previous value can no longer be used
+func (service *auth) RotateToken(w http.ResponseWriter, r *http.Request) {
+	urlParameters := router.Params(r.Context())
+	token := service.jar.GetWithName(urlParameters[0])
+	if token.IsZero() {
+		httpresponse.Error(w, http.StatusNotFound)
+		return
+	}
+
+	// The requestor needs to hold all the scopes of the token in order
+	// to rotate it, but can rotate its own token
+	if err := canGrant(TokenScope(r.Context()), token.Scope...); err != nil {
+		httpresponse.Error(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	// Create the new token, and remove the old one
+	value := token.Value
+	token.Value = generateToken(service.tokenBytes)
+	token.Modified = time.Now()
+	token.Modifier = TokenName(r.Context())
+	if token.Value == "" {
+		httpresponse.Error(w, http.StatusInternalServerError)
+		return
+	} else if err := service.jar.Create(token); err != nil {
+		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	} else if err := service.jar.Delete(value); err != nil {
+		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Remove the access_time which doesn't make sense for a new value
+	token.Time = time.Time{}
+
+	// Return the token, with the new value
+	httpresponse.JSON(w, token, http.StatusCreated, jsonIndent)
+}
+
+// Exchange the requestor's token for a signed JWT. The JWT is also set as
+// a cookie if configured. When the method is DELETE, the cookie is removed.
+func (service *auth) CreateJWT(w http.ResponseWriter, r *http.Request) {
+	if service.jwt == nil {
+		httpresponse.Error(w, http.StatusNotImplemented)
+		return
+	}
+
+	// Remove the cookie
+	if r.Method == http.MethodDelete {
+		if service.jwt.cookie != "" {
+			service.jwt.setCookie(w, TokenJWT{})
+		}
+		httpresponse.Empty(w, http.StatusOK)
+		return
+	}
+
+	// Get the requestor's token from the jar, to pick up the current scopes
+	// and expiry
+	token := service.jar.GetWithName(TokenName(r.Context()))
+	if token.IsZero() || !token.IsValid() {
+		httpresponse.Error(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	// Sign the token
+	value, err := service.jwt.Sign(token)
+	if err != nil {
+		httpresponse.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	// Set the cookie
+	if service.jwt.cookie != "" {
+		service.jwt.setCookie(w, value)
+	}
+
+	// Return the JWT
+	httpresponse.JSON(w, value, http.StatusCreated, jsonIndent)
+}
+
+// Exchange a user and password for a token, which has the scopes of the
+// user's LDAP groups and expires after a short time. The user and password
+// are read from the request body, or from HTTP Basic authentication
+func (service *auth) Login(w http.ResponseWriter, r *http.Request) {
+	if service.ldap == nil {
+		httpresponse.Error(w, http.StatusNotImplemented)
+		return
+	}
+
+	// Get the request
+	var req TokenLogin
+	if user, password, ok := r.BasicAuth(); ok {
+		req.User, req.Password = user, password
+	} else if err := httprequest.Body(&req, r); err != nil {
+		httpresponse.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Authenticate the user
+	token, err := service.ldap.Authenticate(r.Context(), strings.TrimSpace(req.User), req.Password)
+	if errors.Is(err, ErrNotAuthorized) {
+		httpresponse.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	} else if err != nil {
+		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Add the token to the jar, replacing the token issued on a previous
+	// login so that tokens do not accumulate. The previous value can no
+	// longer be used
+	previous := service.jar.GetWithName(token.Name)
+	token.Value = generateToken(service.tokenBytes)
+	if err := service.jar.Create(token); err != nil {
+		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	} else if !previous.IsZero() {
+		if err := service.jar.Delete(previous.Value); err != nil {
+			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	// Return the token
+	httpresponse.JSON(w, token, http.StatusCreated, jsonIndent)
+}
@@ -0,0 +1,240 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/handler/auth"
+	"github.com/mutablelogic/go-server/pkg/handler/router"
+	"github.com/mutablelogic/go-server/pkg/handler/tokenjar"
+	"github.com/mutablelogic/go-server/pkg/provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_endpoints_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// Create a token jar and auth object
+	jar, err := tokenjar.New(tokenjar.Config{
+		DataPath: t.TempDir(),
+	})
+	assert.NoError(err)
+	tokens, err := auth.New(auth.Config{
+		TokenJar: jar,
+		Bearer:   true,
+	})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Add the endpoints to a router, with the auth middleware
+	r, err := router.Config{}.New()
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	r.(router.Router).AddServiceEndpoints("auth", tokens, tokens)
+	handler := r.(http.Handler)
+
+	// Create a root token, and a token which can only read and write
+	// tokens and has one other scope
+	root := auth.NewToken("root", 16, 0, auth.ScopeRoot)
+	assert.NoError(jar.Create(root))
+	admin := auth.NewToken("admin", 16, 0, append(append(tokens.ScopeRead(), tokens.ScopeWrite()...), ScopeRead)...)
+	assert.NoError(jar.Create(admin))
+
+	// Make a request with a token
+	do := func(method, path string, token auth.Token, body string) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		}
+		req := httptest.NewRequest(method, "/auth"+path, reader)
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("Authorization", "Bearer "+token.Value)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("CreateEscalation", func(t *testing.T) {
+		resp := do(http.MethodPost, "/", admin, `{"name":"test1","scopes":["`+ScopeWrite+`"]}`)
+		assert.Equal(http.StatusForbidden, resp.Code)
+		resp = do(http.MethodPost, "/", admin, `{"name":"test1","scopes":["`+auth.ScopeRoot+`"]}`)
+		assert.Equal(http.StatusForbidden, resp.Code)
+		assert.True(jar.GetWithName("test1").IsZero())
+
+		// The admin token can grant scopes which it holds
+		resp = do(http.MethodPost, "/", admin, `{"name":"test1","scopes":["`+ScopeRead+`"]}`)
+		assert.Equal(http.StatusCreated, resp.Code)
+		assert.Equal("admin", jar.GetWithName("test1").Modifier)
+
+		// The root token can create root tokens
+		resp = do(http.MethodPost, "/", root, `{"name":"test2","scopes":["`+auth.ScopeRoot+`"]}`)
+		assert.Equal(http.StatusCreated, resp.Code)
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		var token auth.Token
+		resp := do(http.MethodPatch, "/test1", admin, `{"name":"test3","duration":"1h"}`)
+		if !assert.Equal(http.StatusOK, resp.Code) {
+			t.SkipNow()
+		}
+		assert.NoError(json.Unmarshal(resp.Body.Bytes(), &token))
+		assert.Equal("test3", token.Name)
+		assert.Empty(token.Value)
+		assert.Equal([]string{ScopeRead}, token.Scope)
+		assert.WithinDuration(time.Now().Add(time.Hour), token.Expire, time.Minute)
+		assert.Equal("admin", token.Modifier)
+		assert.True(jar.GetWithName("test1").IsZero())
+
+		// Remove the expiry and the scopes
+		resp = do(http.MethodPatch, "/test3", admin, `{"duration":"0s","scopes":[]}`)
+		assert.Equal(http.StatusOK, resp.Code)
+		token = jar.GetWithName("test3")
+		assert.True(token.Expire.IsZero())
+		assert.Empty(token.Scope)
+
+		// Invalid requests
+		resp = do(http.MethodPatch, "/test3", admin, `{"name":"test2"}`)
+		assert.Equal(http.StatusConflict, resp.Code)
+		resp = do(http.MethodPatch, "/test3", admin, `{"duration":"1s"}`)
+		assert.Equal(http.StatusBadRequest, resp.Code)
+		resp = do(http.MethodPatch, "/test4", admin, `{"name":"test5"}`)
+		assert.Equal(http.StatusNotFound, resp.Code)
+		resp = do(http.MethodPatch, "/admin", admin, `{"name":"test5"}`)
+		assert.Equal(http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("PatchEscalation", func(t *testing.T) {
+		// Cannot add scopes which the requestor does not hold
+		resp := do(http.MethodPatch, "/test3", admin, `{"scopes":["`+ScopeWrite+`"]}`)
+		assert.Equal(http.StatusForbidden, resp.Code)
+		resp = do(http.MethodPatch, "/test3", admin, `{"scopes":["`+auth.ScopeRoot+`"]}`)
+		assert.Equal(http.StatusForbidden, resp.Code)
+		assert.Empty(jar.GetWithName("test3").Scope)
+
+		// Cannot change or delete a token with scopes which the requestor
+		// does not hold
+		resp = do(http.MethodPatch, "/test2", admin, `{"scopes":["`+ScopeRead+`"]}`)
+		assert.Equal(http.StatusForbidden, resp.Code)
+		resp = do(http.MethodDelete, "/test2", admin, "")
+		assert.Equal(http.StatusForbidden, resp.Code)
+		resp = do(http.MethodPost, "/test2/rotate", admin, "")
+		assert.Equal(http.StatusForbidden, resp.Code)
+
+		// The root token can
+		resp = do(http.MethodPatch, "/test3", root, `{"scopes":["`+auth.ScopeRoot+`"]}`)
+		assert.Equal(http.StatusOK, resp.Code)
+		assert.Equal("root", jar.GetWithName("test3").Modifier)
+	})
+
+	t.Run("Rotate", func(t *testing.T) {
+		var token auth.Token
+		before := jar.GetWithName("admin")
+		resp := do(http.MethodPost, "/admin/rotate", admin, "")
+		if !assert.Equal(http.StatusCreated, resp.Code) {
+			t.SkipNow()
+		}
+		assert.NoError(json.Unmarshal(resp.Body.Bytes(), &token))
+		assert.Equal("admin", token.Name)
+		assert.NotEmpty(token.Value)
+		assert.NotEqual(before.Value, token.Value)
+		assert.Equal(before.Scope, token.Scope)
+		assert.Equal("admin", token.Modifier)
+
+		// The previous value can no longer be used
+		assert.True(jar.GetWithValue(before.Value).IsZero())
+		resp = do(http.MethodGet, "/", admin, "")
+		assert.Equal(http.StatusUnauthorized, resp.Code)
+		resp = do(http.MethodGet, "/", token, "")
+		assert.Equal(http.StatusOK, resp.Code)
+	})
+}
+
+func Test_endpoints_002(t *testing.T) {
+	assert := assert.New(t)
+
+	// Create a token jar and auth object, and run it with a logger
+	jar, err := tokenjar.New(tokenjar.Config{
+		DataPath: t.TempDir(),
+	})
+	assert.NoError(err)
+	tokens, err := auth.New(auth.Config{
+		TokenJar: jar,
+		Bearer:   true,
+	})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	var logger testLogger
+	ctx, cancel := context.WithCancel(provider.WithLogger(context.Background(), &logger))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tokens.Run(ctx)
+	}()
+	defer wg.Wait()
+	defer cancel()
+
+	// Wait for the root token to be created, once the task is running
+	assert.Eventually(func() bool {
+		return len(jar.Tokens()) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	// Add the endpoints to a router, with the auth middleware
+	r, err := router.Config{}.New()
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	r.(router.Router).AddServiceEndpoints("auth", tokens, tokens)
+	admin := auth.NewToken("admin", 16, 0, auth.ScopeRoot)
+	assert.NoError(jar.Create(admin))
+	assert.NoError(jar.Create(auth.NewToken("test", 16, 0)))
+
+	// A deletion is recorded with the name of the acting token
+	req := httptest.NewRequest(http.MethodDelete, "/auth/test", nil)
+	req.Header.Set("Authorization", "Bearer "+admin.Value)
+	resp := httptest.NewRecorder()
+	r.(http.Handler).ServeHTTP(resp, req)
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Contains(logger.Lines(), `Token "test" deleted by "admin"`)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// LOGGER
+
+// testLogger records the messages which are logged
+type testLogger struct {
+	sync.Mutex
+	lines []string
+}
+
+func (logger *testLogger) Print(ctx context.Context, args ...any) {
+	logger.Lock()
+	defer logger.Unlock()
+	logger.lines = append(logger.lines, fmt.Sprint(args...))
+}
+
+func (logger *testLogger) Printf(ctx context.Context, format string, args ...any) {
+	logger.Lock()
+	defer logger.Unlock()
+	logger.lines = append(logger.lines, fmt.Sprintf(format, args...))
+}
+
+func (logger *testLogger) Lines() []string {
+	logger.Lock()
+	defer logger.Unlock()
+	return append([]string{}, logger.lines...)
+}
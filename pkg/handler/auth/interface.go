@@ -3,12 +3,14 @@ package auth
 import (
 	// Packages
 	server "github.com/mutablelogic/go-server"
+	schema "github.com/mutablelogic/go-server/pkg/handler/ldap/schema"
 )
 
 type TokenJar interface {
 	server.Task
 
-	// Return all tokens
+	// Return all tokens. A jar which does not store token values returns
+	// a hash of the value in place of the value.
 	Tokens() []Token
 
 	// Return a token from the jar by value, or an invalid token
@@ -24,9 +26,25 @@ type TokenJar interface {
 	// Put a token into the jar, assuming it does not yet exist.
 	Create(Token) error
 
-	// Update an existing token in the jar, assuming it already exists.
+	// Update an existing token in the jar, assuming it already exists. The
+	// token value is either the value or the value returned by the jar.
 	Update(Token) error
 
-	// Remove a token from the jar, based on key.
+	// Remove a token from the jar, based on key, which is either the value
+	// of the token or the value returned by the jar.
 	Delete(string) error
 }
+
+type LDAP interface {
+	server.Task
+
+	// Return a user by name, or ErrNotFound
+	GetUser(string) (*schema.Object, error)
+
+	// Return the groups which a user is a member of
+	GetUserGroups(*schema.Object) ([]*schema.Object, error)
+
+	// Check a password for a user, returning ErrNotAuthorized if the
+	// password is not valid
+	Bind(*schema.Object, string) error
+}
@@ -0,0 +1,307 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+/////////////////////////////////////////////////////////////////////
+// TYPES
+
+// jwt signs and verifies JSON Web Tokens
+type jwt struct {
+	alg    string
+	secret []byte
+	key    *ecdsa.PrivateKey
+	issuer string
+	expiry time.Duration
+	cookie string
+
+	// Whether the cookie can be sent without TLS
+	insecure bool
+}
+
+// TokenJWT is the response when a token is exchanged for a JWT
+type TokenJWT struct {
+	Value  string    `json:"jwt"`                   // Signed JWT
+	Expire time.Time `json:"expire_time,omitempty"` // Time of expiration for the JWT
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+type jwtClaims struct {
+	Issuer   string   `json:"iss,omitempty"`
+	Subject  string   `json:"sub"`
+	Scope    []string `json:"scopes,omitempty"`
+	IssuedAt int64    `json:"iat"`
+	Expires  int64    `json:"exp"`
+}
+
+/////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	jwtAlgHS256      = "HS256"
+	jwtAlgES256      = "ES256"
+	jwtType          = "JWT"
+	jwtSep           = "."
+	jwtSecretBytes   = 32
+	jwtES256KeyBytes = 32
+	defaultJWTExpiry = time.Hour
+)
+
+/////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a JWT signer and verifier. If the secret (for HS256) or key path
+// (for ES256) is empty, then a random secret or key is generated, which
+// means any issued JWT is invalidated when the process restarts.
+func newJWT(alg, secret, keyPath, issuer, cookie string, expiry time.Duration) (*jwt, error) {
+	j := new(jwt)
+	j.alg = strings.ToUpper(alg)
+	j.issuer = issuer
+	j.cookie = cookie
+
+	// Set signing secret or key
+	switch j.alg {
+	case jwtAlgHS256:
+		if secret != "" {
+			j.secret = []byte(secret)
+		} else {
+			j.secret = make([]byte, jwtSecretBytes)
+			if _, err := rand.Read(j.secret); err != nil {
+				return nil, err
+			}
+		}
+	case jwtAlgES256:
+		if keyPath != "" {
+			if key, err := readECKey(keyPath); err != nil {
+				return nil, err
+			} else {
+				j.key = key
+			}
+		} else if key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return nil, err
+		} else {
+			j.key = key
+		}
+	default:
+		return nil, ErrBadParameter.Withf("unsupported jwt algorithm %q (expected %q or %q)", alg, jwtAlgHS256, jwtAlgES256)
+	}
+
+	// Set expiry
+	if expiry <= 0 {
+		j.expiry = defaultJWTExpiry
+	} else {
+		j.expiry = expiry.Truncate(time.Second)
+	}
+
+	// Return success
+	return j, nil
+}
+
+/////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Sign returns a JWT for a token, with the name and scopes of the token.
+// The JWT expires at the configured expiry or when the token expires,
+// whichever is sooner.
+func (j *jwt) Sign(token Token) (TokenJWT, error) {
+	now := time.Now().Truncate(time.Second)
+	expire := now.Add(j.expiry)
+	if !token.Expire.IsZero() && token.Expire.Before(expire) {
+		expire = token.Expire.Truncate(time.Second)
+	}
+	if !expire.After(now) {
+		return TokenJWT{}, ErrBadParameter.With("token has expired")
+	}
+
+	// Encode the header and claims
+	header, err := jwtEncode(jwtHeader{Alg: j.alg, Typ: jwtType})
+	if err != nil {
+		return TokenJWT{}, err
+	}
+	claims, err := jwtEncode(jwtClaims{
+		Issuer:   j.issuer,
+		Subject:  token.Name,
+		Scope:    token.Scope,
+		IssuedAt: now.Unix(),
+		Expires:  expire.Unix(),
+	})
+	if err != nil {
+		return TokenJWT{}, err
+	}
+
+	// Sign the header and claims
+	payload := header + jwtSep + claims
+	signature, err := j.sign([]byte(payload))
+	if err != nil {
+		return TokenJWT{}, err
+	}
+
+	// Return success
+	return TokenJWT{
+		Value:  payload + jwtSep + base64.RawURLEncoding.EncodeToString(signature),
+		Expire: expire,
+	}, nil
+}
+
+// Verify checks the signature and expiry of a JWT, and returns a token
+// with the name, scopes and expiry from the claims. The token value
+// is not set.
+func (j *jwt) Verify(value string) (Token, error) {
+	parts := strings.Split(value, jwtSep)
+	if len(parts) != 3 {
+		return Token{}, ErrBadParameter.With("malformed jwt")
+	}
+
+	// Check the header, to prevent algorithm substitution
+	var header jwtHeader
+	if err := jwtDecode(parts[0], &header); err != nil {
+		return Token{}, err
+	} else if header.Alg != j.alg {
+		return Token{}, ErrBadParameter.Withf("unexpected jwt algorithm %q", header.Alg)
+	}
+
+	// Check the signature
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Token{}, ErrBadParameter.With("malformed jwt signature")
+	} else if !j.verify([]byte(parts[0]+jwtSep+parts[1]), signature) {
+		return Token{}, ErrNotAuthorized.With("invalid jwt signature")
+	}
+
+	// Decode the claims and check the expiry
+	var claims jwtClaims
+	if err := jwtDecode(parts[1], &claims); err != nil {
+		return Token{}, err
+	} else if claims.Subject == "" {
+		return Token{}, ErrBadParameter.With("missing jwt subject")
+	} else if j.issuer != "" && claims.Issuer != j.issuer {
+		return Token{}, ErrNotAuthorized.Withf("unexpected jwt issuer %q", claims.Issuer)
+	}
+	expire := time.Unix(claims.Expires, 0)
+	if !expire.After(time.Now()) {
+		return Token{}, ErrNotAuthorized.With("jwt has expired")
+	}
+
+	// Return the token
+	return Token{
+		Name:   claims.Subject,
+		Expire: expire,
+		Scope:  claims.Scope,
+	}, nil
+}
+
+/////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return true if the value looks like a JWT rather than a token value
+func isJWT(value string) bool {
+	return strings.Count(value, jwtSep) == 2
+}
+
+func (j *jwt) sign(payload []byte) ([]byte, error) {
+	switch j.alg {
+	case jwtAlgHS256:
+		mac := hmac.New(sha256.New, j.secret)
+		mac.Write(payload)
+		return mac.Sum(nil), nil
+	case jwtAlgES256:
+		hash := sha256.Sum256(payload)
+		r, s, err := ecdsa.Sign(rand.Reader, j.key, hash[:])
+		if err != nil {
+			return nil, err
+		}
+		// Signature is r and s as fixed-length big-endian integers
+		signature := make([]byte, 2*jwtES256KeyBytes)
+		r.FillBytes(signature[:jwtES256KeyBytes])
+		s.FillBytes(signature[jwtES256KeyBytes:])
+		return signature, nil
+	default:
+		return nil, ErrInternalAppError.With(j.alg)
+	}
+}
+
+func (j *jwt) verify(payload, signature []byte) bool {
+	switch j.alg {
+	case jwtAlgHS256:
+		mac := hmac.New(sha256.New, j.secret)
+		mac.Write(payload)
+		return hmac.Equal(signature, mac.Sum(nil))
+	case jwtAlgES256:
+		if len(signature) != 2*jwtES256KeyBytes {
+			return false
+		}
+		hash := sha256.Sum256(payload)
+		r := new(big.Int).SetBytes(signature[:jwtES256KeyBytes])
+		s := new(big.Int).SetBytes(signature[jwtES256KeyBytes:])
+		return ecdsa.Verify(&j.key.PublicKey, hash[:], r, s)
+	default:
+		return false
+	}
+}
+
+func jwtEncode(v any) (string, error) {
+	if data, err := json.Marshal(v); err != nil {
+		return "", err
+	} else {
+		return base64.RawURLEncoding.EncodeToString(data), nil
+	}
+}
+
+func jwtDecode(v string, dest any) error {
+	if data, err := base64.RawURLEncoding.DecodeString(v); err != nil {
+		return ErrBadParameter.With("malformed jwt")
+	} else if err := json.Unmarshal(data, dest); err != nil {
+		return ErrBadParameter.With("malformed jwt")
+	}
+	return nil
+}
+
+// Read a PEM-encoded P-256 private key from a file
+func readECKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrBadParameter.Withf("unable to decode private key: %q", path)
+	}
+
+	// Try PKCS8 and then SEC1 encoding
+	var key *ecdsa.PrivateKey
+	if v, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if v, ok := v.(*ecdsa.PrivateKey); ok {
+			key = v
+		}
+	} else if v, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		key = v
+	}
+	if key == nil {
+		return nil, ErrBadParameter.Withf("not an EC private key: %q", path)
+	} else if key.Curve != elliptic.P256() {
+		return nil, ErrBadParameter.Withf("not a P-256 private key: %q", path)
+	}
+
+	// Return success
+	return key, nil
+}
@@ -0,0 +1,73 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/handler/auth"
+	"github.com/mutablelogic/go-server/pkg/handler/tokenjar"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_jwt_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// Create a token jar and auth object, with a token
+	jar, err := tokenjar.New(tokenjar.Config{
+		DataPath: t.TempDir(),
+	})
+	assert.NoError(err)
+	config := auth.Config{
+		TokenJar: jar,
+		Bearer:   true,
+	}
+	config.JWT.Algorithm = "HS256"
+	config.JWT.Cookie = "jwt"
+	tokens, err := auth.New(config)
+	assert.NoError(err)
+	token := auth.NewToken("test", 16, 0, "test/read")
+	assert.NoError(jar.Create(token))
+
+	// Exchange the token for a JWT
+	var value auth.TokenJWT
+	handler := tokens.Wrap(context.Background(), tokens.CreateJWT)
+	req := httptest.NewRequest(http.MethodPost, "/-/jwt", nil)
+	req.Header.Set("Authorization", "Bearer "+token.Value)
+	resp := httptest.NewRecorder()
+	handler(resp, req)
+	assert.Equal(http.StatusCreated, resp.Code)
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &value))
+	assert.NotEmpty(value.Value)
+	if cookies := resp.Result().Cookies(); assert.Len(cookies, 1) {
+		assert.True(cookies[0].Secure)
+	}
+
+	// Use the JWT in the Authorization header and the cookie
+	handler = tokens.Wrap(context.Background(), func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("test", auth.TokenName(r.Context()))
+		assert.Equal([]string{"test/read"}, auth.TokenScope(r.Context()))
+		w.WriteHeader(http.StatusOK)
+	})
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+value.Value)
+	resp = httptest.NewRecorder()
+	handler(resp, req)
+	assert.Equal(http.StatusOK, resp.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "jwt", Value: value.Value})
+	resp = httptest.NewRecorder()
+	handler(resp, req)
+	assert.Equal(http.StatusOK, resp.Code)
+
+	// A tampered JWT is rejected
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+value.Value+"x")
+	resp = httptest.NewRecorder()
+	handler(resp, req)
+	assert.Equal(http.StatusUnauthorized, resp.Code)
+}
@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	// Packages
+	schema "github.com/mutablelogic/go-server/pkg/handler/ldap/schema"
+	trace "github.com/mutablelogic/go-server/pkg/trace"
+	types "github.com/mutablelogic/go-server/pkg/types"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+/////////////////////////////////////////////////////////////////////
+// TYPES
+
+// ldap authenticates users with a password, and maps the groups which
+// a user is a member of to scopes
+type ldap struct {
+	service LDAP
+	groups  map[string][]string
+	expiry  time.Duration
+	basic   bool
+
+	// Tokens for users verified with HTTP Basic authentication, keyed by a
+	// keyed hash of the user and password, which expire after the cache period
+	sync.Mutex
+	key   []byte
+	ttl   time.Duration
+	cache map[[sha256.Size]byte]ldapCached
+}
+
+// ldapCached is a token for a verified user, and when it is removed from
+// the cache
+type ldapCached struct {
+	Token
+	expires time.Time
+}
+
+// TokenLogin is the request to exchange a user and password for a token
+type TokenLogin struct {
+	User     string `json:"user"`               // Name of the user
+	Password string `json:"password,omitempty"` // Password for the user
+}
+
+/////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	ldapTokenPrefix     = "ldap-"
+	ldapTokenNameMax    = 32 // Maximum length of an identifier
+	ldapTokenHashBytes  = 4
+	defaultLDAPExpiry   = time.Hour
+	defaultLDAPCache    = 30 * time.Second
+	ldapPurgeInterval   = time.Minute
+	ldapBasicRealm      = `Basic realm="` + defaultName + `"`
+	ldapAuthenticateKey = "WWW-Authenticate"
+)
+
+/////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create an LDAP authenticator, with a mapping from group names to scopes
+// and the lifetime of issued tokens. If basic is true, then HTTP Basic
+// authentication is accepted by the middleware, and verified users are
+// cached for the cache period, or not cached if the period is negative
+func newLDAP(service LDAP, groups map[string][]string, expiry time.Duration, basic bool, cache time.Duration) *ldap {
+	l := new(ldap)
+	l.service = service
+	l.groups = groups
+	l.basic = basic
+	if expiry > 0 {
+		l.expiry = expiry
+	} else {
+		l.expiry = defaultLDAPExpiry
+	}
+	switch {
+	case cache < 0:
+		l.ttl = 0
+	case cache == 0:
+		l.ttl = defaultLDAPCache
+	default:
+		l.ttl = cache
+	}
+
+	// The hash of the user and password is keyed, so that the cache does
+	// not hold hashes which can be checked against a password list
+	l.key = make([]byte, sha256.Size)
+	if _, err := rand.Read(l.key); err != nil {
+		l.ttl = 0
+	}
+	l.cache = make(map[[sha256.Size]byte]ldapCached)
+
+	// Return the authenticator
+	return l
+}
+
+/////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Authenticate a user with a password, and return a token without a value,
+// which has the scopes for the groups which the user is a member of. Returns
+// ErrNotAuthorized if the user or password is not valid, or the user has
+// no scopes, which are not told apart so that a caller cannot learn whether
+// a password is correct. Calls to the LDAP service are traced as client spans
+func (l *ldap) Authenticate(ctx context.Context, user, password string) (Token, error) {
+	if !schema.IsName(user) || password == "" {
+		return Token{}, ErrNotAuthorized.With("invalid credentials")
+	}
+
+	// Check the password. A user which does not exist is reported
+	// in the same way as an invalid password
+	object, err := l.getUser(ctx, user)
+	if errors.Is(err, ErrNotFound) {
+		return Token{}, ErrNotAuthorized.With("invalid credentials")
+	} else if err != nil {
+		return Token{}, err
+	} else if err := l.bind(ctx, object, password); errors.Is(err, ErrNotAuthorized) {
+		return Token{}, ErrNotAuthorized.With("invalid credentials")
+	} else if err != nil {
+		return Token{}, err
+	}
+
+	// Determine the scopes from the groups
+	groups, err := l.getUserGroups(ctx, object)
+	if err != nil {
+		return Token{}, err
+	}
+	var scopes []string
+	for _, group := range groups {
+		for _, scope := range l.groups[group.Get("cn")] {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	if len(scopes) == 0 {
+		return Token{}, ErrNotAuthorized.With("invalid credentials")
+	}
+
+	// Return the token
+	return Token{
+		Name:   ldapTokenName(user),
+		Expire: time.Now().Add(l.expiry),
+		Scope:  scopes,
+	}, nil
+}
+
+// Verify a user and password with HTTP Basic authentication, and return a
+// token without a value. A verified user is cached for a short time, so that
+// each request does not query the LDAP service
+func (l *ldap) Basic(ctx context.Context, user, password string) (Token, error) {
+	if l.ttl == 0 {
+		return l.Authenticate(ctx, user, password)
+	}
+
+	// Return the token from the cache
+	key := l.hash(user, password)
+	if token, exists := l.cached(key); exists {
+		return token, nil
+	}
+
+	// Authenticate the user, and cache the token
+	token, err := l.Authenticate(ctx, user, password)
+	if err != nil {
+		return Token{}, err
+	}
+	l.Lock()
+	defer l.Unlock()
+	now := time.Now()
+	for key, cached := range l.cache {
+		if now.After(cached.expires) {
+			delete(l.cache, key)
+		}
+	}
+	l.cache[key] = ldapCached{Token: token, expires: now.Add(l.ttl)}
+
+	// Return the token
+	return token, nil
+}
+
+/////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Operations on the LDAP service, each of which is traced as a client span
+// when tracing is enabled
+
+func (l *ldap) getUser(ctx context.Context, user string) (*schema.Object, error) {
+	return trace.Call(ctx, "ldap GetUser", func() (*schema.Object, error) {
+		return l.service.GetUser(user)
+	})
+}
+
+func (l *ldap) bind(ctx context.Context, user *schema.Object, password string) error {
+	return trace.Do(ctx, "ldap Bind", func() error {
+		return l.service.Bind(user, password)
+	})
+}
+
+func (l *ldap) getUserGroups(ctx context.Context, user *schema.Object) ([]*schema.Object, error) {
+	return trace.Call(ctx, "ldap GetUserGroups", func() ([]*schema.Object, error) {
+		return l.service.GetUserGroups(user)
+	})
+}
+
+// Return a keyed hash of a user and password
+func (l *ldap) hash(user, password string) [sha256.Size]byte {
+	var result [sha256.Size]byte
+	mac := hmac.New(sha256.New, l.key)
+	mac.Write([]byte(user))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	copy(result[:], mac.Sum(nil))
+	return result
+}
+
+// Return a token from the cache, if it has not expired
+func (l *ldap) cached(key [sha256.Size]byte) (Token, bool) {
+	l.Lock()
+	defer l.Unlock()
+	if cached, exists := l.cache[key]; !exists || time.Now().After(cached.expires) {
+		return Token{}, false
+	} else {
+		return cached.Token, true
+	}
+}
+
+// Return the name of the token which is issued to a user on login. The name
+// is an identifier, so that the token can be managed with the token
+// endpoints. When the user name cannot be used as it is, invalid characters
+// are replaced and a hash of the user name is appended, so that the token
+// name remains unique
+func ldapTokenName(user string) string {
+	name := ldapTokenPrefix + user
+	if types.IsIdentifier(name) {
+		return name
+	}
+	sum := sha256.Sum256([]byte(user))
+	suffix := "-" + hex.EncodeToString(sum[:ldapTokenHashBytes])
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+	if max := ldapTokenNameMax - len(suffix); len(name) > max {
+		name = name[:max]
+	}
+	return name + suffix
+}
+
+// Return true if a token name is reserved for tokens issued on login
+func isLDAPToken(name string) bool {
+	return strings.HasPrefix(name, ldapTokenPrefix)
+}
@@ -0,0 +1,271 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/handler/auth"
+	"github.com/mutablelogic/go-server/pkg/handler/ldap/schema"
+	"github.com/mutablelogic/go-server/pkg/handler/router"
+	"github.com/mutablelogic/go-server/pkg/handler/tokenjar"
+	"github.com/mutablelogic/go-server/pkg/trace"
+	"github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+func Test_ldap_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// Create a token jar and auth object, with users in groups
+	jar, err := tokenjar.New(tokenjar.Config{
+		DataPath: t.TempDir(),
+	})
+	assert.NoError(err)
+	config := auth.Config{
+		TokenJar: jar,
+		Bearer:   true,
+	}
+	config.LDAP.Service = newTestLDAP(map[string]string{
+		"alice":    "alice-password",
+		"bob":      "bob-password",
+		"dave":     "dave-password",
+		"john.doe": "john-password",
+	}, map[string][]string{
+		"admins": {"alice"},
+		"users":  {"alice", "bob", "john.doe"},
+	})
+	config.LDAP.Groups = map[string][]string{
+		"admins": {auth.ScopeRoot},
+		"users":  {"test/read"},
+	}
+	config.LDAP.Basic = true
+	config.JWT.Algorithm = "HS256"
+	config.JWT.Cookie = "jwt"
+	config.JWT.Expiry = 3 * time.Hour
+	tokens, err := auth.New(config)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Add the endpoints to a router, with the auth middleware
+	r, err := router.Config{}.New()
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	r.(router.Router).AddServiceEndpoints("auth", tokens, tokens)
+	handler := r.(http.Handler)
+
+	t.Run("Login", func(t *testing.T) {
+		// Login does not require a token
+		var token auth.Token
+		req := httptest.NewRequest(http.MethodPost, "/auth/-/login", strings.NewReader(`{"user":"alice","password":"alice-password"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		if !assert.Equal(http.StatusCreated, resp.Code) {
+			t.SkipNow()
+		}
+		assert.NoError(json.Unmarshal(resp.Body.Bytes(), &token))
+		assert.NotEmpty(token.Value)
+		assert.False(token.Expire.IsZero())
+		assert.ElementsMatch([]string{"test/read", auth.ScopeRoot}, token.Scope)
+
+		// The token is in the jar, and can be used for other endpoints
+		assert.Equal(token.Name, jar.GetWithValue(token.Value).Name)
+		req = httptest.NewRequest(http.MethodGet, "/auth/", nil)
+		req.Header.Set("Authorization", "Bearer "+token.Value)
+		resp = httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		assert.Equal(http.StatusOK, resp.Code)
+		req = httptest.NewRequest(http.MethodGet, "/auth/", nil)
+		resp = httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		assert.Equal(http.StatusUnauthorized, resp.Code)
+	})
+
+	t.Run("LoginDenied", func(t *testing.T) {
+		// A user without scopes is denied in the same way as an invalid
+		// password, so that a caller cannot tell the password was correct
+		var reasons []string
+		for _, body := range []string{
+			`{"user":"alice","password":"bob-password"}`,
+			`{"user":"alice","password":""}`,
+			`{"user":"carol","password":"carol-password"}`,
+			`{"user":"dave","password":"dave-password"}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/auth/-/login", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			assert.Equal(http.StatusUnauthorized, resp.Code, body)
+			reasons = append(reasons, resp.Body.String())
+		}
+		for _, reason := range reasons {
+			assert.Equal(reasons[0], reason)
+		}
+	})
+
+	t.Run("LoginAgain", func(t *testing.T) {
+		login := func(user, password string) auth.Token {
+			var token auth.Token
+			req := httptest.NewRequest(http.MethodPost, "/auth/-/login", strings.NewReader(`{"user":"`+user+`","password":"`+password+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			assert.Equal(http.StatusCreated, resp.Code)
+			assert.NoError(json.Unmarshal(resp.Body.Bytes(), &token))
+			return token
+		}
+
+		// A second login replaces the token from the first login
+		first, second := login("alice", "alice-password"), login("alice", "alice-password")
+		assert.Equal("ldap-alice", second.Name)
+		assert.True(jar.GetWithValue(first.Value).IsZero())
+		assert.False(jar.GetWithValue(second.Value).IsZero())
+		var count int
+		for _, token := range jar.Tokens() {
+			if token.Name == "ldap-alice" {
+				count++
+			}
+		}
+		assert.Equal(1, count)
+
+		// The token can be read through the token endpoints
+		req := httptest.NewRequest(http.MethodGet, "/auth/"+second.Name, nil)
+		req.Header.Set("Authorization", "Bearer "+second.Value)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		assert.Equal(http.StatusOK, resp.Code)
+
+		// A user name which is not an identifier has a token name which is
+		// an identifier
+		a := login("john.doe", "john-password")
+		assert.Regexp(`^ldap-john_doe-[0-9a-f]{8}$`, a.Name)
+	})
+
+	t.Run("Trace", func(t *testing.T) {
+		// Calls to the LDAP service are traced
+		var spans testExporter
+		req := httptest.NewRequest(http.MethodPost, "/auth/-/login", strings.NewReader(`{"user":"bob","password":"bob-password"}`))
+		req = req.WithContext(trace.WithExporter(req.Context(), &spans))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		assert.Equal(http.StatusCreated, resp.Code)
+		var names []string
+		for _, span := range spans {
+			if strings.HasPrefix(span.Name, "ldap ") {
+				names = append(names, span.Name)
+			}
+		}
+		assert.Equal([]string{"ldap GetUser", "ldap Bind", "ldap GetUserGroups"}, names)
+	})
+
+	t.Run("Basic", func(t *testing.T) {
+		handler := tokens.Wrap(context.Background(), func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal("ldap-bob", auth.TokenName(r.Context()))
+			assert.Equal([]string{"test/read"}, auth.TokenScope(r.Context()))
+			w.WriteHeader(http.StatusOK)
+		})
+
+		// Authenticate with HTTP Basic authentication
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth("bob", "bob-password")
+		resp := httptest.NewRecorder()
+		handler(resp, req)
+		assert.Equal(http.StatusOK, resp.Code)
+
+		// A JWT cookie is not issued, even though bob has a login token
+		// which is more than half-way through the lifetime of a JWT
+		assert.Empty(resp.Result().Cookies())
+
+		// The verified user is cached
+		binds := config.LDAP.Service.(*testLDAP).binds.Load()
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth("bob", "bob-password")
+		resp = httptest.NewRecorder()
+		handler(resp, req)
+		assert.Equal(http.StatusOK, resp.Code)
+		assert.Equal(binds, config.LDAP.Service.(*testLDAP).binds.Load())
+
+		// Invalid password
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth("bob", "alice-password")
+		resp = httptest.NewRecorder()
+		handler(resp, req)
+		assert.Equal(http.StatusUnauthorized, resp.Code)
+		assert.NotEmpty(resp.Header().Get("WWW-Authenticate"))
+	})
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// LDAP
+
+// testLDAP is an in-process LDAP service, with users, passwords and groups
+type testLDAP struct {
+	passwords map[string]string
+	groups    map[string][]string
+	binds     atomic.Int32
+}
+
+func newTestLDAP(passwords map[string]string, groups map[string][]string) *testLDAP {
+	return &testLDAP{passwords: passwords, groups: groups}
+}
+
+func (ldap *testLDAP) Label() string {
+	return "ldap"
+}
+
+func (ldap *testLDAP) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (ldap *testLDAP) GetUser(name string) (*schema.Object, error) {
+	if _, exists := ldap.passwords[name]; !exists {
+		return nil, ErrNotFound.With(name)
+	}
+	user := schema.NewObject("uid=" + name)
+	user.Set("uid", name)
+	return user, nil
+}
+
+func (ldap *testLDAP) GetUserGroups(user *schema.Object) ([]*schema.Object, error) {
+	var result []*schema.Object
+	for name, members := range ldap.groups {
+		for _, member := range members {
+			if member == user.Get("uid") {
+				group := schema.NewObject("cn=" + name)
+				group.Set("cn", name)
+				result = append(result, group)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (ldap *testLDAP) Bind(user *schema.Object, password string) error {
+	ldap.binds.Add(1)
+	if password == "" || ldap.passwords[user.Get("uid")] != password {
+		return ErrNotAuthorized.With("Invalid credentials")
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// EXPORTER
+
+type testExporter []trace.Span
+
+func (e *testExporter) Export(span trace.Span) {
+	*e = append(*e, span)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	// Packages
 	"github.com/mutablelogic/go-server"
@@ -29,24 +30,62 @@ const (
 // PUBLIC METHODS
 
 func (middleware *auth) Wrap(ctx context.Context, next http.HandlerFunc) http.HandlerFunc {
+	// Endpoints which do not require a token
+	if isPublic(ctx) {
+		return next
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		var tokenValue string
+		var fromCookie bool
 
 		// If bearer is true, get the token from the Authorization: Bearer header
 		if middleware.bearer {
-			tokenValue = strings.ToLower(strings.TrimSpace(getBearer(r)))
+			tokenValue = strings.TrimSpace(getBearer(r))
+		}
+
+		// Otherwise get a JWT from the cookie
+		if tokenValue == "" && middleware.jwt != nil {
+			tokenValue = getCookie(r, middleware.jwt.cookie)
+			fromCookie = tokenValue != ""
+		}
+
+		// Otherwise authenticate with an LDAP user and password
+		var token Token
+		if tokenValue == "" && middleware.ldap != nil && middleware.ldap.basic {
+			if user, password, ok := r.BasicAuth(); !ok {
+				w.Header().Set(ldapAuthenticateKey, ldapBasicRealm)
+			} else if token_, err := middleware.ldap.Basic(r.Context(), user, password); err != nil {
+				w.Header().Set(ldapAuthenticateKey, ldapBasicRealm)
+				httpresponse.Error(w, http.StatusUnauthorized, err.Error())
+				return
+			} else {
+				token = token_
+			}
 		}
 
 		// Get token from request
-		if tokenValue == "" {
+		if tokenValue == "" && token.IsZero() {
 			httpresponse.Error(w, http.StatusUnauthorized)
 			return
 		}
 
-		// TODO: Hook for getting JWT from request here
-
-		// Get token from the jar - check it is found and valid
-		token := middleware.jar.GetWithValue(tokenValue)
+		// Get token from LDAP, a JWT, or from the jar - check it is found and valid
+		if !token.IsZero() {
+			// Token from LDAP
+		} else if middleware.jwt != nil && isJWT(tokenValue) {
+			if token_, err := middleware.jwt.Verify(tokenValue); err != nil {
+				httpresponse.Error(w, http.StatusUnauthorized, err.Error())
+				return
+			} else {
+				token = token_
+			}
+		} else if fromCookie {
+			httpresponse.Error(w, http.StatusUnauthorized, "invalid token")
+			return
+		} else {
+			token = middleware.jar.GetWithValue(strings.ToLower(tokenValue))
+		}
 		if token.IsZero() {
 			httpresponse.Error(w, http.StatusUnauthorized, "invalid or missing token")
 			return
@@ -63,7 +102,10 @@ func (middleware *auth) Wrap(ctx context.Context, next http.HandlerFunc) http.Ha
 			return
 		}
 
-		// TODO: Hook for setting JWT cookie here
+		// Refresh the JWT cookie when it is more than half-way through its lifetime
+		if fromCookie && time.Until(token.Expire) < middleware.jwt.expiry/2 {
+			middleware.refreshCookie(w, token)
+		}
 
 		// Create a new context with the token name and scopes
 		r = r.WithContext(WithToken(r.Context(), token))
@@ -89,3 +131,43 @@ func getBearer(r *http.Request) string {
 		return parts[1]
 	}
 }
+
+// Get a cookie value from request, or return empty string
+func getCookie(r *http.Request, name string) string {
+	if name == "" {
+		return ""
+	} else if cookie, err := r.Cookie(name); err != nil {
+		return ""
+	} else {
+		return cookie.Value
+	}
+}
+
+// Set a JWT cookie on the response, or remove the cookie if the
+// JWT value is empty. The cookie is only sent over TLS, which may end
+// at a reverse proxy, unless it is configured to be insecure
+func (j *jwt) setCookie(w http.ResponseWriter, value TokenJWT) {
+	cookie := &http.Cookie{
+		Name:     j.cookie,
+		Value:    value.Value,
+		Path:     "/",
+		Expires:  value.Expire,
+		Secure:   !j.insecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}
+	if value.Value == "" {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+// Issue a new JWT cookie for a token, if the token still exists in the
+// jar and is valid. Otherwise, the cookie is left to expire.
+func (middleware *auth) refreshCookie(w http.ResponseWriter, token Token) {
+	if token = middleware.jar.GetWithName(token.Name); token.IsZero() || !token.IsValid() {
+		return
+	} else if value, err := middleware.jwt.Sign(token); err == nil {
+		middleware.jwt.setCookie(w, value)
+	}
+}
@@ -1,8 +1,13 @@
 package auth
 
 import (
+	"slices"
+
 	// Packages
 	"github.com/mutablelogic/go-server/pkg/version"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -34,3 +39,24 @@ func (auth *auth) ScopeWrite() []string {
 		scopePrefix + defaultName + "/write",
 	}
 }
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Check that a requestor with scopes can grant scopes to a token. A requestor
+// with the root scope can grant any scope. Otherwise the root scope cannot be
+// granted, and other scopes can only be granted if the requestor holds them.
+// Returns ErrNotAuthorized if any scope cannot be granted
+func canGrant(requestor []string, scopes ...string) error {
+	if slices.Contains(requestor, ScopeRoot) {
+		return nil
+	}
+	for _, scope := range scopes {
+		if scope == ScopeRoot {
+			return ErrNotAuthorized.With("cannot grant the root scope")
+		} else if !slices.Contains(requestor, scope) {
+			return ErrNotAuthorized.Withf("cannot grant scope %q", scope)
+		}
+	}
+	return nil
+}
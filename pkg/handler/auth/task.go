@@ -2,6 +2,8 @@ package auth
 
 import (
 	"context"
+	"sync/atomic"
+	"time"
 
 	// Packages
 	server "github.com/mutablelogic/go-server"
@@ -18,6 +20,11 @@ type auth struct {
 	jar        TokenJar
 	tokenBytes int
 	bearer     bool
+	jwt        *jwt
+	ldap       *ldap
+
+	// The logger for the task, which records changes to tokens
+	logger atomic.Value
 }
 
 // Check interfaces are satisfied
@@ -47,6 +54,21 @@ func New(c Config) (*auth, error) {
 	// Set bearer
 	task.bearer = c.Bearer
 
+	// Set JSON Web Token signing
+	if c.JWT.Algorithm != "" {
+		if jwt, err := newJWT(c.JWT.Algorithm, c.JWT.Secret, c.JWT.Key, defaultName, c.JWT.Cookie, c.JWT.Expiry); err != nil {
+			return nil, err
+		} else {
+			jwt.insecure = c.JWT.Insecure
+			task.jwt = jwt
+		}
+	}
+
+	// Set LDAP authentication
+	if c.LDAP.Service != nil {
+		task.ldap = newLDAP(c.LDAP.Service, c.LDAP.Groups, c.LDAP.Expiry, c.LDAP.Basic, c.LDAP.Cache)
+	}
+
 	// Return success
 	return task, nil
 }
@@ -64,8 +86,11 @@ func (task *auth) Label() string {
 func (task *auth) Run(ctx context.Context) error {
 	var result error
 
-	// Logger
+	// Logger, which is also used to record changes to tokens
 	logger := provider.Logger(ctx)
+	if logger != nil {
+		task.logger.Store(logger)
+	}
 
 	// If there are no tokens, then create a "root" token
 	if tokens := task.jar.Tokens(); len(tokens) == 0 {
@@ -76,9 +101,37 @@ func (task *auth) Run(ctx context.Context) error {
 		}
 	}
 
-	// Run the task until cancelled
-	<-ctx.Done()
+	// Run the task until cancelled, removing expired tokens which were
+	// issued on login
+	ticker := time.NewTicker(ldapPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return result
+		case <-ticker.C:
+			if task.ldap != nil {
+				task.purge()
+			}
+		}
+	}
+}
+
+/////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Log a message, when the task is running with a logger
+func (task *auth) logf(ctx context.Context, format string, args ...any) {
+	if logger, ok := task.logger.Load().(server.Logger); ok {
+		logger.Printf(ctx, format, args...)
+	}
+}
 
-	// Return any errors
-	return result
+// Remove expired tokens which were issued on login
+func (task *auth) purge() {
+	for _, token := range task.jar.Tokens() {
+		if isLDAPToken(token.Name) && !token.IsValid() {
+			task.jar.Delete(token.Value)
+		}
+	}
 }
@@ -3,6 +3,7 @@ package auth
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -21,6 +22,11 @@ type Token struct {
 	Time   time.Time `json:"access_time,omitempty" writer:",width:29"`  // Time of last access
 	Scope  []string  `json:"scopes,omitempty" writer:",wrap"`           // Authentication scopes
 
+	// Time of the last change to the token, and the name of the token
+	// which made the change
+	Modified time.Time `json:"modify_time,omitempty" writer:",width:29"`
+	Modifier string    `json:"modifier,omitempty"`
+
 	// Private field which when set true does not write the 'valid' field
 	write bool `json:"-"`
 }
@@ -31,6 +37,12 @@ type TokenCreate struct {
 	Scope    []string `json:"scopes,omitempty"`   // Authentication scopes
 }
 
+type TokenPatch struct {
+	Name     string    `json:"name,omitempty"`     // New name of the token
+	Duration *duration `json:"duration,omitempty"` // Duration of the token from now, or zero for no expiration
+	Scope    *[]string `json:"scopes,omitempty"`   // Authentication scopes, which replace the existing scopes, or empty to remove them
+}
+
 type duration struct {
 	time.Duration
 }
@@ -74,6 +86,23 @@ func NewCreateToken(name string, expires_in time.Duration, scope ...string) Toke
 	}
 }
 
+// Create a new patch token request, which changes the name, duration or
+// scopes of a token. The name is changed if not empty, the duration if not
+// nil and the scopes if not nil, where an empty slice removes the scopes
+func NewPatchToken(name string, expires_in *time.Duration, scope *[]string) TokenPatch {
+	patch := TokenPatch{
+		Name: name,
+	}
+	if expires_in != nil {
+		patch.Duration = &duration{max(*expires_in, 0)}
+	}
+	if scope != nil {
+		patch.Scope = &[]string{}
+		*patch.Scope = append(*patch.Scope, *scope...)
+	}
+	return patch
+}
+
 /////////////////////////////////////////////////////////////////////
 // STRINGIFY
 
@@ -85,6 +114,13 @@ func (t Token) String() string {
 /////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
+// Return a hash of a token value, which a token jar can store in place of
+// the value, so that stored tokens cannot be used for authentication
+func HashValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
 // Compares token name, value, expiry and scopes
 func (t Token) Equals(other Token) bool {
 	if t.Name != other.Name || t.Value != other.Value || t.Expire != other.Expire {
@@ -144,43 +180,55 @@ func (t Token) IsScope(scopes ...string) bool {
 func (t Token) MarshalJSON() ([]byte, error) {
 	var buf bytes.Buffer
 
+	// Write a field name, preceded by a separator if it's not the first field
+	field := func(name string) {
+		if buf.Len() > 1 {
+			buf.WriteRune(',')
+		}
+		buf.WriteString(strconv.Quote(name))
+		buf.WriteRune(':')
+	}
+
 	buf.WriteRune('{')
 
 	// Write the fields
 	if t.Name != "" {
-		buf.WriteString(`"name":`)
+		field("name")
 		buf.WriteString(strconv.Quote(t.Name))
-		buf.WriteRune(',')
 	}
 	if t.Value != "" {
-		buf.WriteString(`"token":`)
+		field("token")
 		buf.WriteString(strconv.Quote(t.Value))
-		buf.WriteRune(',')
 	}
 	if !t.Expire.IsZero() {
-		buf.WriteString(`"expire_time":`)
+		field("expire_time")
 		buf.WriteString(strconv.Quote(t.Expire.Format(time.RFC3339)))
-		buf.WriteRune(',')
 	}
 	if !t.Time.IsZero() {
-		buf.WriteString(`"access_time":`)
+		field("access_time")
 		buf.WriteString(strconv.Quote(t.Time.Format(time.RFC3339)))
-		buf.WriteRune(',')
 	}
 	if len(t.Scope) > 0 {
-		buf.WriteString(`"scopes":`)
+		field("scopes")
 		if data, err := json.Marshal(t.Scope); err != nil {
 			return nil, err
 		} else {
 			buf.Write(data)
 		}
 	}
+	if !t.Modified.IsZero() {
+		field("modify_time")
+		buf.WriteString(strconv.Quote(t.Modified.Format(time.RFC3339)))
+	}
+	if t.Modifier != "" {
+		field("modifier")
+		buf.WriteString(strconv.Quote(t.Modifier))
+	}
 
 	// Include the valid flag when write is false (means that
 	// the JSON is not for persistent storage)
 	if !t.write {
-		buf.WriteRune(',')
-		buf.WriteString(`"valid":`)
+		field("valid")
 		buf.WriteString(strconv.FormatBool(t.IsValid()))
 	}
 
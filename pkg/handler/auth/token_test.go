@@ -60,3 +60,41 @@ func Test_token_005(t *testing.T) {
 	assert.NotNil(bytes)
 	t.Log(string(bytes))
 }
+
+func Test_token_006(t *testing.T) {
+	assert := assert.New(t)
+
+	// Marshal a token without scopes, and a token with a modifier
+	for _, token := range []auth.Token{
+		auth.NewToken("test", 100, 0),
+		{Name: "test", Modified: time.Now(), Modifier: "admin"},
+	} {
+		var other auth.Token
+		data, err := json.Marshal(token)
+		assert.NoError(err)
+		assert.NoError(json.Unmarshal(data, &other), string(data))
+		assert.Equal(token.Name, other.Name)
+		assert.Equal(token.Modifier, other.Modifier)
+	}
+}
+
+func Test_token_007(t *testing.T) {
+	assert := assert.New(t)
+
+	// A patch without scopes leaves them unchanged, and a patch with no
+	// scopes removes them
+	var none []string
+	for _, test := range []struct {
+		scope    *[]string
+		expected string
+	}{
+		{nil, `{}`},
+		{&none, `{"scopes":[]}`},
+		{&[]string{}, `{"scopes":[]}`},
+		{&[]string{ScopeRead}, `{"scopes":["read"]}`},
+	} {
+		data, err := json.Marshal(auth.NewPatchToken("", nil, test.scope))
+		assert.NoError(err)
+		assert.Equal(test.expected, string(data))
+	}
+}
@@ -0,0 +1,282 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"sync"
+	"time"
+
+	// Packages
+	certmanager "github.com/mutablelogic/go-server/pkg/handler/certmanager"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+/////////////////////////////////////////////////////////////////////
+// TYPES
+
+// acme is an ACME server (RFC 8555), which issues certificates signed
+// by a certificate authority. Accounts, orders and authorizations are
+// kept in memory, and issued certificates are stored by the certificate
+// manager.
+type acme struct {
+	sync.Mutex
+	certmanager CertManager
+	ca          string
+	url         string
+	expiry      time.Duration
+	resolver    *resolver
+
+	// State
+	nonces   map[string]time.Time
+	accounts map[string]*account
+	orders   map[string]*order
+	authz    map[string]*authorization
+	certs    map[string]string // serial -> account
+}
+
+type account struct {
+	Status  string   `json:"status"`
+	Contact []string `json:"contact,omitempty"`
+	Orders  string   `json:"orders"`
+
+	id, url    string
+	key        crypto.PublicKey
+	thumbprint string
+}
+
+type identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type order struct {
+	Status         string       `json:"status"`
+	Expires        time.Time    `json:"expires"`
+	Identifiers    []identifier `json:"identifiers"`
+	NotBefore      *time.Time   `json:"notBefore,omitempty"`
+	NotAfter       *time.Time   `json:"notAfter,omitempty"`
+	Error          *problem     `json:"error,omitempty"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate,omitempty"`
+
+	id, url, account string
+	authz            []*authorization
+}
+
+type authorization struct {
+	Identifier identifier   `json:"identifier"`
+	Status     string       `json:"status"`
+	Expires    time.Time    `json:"expires"`
+	Challenges []*challenge `json:"challenges"`
+
+	id, url, account string
+}
+
+type challenge struct {
+	Type      string     `json:"type"`
+	URL       string     `json:"url"`
+	Token     string     `json:"token"`
+	Status    string     `json:"status"`
+	Validated *time.Time `json:"validated,omitempty"`
+	Error     *problem   `json:"error,omitempty"`
+
+	id    string
+	authz *authorization
+}
+
+/////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	statusPending     = "pending"
+	statusProcessing  = "processing"
+	statusReady       = "ready"
+	statusValid       = "valid"
+	statusInvalid     = "invalid"
+	statusDeactivated = "deactivated"
+	statusExpired     = "expired"
+)
+
+const (
+	identifierDNS = "dns"
+	challengeHTTP = "http-01"
+	idBytes       = 16
+	tokenBytes    = 32
+)
+
+/////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a new ACME server from the configuration
+func New(c Config) (*acme, error) {
+	task := new(acme)
+	task.ca = c.CA
+	task.url = strings.TrimSuffix(c.URL, "/")
+	task.expiry = c.expiry()
+	task.resolver = newResolver(c.Hosts, c.Resolver, c.port())
+	task.nonces = make(map[string]time.Time)
+	task.accounts = make(map[string]*account)
+	task.orders = make(map[string]*order)
+	task.authz = make(map[string]*authorization)
+	task.certs = make(map[string]string)
+
+	// Set the certificate manager and check the CA
+	if c.CertManager == nil {
+		return nil, ErrBadParameter.With("missing 'certmanager'")
+	} else {
+		task.certmanager = c.CertManager
+	}
+	if c.CA == "" {
+		return nil, ErrBadParameter.With("missing 'ca'")
+	}
+
+	// Return success
+	return task, nil
+}
+
+/////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return the certificate authority which signs certificates
+func (task *acme) CA() (certmanager.Cert, error) {
+	if ca, err := task.certmanager.Read(task.ca); err != nil {
+		return nil, err
+	} else if !ca.IsCA() {
+		return nil, ErrBadParameter.Withf("not a CA: %q", task.ca)
+	} else {
+		return ca, nil
+	}
+}
+
+/////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return a new nonce, which is valid until it is used or expires
+func (task *acme) newNonce() string {
+	nonce := randomString(idBytes)
+	task.Lock()
+	defer task.Unlock()
+	task.nonces[nonce] = time.Now().Add(defaultNonceExpiry)
+	return nonce
+}
+
+// Use a nonce, returning false if the nonce is not valid
+func (task *acme) useNonce(nonce string) bool {
+	task.Lock()
+	defer task.Unlock()
+	expires, exists := task.nonces[nonce]
+	delete(task.nonces, nonce)
+	return exists && time.Now().Before(expires)
+}
+
+// Return an account for a key thumbprint, or nil. The lock should be
+// held by the caller
+func (task *acme) accountForThumbprint(thumbprint string) *account {
+	for _, account := range task.accounts {
+		if account.thumbprint == thumbprint {
+			return account
+		}
+	}
+	return nil
+}
+
+// Return a valid authorization for an account and identifier which can
+// be re-used for a new order, or nil. The lock should be held by the caller
+func (task *acme) authzForIdentifier(account string, id identifier, expires time.Time) *authorization {
+	for _, authz := range task.authz {
+		if authz.account == account && authz.Identifier == id && authz.Status == statusValid && authz.Expires.After(expires) {
+			return authz
+		}
+	}
+	return nil
+}
+
+// Update the status of an authorization from the status of the challenges
+// and the expiry time. The lock should be held by the caller
+func (authz *authorization) update(now time.Time) {
+	switch authz.Status {
+	case statusPending:
+		for _, challenge := range authz.Challenges {
+			switch challenge.Status {
+			case statusValid:
+				authz.Status = statusValid
+			case statusInvalid:
+				authz.Status = statusInvalid
+			}
+		}
+		if authz.Status == statusPending && now.After(authz.Expires) {
+			authz.Status = statusExpired
+		}
+	case statusValid:
+		if now.After(authz.Expires) {
+			authz.Status = statusExpired
+		}
+	}
+}
+
+// Update the status of an order from the status of the authorizations
+// and the expiry time. The lock should be held by the caller
+func (order *order) update(now time.Time) {
+	if order.Status != statusPending {
+		return
+	}
+	ready := true
+	for _, authz := range order.authz {
+		authz.update(now)
+		switch authz.Status {
+		case statusValid:
+			continue
+		case statusPending:
+			ready = false
+		default:
+			order.Status = statusInvalid
+			order.Error = newProblem(problemUnauthorized, "authorization ", authz.Status, " for ", authz.Identifier.Value)
+			return
+		}
+	}
+	if ready {
+		order.Status = statusReady
+	} else if now.After(order.Expires) {
+		order.Status = statusInvalid
+		order.Error = newProblem(problemUnauthorized, "order has expired")
+	}
+}
+
+// Remove expired nonces, and orders and authorizations which expired
+// more than the expiry period ago
+func (task *acme) purge() {
+	task.Lock()
+	defer task.Unlock()
+
+	now := time.Now()
+	for nonce, expires := range task.nonces {
+		if now.After(expires) {
+			delete(task.nonces, nonce)
+		}
+	}
+	for id, order := range task.orders {
+		if now.After(order.Expires.Add(task.expiry)) {
+			delete(task.orders, id)
+		}
+	}
+	for id, authz := range task.authz {
+		if now.After(authz.Expires.Add(task.expiry)) {
+			delete(task.authz, id)
+		}
+	}
+}
+
+// Return a random string with n bytes of entropy, which is safe to use
+// in a URL
+func randomString(n int) string {
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,241 @@
+package acme_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	certmanager "github.com/mutablelogic/go-server/pkg/handler/certmanager"
+	acme "github.com/mutablelogic/go-server/pkg/handler/certmanager/acme"
+	certstore "github.com/mutablelogic/go-server/pkg/handler/certmanager/certstore"
+	router "github.com/mutablelogic/go-server/pkg/handler/router"
+	assert "github.com/stretchr/testify/assert"
+	client "golang.org/x/crypto/acme"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+const (
+	testHost = "test.internal"
+)
+
+// Create a certificate manager with a CA, an ACME server and a server
+// which responds to HTTP-01 challenges
+func newTestServer(t *testing.T) (*client.Client, certmanager.Cert, acme.CertManager, *sync.Map) {
+	t.Helper()
+
+	// Create the certificate manager and CA
+	store, err := certstore.New(certstore.Config{DataPath: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager, err := certmanager.New(certmanager.Config{CertStorage: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := manager.CreateCA(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Respond to challenges with key authorizations
+	var challenges sync.Map
+	challenge := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if keyAuth, exists := challenges.Load(strings.TrimPrefix(r.URL.Path, "/.well-known/acme-challenge/")); exists {
+			w.Write([]byte(keyAuth.(string)))
+		} else {
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(challenge.Close)
+
+	// Create the ACME server, which resolves the test host to the
+	// challenge server
+	task, err := acme.New(acme.Config{
+		CertManager: manager,
+		CA:          ca.Serial(),
+		Hosts: map[string]string{
+			testHost: challenge.Listener.Addr().String(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := router.Config{}.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.(router.Router).AddServiceEndpoints("acme", task)
+	server := httptest.NewServer(r.(http.Handler))
+	t.Cleanup(server.Close)
+
+	// Create the client
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &client.Client{
+		Key:          key,
+		DirectoryURL: server.URL + "/acme/directory",
+	}, ca, manager, &challenges
+}
+
+// Create a certificate signing request for hosts
+func newRequest(t *testing.T, hosts ...string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: hosts[0]},
+		DNSNames: hosts,
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return csr
+}
+
+func Test_acme_001(t *testing.T) {
+	assert := assert.New(t)
+	task, err := acme.New(acme.Config{})
+	assert.ErrorIs(err, ErrBadParameter)
+	assert.Nil(task)
+	assert.Implements((*server.Plugin)(nil), acme.Config{})
+}
+
+func Test_acme_002(t *testing.T) {
+	assert := assert.New(t)
+	c, ca, manager, challenges := newTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Register an account
+	account, err := c.Register(ctx, &client.Account{Contact: []string{"mailto:test@" + testHost}}, client.AcceptTOS)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.Equal(client.StatusValid, account.Status)
+	_, err = c.Register(ctx, &client.Account{}, client.AcceptTOS)
+	assert.ErrorIs(err, client.ErrAccountAlreadyExists)
+
+	// Create an order
+	order, err := c.AuthorizeOrder(ctx, client.DomainIDs(testHost))
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.Equal(client.StatusPending, order.Status)
+	assert.Len(order.AuthzURLs, 1)
+
+	// Respond to the challenges
+	for _, url := range order.AuthzURLs {
+		authz, err := c.GetAuthorization(ctx, url)
+		if !assert.NoError(err) {
+			t.SkipNow()
+		}
+		assert.Equal(testHost, authz.Identifier.Value)
+		for _, challenge := range authz.Challenges {
+			if challenge.Type != "http-01" {
+				continue
+			}
+			keyAuth, err := c.HTTP01ChallengeResponse(challenge.Token)
+			if !assert.NoError(err) {
+				t.SkipNow()
+			}
+			challenges.Store(challenge.Token, keyAuth)
+			_, err = c.Accept(ctx, challenge)
+			assert.NoError(err)
+		}
+		_, err = c.WaitAuthorization(ctx, url)
+		assert.NoError(err)
+	}
+
+	// Wait for the order to be ready, and finalize it
+	order, err = c.WaitOrder(ctx, order.URI)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.Equal(client.StatusReady, order.Status)
+	der, _, err := c.CreateOrderCert(ctx, order.FinalizeURL, newRequest(t, testHost), true)
+	if !assert.NoError(err) || !assert.Len(der, 2) {
+		t.SkipNow()
+	}
+
+	// Check the certificate is signed by the CA
+	leaf, err := x509.ParseCertificate(der[0])
+	assert.NoError(err)
+	issuer, err := x509.ParseCertificate(der[1])
+	assert.NoError(err)
+	assert.Equal(ca.Serial(), issuer.SerialNumber.String())
+	assert.Equal([]string{testHost}, leaf.DNSNames)
+	roots := x509.NewCertPool()
+	roots.AddCert(issuer)
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: testHost, Roots: roots})
+	assert.NoError(err)
+
+	// The certificate is stored, and can be revoked
+	_, err = manager.Read(leaf.SerialNumber.String())
+	assert.NoError(err)
+	assert.NoError(c.RevokeCert(ctx, nil, der[0], client.CRLReasonKeyCompromise))
+	_, err = manager.Read(leaf.SerialNumber.String())
+	assert.ErrorIs(err, ErrNotFound)
+}
+
+func Test_acme_003(t *testing.T) {
+	assert := assert.New(t)
+	c, _, _, challenges := newTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Register an account
+	if _, err := c.Register(ctx, &client.Account{}, client.AcceptTOS); !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	t.Run("UnsupportedIdentifier", func(t *testing.T) {
+		_, err := c.AuthorizeOrder(ctx, client.IPIDs("127.0.0.1"))
+		assert.ErrorContains(err, "unsupportedIdentifier")
+		_, err = c.AuthorizeOrder(ctx, client.DomainIDs("*."+testHost))
+		assert.ErrorContains(err, "rejectedIdentifier")
+	})
+
+	t.Run("OrderNotReady", func(t *testing.T) {
+		order, err := c.AuthorizeOrder(ctx, client.DomainIDs(testHost))
+		if !assert.NoError(err) {
+			t.SkipNow()
+		}
+		_, _, err = c.CreateOrderCert(ctx, order.FinalizeURL, newRequest(t, testHost), false)
+		assert.ErrorContains(err, "orderNotReady")
+	})
+
+	t.Run("IncorrectResponse", func(t *testing.T) {
+		order, err := c.AuthorizeOrder(ctx, client.DomainIDs(testHost))
+		if !assert.NoError(err) {
+			t.SkipNow()
+		}
+		authz, err := c.GetAuthorization(ctx, order.AuthzURLs[0])
+		if !assert.NoError(err) {
+			t.SkipNow()
+		}
+		challenges.Store(authz.Challenges[0].Token, "incorrect")
+		_, err = c.Accept(ctx, authz.Challenges[0])
+		assert.NoError(err)
+		_, err = c.WaitAuthorization(ctx, authz.URI)
+		assert.Error(err)
+		_, err = c.WaitOrder(ctx, order.URI)
+		assert.Error(err)
+	})
+}
@@ -0,0 +1,75 @@
+package acme
+
+import (
+	"time"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+)
+
+////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type Config struct {
+	CertManager CertManager       `hcl:"certmanager,required" description:"Certificate manager which signs and revokes certificates"`
+	CA          string            `hcl:"ca,required" description:"Serial number of the certificate authority which signs certificates"`
+	URL         string            `hcl:"url" description:"External URL of the service, or empty to use the request host and prefix"`
+	Resolver    string            `hcl:"resolver" description:"Nameserver address for resolving hosts in HTTP-01 challenges, or empty to use the system resolver"`
+	Hosts       map[string]string `hcl:"hosts" description:"Addresses for hosts in HTTP-01 challenges, which are used instead of the resolver"`
+	Port        int               `hcl:"port" description:"Port for HTTP-01 challenges (default 80)"`
+	Expiry      time.Duration     `hcl:"expiry" description:"Period of validity for orders and authorizations (default 24h)"`
+}
+
+// Check interfaces are satisfied
+var _ server.Plugin = Config{}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	defaultName          = "acme"
+	defaultPort          = 80
+	defaultExpiry        = 24 * time.Hour
+	defaultNonceExpiry   = time.Hour
+	defaultPurgeInterval = time.Minute
+	challengeTimeout     = 30 * time.Second
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Name returns the name of the service
+func (Config) Name() string {
+	return defaultName
+}
+
+// Description returns the description of the service
+func (Config) Description() string {
+	return "ACME server which issues certificates from a certificate authority"
+}
+
+// Create a new task from the configuration
+func (c Config) New() (server.Task, error) {
+	return New(c)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the port for HTTP-01 challenges
+func (c Config) port() int {
+	if c.Port > 0 {
+		return c.Port
+	} else {
+		return defaultPort
+	}
+}
+
+// Return the period of validity for orders and authorizations
+func (c Config) expiry() time.Duration {
+	if c.Expiry > 0 {
+		return c.Expiry
+	} else {
+		return defaultExpiry
+	}
+}
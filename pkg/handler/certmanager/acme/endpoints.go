@@ -0,0 +1,958 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	certmanager "github.com/mutablelogic/go-server/pkg/handler/certmanager"
+	cert "github.com/mutablelogic/go-server/pkg/handler/certmanager/cert"
+	router "github.com/mutablelogic/go-server/pkg/handler/router"
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type reqNewAccount struct {
+	Contact              []string `json:"contact"`
+	TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed"`
+	OnlyReturnExisting   bool     `json:"onlyReturnExisting"`
+}
+
+type reqUpdate struct {
+	Status  string   `json:"status"`
+	Contact []string `json:"contact"`
+}
+
+type reqNewOrder struct {
+	Identifiers []identifier `json:"identifiers"`
+	NotBefore   string       `json:"notBefore"`
+	NotAfter    string       `json:"notAfter"`
+}
+
+type reqFinalize struct {
+	CSR string `json:"csr"`
+}
+
+type reqRevoke struct {
+	Certificate string `json:"certificate"`
+	Reason      *int   `json:"reason"`
+}
+
+type respDirectory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+	RevokeCert string `json:"revokeCert"`
+}
+
+type respOrders struct {
+	Orders []string `json:"orders"`
+}
+
+// request is a POST request which has been authenticated with the key
+// of an account, or with a key in the request
+type request struct {
+	base       string
+	payload    []byte
+	account    *account
+	key        crypto.PublicKey
+	thumbprint string
+}
+
+// Check interfaces are satisfied
+var _ server.ServiceEndpoints = (*acme)(nil)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	jsonIndent          = 2
+	maxBodySize         = 64 * 1024
+	contentTypeJOSE     = "application/jose+json"
+	contentTypeProblem  = "application/problem+json"
+	contentTypePEMChain = "application/pem-certificate-chain"
+	headerNonce         = "Replay-Nonce"
+	headerLocation      = "Location"
+	headerLink          = "Link"
+	headerCacheControl  = "Cache-Control"
+	contactPrefix       = "mailto:"
+)
+
+const (
+	pathDirectory  = "/directory"
+	pathNewNonce   = "/new-nonce"
+	pathNewAccount = "/new-account"
+	pathNewOrder   = "/new-order"
+	pathRevokeCert = "/revoke-cert"
+	pathAccount    = "/account/"
+	pathOrder      = "/order/"
+	pathAuthz      = "/authz/"
+	pathChallenge  = "/challenge/"
+	pathCert       = "/cert/"
+	pathOrders     = "/orders"
+	pathFinalize   = "/finalize"
+)
+
+var (
+	reDirectory  = regexp.MustCompile(`^/directory$`)
+	reNewNonce   = regexp.MustCompile(`^/new-nonce$`)
+	reNewAccount = regexp.MustCompile(`^/new-account$`)
+	reNewOrder   = regexp.MustCompile(`^/new-order$`)
+	reRevokeCert = regexp.MustCompile(`^/revoke-cert$`)
+	reAccount    = regexp.MustCompile(`^/account/([A-Za-z0-9_-]+)$`)
+	reOrders     = regexp.MustCompile(`^/account/([A-Za-z0-9_-]+)/orders$`)
+	reOrder      = regexp.MustCompile(`^/order/([A-Za-z0-9_-]+)$`)
+	reFinalize   = regexp.MustCompile(`^/order/([A-Za-z0-9_-]+)/finalize$`)
+	reAuthz      = regexp.MustCompile(`^/authz/([A-Za-z0-9_-]+)$`)
+	reChallenge  = regexp.MustCompile(`^/challenge/([A-Za-z0-9_-]+)$`)
+	reCert       = regexp.MustCompile(`^/cert/([0-9]+)$`)
+	reHostLabel  = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Add endpoints to the router. The endpoints should not use authentication
+// middleware, as requests are authenticated with the account key
+func (service *acme) AddEndpoints(ctx context.Context, r server.Router) {
+	// Path: /directory
+	// Methods: GET
+	// Scopes: none
+	r.AddHandlerFuncRe(ctx, reDirectory, service.wrap(service.reqDirectory), http.MethodGet).(router.Route).
+		SetSummary("Return the URLs for the ACME endpoints")
+
+	// Path: /new-nonce
+	// Methods: HEAD, GET
+	// Scopes: none
+	r.AddHandlerFuncRe(ctx, reNewNonce, service.wrap(service.reqNewNonce), http.MethodHead, http.MethodGet).(router.Route).
+		SetSummary("Return a nonce in the Replay-Nonce header")
+
+	// Path: /new-account
+	// Methods: POST
+	// Scopes: none
+	r.AddHandlerFuncRe(ctx, reNewAccount, service.wrap(service.reqNewAccount), http.MethodPost).(router.Route).
+		SetSummary("Create an account, or return an existing account for a key")
+
+	// Path: /account/<id>
+	// Methods: POST
+	// Scopes: none
+	r.AddHandlerFuncRe(ctx, reAccount, service.wrap(service.reqAccount), http.MethodPost).(router.Route).
+		SetSummary("Return, update or deactivate an account")
+
+	// Path: /account/<id>/orders
+	// Methods: POST
+	// Scopes: none
+	r.AddHandlerFuncRe(ctx, reOrders, service.wrap(service.reqOrders), http.MethodPost).(router.Route).
+		SetSummary("Return the pending and ready orders for an account")
+
+	// Path: /new-order
+	// Methods: POST
+	// Scopes: none
+	r.AddHandlerFuncRe(ctx, reNewOrder, service.wrap(service.reqNewOrder), http.MethodPost).(router.Route).
+		SetSummary("Create an order for a certificate")
+
+	// Path: /order/<id>
+	// Methods: POST
+	// Scopes: none
+	r.AddHandlerFuncRe(ctx, reOrder, service.wrap(service.reqOrder), http.MethodPost).(router.Route).
+		SetSummary("Return an order")
+
+	// Path: /order/<id>/finalize
+	// Methods: POST
+	// Scopes: none
+	r.AddHandlerFuncRe(ctx, reFinalize, service.wrap(service.reqFinalize), http.MethodPost).(router.Route).
+		SetSummary("Finalize an order with a certificate signing request")
+
+	// Path: /authz/<id>
+	// Methods: POST
+	// Scopes: none
+	r.AddHandlerFuncRe(ctx, reAuthz, service.wrap(service.reqAuthz), http.MethodPost).(router.Route).
+		SetSummary("Return or deactivate an authorization")
+
+	// Path: /challenge/<id>
+	// Methods: POST
+	// Scopes: none
+	r.AddHandlerFuncRe(ctx, reChallenge, service.wrap(service.reqChallenge), http.MethodPost).(router.Route).
+		SetSummary("Return a challenge, or respond to a challenge to start validation")
+
+	// Path: /cert/<serial>
+	// Methods: POST
+	// Scopes: none
+	r.AddHandlerFuncRe(ctx, reCert, service.wrap(service.reqCert), http.MethodPost).(router.Route).
+		SetSummary("Return a certificate chain for a finalized order")
+
+	// Path: /revoke-cert
+	// Methods: POST
+	// Scopes: none
+	r.AddHandlerFuncRe(ctx, reRevokeCert, service.wrap(service.reqRevokeCert), http.MethodPost).(router.Route).
+		SetSummary("Revoke a certificate")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS - HANDLERS
+
+// Return the directory
+func (service *acme) reqDirectory(w http.ResponseWriter, r *http.Request) {
+	base := service.base(r)
+	httpresponse.JSON(w, respDirectory{
+		NewNonce:   base + pathNewNonce,
+		NewAccount: base + pathNewAccount,
+		NewOrder:   base + pathNewOrder,
+		RevokeCert: base + pathRevokeCert,
+	}, http.StatusOK, jsonIndent)
+}
+
+// Return a nonce, which is set in the headers for every response
+func (service *acme) reqNewNonce(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		httpresponse.Empty(w, http.StatusOK)
+	} else {
+		httpresponse.Empty(w, http.StatusNoContent)
+	}
+}
+
+// Create an account, or return an existing account
+func (service *acme) reqNewAccount(w http.ResponseWriter, r *http.Request) {
+	req, err := service.authenticate(r)
+	if err != nil {
+		service.problem(w, err)
+		return
+	} else if req.account != nil {
+		service.problem(w, newProblem(problemMalformed, "expected jwk in protected header"))
+		return
+	}
+
+	// Decode the request
+	var body reqNewAccount
+	if err := json.Unmarshal(req.payload, &body); err != nil {
+		service.problem(w, newProblem(problemMalformed, err.Error()))
+		return
+	} else if err := checkContacts(body.Contact); err != nil {
+		service.problem(w, err)
+		return
+	}
+
+	// Return an existing account, or create a new account
+	service.Lock()
+	code := http.StatusOK
+	acct := service.accountForThumbprint(req.thumbprint)
+	if acct == nil && !body.OnlyReturnExisting {
+		id := randomString(idBytes)
+		acct = &account{
+			Status:     statusValid,
+			Contact:    body.Contact,
+			Orders:     req.base + pathAccount + id + pathOrders,
+			id:         id,
+			url:        req.base + pathAccount + id,
+			key:        req.key,
+			thumbprint: req.thumbprint,
+		}
+		service.accounts[id] = acct
+		code = http.StatusCreated
+	}
+	var response account
+	if acct != nil {
+		response = *acct
+	}
+	service.Unlock()
+
+	// Respond with a copy of the account, which was taken under the lock
+	if acct == nil {
+		service.problem(w, newProblem(problemAccountDoesNotExist, "no account for key"))
+	} else {
+		service.respond(w, code, response, headerLocation, response.url)
+	}
+}
+
+// Return, update or deactivate an account
+func (service *acme) reqAccount(w http.ResponseWriter, r *http.Request) {
+	req, err := service.authenticateAccount(r)
+	if err != nil {
+		service.problem(w, err)
+		return
+	} else if req.account.id != router.Params(r.Context())[0] {
+		service.problem(w, newProblem(problemUnauthorized, "account does not match key"))
+		return
+	}
+
+	// Update the account
+	if !isPostAsGet(req.payload) {
+		var body reqUpdate
+		if err := json.Unmarshal(req.payload, &body); err != nil {
+			service.problem(w, newProblem(problemMalformed, err.Error()))
+			return
+		} else if body.Status != "" && body.Status != statusDeactivated {
+			service.problem(w, newProblem(problemMalformed, "invalid status ", strconv.Quote(body.Status)))
+			return
+		} else if err := checkContacts(body.Contact); err != nil {
+			service.problem(w, err)
+			return
+		}
+		service.Lock()
+		if body.Status != "" {
+			req.account.Status = body.Status
+		}
+		if body.Contact != nil {
+			req.account.Contact = body.Contact
+		}
+		service.Unlock()
+	}
+
+	// Respond with a copy of the account, taken under the lock
+	service.Lock()
+	response := *req.account
+	service.Unlock()
+	service.respond(w, http.StatusOK, response, headerLocation, response.url)
+}
+
+// Return the pending and ready orders for an account
+func (service *acme) reqOrders(w http.ResponseWriter, r *http.Request) {
+	req, err := service.authenticateAccount(r)
+	if err != nil {
+		service.problem(w, err)
+		return
+	} else if req.account.id != router.Params(r.Context())[0] {
+		service.problem(w, newProblem(problemUnauthorized, "account does not match key"))
+		return
+	}
+
+	// Gather the orders
+	service.Lock()
+	now := time.Now()
+	orders := respOrders{Orders: []string{}}
+	for _, order := range service.orders {
+		order.update(now)
+		if order.account == req.account.id && (order.Status == statusPending || order.Status == statusReady) {
+			orders.Orders = append(orders.Orders, order.url)
+		}
+	}
+	service.Unlock()
+
+	// Respond with the orders
+	slices.Sort(orders.Orders)
+	service.respond(w, http.StatusOK, orders)
+}
+
+// Create an order for a certificate
+func (service *acme) reqNewOrder(w http.ResponseWriter, r *http.Request) {
+	req, err := service.authenticateAccount(r)
+	if err != nil {
+		service.problem(w, err)
+		return
+	}
+
+	// Decode the request and check the identifiers
+	var body reqNewOrder
+	if err := json.Unmarshal(req.payload, &body); err != nil {
+		service.problem(w, newProblem(problemMalformed, err.Error()))
+		return
+	} else if body.NotBefore != "" || body.NotAfter != "" {
+		service.problem(w, newProblem(problemMalformed, "notBefore and notAfter are not supported"))
+		return
+	}
+	identifiers, err := checkIdentifiers(body.Identifiers)
+	if err != nil {
+		service.problem(w, err)
+		return
+	}
+
+	// Create the order, re-using any valid authorizations for the identifiers
+	service.Lock()
+	now := time.Now()
+	id := randomString(idBytes)
+	order := &order{
+		Status:      statusPending,
+		Expires:     now.Add(service.expiry),
+		Identifiers: identifiers,
+		Finalize:    req.base + pathOrder + id + pathFinalize,
+		id:          id,
+		url:         req.base + pathOrder + id,
+		account:     req.account.id,
+	}
+	for _, identifier := range identifiers {
+		authz := service.authzForIdentifier(req.account.id, identifier, order.Expires)
+		if authz == nil {
+			authz = service.newAuthz(req, identifier, order.Expires)
+		}
+		order.authz = append(order.authz, authz)
+		order.Authorizations = append(order.Authorizations, authz.url)
+	}
+	order.update(now)
+	service.orders[id] = order
+	service.Unlock()
+
+	// Respond with the order
+	service.respond(w, http.StatusCreated, order, headerLocation, order.url)
+}
+
+// Return an order
+func (service *acme) reqOrder(w http.ResponseWriter, r *http.Request) {
+	req, err := service.authenticateAccount(r)
+	if err != nil {
+		service.problem(w, err)
+		return
+	}
+	order, err := service.orderForAccount(req.account, router.Params(r.Context())[0])
+	if err != nil {
+		service.problem(w, err)
+		return
+	}
+
+	// Respond with the order
+	service.respond(w, http.StatusOK, order, headerLocation, order.url)
+}
+
+// Finalize an order with a certificate signing request, and sign the
+// certificate
+func (service *acme) reqFinalize(w http.ResponseWriter, r *http.Request) {
+	req, err := service.authenticateAccount(r)
+	if err != nil {
+		service.problem(w, err)
+		return
+	}
+	order, err := service.orderForAccount(req.account, router.Params(r.Context())[0])
+	if err != nil {
+		service.problem(w, err)
+		return
+	}
+
+	// Decode the request and check the certificate signing request
+	var body reqFinalize
+	if err := json.Unmarshal(req.payload, &body); err != nil {
+		service.problem(w, newProblem(problemMalformed, err.Error()))
+		return
+	}
+	csr, err := checkRequest(body.CSR, order.Identifiers)
+	if err != nil {
+		service.problem(w, err)
+		return
+	}
+
+	// Mark the order as processing, so it cannot be finalized twice
+	service.Lock()
+	status := order.Status
+	if status == statusReady {
+		order.Status = statusProcessing
+	}
+	service.Unlock()
+	if status != statusReady {
+		service.problem(w, newProblem(problemOrderNotReady, "order is ", status))
+		return
+	}
+
+	// Sign the certificate, and update the order
+	c, err := service.sign(csr)
+	service.Lock()
+	if err != nil {
+		order.Status = statusInvalid
+		order.Error = problemForError(err)
+	} else {
+		order.Status = statusValid
+		order.Certificate = req.base + pathCert + c.Serial()
+		service.certs[c.Serial()] = req.account.id
+	}
+	service.Unlock()
+	if err != nil {
+		service.problem(w, err)
+		return
+	}
+
+	// Respond with the order
+	service.respond(w, http.StatusOK, order, headerLocation, order.url)
+}
+
+// Return or deactivate an authorization
+func (service *acme) reqAuthz(w http.ResponseWriter, r *http.Request) {
+	req, err := service.authenticateAccount(r)
+	if err != nil {
+		service.problem(w, err)
+		return
+	}
+
+	// Get the authorization
+	service.Lock()
+	authz, exists := service.authz[router.Params(r.Context())[0]]
+	if exists {
+		authz.update(time.Now())
+	}
+	service.Unlock()
+	if !exists || authz.account != req.account.id {
+		service.problem(w, ErrNotFound.With("authorization"))
+		return
+	}
+
+	// Deactivate the authorization
+	if !isPostAsGet(req.payload) {
+		var body reqUpdate
+		if err := json.Unmarshal(req.payload, &body); err != nil {
+			service.problem(w, newProblem(problemMalformed, err.Error()))
+			return
+		} else if body.Status != statusDeactivated {
+			service.problem(w, newProblem(problemMalformed, "invalid status ", strconv.Quote(body.Status)))
+			return
+		}
+		service.Lock()
+		if authz.Status == statusPending || authz.Status == statusValid {
+			authz.Status = statusDeactivated
+		}
+		service.Unlock()
+	}
+
+	// Respond with the authorization
+	service.respond(w, http.StatusOK, authz)
+}
+
+// Return a challenge, or start validation of a challenge
+func (service *acme) reqChallenge(w http.ResponseWriter, r *http.Request) {
+	req, err := service.authenticateAccount(r)
+	if err != nil {
+		service.problem(w, err)
+		return
+	}
+
+	// Get the challenge
+	var challenge *challenge
+	id := router.Params(r.Context())[0]
+	service.Lock()
+	for _, authz := range service.authz {
+		for _, c := range authz.Challenges {
+			if c.id == id && authz.account == req.account.id {
+				challenge = c
+			}
+		}
+	}
+	service.Unlock()
+	if challenge == nil {
+		service.problem(w, ErrNotFound.With("challenge"))
+		return
+	}
+
+	// Start validation if the challenge is pending, which occurs in
+	// the background
+	if !isPostAsGet(req.payload) {
+		service.Lock()
+		challenge.authz.update(time.Now())
+		start := challenge.Status == statusPending && challenge.authz.Status == statusPending
+		if start {
+			challenge.Status = statusProcessing
+		}
+		service.Unlock()
+		if start {
+			go service.validate(challenge, challenge.authz.Identifier.Value, challenge.Token+jwsSep+req.thumbprint)
+		}
+	}
+
+	// Respond with the challenge
+	service.respond(w, http.StatusOK, challenge, headerLink, `<`+challenge.authz.url+`>;rel="up"`)
+}
+
+// Return the certificate chain for a finalized order
+func (service *acme) reqCert(w http.ResponseWriter, r *http.Request) {
+	req, err := service.authenticateAccount(r)
+	if err != nil {
+		service.problem(w, err)
+		return
+	}
+
+	// Check the certificate was issued to the account
+	serial := router.Params(r.Context())[0]
+	service.Lock()
+	owner := service.certs[serial]
+	service.Unlock()
+	if owner != req.account.id {
+		service.problem(w, ErrNotFound.With("certificate"))
+		return
+	}
+
+	// Write the certificate and the certificate authority
+	var data bytes.Buffer
+	if c, err := service.certmanager.Read(serial); err != nil {
+		service.problem(w, err)
+		return
+	} else if err := c.WriteCertificate(&data); err != nil {
+		service.problem(w, err)
+		return
+	}
+	if ca, err := service.CA(); err != nil {
+		service.problem(w, err)
+		return
+	} else if err := ca.WriteCertificate(&data); err != nil {
+		service.problem(w, err)
+		return
+	}
+
+	// Respond with the certificate chain
+	w.Header().Set(httpresponse.ContentTypeKey, contentTypePEMChain)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data.Bytes())
+}
+
+// Revoke a certificate, which is authorized by the account which ordered
+// the certificate, or by the key of the certificate
+func (service *acme) reqRevokeCert(w http.ResponseWriter, r *http.Request) {
+	req, err := service.authenticate(r)
+	if err != nil {
+		service.problem(w, err)
+		return
+	}
+
+	// Decode the request
+	var body reqRevoke
+	if err := json.Unmarshal(req.payload, &body); err != nil {
+		service.problem(w, newProblem(problemMalformed, err.Error()))
+		return
+	}
+	reason := cert.ReasonUnspecified
+	if body.Reason != nil {
+		if reason, err = cert.ParseReason(strconv.Itoa(*body.Reason)); err != nil {
+			service.problem(w, newProblem(problemBadRevocationReason, err.Error()))
+			return
+		}
+	}
+	der, err := base64.RawURLEncoding.DecodeString(body.Certificate)
+	if err != nil {
+		service.problem(w, newProblem(problemMalformed, "malformed certificate"))
+		return
+	}
+	x509cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		service.problem(w, newProblem(problemMalformed, "malformed certificate"))
+		return
+	}
+	serial := x509cert.SerialNumber.String()
+
+	// Check the request is authorized
+	if req.account != nil {
+		service.Lock()
+		owner := service.certs[serial]
+		service.Unlock()
+		if owner != req.account.id {
+			service.problem(w, newProblem(problemUnauthorized, "certificate was not issued to account"))
+			return
+		}
+	} else if key, ok := x509cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !key.Equal(req.key) {
+		service.problem(w, newProblem(problemUnauthorized, "key does not match certificate"))
+		return
+	}
+
+	// Get the certificate, and check it matches the request
+	c, err := service.certmanager.Read(serial)
+	if errors.Is(err, ErrNotFound) {
+		service.problem(w, newProblem(problemAlreadyRevoked, "certificate not found"))
+		return
+	} else if err != nil {
+		service.problem(w, err)
+		return
+	}
+	var data bytes.Buffer
+	if err := c.WriteCertificate(&data); err != nil {
+		service.problem(w, err)
+		return
+	} else if !bytes.Equal(data.Bytes(), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})) {
+		service.problem(w, newProblem(problemUnauthorized, "certificate does not match"))
+		return
+	}
+
+	// Revoke the certificate
+	if err := service.certmanager.Revoke(c, reason); err != nil {
+		service.problem(w, err)
+		return
+	}
+	service.Lock()
+	delete(service.certs, serial)
+	service.Unlock()
+
+	// Respond with success
+	httpresponse.Empty(w, http.StatusOK)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Set the nonce and cache headers for every response, and a link to
+// the directory
+func (service *acme) wrap(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerNonce, service.newNonce())
+		w.Header().Set(headerCacheControl, "no-store")
+		w.Header().Add(headerLink, `<`+service.base(r)+pathDirectory+`>;rel="index"`)
+		fn(w, r)
+	}
+}
+
+// Return the base URL for the endpoints, which is either the configured
+// URL or the URL from the request host and prefix
+func (service *acme) base(r *http.Request) string {
+	if service.url != "" {
+		return service.url
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + strings.TrimSuffix(router.Prefix(r.Context()), "/")
+}
+
+// Authenticate a POST request, by checking the signature, nonce and URL
+func (service *acme) authenticate(r *http.Request) (*request, error) {
+	if contentType := r.Header.Get(httpresponse.ContentTypeKey); contentType != contentTypeJOSE {
+		return nil, newProblem(problemMalformed, "unexpected content type ", strconv.Quote(contentType))
+	}
+
+	// Decode the body
+	var body jws
+	if data, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize)); err != nil {
+		return nil, newProblem(problemMalformed, err.Error())
+	} else if err := json.Unmarshal(data, &body); err != nil {
+		return nil, newProblem(problemMalformed, err.Error())
+	}
+	header, payload, err := body.decode()
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the URL and the nonce
+	req := &request{base: service.base(r), payload: payload}
+	if header.URL != req.base+r.URL.Path {
+		return nil, newProblem(problemUnauthorized, "url does not match request")
+	} else if !service.useNonce(header.Nonce) {
+		return nil, newProblem(problemBadNonce, "invalid nonce")
+	}
+
+	// Get the key from the request or from the account
+	if len(header.JWK) > 0 {
+		if key, thumbprint, err := parseJWK(header.JWK); err != nil {
+			return nil, err
+		} else {
+			req.key, req.thumbprint = key, thumbprint
+		}
+	} else {
+		// The status is read under the lock, as it is changed when the
+		// account is deactivated
+		var status string
+		service.Lock()
+		account, exists := service.accounts[strings.TrimPrefix(header.Kid, req.base+pathAccount)]
+		if exists {
+			status = account.Status
+		}
+		service.Unlock()
+		if !exists || account.url != header.Kid {
+			return nil, newProblem(problemAccountDoesNotExist, "account does not exist")
+		} else if status != statusValid {
+			return nil, newProblem(problemUnauthorized, "account is ", status)
+		}
+		req.account, req.key, req.thumbprint = account, account.key, account.thumbprint
+	}
+
+	// Verify the signature
+	if err := body.verify(header.Alg, req.key); err != nil {
+		return nil, err
+	}
+
+	// Return success
+	return req, nil
+}
+
+// Authenticate a POST request, which should be signed by an account key
+func (service *acme) authenticateAccount(r *http.Request) (*request, error) {
+	if req, err := service.authenticate(r); err != nil {
+		return nil, err
+	} else if req.account == nil {
+		return nil, newProblem(problemMalformed, "expected kid in protected header")
+	} else {
+		return req, nil
+	}
+}
+
+// Return an order for an account, updating the status
+func (service *acme) orderForAccount(acct *account, id string) (*order, error) {
+	service.Lock()
+	defer service.Unlock()
+	order, exists := service.orders[id]
+	if !exists || order.account != acct.id {
+		return nil, ErrNotFound.With("order")
+	}
+	order.update(time.Now())
+	return order, nil
+}
+
+// Create a new authorization with a HTTP-01 challenge. The lock should
+// be held by the caller
+func (service *acme) newAuthz(req *request, id identifier, expires time.Time) *authorization {
+	authzId, challengeId := randomString(idBytes), randomString(idBytes)
+	authz := &authorization{
+		Identifier: id,
+		Status:     statusPending,
+		Expires:    expires,
+		id:         authzId,
+		url:        req.base + pathAuthz + authzId,
+		account:    req.account.id,
+	}
+	authz.Challenges = []*challenge{{
+		Type:   challengeHTTP,
+		URL:    req.base + pathChallenge + challengeId,
+		Token:  randomString(tokenBytes),
+		Status: statusPending,
+		id:     challengeId,
+		authz:  authz,
+	}}
+	service.authz[authzId] = authz
+	return authz
+}
+
+// Sign a certificate signing request with the certificate authority
+func (service *acme) sign(csr *x509.CertificateRequest) (certmanager.Cert, error) {
+	if ca, err := service.CA(); err != nil {
+		return nil, err
+	} else {
+		return service.certmanager.SignRequest(csr, ca)
+	}
+}
+
+// Validate a challenge for a host, and update the status of the challenge
+// and the authorization
+func (service *acme) validate(challenge *challenge, host, keyAuth string) {
+	ctx, cancel := context.WithTimeout(context.Background(), challengeTimeout)
+	defer cancel()
+	err := service.resolver.validate(ctx, host, challenge.Token, keyAuth)
+
+	// Update the status
+	service.Lock()
+	defer service.Unlock()
+	now := time.Now()
+	if err != nil {
+		challenge.Status = statusInvalid
+		challenge.Error = problemForError(err)
+	} else {
+		challenge.Status = statusValid
+		challenge.Validated = &now
+	}
+	challenge.authz.update(now)
+}
+
+// Respond with JSON, which is encoded with the lock held
+func (service *acme) respond(w http.ResponseWriter, code int, v any, tuples ...string) {
+	service.Lock()
+	data, err := json.Marshal(v)
+	service.Unlock()
+	if err != nil {
+		service.problem(w, err)
+	} else {
+		httpresponse.JSON(w, json.RawMessage(data), code, jsonIndent, tuples...)
+	}
+}
+
+// Respond with a problem document
+func (service *acme) problem(w http.ResponseWriter, err error) {
+	problem := problemForError(err)
+	httpresponse.JSON(w, problem, problem.Status, jsonIndent, httpresponse.ContentTypeKey, contentTypeProblem)
+}
+
+// Check contacts are email addresses
+func checkContacts(contacts []string) error {
+	for _, contact := range contacts {
+		if !strings.HasPrefix(contact, contactPrefix) || len(contact) == len(contactPrefix) {
+			return newProblem(problemUnsupportedContact, "unsupported contact ", strconv.Quote(contact))
+		}
+	}
+	return nil
+}
+
+// Check identifiers are valid host names, and return the unique identifiers
+// in lowercase
+func checkIdentifiers(identifiers []identifier) ([]identifier, error) {
+	if len(identifiers) == 0 {
+		return nil, newProblem(problemMalformed, "missing identifiers")
+	}
+	result := make([]identifier, 0, len(identifiers))
+	for _, id := range identifiers {
+		if id.Type != identifierDNS {
+			return nil, newProblem(problemUnsupportedIdentifier, "unsupported identifier type ", strconv.Quote(id.Type))
+		}
+		id.Value = strings.ToLower(id.Value)
+		if strings.HasPrefix(id.Value, "*.") {
+			return nil, newProblem(problemRejectedIdentifier, "wildcard identifiers are not supported")
+		} else if !isHost(id.Value) {
+			return nil, newProblem(problemRejectedIdentifier, "invalid identifier ", strconv.Quote(id.Value))
+		}
+		if !slices.Contains(result, id) {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+// Check a certificate signing request is signed, and the names in the
+// request match the identifiers
+func checkRequest(data string, identifiers []identifier) (*x509.CertificateRequest, error) {
+	der, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return nil, newProblem(problemBadCSR, "malformed csr")
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, newProblem(problemBadCSR, err.Error())
+	} else if err := csr.CheckSignature(); err != nil {
+		return nil, newProblem(problemBadCSR, err.Error())
+	} else if len(csr.IPAddresses) > 0 || len(csr.EmailAddresses) > 0 || len(csr.URIs) > 0 {
+		return nil, newProblem(problemBadCSR, "csr can only contain dns names")
+	}
+
+	// Names in the request, including the common name
+	names := make([]string, 0, len(csr.DNSNames)+1)
+	for _, name := range csr.DNSNames {
+		names = append(names, strings.ToLower(name))
+	}
+	if name := strings.ToLower(csr.Subject.CommonName); name != "" && !slices.Contains(names, name) {
+		names = append(names, name)
+	}
+
+	// Check names match the identifiers
+	for _, name := range names {
+		if !slices.Contains(identifiers, identifier{Type: identifierDNS, Value: name}) {
+			return nil, newProblem(problemBadCSR, "csr contains unauthorized name ", strconv.Quote(name))
+		}
+	}
+	for _, id := range identifiers {
+		if !slices.Contains(names, id.Value) {
+			return nil, newProblem(problemBadCSR, "csr is missing name ", strconv.Quote(id.Value))
+		}
+	}
+
+	// Return success
+	return csr, nil
+}
+
+// Return true if the value is a host name, and not an IP address
+func isHost(value string) bool {
+	if len(value) > 253 || net.ParseIP(value) != nil {
+		return false
+	}
+	labels := strings.Split(strings.TrimSuffix(value, "."), ".")
+	for _, label := range labels {
+		if !reHostLabel.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+package acme
+
+import (
+	"crypto/x509"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	certmanager "github.com/mutablelogic/go-server/pkg/handler/certmanager"
+	cert "github.com/mutablelogic/go-server/pkg/handler/certmanager/cert"
+)
+
+// CertManager signs certificate requests with a certificate authority,
+// and revokes certificates
+type CertManager interface {
+	server.Task
+
+	// Return a certificate or certificate authority by serial number
+	Read(string) (certmanager.Cert, error)
+
+	// Sign a certificate request with a certificate authority, and
+	// store the certificate
+	SignRequest(*x509.CertificateRequest, certmanager.Cert, ...cert.Opt) (certmanager.Cert, error)
+
+	// Revoke a certificate with a reason
+	Revoke(certmanager.Cert, cert.Reason) error
+}
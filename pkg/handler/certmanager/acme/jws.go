@@ -0,0 +1,200 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+)
+
+/////////////////////////////////////////////////////////////////////
+// TYPES
+
+// jws is a JSON Web Signature in flattened JSON serialization, which
+// is the body of every POST request
+type jws struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// jwsHeader is the protected header of a JSON Web Signature, which
+// contains either a key (for new accounts) or an account URL
+type jwsHeader struct {
+	Alg   string          `json:"alg"`
+	Nonce string          `json:"nonce"`
+	URL   string          `json:"url"`
+	Kid   string          `json:"kid,omitempty"`
+	JWK   json.RawMessage `json:"jwk,omitempty"`
+}
+
+// jwk is a JSON Web Key, for an RSA or EC public key
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+/////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	jwsAlgRS256 = "RS256"
+	jwsAlgES256 = "ES256"
+	jwsAlgES384 = "ES384"
+	jwsAlgES512 = "ES512"
+	jwkTypeRSA  = "RSA"
+	jwkTypeEC   = "EC"
+	jwsSep      = "."
+)
+
+/////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Decode the protected header and payload of a JSON Web Signature
+func (j *jws) decode() (jwsHeader, []byte, error) {
+	var header jwsHeader
+	if data, err := base64.RawURLEncoding.DecodeString(j.Protected); err != nil {
+		return header, nil, newProblem(problemMalformed, "malformed protected header")
+	} else if err := json.Unmarshal(data, &header); err != nil {
+		return header, nil, newProblem(problemMalformed, "malformed protected header")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(j.Payload)
+	if err != nil {
+		return header, nil, newProblem(problemMalformed, "malformed payload")
+	}
+
+	// Exactly one of jwk and kid is required
+	if (len(header.JWK) == 0) == (header.Kid == "") {
+		return header, nil, newProblem(problemMalformed, "expected one of jwk or kid in protected header")
+	}
+
+	// Return success
+	return header, payload, nil
+}
+
+// Verify the signature with a public key
+func (j *jws) verify(alg string, key crypto.PublicKey) error {
+	signature, err := base64.RawURLEncoding.DecodeString(j.Signature)
+	if err != nil {
+		return newProblem(problemMalformed, "malformed signature")
+	}
+	payload := []byte(j.Protected + jwsSep + j.Payload)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if alg != jwsAlgRS256 {
+			return newProblem(problemBadSignatureAlgorithm, "unsupported algorithm ", alg)
+		}
+		hash := sha256.Sum256(payload)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+			return newProblem(problemMalformed, "invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		var hash []byte
+		switch {
+		case alg == jwsAlgES256 && key.Curve == elliptic.P256():
+			v := sha256.Sum256(payload)
+			hash = v[:]
+		case alg == jwsAlgES384 && key.Curve == elliptic.P384():
+			v := sha512.Sum384(payload)
+			hash = v[:]
+		case alg == jwsAlgES512 && key.Curve == elliptic.P521():
+			v := sha512.Sum512(payload)
+			hash = v[:]
+		default:
+			return newProblem(problemBadSignatureAlgorithm, "unsupported algorithm ", alg)
+		}
+		// Signature is r and s as fixed-length big-endian integers
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return newProblem(problemMalformed, "invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, hash, r, s) {
+			return newProblem(problemMalformed, "invalid signature")
+		}
+	default:
+		return newProblem(problemBadPublicKey, "unsupported key")
+	}
+
+	// Return success
+	return nil
+}
+
+// Parse a JSON Web Key, and return the public key and thumbprint
+// as defined in RFC 7638
+func parseJWK(data []byte) (crypto.PublicKey, string, error) {
+	var key jwk
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, "", newProblem(problemMalformed, "malformed jwk")
+	}
+
+	// The thumbprint is the hash of the required members in
+	// lexicographic order
+	var public crypto.PublicKey
+	var members string
+	switch key.Kty {
+	case jwkTypeRSA:
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil || len(n) == 0 {
+			return nil, "", newProblem(problemBadPublicKey, "malformed rsa key")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, "", newProblem(problemBadPublicKey, "malformed rsa key")
+		}
+		public = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		members = `{"e":"` + key.E + `","kty":"RSA","n":"` + key.N + `"}`
+	case jwkTypeEC:
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, "", newProblem(problemBadPublicKey, "unsupported curve ", key.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil {
+			return nil, "", newProblem(problemBadPublicKey, "malformed ec key")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(key.Y)
+		if err != nil {
+			return nil, "", newProblem(problemBadPublicKey, "malformed ec key")
+		}
+		ec := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if _, err := ec.ECDH(); err != nil {
+			return nil, "", newProblem(problemBadPublicKey, "invalid ec key")
+		}
+		public = ec
+		members = `{"crv":"` + key.Crv + `","kty":"EC","x":"` + key.X + `","y":"` + key.Y + `"}`
+	default:
+		return nil, "", newProblem(problemBadPublicKey, "unsupported key type ", key.Kty)
+	}
+
+	// Return the key and thumbprint
+	hash := sha256.Sum256([]byte(members))
+	return public, base64.RawURLEncoding.EncodeToString(hash[:]), nil
+}
+
+// Return true if the payload is empty, which indicates a POST-as-GET request
+func isPostAsGet(payload []byte) bool {
+	return len(strings.TrimSpace(string(payload))) == 0
+}
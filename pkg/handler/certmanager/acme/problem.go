@@ -0,0 +1,99 @@
+package acme
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+/////////////////////////////////////////////////////////////////////
+// TYPES
+
+// problem is an error which is returned to the client as a problem
+// document, as defined in RFC 7807
+type problem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail,omitempty"`
+	Status int    `json:"status,omitempty"`
+}
+
+/////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	problemPrefix                = "urn:ietf:params:acme:error:"
+	problemAccountDoesNotExist   = "accountDoesNotExist"
+	problemAlreadyRevoked        = "alreadyRevoked"
+	problemBadCSR                = "badCSR"
+	problemBadNonce              = "badNonce"
+	problemBadPublicKey          = "badPublicKey"
+	problemBadRevocationReason   = "badRevocationReason"
+	problemBadSignatureAlgorithm = "badSignatureAlgorithm"
+	problemConnection            = "connection"
+	problemIncorrectResponse     = "incorrectResponse"
+	problemMalformed             = "malformed"
+	problemOrderNotReady         = "orderNotReady"
+	problemRejectedIdentifier    = "rejectedIdentifier"
+	problemServerInternal        = "serverInternal"
+	problemUnauthorized          = "unauthorized"
+	problemUnsupportedContact    = "unsupportedContact"
+	problemUnsupportedIdentifier = "unsupportedIdentifier"
+)
+
+var (
+	// Status codes for problems, where the default is http.StatusBadRequest
+	problemStatus = map[string]int{
+		problemAccountDoesNotExist: http.StatusBadRequest,
+		problemOrderNotReady:       http.StatusForbidden,
+		problemServerInternal:      http.StatusInternalServerError,
+		problemUnauthorized:        http.StatusForbidden,
+	}
+)
+
+/////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Return a new problem with a type and detail
+func newProblem(t string, detail ...any) *problem {
+	status, exists := problemStatus[t]
+	if !exists {
+		status = http.StatusBadRequest
+	}
+	return &problem{
+		Type:   problemPrefix + t,
+		Detail: fmt.Sprint(detail...),
+		Status: status,
+	}
+}
+
+// Return a problem for an error, mapping errors which are not problems
+// to the most appropriate problem type
+func problemForError(err error) *problem {
+	var p *problem
+	switch {
+	case errors.As(err, &p):
+		return p
+	case errors.Is(err, ErrNotFound):
+		p = newProblem(problemMalformed, err.Error())
+		p.Status = http.StatusNotFound
+		return p
+	case errors.Is(err, ErrBadParameter):
+		return newProblem(problemMalformed, err.Error())
+	default:
+		return newProblem(problemServerInternal, err.Error())
+	}
+}
+
+/////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (p *problem) Error() string {
+	if p.Detail == "" {
+		return p.Type
+	} else {
+		return p.Type + ": " + p.Detail
+	}
+}
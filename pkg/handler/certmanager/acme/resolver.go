@@ -0,0 +1,141 @@
+package acme
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/////////////////////////////////////////////////////////////////////
+// TYPES
+
+// resolver validates HTTP-01 challenges, by resolving the host either
+// from a fixed set of addresses or with a nameserver, and then requesting
+// the key authorization from the host
+type resolver struct {
+	hosts    map[string]string
+	port     string
+	resolver *net.Resolver
+	client   *http.Client
+}
+
+/////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	challengePath    = "/.well-known/acme-challenge/"
+	challengeMaxSize = 1024
+)
+
+/////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a resolver with a set of addresses for hosts, a nameserver address
+// (or empty to use the system resolver) and a port for challenges
+func newResolver(hosts map[string]string, nameserver string, port int) *resolver {
+	r := new(resolver)
+	r.port = strconv.Itoa(port)
+	r.hosts = make(map[string]string, len(hosts))
+	for host, addr := range hosts {
+		r.hosts[strings.ToLower(host)] = addr
+	}
+
+	// Set the resolver, using the nameserver if set
+	if nameserver != "" {
+		if _, _, err := net.SplitHostPort(nameserver); err != nil {
+			nameserver = net.JoinHostPort(nameserver, "53")
+		}
+		r.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, nameserver)
+			},
+		}
+	} else {
+		r.resolver = net.DefaultResolver
+	}
+
+	// Dial hosts through the resolver
+	r.client = &http.Client{
+		Transport: &http.Transport{
+			DialContext:       r.dial,
+			DisableKeepAlives: true,
+		},
+	}
+
+	// Return the resolver
+	return r
+}
+
+/////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Validate a HTTP-01 challenge for a host, by requesting the token and
+// comparing the response with the key authorization
+func (r *resolver) validate(ctx context.Context, host, token, keyAuth string) error {
+	url := "http://" + net.JoinHostPort(host, r.port) + challengePath + token
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return newProblem(problemMalformed, err.Error())
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return newProblem(problemConnection, err.Error())
+	}
+	defer resp.Body.Close()
+
+	// Check the response
+	if resp.StatusCode != http.StatusOK {
+		return newProblem(problemIncorrectResponse, fmt.Sprintf("%s: unexpected status %d", url, resp.StatusCode))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, challengeMaxSize))
+	if err != nil {
+		return newProblem(problemConnection, err.Error())
+	} else if strings.TrimSpace(string(data)) != keyAuth {
+		return newProblem(problemIncorrectResponse, fmt.Sprintf("%s: unexpected key authorization", url))
+	}
+
+	// Return success
+	return nil
+}
+
+// Dial a host, using a fixed address or port if set. Fixed addresses
+// can be an IP address, or an IP address and port
+func (r *resolver) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the host
+	var addrs []string
+	if fixed, exists := r.hosts[strings.ToLower(host)]; exists {
+		if fixedHost, fixedPort, err := net.SplitHostPort(fixed); err == nil {
+			addrs, port = []string{fixedHost}, fixedPort
+		} else {
+			addrs = []string{fixed}
+		}
+	} else if ip := net.ParseIP(host); ip != nil {
+		addrs = []string{host}
+	} else if addrs, err = r.resolver.LookupHost(ctx, host); err != nil {
+		return nil, err
+	}
+
+	// Dial each address in turn
+	var dialer net.Dialer
+	for _, addr := range addrs {
+		var conn net.Conn
+		if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(addr, port)); err == nil {
+			return conn, nil
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("no addresses for %q", host)
+	}
+	return nil, err
+}
@@ -0,0 +1,41 @@
+package acme
+
+import (
+	"context"
+	"time"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Check interfaces are satisfied
+var _ server.Task = (*acme)(nil)
+
+/////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return the label
+func (task *acme) Label() string {
+	// TODO
+	return defaultName
+}
+
+// Run the task until the context is cancelled, removing expired nonces,
+// orders and authorizations
+func (task *acme) Run(ctx context.Context) error {
+	ticker := time.NewTicker(defaultPurgeInterval)
+	defer ticker.Stop()
+
+	// Run the task until cancelled
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			task.purge()
+		}
+	}
+}
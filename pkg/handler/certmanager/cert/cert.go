@@ -3,6 +3,7 @@ package cert
 // Ref: https://go.dev/src/crypto/tls/generate_cert.go
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
@@ -10,6 +11,7 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
@@ -91,7 +93,7 @@ func NewCA(commonName string, opt ...Opt) (*Cert, error) {
 		SerialNumber:          serial,
 		NotBefore:             time.Now(),
 		NotAfter:              time.Now().AddDate(o.Years, o.Months, o.Days),
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		IsCA:                  true,
 		ExtKeyUsage:           []x509.ExtKeyUsage{},
 		BasicConstraintsValid: true,
@@ -202,6 +204,11 @@ func NewCert(commonName string, ca *Cert, opt ...Opt) (*Cert, error) {
 		template.DNSNames = o.DNSNames
 	}
 
+	// Set the location of the revocation list
+	if parent != nil && len(o.CRL) > 0 {
+		template.CRLDistributionPoints = o.CRL
+	}
+
 	// Generate public, private keys
 	publicKey, privateKey, err := generateKey(o.KeyType)
 	if err != nil {
@@ -240,20 +247,110 @@ func NewCert(commonName string, ca *Cert, opt ...Opt) (*Cert, error) {
 	return cert, nil
 }
 
+// Create a new certificate from a certificate signing request, signed by
+// the certificate authority. The subject and hosts are copied from the
+// request, and the certificate has no private key
+func NewFromRequest(csr *x509.CertificateRequest, ca *Cert, opt ...Opt) (*Cert, error) {
+	var o opts
+
+	// Set defaults
+	o.Months = defaultMonthsCert
+
+	// Set options
+	for _, fn := range opt {
+		if err := fn(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	// Check the request
+	if csr == nil {
+		return nil, ErrBadParameter.With("Missing certificate request")
+	} else if err := csr.CheckSignature(); err != nil {
+		return nil, ErrBadParameter.With("Invalid certificate request: ", err)
+	}
+
+	// Get serial number
+	var serial *big.Int
+	if o.Serial != 0 {
+		serial = big.NewInt(o.Serial)
+	} else if serial = SerialNumber(); serial == nil {
+		return nil, ErrInternalAppError.With("SerialNumber")
+	}
+
+	// Parse the CA certificate
+	if ca == nil {
+		return nil, ErrBadParameter.With("Cannot sign without a valid CA")
+	}
+	parent, err := x509.ParseCertificate(ca.data)
+	if err != nil {
+		return nil, err
+	}
+	if !parent.IsCA {
+		return nil, ErrBadParameter.With("Invalid CA certificate")
+	}
+	if parent.NotAfter.Before(time.Now()) {
+		return nil, ErrBadParameter.With("CA certificate has expired")
+	}
+	if parent.NotBefore.After(time.Now()) {
+		return nil, ErrBadParameter.With("CA certificate is not yet valid")
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().AddDate(o.Years, o.Months, o.Days),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		AuthorityKeyId:        parent.SubjectKeyId,
+		DNSNames:              csr.DNSNames,
+		IPAddresses:           csr.IPAddresses,
+		CRLDistributionPoints: o.CRL,
+	}
+
+	// Set subject
+	if o.Name != nil {
+		template.Subject = *o.Name
+	}
+	template.Subject.CommonName = csr.Subject.CommonName
+	if template.Subject.CommonName == "" && len(csr.DNSNames) > 0 {
+		template.Subject.CommonName = csr.DNSNames[0]
+	}
+
+	// Only RSA subject keys should have the KeyEncipherment KeyUsage bits set
+	if _, isRSA := csr.PublicKey.(*rsa.PublicKey); isRSA {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+
+	// Create cert signed by the CA
+	cert := new(Cert)
+	data, err := x509.CreateCertificate(rand.Reader, template, parent, csr.PublicKey, ca.privateKey)
+	if err != nil {
+		return nil, err
+	} else {
+		cert.data = data
+	}
+
+	// Return success
+	return cert, nil
+}
+
 // Import certificate from byte stream
 func NewFromBytes(data []byte) (*Cert, error) {
 	public, rest := pem.Decode(data)
 	if public == nil {
 		return nil, ErrBadParameter.With("unable to decode certificate")
 	}
-	priv, _ := pem.Decode(rest)
-	if priv == nil {
-		return nil, ErrBadParameter.With("unable to decode private key")
-	}
-
 	cert := new(Cert)
 	cert.data = public.Bytes
-	if privKey, err := x509.ParsePKCS8PrivateKey(priv.Bytes); err != nil {
+
+	// A certificate signed from a request has no private key
+	if priv, _ := pem.Decode(rest); priv == nil {
+		if len(bytes.TrimSpace(rest)) > 0 {
+			return nil, ErrBadParameter.With("unable to decode private key")
+		}
+	} else if privKey, err := x509.ParsePKCS8PrivateKey(priv.Bytes); err != nil {
 		return nil, err
 	} else {
 		cert.privateKey = privKey
@@ -321,14 +418,25 @@ func SerialNumber() *big.Int {
 	}
 }
 
-// Return the key type
+// Return the key type. If there is no private key, the key type is
+// determined from the public key
 func (c *Cert) KeyType() string {
-	switch v := c.privateKey.(type) {
+	key := c.privateKey
+	if key == nil {
+		if cert, err := x509.ParseCertificate(c.data); err == nil {
+			key = cert.PublicKey
+		}
+	}
+	switch v := key.(type) {
 	case *rsa.PrivateKey:
 		return fmt.Sprintf("RSA%d", v.Size()*8)
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA%d", v.Size()*8)
 	case *ecdsa.PrivateKey:
 		return "ECDSA " + v.Curve.Params().Name
-	case ed25519.PrivateKey:
+	case *ecdsa.PublicKey:
+		return "ECDSA " + v.Curve.Params().Name
+	case ed25519.PrivateKey, ed25519.PublicKey:
 		return "ED25519"
 	default:
 		return "UNKNOWN"
@@ -367,6 +475,15 @@ func (c *Cert) Expires() time.Time {
 	}
 }
 
+// Return the period of validity for the certificate
+func (c *Cert) Validity() time.Duration {
+	if cert, err := x509.ParseCertificate(c.data); err != nil {
+		return 0
+	} else {
+		return cert.NotAfter.Sub(cert.NotBefore)
+	}
+}
+
 func (c *Cert) IsValid() error {
 	cert, err := x509.ParseCertificate(c.data)
 	if err != nil {
@@ -379,14 +496,109 @@ func (c *Cert) IsValid() error {
 	return nil
 }
 
+// Return the subject key identifier as a hex string, which identifies
+// the public key of the certificate
+func (c *Cert) KeyId() string {
+	if cert, err := x509.ParseCertificate(c.data); err != nil {
+		return ""
+	} else {
+		return hex.EncodeToString(cert.SubjectKeyId)
+	}
+}
+
+// Return the authority key identifier as a hex string, which identifies
+// the public key of the issuer, or an empty string if the certificate
+// is self-signed
+func (c *Cert) IssuerKeyId() string {
+	if cert, err := x509.ParseCertificate(c.data); err != nil {
+		return ""
+	} else if bytes.Equal(cert.AuthorityKeyId, cert.SubjectKeyId) {
+		return ""
+	} else {
+		return hex.EncodeToString(cert.AuthorityKeyId)
+	}
+}
+
+// Renew returns a new certificate with the same subject, hosts and private
+// key, and a new serial number. The validity period is the same as the
+// original certificate, starting now. The certificate is signed by the
+// certificate authority, or self-signed if ca is nil
+func (c *Cert) Renew(ca *Cert) (*Cert, error) {
+	cert, err := x509.ParseCertificate(c.data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get serial number
+	serial := SerialNumber()
+	if serial == nil {
+		return nil, ErrInternalAppError.With("SerialNumber")
+	}
+
+	// Copy the template from the existing certificate
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               cert.Subject,
+		NotBefore:             now,
+		NotAfter:              now.Add(cert.NotAfter.Sub(cert.NotBefore)),
+		KeyUsage:              cert.KeyUsage,
+		ExtKeyUsage:           cert.ExtKeyUsage,
+		IsCA:                  cert.IsCA,
+		BasicConstraintsValid: cert.BasicConstraintsValid,
+		IPAddresses:           cert.IPAddresses,
+		DNSNames:              cert.DNSNames,
+		SubjectKeyId:          cert.SubjectKeyId,
+		CRLDistributionPoints: cert.CRLDistributionPoints,
+	}
+
+	// A certificate authority can sign revocation lists
+	if cert.IsCA {
+		template.KeyUsage |= x509.KeyUsageCRLSign
+	}
+
+	// Who is going to sign the certificate?
+	signer, signerPrivateKey := template, c.privateKey
+	if ca != nil {
+		parent, err := x509.ParseCertificate(ca.data)
+		if err != nil {
+			return nil, err
+		}
+		if !parent.IsCA {
+			return nil, ErrBadParameter.With("Invalid CA certificate")
+		}
+		if parent.NotAfter.Before(now) {
+			return nil, ErrBadParameter.With("CA certificate has expired")
+		}
+		signer, signerPrivateKey = parent, ca.privateKey
+		template.AuthorityKeyId = parent.SubjectKeyId
+	}
+
+	// Create the certificate with the existing public key
+	renewed := new(Cert)
+	data, err := x509.CreateCertificate(rand.Reader, template, signer, cert.PublicKey, signerPrivateKey)
+	if err != nil {
+		return nil, err
+	} else {
+		renewed.data = data
+		renewed.privateKey = c.privateKey
+	}
+
+	// Return success
+	return renewed, nil
+}
+
 // Write a .pem file with the certificate
 func (c *Cert) WriteCertificate(w io.Writer) error {
 	return pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: c.data})
 }
 
-// Write a .pem file with the private key
+// Write a .pem file with the private key. Returns ErrNotFound if the
+// certificate was signed from a request, and has no private key
 func (c *Cert) WritePrivateKey(w io.Writer) error {
-	if privBytes, err := x509.MarshalPKCS8PrivateKey(c.privateKey); err != nil {
+	if c.privateKey == nil {
+		return ErrNotFound.With("private key")
+	} else if privBytes, err := x509.MarshalPKCS8PrivateKey(c.privateKey); err != nil {
 		return err
 	} else if err := pem.Encode(w, &pem.Block{Type: "PRIVATE KEY", Bytes: privBytes}); err != nil {
 		return err
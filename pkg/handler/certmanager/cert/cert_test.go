@@ -2,11 +2,20 @@ package cert_test
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
 	"testing"
+	"time"
 
 	"github.com/mutablelogic/go-server/pkg/handler/certmanager/cert"
 	"github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
 )
 
 func Test_Cert_001(t *testing.T) {
@@ -74,3 +83,205 @@ func Test_Cert_006(t *testing.T) {
 	assert.NotNil(cert)
 	t.Log(cert)
 }
+
+func Test_Cert_007(t *testing.T) {
+	assert := assert.New(t)
+	ca, err := cert.NewCA(t.Name())
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	c, err := cert.NewCert(t.Name(), ca, cert.OptHosts("localhost", "127.0.0.1"))
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.Equal(ca.KeyId(), c.IssuerKeyId())
+	assert.Empty(ca.IssuerKeyId())
+
+	// Renew the certificate
+	renewed, err := c.Renew(ca)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.NotEqual(c.Serial(), renewed.Serial())
+	assert.Equal(c.Subject(), renewed.Subject())
+	assert.Equal(c.KeyType(), renewed.KeyType())
+	assert.Equal(c.IssuerKeyId(), renewed.IssuerKeyId())
+	assert.Equal(c.Validity(), renewed.Validity())
+	assert.False(renewed.Expires().Before(c.Expires()))
+
+	// Check the private key and hosts are the same
+	a, b := new(bytes.Buffer), new(bytes.Buffer)
+	assert.NoError(c.WritePrivateKey(a))
+	assert.NoError(renewed.WritePrivateKey(b))
+	assert.Equal(a.String(), b.String())
+	assert.Contains(renewed.String(), "localhost")
+	assert.Contains(renewed.String(), "127.0.0.1")
+}
+
+func Test_Cert_008(t *testing.T) {
+	assert := assert.New(t)
+	ca, err := cert.NewCA(t.Name())
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Renew the CA, which should have the same key identifier so that
+	// certificates issued by the CA are still valid
+	renewed, err := ca.Renew(nil)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.True(renewed.IsCA())
+	assert.NotEqual(ca.Serial(), renewed.Serial())
+	assert.Equal(ca.KeyId(), renewed.KeyId())
+}
+
+func Test_Cert_009(t *testing.T) {
+	assert := assert.New(t)
+	ca, err := cert.NewCA(t.Name())
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	c, err := cert.NewCert(t.Name(), ca)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Write a revocation list
+	data := new(bytes.Buffer)
+	if !assert.NoError(ca.WriteCRL(data, []cert.Revoked{
+		{Serial: c.Serial(), Time: time.Now(), Reason: cert.ReasonKeyCompromise},
+	}, time.Hour)) {
+		t.SkipNow()
+	}
+
+	// Parse the revocation list and check the signature
+	block, _ := pem.Decode(data.Bytes())
+	if !assert.NotNil(block) {
+		t.SkipNow()
+	}
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	public := new(bytes.Buffer)
+	assert.NoError(ca.WriteCertificate(public))
+	block, _ = pem.Decode(public.Bytes())
+	issuer, err := x509.ParseCertificate(block.Bytes)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.NoError(crl.CheckSignatureFrom(issuer))
+	if assert.Len(crl.RevokedCertificateEntries, 1) {
+		assert.Equal(c.Serial(), crl.RevokedCertificateEntries[0].SerialNumber.String())
+		assert.Equal(int(cert.ReasonKeyCompromise), crl.RevokedCertificateEntries[0].ReasonCode)
+	}
+
+	// A certificate cannot sign a revocation list
+	assert.Error(c.WriteCRL(data, nil, time.Hour))
+}
+
+func Test_Cert_010(t *testing.T) {
+	assert := assert.New(t)
+	for _, v := range []string{"", "0", "keyCompromise", "KEYCOMPROMISE", "4", "superseded"} {
+		_, err := cert.ParseReason(v)
+		assert.NoError(err, v)
+	}
+	for _, v := range []string{"7", "11", "other"} {
+		_, err := cert.ParseReason(v)
+		assert.Error(err, v)
+	}
+	reason, err := cert.ParseReason("1")
+	assert.NoError(err)
+	assert.Equal(cert.ReasonKeyCompromise, reason)
+	assert.Equal("keyCompromise", reason.String())
+}
+
+func Test_Cert_011(t *testing.T) {
+	assert := assert.New(t)
+	ca, err := cert.NewCA(t.Name())
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Create a request with a key which is not known to the CA
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	data, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		DNSNames: []string{"test.internal", "www.test.internal"},
+	}, key)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	csr, err := x509.ParseCertificateRequest(data)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Sign the request
+	c, err := cert.NewFromRequest(csr, ca)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.Equal("test.internal", c.Subject())
+	assert.Equal("ECDSA P-256", c.KeyType())
+	assert.Equal(ca.KeyId(), c.IssuerKeyId())
+	assert.ErrorIs(c.WritePrivateKey(new(bytes.Buffer)), ErrNotFound)
+
+	// A certificate without a private key can be read back
+	buf := new(bytes.Buffer)
+	assert.NoError(c.WriteCertificate(buf))
+	c2, err := cert.NewFromBytes(buf.Bytes())
+	if assert.NoError(err) {
+		assert.Equal(c.Serial(), c2.Serial())
+	}
+
+	// A request cannot be self-signed
+	_, err = cert.NewFromRequest(csr, nil)
+	assert.ErrorIs(err, ErrBadParameter)
+}
+
+func Test_Cert_012(t *testing.T) {
+	assert := assert.New(t)
+	ca, err := cert.NewCA(t.Name())
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Return the revocation list locations of a certificate
+	crl := func(c *cert.Cert) []string {
+		buf := new(bytes.Buffer)
+		assert.NoError(c.WriteCertificate(buf))
+		block, _ := pem.Decode(buf.Bytes())
+		if !assert.NotNil(block) {
+			return nil
+		}
+		x509cert, err := x509.ParseCertificate(block.Bytes)
+		if !assert.NoError(err) {
+			return nil
+		}
+		return x509cert.CRLDistributionPoints
+	}
+
+	// The location of the revocation list is kept when the certificate is renewed
+	url := "https://ca.internal/" + ca.Serial() + "/crl.pem"
+	c, err := cert.NewCert("localhost", ca, cert.OptCRL(url))
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.Equal([]string{url}, crl(c))
+	renewed, err := c.Renew(ca)
+	if assert.NoError(err) {
+		assert.Equal([]string{url}, crl(renewed))
+	}
+
+	// A self-signed certificate has no revocation list
+	c, err = cert.NewCert("localhost", nil, cert.OptCRL(url))
+	if assert.NoError(err) {
+		assert.Empty(crl(c))
+	}
+	_, err = cert.NewCert("localhost", ca, cert.OptCRL(""))
+	assert.ErrorIs(err, ErrBadParameter)
+}
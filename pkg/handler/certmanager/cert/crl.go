@@ -0,0 +1,156 @@
+package cert
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Revoked is a revoked certificate, which is included in a certificate
+// revocation list
+type Revoked struct {
+	Serial string    `json:"serial"`
+	Time   time.Time `json:"revoked_at"`
+	Reason Reason    `json:"reason"`
+}
+
+// Reason is the reason code for revoking a certificate, as defined in
+// RFC 5280 section 5.3.1
+type Reason int
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	ReasonUnspecified          Reason = 0
+	ReasonKeyCompromise        Reason = 1
+	ReasonCACompromise         Reason = 2
+	ReasonAffiliationChanged   Reason = 3
+	ReasonSuperseded           Reason = 4
+	ReasonCessationOfOperation Reason = 5
+	ReasonCertificateHold      Reason = 6
+	ReasonPrivilegeWithdrawn   Reason = 9
+	ReasonAACompromise         Reason = 10
+)
+
+var (
+	reasonNames = map[Reason]string{
+		ReasonUnspecified:          "unspecified",
+		ReasonKeyCompromise:        "keyCompromise",
+		ReasonCACompromise:         "cACompromise",
+		ReasonAffiliationChanged:   "affiliationChanged",
+		ReasonSuperseded:           "superseded",
+		ReasonCessationOfOperation: "cessationOfOperation",
+		ReasonCertificateHold:      "certificateHold",
+		ReasonPrivilegeWithdrawn:   "privilegeWithdrawn",
+		ReasonAACompromise:         "aACompromise",
+	}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// ParseReason returns a reason from a name (case-insensitive) or a number.
+// An empty string returns ReasonUnspecified
+func ParseReason(v string) (Reason, error) {
+	if v == "" {
+		return ReasonUnspecified, nil
+	}
+	if n, err := strconv.ParseUint(v, 10, 8); err == nil {
+		if _, exists := reasonNames[Reason(n)]; exists {
+			return Reason(n), nil
+		}
+	}
+	for reason, name := range reasonNames {
+		if strings.EqualFold(v, name) {
+			return reason, nil
+		}
+	}
+	return ReasonUnspecified, ErrBadParameter.Withf("invalid reason %q", v)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (r Reason) String() string {
+	if name, exists := reasonNames[r]; exists {
+		return name
+	}
+	return "Reason(" + strconv.Itoa(int(r)) + ")"
+}
+
+func (r Reason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Reason) UnmarshalText(data []byte) error {
+	if reason, err := ParseReason(string(data)); err != nil {
+		return err
+	} else {
+		*r = reason
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// WriteCRL writes a .pem file with a certificate revocation list, signed
+// by the certificate authority. The list is valid until the expiry
+// duration has passed, or the certificate authority expires
+func (c *Cert) WriteCRL(w io.Writer, revoked []Revoked, expiry time.Duration) error {
+	issuer, err := x509.ParseCertificate(c.data)
+	if err != nil {
+		return err
+	} else if !issuer.IsCA {
+		return ErrBadParameter.With("Invalid CA certificate")
+	}
+	signer, ok := c.privateKey.(crypto.Signer)
+	if !ok {
+		return ErrInternalAppError.With("private key cannot sign")
+	}
+
+	// The revocation list is numbered by the time it was created, so that
+	// the number increases every time the list is created
+	now := time.Now()
+	template := &x509.RevocationList{
+		Number:     big.NewInt(now.UnixNano()),
+		ThisUpdate: now,
+		NextUpdate: now.Add(expiry),
+	}
+	if template.NextUpdate.After(issuer.NotAfter) {
+		template.NextUpdate = issuer.NotAfter
+	}
+
+	// Add the revoked certificates
+	for _, entry := range revoked {
+		serial, ok := new(big.Int).SetString(entry.Serial, 10)
+		if !ok {
+			return ErrBadParameter.Withf("invalid serial %q", entry.Serial)
+		}
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: entry.Time,
+			ReasonCode:     int(entry.Reason),
+		})
+	}
+
+	// Create and write the revocation list
+	data, err := x509.CreateRevocationList(rand.Reader, template, issuer, signer)
+	if err != nil {
+		return err
+	}
+	return pem.Encode(w, &pem.Block{Type: "X509 CRL", Bytes: data})
+}
@@ -21,6 +21,7 @@ type opts struct {
 	Years, Months, Days int
 	IPAddresses         []net.IP
 	DNSNames            []string
+	CRL                 []string
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -98,3 +99,16 @@ func OptHosts(v ...string) Opt {
 		return nil
 	}
 }
+
+// Set the locations of the revocation list of the issuer
+func OptCRL(url ...string) Opt {
+	return func(o *opts) error {
+		for _, url := range url {
+			if url == "" {
+				return ErrBadParameter.Withf("OptCRL")
+			}
+			o.CRL = append(o.CRL, url)
+		}
+		return nil
+	}
+}
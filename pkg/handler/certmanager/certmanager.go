@@ -1,22 +1,31 @@
 package certmanager
 
-import ( // Packages
-	// Namespace imports
+import (
+	"crypto/x509"
 	"crypto/x509/pkix"
 	"errors"
+	"fmt"
+	"io"
 	"os"
+	"strings"
 	"time"
 
-	. "github.com/djthorpe/go-errors"
+	// Packages
 	"github.com/mutablelogic/go-server/pkg/handler/certmanager/cert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
 // TYPES
 
 type certmanager struct {
-	name  X509Name
-	store CertStorage
+	name        X509Name
+	store       CertStorage
+	renewBefore time.Duration
+	crlExpiry   time.Duration
+	url         string
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -26,6 +35,9 @@ type certmanager struct {
 func New(c Config) (*certmanager, error) {
 	task := new(certmanager)
 	task.name = c.X509Name
+	task.renewBefore = c.renewBefore()
+	task.crlExpiry = c.crlExpiry()
+	task.url = strings.TrimSuffix(c.URL, "/")
 
 	// Set storage for certificates
 	if c.CertStorage == nil {
@@ -129,9 +141,10 @@ func (task *certmanager) CreateSignedCert(commonName string, ca Cert, opts ...ce
 		}
 	}
 
-	// Append KeyType to options
+	// Append KeyType and revocation list to options
 	if ca != nil {
 		o = append(o, cert.OptKeyType(ca.KeyType()))
+		o = append(o, task.crl(ca)...)
 	}
 
 	// Create the certificate and store it
@@ -146,3 +159,193 @@ func (task *certmanager) CreateSignedCert(commonName string, ca Cert, opts ...ce
 	// Return success
 	return cert, nil
 }
+
+// Sign a certificate request with a certificate authority, and store the
+// certificate. The subject and hosts are copied from the request, and the
+// certificate is stored without a private key
+func (task *certmanager) SignRequest(csr *x509.CertificateRequest, ca Cert, opts ...cert.Opt) (Cert, error) {
+	// Default options
+	o := []cert.Opt{
+		cert.OptX509Name(pkix.Name{
+			OrganizationalUnit: []string{task.name.OrganizationalUnit},
+			Organization:       []string{task.name.Organization},
+			Locality:           []string{task.name.Locality},
+			Province:           []string{task.name.Province},
+			Country:            []string{task.name.Country},
+			StreetAddress:      []string{task.name.StreetAddress},
+			PostalCode:         []string{task.name.PostalCode},
+		}),
+	}
+
+	// Make the CA "concrete" by reading it
+	if ca == nil {
+		return nil, ErrBadParameter.With("Cannot sign without a valid CA")
+	}
+	ca, err := task.Read(ca.Serial())
+	if err != nil {
+		return nil, err
+	} else if !ca.IsCA() {
+		return nil, ErrBadParameter.With("Cannot sign without a valid CA")
+	}
+
+	// Append revocation list to options
+	o = append(o, task.crl(ca)...)
+
+	// Create the certificate and store it
+	ca_, _ := ca.(*cert.Cert)
+	cert, err := cert.NewFromRequest(csr, ca_, append(o, opts...)...)
+	if err != nil {
+		return nil, err
+	} else if err := task.store.Write(cert); err != nil {
+		return nil, err
+	}
+
+	// Return success
+	return cert, nil
+}
+
+// Renew a certificate or certificate authority, with the same subject, hosts
+// and key, and a new validity period. The renewed certificate replaces the
+// existing certificate, and has a new serial number. The serial number of
+// the existing certificate continues to refer to the renewed certificate, so
+// that configurations which refer to it do not change
+func (task *certmanager) Renew(c Cert) (Cert, error) {
+	// Make the certificate "concrete" by reading it
+	c, err := task.Read(c.Serial())
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the issuer
+	var ca_ *cert.Cert
+	if !c.IsCA() {
+		if ca, err := task.issuer(c); err != nil {
+			return nil, err
+		} else if ca != nil {
+			ca_, _ = ca.(*cert.Cert)
+		}
+	}
+
+	// Renew the certificate, store it and remove the existing certificate
+	c_, _ := c.(*cert.Cert)
+	if c_ == nil {
+		return nil, ErrInternalAppError.With("Cannot renew certificate")
+	}
+	renewed, err := c_.Renew(ca_)
+	if err != nil {
+		return nil, err
+	} else if err := task.store.Replace(c, renewed); err != nil {
+		return nil, err
+	}
+
+	// Return success
+	return renewed, nil
+}
+
+// Revoke a certificate with a reason, which adds the certificate to the
+// revocation list of the issuer and deletes it. A certificate authority
+// cannot be revoked
+func (task *certmanager) Revoke(c Cert, reason cert.Reason) error {
+	if c.IsCA() {
+		return ErrBadParameter.With("Cannot revoke a CA")
+	}
+
+	// Record the revocation with the issuer, unless self-signed
+	if ca, err := task.issuer(c); err != nil {
+		return err
+	} else if ca != nil {
+		if err := task.store.Revoke(ca.KeyId(), cert.Revoked{
+			Serial: c.Serial(),
+			Time:   time.Now(),
+			Reason: reason,
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Delete the certificate
+	return task.store.Delete(c)
+}
+
+// Write a revocation list for a certificate authority, signed by the
+// certificate authority
+func (task *certmanager) WriteCRL(w io.Writer, ca Cert) error {
+	if !ca.IsCA() {
+		return ErrBadParameter.With("Not a CA")
+	}
+
+	// Make the CA "concrete" by reading it
+	ca, err := task.Read(ca.Serial())
+	if err != nil {
+		return err
+	}
+	ca_, _ := ca.(*cert.Cert)
+	if ca_ == nil {
+		return ErrInternalAppError.With("Cannot sign revocation list")
+	}
+
+	// Write the revocation list
+	revoked, err := task.store.Revoked(ca.KeyId())
+	if err != nil {
+		return err
+	}
+	return ca_.WriteCRL(w, revoked, task.crlExpiry)
+}
+
+/////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the option which sets the location of the revocation list of a
+// certificate authority, or nil if the URL of the service is not set. The
+// serial number of the certificate authority continues to refer to it
+// when it is renewed
+func (task *certmanager) crl(ca Cert) []cert.Opt {
+	if task.url == "" {
+		return nil
+	}
+	return []cert.Opt{cert.OptCRL(task.url + "/" + ca.Serial() + "/crl.pem")}
+}
+
+// Return the certificate authority which issued a certificate, or nil
+// if the certificate is self-signed
+func (task *certmanager) issuer(c Cert) (Cert, error) {
+	keyId := c.IssuerKeyId()
+	if keyId == "" {
+		return nil, nil
+	}
+	for _, ca := range task.List() {
+		if ca.IsCA() && ca.KeyId() == keyId {
+			return task.Read(ca.Serial())
+		}
+	}
+	return nil, ErrNotFound.Withf("CA for %q", c.Serial())
+}
+
+// Renew certificates which expire soon, and return the renewed certificates.
+// Certificate authorities are not renewed, as relying parties which trust a
+// certificate authority would need to trust the renewed certificate authority
+func (task *certmanager) renew() ([]Cert, error) {
+	var result error
+	var renewed []Cert
+
+	// Determine which certificates expire soon
+	expires := time.Now().Add(task.renewBefore)
+	for _, c := range task.List() {
+		if c.IsCA() || c.Expires().After(expires) {
+			continue
+		}
+		if c_, ok := c.(*cert.Cert); ok && c_.Validity() <= task.renewBefore {
+			// A renewed certificate would also expire soon
+			result = errors.Join(result, fmt.Errorf("%q: validity is shorter than the renewal period", c.Serial()))
+			continue
+		}
+		if cert, err := task.Renew(c); err != nil {
+			result = errors.Join(result, fmt.Errorf("%q: %w", c.Serial(), err))
+		} else {
+			renewed = append(renewed, cert)
+		}
+	}
+
+	// Return renewed certificates and any errors
+	return renewed, result
+}
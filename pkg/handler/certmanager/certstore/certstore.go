@@ -1,12 +1,14 @@
 package certstore
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 
 	// Packages
@@ -22,6 +24,8 @@ import (
 
 // CertStore represents a certificate store
 type certstore struct {
+	sync.Mutex
+
 	// Root of the file storage
 	dataPath string
 
@@ -105,10 +109,21 @@ func (c *certstore) List() ([]certmanager.Cert, error) {
 	return certs, result
 }
 
-// Read a certificate
+// Read a certificate. The serial number of a certificate which has been
+// renewed returns the renewed certificate
 func (c *certstore) Read(serial string) (certmanager.Cert, error) {
-	// Check for file
+	// Check for file, or a renewed certificate
 	pathForCert, err := c.pathForKey(serial)
+	if errors.Is(err, os.ErrNotExist) {
+		c.Lock()
+		renewed, err_ := c.readRenewed()
+		c.Unlock()
+		if err_ != nil {
+			return nil, err_
+		} else if alias, exists := renewed[serial]; exists {
+			pathForCert, err = c.pathForKey(alias)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -151,7 +166,7 @@ func (c *certstore) Write(cert certmanager.Cert) error {
 	// Write the certificate, set mode and group
 	if err := cert.WriteCertificate(fh); err != nil {
 		return errors.Join(err, os.Remove(pathForCert))
-	} else if err := cert.WritePrivateKey(fh); err != nil {
+	} else if err := cert.WritePrivateKey(fh); err != nil && !errors.Is(err, ErrNotFound) {
 		return errors.Join(err, os.Remove(pathForCert))
 	} else if err := os.Chown(pathForCert, -1, c.fileGroup); err != nil {
 		return errors.Join(err, os.Remove(pathForCert))
@@ -163,7 +178,7 @@ func (c *certstore) Write(cert certmanager.Cert) error {
 	return nil
 }
 
-// Delete a certificate
+// Delete a certificate, and the serial numbers which refer to it
 func (c *certstore) Delete(cert certmanager.Cert) error {
 	pathForCert, err := c.pathForKey(cert.Serial())
 
@@ -174,12 +189,156 @@ func (c *certstore) Delete(cert certmanager.Cert) error {
 		return err
 	}
 
+	// Remove the serial numbers which refer to the certificate
+	c.Lock()
+	defer c.Unlock()
+	renewed, err := c.readRenewed()
+	if err != nil {
+		return err
+	}
+	var changed bool
+	for serial, alias := range renewed {
+		if alias == cert.Serial() {
+			delete(renewed, serial)
+			changed = true
+		}
+	}
+	if changed {
+		if err := c.writeJSON(renewedFile, renewed); err != nil {
+			return err
+		}
+	}
+
 	return os.Remove(pathForCert)
 }
 
+// Replace a certificate with a renewed certificate. The serial number of the
+// replaced certificate, and any serial numbers which referred to it, refer
+// to the renewed certificate afterwards
+func (c *certstore) Replace(cert, renewed certmanager.Cert) error {
+	pathForCert, err := c.pathForKey(cert.Serial())
+	if err != nil {
+		return err
+	} else if err := c.Write(renewed); err != nil {
+		return err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	// Update the serial numbers which refer to the certificate
+	aliases, err := c.readRenewed()
+	if err != nil {
+		return err
+	}
+	for serial, alias := range aliases {
+		if alias == cert.Serial() {
+			aliases[serial] = renewed.Serial()
+		}
+	}
+	aliases[cert.Serial()] = renewed.Serial()
+	if err := c.writeJSON(renewedFile, aliases); err != nil {
+		return err
+	}
+
+	// Remove the certificate
+	return os.Remove(pathForCert)
+}
+
+// Record a revoked certificate for an issuer
+func (c *certstore) Revoke(issuer string, revoked cert.Revoked) error {
+	c.Lock()
+	defer c.Unlock()
+
+	// Read the revocation list
+	revocations, err := c.readRevoked()
+	if err != nil {
+		return err
+	}
+
+	// Check for existing revocation
+	for _, entry := range revocations[issuer] {
+		if entry.Serial == revoked.Serial {
+			return ErrDuplicateEntry.With(revoked.Serial)
+		}
+	}
+
+	// Append and write the revocation list
+	revocations[issuer] = append(revocations[issuer], revoked)
+	return c.writeJSON(revokedFile, revocations)
+}
+
+// Return the revoked certificates for an issuer
+func (c *certstore) Revoked(issuer string) ([]cert.Revoked, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if revocations, err := c.readRevoked(); err != nil {
+		return nil, err
+	} else {
+		return revocations[issuer], nil
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// Read the revoked certificates for all issuers
+func (c *certstore) readRevoked() (map[string][]cert.Revoked, error) {
+	revocations := make(map[string][]cert.Revoked)
+	if err := c.readJSON(revokedFile, &revocations); err != nil {
+		return nil, err
+	}
+	return revocations, nil
+}
+
+// Read the serial numbers of renewed certificates, which map to the serial
+// number of the certificate which replaced them
+func (c *certstore) readRenewed() (map[string]string, error) {
+	renewed := make(map[string]string)
+	if err := c.readJSON(renewedFile, &renewed); err != nil {
+		return nil, err
+	}
+	return renewed, nil
+}
+
+// Read a JSON file in the data path, or leave the value unchanged if the
+// file does not exist
+func (c *certstore) readJSON(name string, v any) error {
+	data, err := os.ReadFile(filepath.Join(c.dataPath, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Write a JSON file in the data path, replacing the existing file once the
+// data has been written
+func (c *certstore) writeJSON(name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(c.dataPath, name)
+	fh, err := os.CreateTemp(c.dataPath, "."+name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fh.Name())
+	if _, err := fh.Write(data); err != nil {
+		return errors.Join(err, fh.Close())
+	} else if err := fh.Close(); err != nil {
+		return err
+	} else if err := os.Chown(fh.Name(), -1, c.fileGroup); err != nil {
+		return err
+	} else if err := os.Chmod(fh.Name(), c.fileMode); err != nil {
+		return err
+	}
+	return os.Rename(fh.Name(), path)
+}
+
 // Returns the path for a certificate, and a boolean value which indicates
 // if the folder exists
 func (c *certstore) pathForKey(serial string) (string, error) {
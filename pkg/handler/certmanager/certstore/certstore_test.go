@@ -1,14 +1,19 @@
 package certstore_test
 
 import (
+	"os"
 	"os/user"
 	"path/filepath"
 	"testing"
+	"time"
 
 	// Packages
 	"github.com/mutablelogic/go-server/pkg/handler/certmanager/cert"
 	"github.com/mutablelogic/go-server/pkg/handler/certmanager/certstore"
 	"github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
 )
 
 func Test_certstore_001(t *testing.T) {
@@ -100,3 +105,87 @@ func Test_certstore_003(t *testing.T) {
 
 	t.Log(certs)
 }
+
+func Test_certstore_004(t *testing.T) {
+	assert := assert.New(t)
+
+	store, err := certstore.New(certstore.Config{
+		DataPath: t.TempDir(),
+	})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// No revoked certificates
+	revoked, err := store.Revoked("issuer")
+	assert.NoError(err)
+	assert.Empty(revoked)
+
+	// Revoke certificates
+	assert.NoError(store.Revoke("issuer", cert.Revoked{Serial: "1", Time: time.Now(), Reason: cert.ReasonSuperseded}))
+	assert.NoError(store.Revoke("issuer", cert.Revoked{Serial: "2", Time: time.Now()}))
+	assert.ErrorIs(store.Revoke("issuer", cert.Revoked{Serial: "2", Time: time.Now()}), ErrDuplicateEntry)
+	assert.NoError(store.Revoke("other", cert.Revoked{Serial: "2", Time: time.Now()}))
+
+	// Read revoked certificates
+	revoked, err = store.Revoked("issuer")
+	assert.NoError(err)
+	if assert.Len(revoked, 2) {
+		assert.Equal("1", revoked[0].Serial)
+		assert.Equal(cert.ReasonSuperseded, revoked[0].Reason)
+		assert.Equal("2", revoked[1].Serial)
+	}
+
+	// Revocations are not listed as certificates
+	certs, err := store.List()
+	assert.NoError(err)
+	assert.Empty(certs)
+}
+
+func Test_certstore_005(t *testing.T) {
+	assert := assert.New(t)
+
+	store, err := certstore.New(certstore.Config{
+		DataPath: t.TempDir(),
+	})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Create a certificate, and renew it twice
+	ca, err := cert.NewCA(t.Name())
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.NoError(store.Write(ca))
+	renewed, err := ca.Renew(nil)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.NoError(store.Replace(ca, renewed))
+	renewed2, err := renewed.Renew(nil)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.NoError(store.Replace(renewed, renewed2))
+
+	// Only the renewed certificate is listed
+	certs, err := store.List()
+	assert.NoError(err)
+	if assert.Len(certs, 1) {
+		assert.Equal(renewed2.Serial(), certs[0].Serial())
+	}
+
+	// The serial numbers of the replaced certificates read the renewed certificate
+	for _, serial := range []string{ca.Serial(), renewed.Serial(), renewed2.Serial()} {
+		c, err := store.Read(serial)
+		if assert.NoError(err) {
+			assert.Equal(renewed2.Serial(), c.Serial())
+		}
+	}
+
+	// Deleting the renewed certificate removes the serial numbers
+	assert.NoError(store.Delete(renewed2))
+	_, err = store.Read(ca.Serial())
+	assert.ErrorIs(err, os.ErrNotExist)
+}
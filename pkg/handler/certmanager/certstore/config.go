@@ -32,6 +32,8 @@ const (
 	allDirMode      = os.FileMode(0777)
 	allFileMode     = os.FileMode(0666)
 	certExt         = ".pem"
+	revokedFile     = "revoked.json"
+	renewedFile     = "renewed.json"
 )
 
 const (
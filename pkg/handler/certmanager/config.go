@@ -1,6 +1,8 @@
 package certmanager
 
 import (
+	"time"
+
 	// Packages
 	server "github.com/mutablelogic/go-server"
 )
@@ -10,7 +12,10 @@ import (
 
 type Config struct {
 	X509Name    `hcl:"x509_name" description:"X509 name for certificate"`
-	CertStorage CertStorage `hcl:"cert_storage" description:"Certificate storage"`
+	CertStorage CertStorage   `hcl:"cert_storage" description:"Certificate storage"`
+	RenewBefore time.Duration `hcl:"renew_before" description:"Renew certificates which expire within this period (default 336h), or negative to disable"`
+	CRLExpiry   time.Duration `hcl:"crl_expiry" description:"Period of validity for certificate revocation lists (default 24h)"`
+	URL         string        `hcl:"url" description:"URL of the service, which is added to signed certificates as the location of the revocation list (for example, https://ca.internal/certmanager)"`
 }
 
 type X509Name struct {
@@ -30,7 +35,10 @@ var _ server.Plugin = Config{}
 // GLOBALS
 
 const (
-	defaultName = "certmanager"
+	defaultName          = "certmanager"
+	defaultRenewBefore   = 14 * 24 * time.Hour
+	defaultRenewInterval = time.Hour
+	defaultCRLExpiry     = 24 * time.Hour
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -50,3 +58,28 @@ func (Config) Description() string {
 func (c Config) New() (server.Task, error) {
 	return New(c)
 }
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the period before expiry when certificates are renewed, or zero
+// if certificates should not be renewed
+func (c Config) renewBefore() time.Duration {
+	switch {
+	case c.RenewBefore < 0:
+		return 0
+	case c.RenewBefore == 0:
+		return defaultRenewBefore
+	default:
+		return c.RenewBefore
+	}
+}
+
+// Return the period of validity for revocation lists
+func (c Config) crlExpiry() time.Duration {
+	if c.CRLExpiry > 0 {
+		return c.CRLExpiry
+	} else {
+		return defaultCRLExpiry
+	}
+}
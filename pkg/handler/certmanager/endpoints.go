@@ -9,6 +9,8 @@ import (
 
 	// Packages
 	server "github.com/mutablelogic/go-server"
+	auth "github.com/mutablelogic/go-server/pkg/handler/auth"
+	cert "github.com/mutablelogic/go-server/pkg/handler/certmanager/cert"
 	router "github.com/mutablelogic/go-server/pkg/handler/router"
 	httprequest "github.com/mutablelogic/go-server/pkg/httprequest"
 	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
@@ -52,8 +54,10 @@ const (
 var (
 	reRoot   = regexp.MustCompile(`^/?$`)
 	reCA     = regexp.MustCompile(`^/ca/?$`)
-	reSerial = regexp.MustCompile(`^/([0-9]+)/?$`)
-	rePem    = regexp.MustCompile(`^/([0-9]+)/(cert|key)\.pem$`)
+	reSerial = regexp.MustCompile(`^/(?P<serial>[0-9]+)/?$`)
+	reRenew  = regexp.MustCompile(`^/(?P<serial>[0-9]+)/renew/?$`)
+	rePem    = regexp.MustCompile(`^/(?P<serial>[0-9]+)/(?P<type>cert|key)\.pem$`)
+	reCRL    = regexp.MustCompile(`^/(?P<serial>[0-9]+)/crl\.pem$`)
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -64,37 +68,61 @@ func (service *certmanager) AddEndpoints(ctx context.Context, r server.Router) {
 	// Path: /
 	// Methods: GET
 	// Scopes: read
-	// Description: Return all existing certificates
 	r.AddHandlerFuncRe(ctx, reRoot, service.reqListCerts, http.MethodGet).(router.Route).
-		SetScope(service.ScopeRead()...)
+		SetScope(service.ScopeRead()...).
+		SetSummary("Return all existing certificates")
 
 	// Path: /
 	// Methods: POST
 	// Scopes: write
-	// Description: Create a new certificate
 	r.AddHandlerFuncRe(ctx, reRoot, service.reqCreateCert, http.MethodPost).(router.Route).
-		SetScope(service.ScopeWrite()...)
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Create a new certificate").
+		SetRequest(reqCreateCert{})
 
 	// Path: /ca
 	// Methods: POST
 	// Scopes: write
-	// Description: Create a new certificate authority
 	r.AddHandlerFuncRe(ctx, reCA, service.reqCreateCA, http.MethodPost).(router.Route).
-		SetScope(service.ScopeWrite()...)
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Create a new certificate authority").
+		SetRequest(reqCreateCA{})
 
 	// Path: /<serial>
 	// Methods: GET
 	// Scopes: read
-	// Description: Read a certificate by serial number
 	r.AddHandlerFuncRe(ctx, reSerial, service.reqGetCert, http.MethodGet).(router.Route).
-		SetScope(service.ScopeRead()...)
+		SetScope(service.ScopeRead()...).
+		SetSummary("Read a certificate by serial number").
+		SetResponse(http.StatusOK, respCert{})
+
+	// Path: /<serial>
+	// Methods: DELETE
+	// Scopes: write
+	r.AddHandlerFuncRe(ctx, reSerial, service.reqDeleteCert, http.MethodDelete).(router.Route).
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Revoke a certificate with an optional reason, or delete a CA").
+		SetResponse(http.StatusOK, nil)
+
+	// Path: /<serial>/renew
+	// Methods: POST
+	// Scopes: write
+	r.AddHandlerFuncRe(ctx, reRenew, service.reqRenewCert, http.MethodPost).(router.Route).
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Renew a certificate or CA with a new validity period")
 
 	// Path: /<serial>/key or /<serial>/cert
 	// Methods: GET
 	// Scopes: read
-	// Description: Read a PEM file for a certificate or key by serial number
 	r.AddHandlerFuncRe(ctx, rePem, service.reqGetCertPEM, http.MethodGet).(router.Route).
-		SetScope(service.ScopeRead()...)
+		SetScope(service.ScopeRead()...).
+		SetSummary("Read a PEM file for a certificate or key by serial number")
+
+	// Path: /<serial>/crl.pem
+	// Methods: GET
+	// Scopes: (none, and no token is required)
+	r.AddHandlerFuncRe(auth.WithPublic(ctx), reCRL, service.reqGetCRL, http.MethodGet).(router.Route).
+		SetSummary("Read the signed certificate revocation list for a CA")
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -138,9 +166,9 @@ func (service *certmanager) reqGetCert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Add private key if it's not a CA
+	// Add private key if it's not a CA, and the certificate has a private key
 	if !cert.IsCA() {
-		if err := cert.WritePrivateKey(&keydata); err != nil {
+		if err := cert.WritePrivateKey(&keydata); err != nil && !errors.Is(err, ErrNotFound) {
 			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
 			return
 		}
@@ -181,10 +209,15 @@ func (service *certmanager) reqGetCertPEM(w http.ResponseWriter, r *http.Request
 			httpresponse.Error(w, http.StatusForbidden, "Cannot return private key for CA")
 			return
 		}
-		if err := cert.WritePrivateKey(w); err != nil {
+		var data bytes.Buffer
+		if err := cert.WritePrivateKey(&data); errors.Is(err, ErrNotFound) {
+			httpresponse.Error(w, http.StatusNotFound, err.Error())
+			return
+		} else if err != nil {
 			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+		w.Write(data.Bytes())
 	}
 }
 
@@ -237,3 +270,91 @@ func (service *certmanager) reqCreateCert(w http.ResponseWriter, r *http.Request
 		httpresponse.JSON(w, cert, http.StatusOK, jsonIndent)
 	}
 }
+
+// Revoke a certificate, or delete a CA
+func (service *certmanager) reqDeleteCert(w http.ResponseWriter, r *http.Request) {
+	urlParameters := router.Params(r.Context())
+
+	// Get the reason for revocation
+	reason, err := cert.ParseReason(r.URL.Query().Get("reason"))
+	if err != nil {
+		httpresponse.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Get the certificate
+	c, err := service.Read(urlParameters[0])
+	if errors.Is(err, ErrNotFound) {
+		httpresponse.Error(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Delete a CA, or revoke a certificate
+	if c.IsCA() {
+		err = service.Delete(c)
+	} else {
+		err = service.Revoke(c, reason)
+	}
+	if errors.Is(err, ErrNotFound) {
+		httpresponse.Error(w, http.StatusNotFound, err.Error())
+	} else if err != nil {
+		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+	} else {
+		httpresponse.Empty(w, http.StatusOK)
+	}
+}
+
+// Renew a certificate or CA
+func (service *certmanager) reqRenewCert(w http.ResponseWriter, r *http.Request) {
+	urlParameters := router.Params(r.Context())
+
+	// Get the certificate
+	c, err := service.Read(urlParameters[0])
+	if errors.Is(err, ErrNotFound) {
+		httpresponse.Error(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Renew the certificate
+	if renewed, err := service.Renew(c); errors.Is(err, ErrNotFound) {
+		httpresponse.Error(w, http.StatusNotFound, err.Error())
+	} else if err != nil {
+		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+	} else {
+		httpresponse.JSON(w, renewed, http.StatusOK, jsonIndent)
+	}
+}
+
+// Get the certificate revocation list for a CA as a PEM file
+func (service *certmanager) reqGetCRL(w http.ResponseWriter, r *http.Request) {
+	urlParameters := router.Params(r.Context())
+
+	// Get the CA
+	ca, err := service.Read(urlParameters[0])
+	if errors.Is(err, ErrNotFound) {
+		httpresponse.Error(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	} else if !ca.IsCA() {
+		httpresponse.Error(w, http.StatusBadRequest, "Not a CA")
+		return
+	}
+
+	// Write the revocation list
+	var data bytes.Buffer
+	if err := service.WriteCRL(&data, ca); err != nil {
+		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set(httpresponse.ContentTypeKey, mimetypePem)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data.Bytes())
+}
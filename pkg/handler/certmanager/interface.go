@@ -31,6 +31,11 @@ type Cert interface {
 	// Return the key type
 	KeyType() string
 
+	// Return the key identifier of the certificate, and the key identifier
+	// of the issuer (or empty if self-signed) as hex strings
+	KeyId() string
+	IssuerKeyId() string
+
 	// Write a .pem file with the certificate
 	WriteCertificate(w io.Writer) error
 
@@ -55,6 +60,17 @@ type CertStorage interface {
 
 	// Delete a certificate
 	Delete(Cert) error
+
+	// Replace a certificate with a renewed certificate. The serial number
+	// of the replaced certificate is read as the renewed certificate
+	Replace(Cert, Cert) error
+
+	// Record a revoked certificate for an issuer, which is identified by
+	// the key identifier of the certificate authority
+	Revoke(string, cert.Revoked) error
+
+	// Return the revoked certificates for an issuer
+	Revoked(string) ([]cert.Revoked, error)
 }
 
 // Ensure that Cert implements the certmanager.Cert interface
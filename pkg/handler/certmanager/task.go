@@ -2,9 +2,12 @@ package certmanager
 
 import (
 	"context"
+	"strconv"
+	"time"
 
 	// Packages
 	server "github.com/mutablelogic/go-server"
+	provider "github.com/mutablelogic/go-server/pkg/provider"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -12,6 +15,7 @@ import (
 
 // Check interfaces are satisfied
 var _ server.Task = (*certmanager)(nil)
+var _ server.Collector = (*certmanager)(nil)
 
 /////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
@@ -22,13 +26,52 @@ func (task *certmanager) Label() string {
 	return defaultName
 }
 
-// Run the task until the context is cancelled
+// Run the task until the context is cancelled, renewing certificates
+// which are close to expiry
 func (task *certmanager) Run(ctx context.Context) error {
 	var result error
 
+	// Renew certificates immediately, and then periodically
+	timer := time.NewTimer(time.Millisecond)
+	defer timer.Stop()
+
 	// Run the task until cancelled
-	<-ctx.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return result
+		case <-timer.C:
+			if task.renewBefore > 0 {
+				task.renewWithLogging(ctx)
+			}
+			timer.Reset(defaultRenewInterval)
+		}
+	}
+}
+
+// Write the expiry time of each certificate, as seconds since the epoch
+func (task *certmanager) Collect(ctx context.Context, w server.MetricWriter) {
+	label := provider.Label(ctx)
+	for _, cert := range task.List() {
+		w.Gauge("certmanager_certificate_expiry_timestamp_seconds", "Expiry time of the certificate", float64(cert.Expires().Unix()),
+			"task", label, "serial", cert.Serial(), "subject", cert.Subject(), "ca", strconv.FormatBool(cert.IsCA()))
+	}
+}
+
+/////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
 
-	// Return any errors
-	return result
+// Renew certificates which are close to expiry, and log the results
+func (task *certmanager) renewWithLogging(ctx context.Context) {
+	renewed, err := task.renew()
+	log := provider.Logger(ctx)
+	if log == nil {
+		return
+	}
+	for _, cert := range renewed {
+		log.Printf(ctx, "Renewed %q (serial %v, expires %v)", cert.Subject(), cert.Serial(), cert.Expires().Format(time.RFC3339))
+	}
+	if err != nil {
+		log.Print(ctx, "Renew: ", err)
+	}
 }
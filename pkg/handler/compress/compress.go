@@ -0,0 +1,213 @@
+/*
+implements middleware which compresses response bodies with an encoding
+negotiated from the Accept-Encoding header, and decompresses request bodies
+which have a Content-Encoding header
+*/
+package compress
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type compress struct {
+	// Encodings in order of preference
+	encodings []string
+
+	// Minimum size of a response body to compress
+	minSize int
+
+	// Content types to compress
+	contentTypes []string
+
+	// Maximum size of a decompressed request body
+	maxBody int64
+}
+
+// requestBody is a decompressed request body, which records whether the
+// maximum size was exceeded
+type requestBody struct {
+	io.ReadCloser
+	exceeded atomic.Bool
+}
+
+// Check interfaces are satisfied
+var _ server.Task = (*compress)(nil)
+var _ server.Middleware = (*compress)(nil)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	headerAcceptEncoding  = "Accept-Encoding"
+	headerCacheControl    = "Cache-Control"
+	headerContentEncoding = "Content-Encoding"
+	headerContentRange    = "Content-Range"
+	headerETag            = "ETag"
+	headerVary            = "Vary"
+	directiveNoTransform  = "no-transform"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a new compression task from the configuration
+func New(c Config) (*compress, error) {
+	self := new(compress)
+
+	// Set the encodings
+	if len(c.Encodings) == 0 {
+		self.encodings = defaultEncodings
+	} else {
+		for _, encoding := range c.Encodings {
+			encoding = strings.ToLower(encoding)
+			if _, exists := codecs[encoding]; !exists {
+				return nil, ErrBadParameter.Withf("encoding: %q", encoding)
+			} else if !slices.Contains(self.encodings, encoding) {
+				self.encodings = append(self.encodings, encoding)
+			}
+		}
+	}
+
+	// Set the minimum size
+	switch {
+	case c.MinSize < 0:
+		return nil, ErrBadParameter.With("min_size")
+	case c.MinSize == 0:
+		self.minSize = defaultMinSize
+	default:
+		self.minSize = c.MinSize
+	}
+
+	// Set the maximum size of a decompressed request body
+	switch {
+	case c.MaxBody < 0:
+		return nil, ErrBadParameter.With("max_body")
+	case c.MaxBody == 0:
+		self.maxBody = defaultMaxBody
+	default:
+		self.maxBody = c.MaxBody
+	}
+
+	// Set the content types
+	if len(c.ContentTypes) == 0 {
+		self.contentTypes = defaultContentTypes
+	} else {
+		for _, contentType := range c.ContentTypes {
+			self.contentTypes = append(self.contentTypes, strings.ToLower(contentType))
+		}
+	}
+
+	// Return success
+	return self, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - MIDDLEWARE
+
+// Wrap a handler so that the request body is decompressed, and the response
+// body is compressed when the client accepts an encoding. When a decompressed
+// request body exceeds the maximum size and the handler responds with an
+// error, the response has status 413 instead
+func (compress *compress) Wrap(ctx context.Context, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Decompress the request body
+		body, status, err := compress.decodeRequest(w, r)
+		if err != nil {
+			httpresponse.Error(w, status, err.Error())
+			return
+		}
+		defer r.Body.Close()
+
+		// Compress the response body
+		cw := compress.newResponseWriter(w, negotiate(r.Header.Get(headerAcceptEncoding), compress.encodings))
+		cw.body = body
+		defer cw.Close()
+
+		// Handle the request
+		next(cw, r)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Replace the body of a request with a decompressed body, which is limited
+// to the maximum size. Returns the body, or nil if the body is not
+// compressed, and the status and an error if the content encoding is not
+// supported
+func (compress *compress) decodeRequest(w http.ResponseWriter, r *http.Request) (*requestBody, int, error) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get(headerContentEncoding)))
+	if encoding == "" || encoding == EncodingIdentity || r.Body == nil || r.Body == http.NoBody {
+		return nil, 0, nil
+	}
+
+	// Only one encoding is supported
+	codec, exists := codecs[encoding]
+	if !exists || !slices.Contains(compress.encodings, encoding) {
+		return nil, http.StatusUnsupportedMediaType, ErrNotImplemented.Withf("content encoding %q", encoding)
+	}
+	decoder, err := codec.decoder(r.Body)
+	if err != nil {
+		return nil, http.StatusBadRequest, ErrBadParameter.Withf("content encoding %q: %v", encoding, err)
+	}
+
+	// Set the body, which has an unknown length
+	body := &requestBody{ReadCloser: http.MaxBytesReader(w, decoder, compress.maxBody)}
+	r.Body = body
+	r.ContentLength = -1
+	r.Header.Del(headerContentEncoding)
+	r.Header.Del(httpresponse.ContentLengthKey)
+
+	// Return success
+	return body, 0, nil
+}
+
+// Read the decompressed body, and record when the maximum size is exceeded
+func (body *requestBody) Read(data []byte) (int, error) {
+	n, err := body.ReadCloser.Read(data)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			body.exceeded.Store(true)
+		}
+	}
+	return n, err
+}
+
+// Return true if the maximum size of the body was exceeded
+func (body *requestBody) Exceeded() bool {
+	return body != nil && body.exceeded.Load()
+}
+
+// Return true if a content type is compressed
+func (compress *compress) compressible(contentType string) bool {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if contentType == "" {
+		return false
+	}
+	for _, allowed := range compress.contentTypes {
+		if strings.HasSuffix(allowed, "/") && strings.HasPrefix(contentType, allowed) {
+			return true
+		} else if contentType == allowed {
+			return true
+		}
+	}
+	return false
+}
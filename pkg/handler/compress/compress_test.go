@@ -0,0 +1,280 @@
+package compress_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	// Packages
+	brotli "github.com/andybalholm/brotli"
+	zstd "github.com/klauspost/compress/zstd"
+	server "github.com/mutablelogic/go-server"
+	compress "github.com/mutablelogic/go-server/pkg/handler/compress"
+	logger "github.com/mutablelogic/go-server/pkg/handler/logger"
+	httprequest "github.com/mutablelogic/go-server/pkg/httprequest"
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+	assert "github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+func Test_compress_001(t *testing.T) {
+	assert := assert.New(t)
+	assert.Implements((*server.Plugin)(nil), compress.Config{})
+
+	// Default configuration
+	task, err := compress.New(compress.Config{})
+	assert.NoError(err)
+	assert.NotNil(task)
+
+	// Invalid configuration
+	_, err = compress.New(compress.Config{Encodings: []string{"lzw"}})
+	assert.ErrorIs(err, ErrBadParameter)
+	_, err = compress.New(compress.Config{MinSize: -1})
+	assert.ErrorIs(err, ErrBadParameter)
+	_, err = compress.New(compress.Config{MaxBody: -1})
+	assert.ErrorIs(err, ErrBadParameter)
+}
+
+func Test_compress_002(t *testing.T) {
+	assert := assert.New(t)
+	task, err := compress.New(compress.Config{MinSize: 100})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	handler := task.Wrap(context.Background(), func(w http.ResponseWriter, r *http.Request) {
+		items := make([]string, 0, 100)
+		if r.URL.Query().Has("large") {
+			for len(items) < cap(items) {
+				items = append(items, "value")
+			}
+		}
+		if r.URL.Query().Has("etag") {
+			w.Header().Set("ETag", `"etag"`)
+		}
+		if r.URL.Query().Has("notransform") {
+			w.Header().Set("Cache-Control", "private, no-transform")
+		}
+		httpresponse.JSON(w, items, http.StatusOK, 0)
+	})
+	do := func(accept, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		req.Header.Set("Accept-Encoding", accept)
+		resp := httptest.NewRecorder()
+		handler(resp, req)
+		return resp
+	}
+	body := do("", "large").Body.String()
+	decode := func(encoding string, r io.Reader) string {
+		var reader io.Reader
+		switch encoding {
+		case "gzip":
+			gz, err := gzip.NewReader(r)
+			if !assert.NoError(err) {
+				return ""
+			}
+			reader = gz
+		case "br":
+			reader = brotli.NewReader(r)
+		case "zstd":
+			zr, err := zstd.NewReader(r)
+			if !assert.NoError(err) {
+				return ""
+			}
+			defer zr.Close()
+			reader = zr
+		default:
+			reader = r
+		}
+		data, err := io.ReadAll(reader)
+		assert.NoError(err)
+		return string(data)
+	}
+
+	// Negotiate the encoding, with quality values
+	for accept, encoding := range map[string]string{
+		"gzip":                     "gzip",
+		"gzip, br":                 "br",
+		"gzip, br, zstd":           "zstd",
+		"gzip;q=1.0, br;q=0.5":     "gzip",
+		"*":                        "zstd",
+		"zstd;q=0, *;q=0.1":        "br",
+		"identity":                 "",
+		"":                         "",
+		"gzip;q=0, deflate;q=0.1 ": "deflate",
+	} {
+		resp := do(accept, "large")
+		assert.Equal(http.StatusOK, resp.Code)
+		assert.Equal(encoding, resp.Header().Get("Content-Encoding"), accept)
+		assert.Equal("Accept-Encoding", resp.Header().Get("Vary"))
+		assert.Empty(resp.Header().Get("Content-Length"))
+		if encoding != "deflate" {
+			assert.Equal(body, decode(encoding, resp.Body), accept)
+		}
+	}
+
+	// Small bodies are not compressed
+	resp := do("gzip", "")
+	assert.Empty(resp.Header().Get("Content-Encoding"))
+	assert.Equal("[]\n", resp.Body.String())
+
+	// Compression weakens the entity tag, and responses which cannot be
+	// transformed are not compressed
+	resp = do("gzip", "large&etag")
+	assert.Equal("gzip", resp.Header().Get("Content-Encoding"))
+	assert.Equal(`W/"etag"`, resp.Header().Get("ETag"))
+	resp = do("gzip", "large&etag&notransform")
+	assert.Empty(resp.Header().Get("Content-Encoding"))
+	assert.Equal(`"etag"`, resp.Header().Get("ETag"))
+	assert.Equal(body, resp.Body.String())
+}
+
+func Test_compress_003(t *testing.T) {
+	assert := assert.New(t)
+	task, err := compress.New(compress.Config{MinSize: 10})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Content types which are not allowed are not compressed
+	handler := task.Wrap(context.Background(), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(bytes.Repeat([]byte{0}, 100))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp := httptest.NewRecorder()
+	handler(resp, req)
+	assert.Empty(resp.Header().Get("Content-Encoding"))
+	assert.Empty(resp.Header().Get("Vary"))
+	assert.Equal(100, resp.Body.Len())
+
+	// Responses without a body are not compressed
+	handler = task.Wrap(context.Background(), func(w http.ResponseWriter, r *http.Request) {
+		httpresponse.Empty(w, http.StatusNoContent)
+	})
+	resp = httptest.NewRecorder()
+	handler(resp, req)
+	assert.Equal(http.StatusNoContent, resp.Code)
+	assert.Empty(resp.Header().Get("Content-Encoding"))
+}
+
+func Test_compress_004(t *testing.T) {
+	assert := assert.New(t)
+	task, err := compress.New(compress.Config{})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// A flushed event stream is compressed, through the logger response writer
+	flushed := make(chan string, 1)
+	handler := task.Wrap(context.Background(), func(w http.ResponseWriter, r *http.Request) {
+		lw := logger.NewResponseWriter(w)
+		lw.Header().Set("Content-Type", "text/event-stream")
+		lw.Write([]byte("event: ping\n\n"))
+		lw.(http.Flusher).Flush()
+		flushed <- w.Header().Get("Content-Encoding")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp := httptest.NewRecorder()
+	handler(resp, req)
+	assert.Equal("gzip", <-flushed)
+	assert.True(resp.Flushed)
+
+	gz, err := gzip.NewReader(resp.Body)
+	if assert.NoError(err) {
+		data, err := io.ReadAll(gz)
+		assert.NoError(err)
+		assert.Equal("event: ping\n\n", string(data))
+	}
+}
+
+func Test_compress_005(t *testing.T) {
+	assert := assert.New(t)
+	task, err := compress.New(compress.Config{})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Request bodies are decompressed before they are read
+	handler := task.Wrap(context.Background(), func(w http.ResponseWriter, r *http.Request) {
+		var v struct {
+			Name string `json:"name"`
+		}
+		if err := httprequest.Body(&v, r); err != nil {
+			httpresponse.Error(w, http.StatusBadRequest, err.Error())
+		} else {
+			httpresponse.Text(w, v.Name, http.StatusOK)
+		}
+	})
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	gz.Write([]byte(`{"name":"value"}`))
+	gz.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	resp := httptest.NewRecorder()
+	handler(resp, req)
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal("value\n", resp.Body.String())
+
+	// Unsupported and invalid encodings are rejected
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"value"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "compress")
+	resp = httptest.NewRecorder()
+	handler(resp, req)
+	assert.Equal(http.StatusUnsupportedMediaType, resp.Code)
+
+	req.Header.Set("Content-Encoding", "gzip")
+	resp = httptest.NewRecorder()
+	handler(resp, req)
+	assert.Equal(http.StatusBadRequest, resp.Code)
+}
+
+func Test_compress_006(t *testing.T) {
+	assert := assert.New(t)
+	task, err := compress.New(compress.Config{MaxBody: 1024})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// A request body which decompresses to more than the maximum size is
+	// rejected, when the handler responds with an error
+	handler := task.Wrap(context.Background(), func(w http.ResponseWriter, r *http.Request) {
+		if data, err := io.ReadAll(r.Body); err != nil {
+			httpresponse.Error(w, http.StatusBadRequest, err.Error())
+		} else {
+			httpresponse.Text(w, strconv.Itoa(len(data)), http.StatusOK)
+		}
+	})
+	do := func(size int) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		gz := gzip.NewWriter(&body)
+		gz.Write(bytes.Repeat([]byte{'a'}, size))
+		gz.Close()
+		req := httptest.NewRequest(http.MethodPost, "/", &body)
+		req.Header.Set("Content-Encoding", "gzip")
+		resp := httptest.NewRecorder()
+		handler(resp, req)
+		return resp
+	}
+
+	resp := do(1024)
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal("1024\n", resp.Body.String())
+	resp = do(1 << 20)
+	assert.Equal(http.StatusRequestEntityTooLarge, resp.Code)
+	assert.Contains(resp.Body.String(), "request body too large")
+}
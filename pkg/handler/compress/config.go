@@ -0,0 +1,51 @@
+package compress
+
+import (
+	// Packages
+	server "github.com/mutablelogic/go-server"
+)
+
+////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type Config struct {
+	Encodings    []string `hcl:"encodings" description:"Encodings in order of preference, from zstd, br, gzip and deflate (default all, in that order)"`
+	MinSize      int      `hcl:"min_size" description:"Minimum size of a response body to compress, in bytes (default 1024)"`
+	ContentTypes []string `hcl:"content_types" description:"Content types to compress, where a type ending in / matches all subtypes (default text, JSON, javascript, XML and SVG)"`
+	MaxBody      int64    `hcl:"max_body" description:"Maximum size of a decompressed request body, in bytes (default 10MiB)"`
+}
+
+// Check interfaces are satisfied
+var _ server.Plugin = Config{}
+
+////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	defaultName    = "compress"
+	defaultMinSize = 1024
+	defaultMaxBody = 10 << 20
+)
+
+var (
+	defaultEncodings    = []string{EncodingZstd, EncodingBrotli, EncodingGzip, EncodingDeflate}
+	defaultContentTypes = []string{"text/", "application/json", "application/problem+json", "application/javascript", "application/xml", "image/svg+xml"}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Name returns the name of the service
+func (Config) Name() string {
+	return defaultName
+}
+
+// Description returns the description of the service
+func (Config) Description() string {
+	return "compresses responses and decompresses request bodies"
+}
+
+// Create a new task from the configuration
+func (c Config) New() (server.Task, error) {
+	return New(c)
+}
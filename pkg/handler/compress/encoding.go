@@ -0,0 +1,141 @@
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	// Packages
+	brotli "github.com/andybalholm/brotli"
+	zstd "github.com/klauspost/compress/zstd"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// encoder compresses a response body, and can be reused for another body
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// codec creates encoders and decoders for a content encoding
+type codec struct {
+	pool    sync.Pool
+	decoder func(io.Reader) (io.ReadCloser, error)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	EncodingZstd     = "zstd"
+	EncodingBrotli   = "br"
+	EncodingGzip     = "gzip"
+	EncodingDeflate  = "deflate"
+	EncodingIdentity = "identity"
+	encodingAny      = "*"
+)
+
+var (
+	codecs = map[string]*codec{
+		EncodingZstd: {
+			pool: sync.Pool{New: func() any {
+				// Errors are only returned for invalid options
+				encoder, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+				return encoder
+			}},
+			decoder: func(r io.Reader) (io.ReadCloser, error) {
+				if decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1)); err != nil {
+					return nil, err
+				} else {
+					return decoder.IOReadCloser(), nil
+				}
+			},
+		},
+		EncodingBrotli: {
+			pool: sync.Pool{New: func() any {
+				return brotli.NewWriter(nil)
+			}},
+			decoder: func(r io.Reader) (io.ReadCloser, error) {
+				return io.NopCloser(brotli.NewReader(r)), nil
+			},
+		},
+		EncodingGzip: {
+			pool: sync.Pool{New: func() any {
+				return gzip.NewWriter(nil)
+			}},
+			decoder: func(r io.Reader) (io.ReadCloser, error) {
+				return gzip.NewReader(r)
+			},
+		},
+		EncodingDeflate: {
+			pool: sync.Pool{New: func() any {
+				return zlib.NewWriter(nil)
+			}},
+			decoder: func(r io.Reader) (io.ReadCloser, error) {
+				return zlib.NewReader(r)
+			},
+		},
+	}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return an encoder from the pool which writes to w
+func (c *codec) get(w io.Writer) encoder {
+	encoder := c.pool.Get().(encoder)
+	encoder.Reset(w)
+	return encoder
+}
+
+// Return an encoder to the pool, once it has been closed
+func (c *codec) put(encoder encoder) {
+	encoder.Reset(nil)
+	c.pool.Put(encoder)
+}
+
+// Return the encoding with the highest quality in an Accept-Encoding header,
+// where encodings with the same quality are chosen in order of preference.
+// Returns an empty string if no encoding is acceptable
+func negotiate(header string, preference []string) string {
+	if header == "" {
+		return ""
+	}
+
+	// Parse the header into the quality for each encoding
+	quality := make(map[string]float64)
+	for _, value := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(value, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if key, value, found := strings.Cut(strings.TrimSpace(params), "="); found && strings.TrimSpace(key) == "q" {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = v
+			}
+		}
+		quality[name] = q
+	}
+
+	// Choose the encoding with the highest quality
+	var result string
+	var best float64
+	for _, encoding := range preference {
+		q, exists := quality[encoding]
+		if !exists {
+			q = quality[encodingAny]
+		}
+		if q > best {
+			result, best = encoding, q
+		}
+	}
+	return result
+}
@@ -0,0 +1,20 @@
+package compress
+
+import (
+	"context"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return the label for the task
+func (compress *compress) Label() string {
+	// TODO
+	return defaultName
+}
+
+// Run the task until the context is cancelled
+func (compress *compress) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,201 @@
+package compress
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	// Packages
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// responseWriter buffers the start of a response body, until there is enough
+// to decide whether to compress it. The body is then written through an
+// encoder, or written as-is
+type responseWriter struct {
+	http.ResponseWriter
+	compress *compress
+	encoding string
+	status   int
+	buf      []byte
+	decided  bool
+	codec    *codec
+	encoder  encoder
+
+	// The decompressed request body, and whether the response is replaced
+	// because the body exceeded the maximum size
+	body     *requestBody
+	rejected bool
+}
+
+// Check interfaces are satisfied
+var _ http.Flusher = (*responseWriter)(nil)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Return a response writer which compresses with an encoding, or does not
+// compress if the encoding is empty
+func (compress *compress) newResponseWriter(w http.ResponseWriter, encoding string) *responseWriter {
+	return &responseWriter{
+		ResponseWriter: w,
+		compress:       compress,
+		encoding:       encoding,
+	}
+}
+
+// Close writes any buffered body and completes the compressed body
+func (w *responseWriter) Close() error {
+	var result error
+	if w.rejected {
+		w.Header().Del(httpresponse.ContentLengthKey)
+		return httpresponse.Error(w.ResponseWriter, http.StatusRequestEntityTooLarge, "request body too large")
+	}
+	if !w.decided && (w.status != 0 || len(w.buf) > 0) {
+		result = errors.Join(result, w.decide(false))
+	}
+	if w.encoder != nil {
+		result = errors.Join(result, w.encoder.Close())
+		w.codec.put(w.encoder)
+		w.encoder = nil
+	}
+	return result
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// WriteHeader records the status, which is written when the body is written.
+// Informational responses are written immediately
+func (w *responseWriter) WriteHeader(status int) {
+	switch {
+	case status >= 100 && status < 200:
+		w.ResponseWriter.WriteHeader(status)
+	case w.status == 0:
+		w.status = status
+		w.rejected = status >= http.StatusBadRequest && w.body.Exceeded()
+	}
+}
+
+// Write the body, which is buffered until it reaches the minimum size
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.rejected {
+		return len(data), nil
+	}
+	if !w.decided {
+		w.buf = append(w.buf, data...)
+		if len(w.buf) < w.compress.minSize {
+			return len(data), nil
+		}
+		return len(data), w.decide(false)
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush the body to the client. A flush before the minimum size is reached
+// means the response is streamed, so it is compressed regardless of size
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.rejected {
+		return
+	}
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+	if w.encoder != nil {
+		if err := w.encoder.Flush(); err != nil {
+			return
+		}
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Satisfy http.ResponseController support
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Decide whether to compress the body, write the header and write any
+// buffered body
+func (w *responseWriter) decide(stream bool) error {
+	w.decided = true
+	header := w.Header()
+
+	// Set the content type, so that it is not detected from the compressed body
+	if header.Get(httpresponse.ContentTypeKey) == "" && len(w.buf) > 0 {
+		header.Set(httpresponse.ContentTypeKey, http.DetectContentType(w.buf))
+	}
+
+	// Compress the body when the content type is allowed, the status has a
+	// body which is not already encoded, and it is large enough. A response
+	// with Cache-Control: no-transform is not compressed, so that its ETag
+	// is not weakened
+	if w.compress.compressible(header.Get(httpresponse.ContentTypeKey)) && !noTransform(header) {
+		if !strings.Contains(strings.Join(header.Values(headerVary), ","), headerAcceptEncoding) {
+			header.Add(headerVary, headerAcceptEncoding)
+		}
+		if w.encoding != "" && hasBody(w.status) && header.Get(headerContentEncoding) == "" && header.Get(headerContentRange) == "" && (stream || len(w.buf) >= w.compress.minSize) {
+			w.codec = codecs[w.encoding]
+			w.encoder = w.codec.get(w.ResponseWriter)
+			header.Set(headerContentEncoding, w.encoding)
+			header.Del(httpresponse.ContentLengthKey)
+			if etag := header.Get(headerETag); etag != "" && !strings.HasPrefix(etag, "W/") {
+				header.Set(headerETag, "W/"+etag)
+			}
+		}
+	}
+
+	// Write the header and the buffered body
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	} else if w.encoder != nil {
+		_, err := w.encoder.Write(buf)
+		return err
+	} else {
+		_, err := w.ResponseWriter.Write(buf)
+		return err
+	}
+}
+
+// Return true if the Cache-Control header has the no-transform directive
+func noTransform(header http.Header) bool {
+	for _, value := range header.Values(headerCacheControl) {
+		for _, directive := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(directive), directiveNoTransform) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Return true if a response with a status has a body which can be compressed
+func hasBody(status int) bool {
+	switch {
+	case status < http.StatusOK:
+		return false
+	case status == http.StatusNoContent, status == http.StatusPartialContent, status == http.StatusNotModified:
+		return false
+	default:
+		return true
+	}
+}
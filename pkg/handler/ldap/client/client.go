@@ -0,0 +1,165 @@
+/*
+Implements an API client for the LDAP API (https://github.com/mutablelogic/go-server/pkg/handler/ldap)
+*/
+package client
+
+import (
+	// Packages
+	"github.com/mutablelogic/go-client"
+	"github.com/mutablelogic/go-server/pkg/handler/ldap"
+	"github.com/mutablelogic/go-server/pkg/handler/ldap/schema"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type Client struct {
+	*client.Client
+}
+
+// Request to create a user or group
+type reqCreate struct {
+	Name  string              `json:"name"`
+	Attrs map[string][]string `json:"attrs,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a new API client, providing the endpoint (ie, http://example.com/api/ldap)
+func New(endPoint string, opts ...client.ClientOpt) (*Client, error) {
+	// Create client
+	client, err := client.New(append(opts, client.OptEndpoint(endPoint))...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the client
+	return &Client{client}, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// METHODS - USERS
+
+// Return all users
+func (c *Client) ListUsers() ([]*schema.Object, error) {
+	var response []*schema.Object
+	if err := c.Do(nil, &response, client.OptPath("u")); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Return a user
+func (c *Client) GetUser(name string) (*schema.Object, error) {
+	var response schema.Object
+	if err := c.Do(nil, &response, client.OptPath("u", name)); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Create a user with attributes (for example, uidNumber, gidNumber or
+// description), and return the user
+func (c *Client) CreateUser(name string, attrs map[string][]string) (*schema.Object, error) {
+	var response schema.Object
+
+	// Request->Response
+	if payload, err := client.NewJSONRequest(reqCreate{Name: name, Attrs: attrs}); err != nil {
+		return nil, err
+	} else if err := c.Do(payload, &response, client.OptPath("u")); err != nil {
+		return nil, err
+	}
+
+	// Return success
+	return &response, nil
+}
+
+// Delete a user
+func (c *Client) DeleteUser(name string) error {
+	if err := c.Do(client.MethodDelete, nil, client.OptPath("u", name)); err != nil {
+		return err
+	}
+	// Return success
+	return nil
+}
+
+// Change the password for a user. The old password may be required by the
+// server. If the new password is empty, a password is generated and returned
+func (c *Client) ChangePassword(name, old, new string) (string, error) {
+	var response ldap.PasswordChange
+
+	// Request->Response
+	if payload, err := client.NewJSONRequest(ldap.PasswordChange{Old: old, New: new}); err != nil {
+		return "", err
+	} else if err := c.Do(payload, &response, client.OptPath("u", name, "password")); err != nil {
+		return "", err
+	}
+
+	// Return success
+	return response.New, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// METHODS - GROUPS
+
+// Return all groups
+func (c *Client) ListGroups() ([]*schema.Object, error) {
+	var response []*schema.Object
+	if err := c.Do(nil, &response, client.OptPath("g")); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Return a group
+func (c *Client) GetGroup(name string) (*schema.Object, error) {
+	var response schema.Object
+	if err := c.Do(nil, &response, client.OptPath("g", name)); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Create a group with attributes (for example, gidNumber or description),
+// and return the group
+func (c *Client) CreateGroup(name string, attrs map[string][]string) (*schema.Object, error) {
+	var response schema.Object
+
+	// Request->Response
+	if payload, err := client.NewJSONRequest(reqCreate{Name: name, Attrs: attrs}); err != nil {
+		return nil, err
+	} else if err := c.Do(payload, &response, client.OptPath("g")); err != nil {
+		return nil, err
+	}
+
+	// Return success
+	return &response, nil
+}
+
+// Delete a group
+func (c *Client) DeleteGroup(name string) error {
+	if err := c.Do(client.MethodDelete, nil, client.OptPath("g", name)); err != nil {
+		return err
+	}
+	// Return success
+	return nil
+}
+
+// Add a user to a group, and return the group
+func (c *Client) AddGroupUser(group, user string) (*schema.Object, error) {
+	var response schema.Object
+	if err := c.Do(client.MethodPut, &response, client.OptPath("g", group, user)); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Remove a user from a group, and return the group
+func (c *Client) RemoveGroupUser(group, user string) (*schema.Object, error) {
+	var response schema.Object
+	if err := c.Do(client.MethodDelete, &response, client.OptPath("g", group, user)); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
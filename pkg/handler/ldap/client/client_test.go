@@ -0,0 +1,97 @@
+package client_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	// Packages
+	client "github.com/mutablelogic/go-client"
+	ldap "github.com/mutablelogic/go-server/pkg/handler/ldap"
+	ldapclient "github.com/mutablelogic/go-server/pkg/handler/ldap/client"
+	schema "github.com/mutablelogic/go-server/pkg/handler/ldap/schema"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func Test_client_001(t *testing.T) {
+	assert := assert.New(t)
+	client, err := ldapclient.New("http://localhost/api/ldap", client.OptTrace(os.Stderr, true))
+	assert.NoError(err)
+	assert.NotNil(client)
+}
+
+func Test_client_002(t *testing.T) {
+	assert := assert.New(t)
+
+	// Respond with the request method and path
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/ldap/u", "/api/ldap/g":
+			if r.Method == http.MethodGet {
+				json.NewEncoder(w).Encode([]*schema.Object{schema.NewObject("cn=test")})
+				return
+			}
+			json.NewEncoder(w).Encode(schema.NewObject("cn=test"))
+		case "/api/ldap/u/test/password":
+			var req ldap.PasswordChange
+			json.NewDecoder(r.Body).Decode(&req)
+			json.NewEncoder(w).Encode(ldap.PasswordChange{New: "generated"})
+		default:
+			json.NewEncoder(w).Encode(schema.NewObject("cn=test"))
+		}
+	}))
+	defer server.Close()
+
+	client, err := ldapclient.New(server.URL + "/api/ldap/")
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Users
+	users, err := client.ListUsers()
+	assert.NoError(err)
+	assert.Len(users, 1)
+	user, err := client.CreateUser("test", nil)
+	assert.NoError(err)
+	assert.Equal("cn=test", user.DN)
+	_, err = client.GetUser("test")
+	assert.NoError(err)
+	password, err := client.ChangePassword("test", "", "")
+	assert.NoError(err)
+	assert.Equal("generated", password)
+	assert.NoError(client.DeleteUser("test"))
+
+	// Groups
+	groups, err := client.ListGroups()
+	assert.NoError(err)
+	assert.Len(groups, 1)
+	_, err = client.CreateGroup("group", map[string][]string{"description": {"A group"}})
+	assert.NoError(err)
+	_, err = client.GetGroup("group")
+	assert.NoError(err)
+	_, err = client.AddGroupUser("group", "test")
+	assert.NoError(err)
+	_, err = client.RemoveGroupUser("group", "test")
+	assert.NoError(err)
+	assert.NoError(client.DeleteGroup("group"))
+
+	// Check the requests
+	assert.Equal([]string{
+		"GET /api/ldap/u",
+		"POST /api/ldap/u",
+		"GET /api/ldap/u/test",
+		"POST /api/ldap/u/test/password",
+		"DELETE /api/ldap/u/test",
+		"GET /api/ldap/g",
+		"POST /api/ldap/g",
+		"GET /api/ldap/g/group",
+		"PUT /api/ldap/g/group/test",
+		"DELETE /api/ldap/g/group/test",
+		"DELETE /api/ldap/g/group",
+	}, requests)
+}
@@ -2,14 +2,22 @@ package ldap
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"regexp"
+	"strings"
 
 	// Packages
+	goldap "github.com/go-ldap/ldap/v3"
 	server "github.com/mutablelogic/go-server"
+	schema "github.com/mutablelogic/go-server/pkg/handler/ldap/schema"
 	router "github.com/mutablelogic/go-server/pkg/handler/router"
+	httprequest "github.com/mutablelogic/go-server/pkg/httprequest"
 	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
 	types "github.com/mutablelogic/go-server/pkg/types"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -39,6 +47,13 @@ type reqCreateGroup struct {
 	Attrs map[string][]string `json:"attrs,omitempty"`
 }
 
+// Request to change a password, and the response which contains the
+// generated password when the new password is empty
+type PasswordChange struct {
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // GLOBALS
 
@@ -47,10 +62,12 @@ const (
 )
 
 var (
-	reUsers  = regexp.MustCompile(`^/u/?$`)
-	reUser   = regexp.MustCompile(`^/u/(` + types.ReIdentifier + `)/?$`)
-	reGroups = regexp.MustCompile(`^/g/?$`)
-	reGroup  = regexp.MustCompile(`^/g/(` + types.ReIdentifier + `)/?$`)
+	reUsers    = regexp.MustCompile(`^/u/?$`)
+	reUser     = regexp.MustCompile(`^/u/(?P<user>` + types.ReIdentifier + `)/?$`)
+	rePassword = regexp.MustCompile(`^/u/(?P<user>` + types.ReIdentifier + `)/password/?$`)
+	reGroups   = regexp.MustCompile(`^/g/?$`)
+	reGroup    = regexp.MustCompile(`^/g/(?P<group>` + types.ReIdentifier + `)/?$`)
+	reMember   = regexp.MustCompile(`^/g/(?P<group>` + types.ReIdentifier + `)/(?P<user>` + types.ReIdentifier + `)/?$`)
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -61,32 +78,85 @@ func (service *ldap) AddEndpoints(ctx context.Context, r server.Router) {
 	// Path: /u
 	// Methods: GET
 	// Scopes: read
-	// Description: Return all users
 	r.AddHandlerFuncRe(ctx, reUsers, service.reqListUsers, http.MethodGet).(router.Route).
-		SetScope(service.ScopeRead()...)
+		SetScope(service.ScopeRead()...).
+		SetSummary("Return all users").
+		SetResponse(http.StatusOK, []schema.Object{})
+
+	// Path: /u
+	// Methods: POST
+	// Scopes: write
+	r.AddHandlerFuncRe(ctx, reUsers, service.reqNewUser, http.MethodPost).(router.Route).
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Create a user").
+		SetRequest(reqCreateUser{}).
+		SetResponse(http.StatusCreated, schema.Object{})
+
+	// Path: /u/<name>
+	// Methods: GET
+	// Scopes: read
+	r.AddHandlerFuncRe(ctx, reUser, service.reqGetUser, http.MethodGet).(router.Route).
+		SetScope(service.ScopeRead()...).
+		SetSummary("Return a user").
+		SetResponse(http.StatusOK, schema.Object{})
+
+	// Path: /u/<name>
+	// Methods: DELETE
+	// Scopes: write
+	r.AddHandlerFuncRe(ctx, reUser, service.reqDeleteUser, http.MethodDelete).(router.Route).
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Delete a user").
+		SetResponse(http.StatusOK, nil)
+
+	// Path: /u/<name>/password
+	// Methods: POST
+	// Scopes: write
+	r.AddHandlerFuncRe(ctx, rePassword, service.reqChangePassword, http.MethodPost).(router.Route).
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Change the password for a user, or generate a new password").
+		SetRequest(PasswordChange{}).
+		SetResponse(http.StatusOK, PasswordChange{})
 
 	// Path: /g
 	// Methods: GET
 	// Scopes: read
-	// Description: Return all groups
 	r.AddHandlerFuncRe(ctx, reGroups, service.reqListGroups, http.MethodGet).(router.Route).
-		SetScope(service.ScopeRead()...)
+		SetScope(service.ScopeRead()...).
+		SetSummary("Return all groups").
+		SetResponse(http.StatusOK, []schema.Object{})
+
+	// Path: /g
+	// Methods: POST
+	// Scopes: write
+	r.AddHandlerFuncRe(ctx, reGroups, service.reqNewGroup, http.MethodPost).(router.Route).
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Create a group").
+		SetRequest(reqCreateGroup{}).
+		SetResponse(http.StatusCreated, schema.Object{})
+
+	// Path: /g/<name>
+	// Methods: GET
+	// Scopes: read
+	r.AddHandlerFuncRe(ctx, reGroup, service.reqGetGroup, http.MethodGet).(router.Route).
+		SetScope(service.ScopeRead()...).
+		SetSummary("Return a group").
+		SetResponse(http.StatusOK, schema.Object{})
 
-	/*
-		// Path: /u/<name>
-		// Methods: GET
-		// Scopes: read
-		// Description: Return a user
-		r.AddHandlerFuncRe(ctx, reUser, service.reqGetUser, http.MethodGet).(router.Route).
-			SetScope(service.ScopeRead()...)
+	// Path: /g/<name>
+	// Methods: DELETE
+	// Scopes: write
+	r.AddHandlerFuncRe(ctx, reGroup, service.reqDeleteGroup, http.MethodDelete).(router.Route).
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Delete a group").
+		SetResponse(http.StatusOK, nil)
 
-		// Path: /g/<name>
-		// Methods: GET
-		// Scopes: read
-		// Description: Return a group
-		r.AddHandlerFuncRe(ctx, reGroup, service.reqGetGroup, http.MethodGet).(router.Route).
-			SetScope(service.ScopeRead()...)
-	*/
+	// Path: /g/<name>/<user>
+	// Methods: PUT, DELETE
+	// Scopes: write
+	r.AddHandlerFuncRe(ctx, reMember, service.reqGroupUser, http.MethodPut, http.MethodDelete).(router.Route).
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Add a user to a group, or remove a user from a group").
+		SetResponse(http.StatusOK, schema.Object{})
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -94,20 +164,262 @@ func (service *ldap) AddEndpoints(ctx context.Context, r server.Router) {
 
 // Get all users
 func (service *ldap) reqListUsers(w http.ResponseWriter, r *http.Request) {
-	list, err := service.GetUsers()
+	list, err := service.getUsers(r.Context())
 	if err != nil {
-		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		httpresponse.Error(w, errorStatus(err), err.Error())
 		return
 	}
 	httpresponse.JSON(w, list, http.StatusOK, jsonIndent)
 }
 
+// Get a user
+func (service *ldap) reqGetUser(w http.ResponseWriter, r *http.Request) {
+	urlParameters := router.Params(r.Context())
+	user, err := service.getUser(r.Context(), urlParameters[0])
+	if err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	httpresponse.JSON(w, user, http.StatusOK, jsonIndent)
+}
+
+// Create a user
+func (service *ldap) reqNewUser(w http.ResponseWriter, r *http.Request) {
+	var req reqCreateUser
+
+	// Get the request
+	if err := httprequest.Body(&req, r); err != nil {
+		httpresponse.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Check for a valid name
+	req.Name = strings.TrimSpace(req.Name)
+	if !types.IsIdentifier(req.Name) {
+		httpresponse.Error(w, http.StatusBadRequest, "invalid 'name'")
+		return
+	}
+
+	// Set the attributes
+	attrs := req.attrs()
+	if req.UserId > 0 {
+		attrs = append(attrs, schema.OptUserId(req.UserId))
+	}
+	if req.GroupId > 0 {
+		attrs = append(attrs, schema.OptGroupId(req.GroupId))
+	}
+	if req.Description != "" {
+		attrs = append(attrs, schema.OptDescription(req.Description))
+	}
+
+	// Create the user
+	user, err := service.createUser(r.Context(), req.Name, attrs...)
+	if err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+		return
+	}
+
+	// Return the user
+	httpresponse.JSON(w, user, http.StatusCreated, jsonIndent)
+}
+
+// Delete a user
+func (service *ldap) reqDeleteUser(w http.ResponseWriter, r *http.Request) {
+	urlParameters := router.Params(r.Context())
+	if user, err := service.getUser(r.Context(), urlParameters[0]); err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+		return
+	} else if err := service.delete(r.Context(), user); err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+		return
+	}
+
+	// Respond with no content
+	httpresponse.Empty(w, http.StatusOK)
+}
+
+// Change the password for a user. If the new password is empty, a password
+// is generated and returned
+func (service *ldap) reqChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req PasswordChange
+
+	// Get the request
+	if err := httprequest.Body(&req, r); err != nil {
+		httpresponse.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Change the password
+	urlParameters := router.Params(r.Context())
+	user, err := service.getUser(r.Context(), urlParameters[0])
+	if err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	password, err := service.changePassword(r.Context(), user, req.Old, req.New)
+	if err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+		return
+	}
+
+	// Return the generated password
+	httpresponse.JSON(w, PasswordChange{New: password}, http.StatusOK, jsonIndent)
+}
+
 // Get all groups
 func (service *ldap) reqListGroups(w http.ResponseWriter, r *http.Request) {
-	list, err := service.GetGroups()
+	list, err := service.getGroups(r.Context())
 	if err != nil {
-		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		httpresponse.Error(w, errorStatus(err), err.Error())
 		return
 	}
 	httpresponse.JSON(w, list, http.StatusOK, jsonIndent)
 }
+
+// Get a group
+func (service *ldap) reqGetGroup(w http.ResponseWriter, r *http.Request) {
+	urlParameters := router.Params(r.Context())
+	group, err := service.getGroup(r.Context(), urlParameters[0])
+	if err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	httpresponse.JSON(w, group, http.StatusOK, jsonIndent)
+}
+
+// Create a group
+func (service *ldap) reqNewGroup(w http.ResponseWriter, r *http.Request) {
+	var req reqCreateGroup
+
+	// Get the request
+	if err := httprequest.Body(&req, r); err != nil {
+		httpresponse.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Check for a valid name
+	req.Name = strings.TrimSpace(req.Name)
+	if !types.IsIdentifier(req.Name) {
+		httpresponse.Error(w, http.StatusBadRequest, "invalid 'name'")
+		return
+	}
+
+	// Set the attributes
+	attrs := req.attrs()
+	if req.GroupId > 0 {
+		attrs = append(attrs, schema.OptGroupId(req.GroupId))
+	}
+	if req.Description != "" {
+		attrs = append(attrs, schema.OptDescription(req.Description))
+	}
+
+	// Create the group
+	group, err := service.createGroup(r.Context(), req.Name, attrs...)
+	if err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+		return
+	}
+
+	// Return the group
+	httpresponse.JSON(w, group, http.StatusCreated, jsonIndent)
+}
+
+// Delete a group
+func (service *ldap) reqDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	urlParameters := router.Params(r.Context())
+	if group, err := service.getGroup(r.Context(), urlParameters[0]); err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+		return
+	} else if err := service.delete(r.Context(), group); err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+		return
+	}
+
+	// Respond with no content
+	httpresponse.Empty(w, http.StatusOK)
+}
+
+// Add a user to a group (PUT) or remove a user from a group (DELETE), and
+// return the group
+func (service *ldap) reqGroupUser(w http.ResponseWriter, r *http.Request) {
+	urlParameters := router.Params(r.Context())
+	group, err := service.getGroup(r.Context(), urlParameters[0])
+	if err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	user, err := service.getUser(r.Context(), urlParameters[1])
+	if err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+		return
+	}
+
+	// Add or remove the user
+	switch r.Method {
+	case http.MethodPut:
+		err = service.addGroupUser(r.Context(), user, group)
+	case http.MethodDelete:
+		err = service.removeGroupUser(r.Context(), user, group)
+	}
+	if err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+		return
+	}
+
+	// Return the updated group
+	if group, err := service.getGroup(r.Context(), urlParameters[0]); err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+	} else {
+		httpresponse.JSON(w, group, http.StatusOK, jsonIndent)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the other attributes for a new user
+func (req reqCreateUser) attrs() []schema.Attr {
+	attrs := make([]schema.Attr, 0, len(req.Attrs)+3)
+	for name, values := range req.Attrs {
+		attrs = append(attrs, schema.OptAttr(name, values...))
+	}
+	return attrs
+}
+
+// Return the other attributes for a new group
+func (req reqCreateGroup) attrs() []schema.Attr {
+	attrs := make([]schema.Attr, 0, len(req.Attrs)+2)
+	for name, values := range req.Attrs {
+		attrs = append(attrs, schema.OptAttr(name, values...))
+	}
+	return attrs
+}
+
+// Return the HTTP status code for an error
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrBadParameter):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNotAuthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrOutOfOrder):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrNotImplemented):
+		return http.StatusNotImplemented
+	}
+	switch ldapErrorCode(err) {
+	case goldap.LDAPResultNoSuchObject:
+		return http.StatusNotFound
+	case goldap.LDAPResultEntryAlreadyExists, goldap.LDAPResultAttributeOrValueExists:
+		return http.StatusConflict
+	case goldap.LDAPResultInsufficientAccessRights, goldap.LDAPResultInvalidCredentials:
+		return http.StatusForbidden
+	case goldap.LDAPResultObjectClassViolation, goldap.LDAPResultConstraintViolation,
+		goldap.LDAPResultInvalidAttributeSyntax, goldap.LDAPResultUndefinedAttributeType,
+		goldap.LDAPResultNotAllowedOnNonLeaf:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
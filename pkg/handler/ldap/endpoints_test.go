@@ -0,0 +1,78 @@
+package ldap_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	// Packages
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/mutablelogic/go-server/pkg/handler/ldap"
+	"github.com/mutablelogic/go-server/pkg/handler/ldap/schema"
+	"github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+func Test_endpoints_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// Errors are mapped to status codes, including wrapped errors and
+	// LDAP result codes
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{ErrBadParameter.With("name"), http.StatusBadRequest},
+		{ErrNotFound.With("name"), http.StatusNotFound},
+		{ErrNotAuthorized, http.StatusUnauthorized},
+		{ErrOutOfOrder.With("Not connected"), http.StatusServiceUnavailable},
+		{ErrNotImplemented, http.StatusNotImplemented},
+		{fmt.Errorf("wrapped: %w", ErrNotFound), http.StatusNotFound},
+		{goldap.NewError(goldap.LDAPResultNoSuchObject, errors.New("test")), http.StatusNotFound},
+		{goldap.NewError(goldap.LDAPResultEntryAlreadyExists, errors.New("test")), http.StatusConflict},
+		{goldap.NewError(goldap.LDAPResultAttributeOrValueExists, errors.New("test")), http.StatusConflict},
+		{goldap.NewError(goldap.LDAPResultInsufficientAccessRights, errors.New("test")), http.StatusForbidden},
+		{goldap.NewError(goldap.LDAPResultInvalidCredentials, errors.New("test")), http.StatusForbidden},
+		{goldap.NewError(goldap.LDAPResultObjectClassViolation, errors.New("test")), http.StatusBadRequest},
+		{goldap.NewError(goldap.LDAPResultNotAllowedOnNonLeaf, errors.New("test")), http.StatusBadRequest},
+		{goldap.NewError(goldap.LDAPResultBusy, errors.New("test")), http.StatusInternalServerError},
+		{fmt.Errorf("other"), http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		assert.Equal(test.code, ldap.ErrorStatus(test.err), test.err.Error())
+	}
+}
+
+func Test_endpoints_002(t *testing.T) {
+	assert := assert.New(t)
+	user := schema.NewObject("uid=test", "ou=users,dc=example,dc=com")
+	user.Values.Set("uid", "test")
+	user.Values.Set("cn", "Test User")
+
+	// Return a group with object classes
+	group := func(classes ...string) *schema.Object {
+		group := schema.NewObject("cn=group", "ou=groups,dc=example,dc=com")
+		for _, class := range classes {
+			group.Values.Add("objectClass", class)
+		}
+		return group
+	}
+
+	// Members are added with the attributes for the object classes of the group
+	assert.Equal(map[string]string{"uniqueMember": user.DN}, ldap.GroupMembers(user, group("top", "groupOfUniqueNames")))
+	assert.Equal(map[string]string{"member": user.DN}, ldap.GroupMembers(user, group("top", "groupOfNames")))
+	assert.Equal(map[string]string{"memberUid": "test"}, ldap.GroupMembers(user, group("posixGroup")))
+	assert.Equal(map[string]string{"memberUid": "test", "member": user.DN}, ldap.GroupMembers(user, group("posixGroup", "groupOfNames")))
+
+	// Object classes are case-insensitive, and groups without a known object
+	// class use the member attribute
+	assert.Equal(map[string]string{"memberUid": "test"}, ldap.GroupMembers(user, group("POSIXGROUP")))
+	assert.Equal(map[string]string{"member": user.DN}, ldap.GroupMembers(user, group()))
+
+	// The common name is used when the user has no uid
+	user.Values.Del("uid")
+	assert.Equal(map[string]string{"memberUid": "Test User"}, ldap.GroupMembers(user, group("posixGroup")))
+}
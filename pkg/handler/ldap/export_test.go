@@ -0,0 +1,7 @@
+package ldap
+
+// Export private functions for testing
+var (
+	ErrorStatus  = errorStatus
+	GroupMembers = groupMembers
+)
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 
 	// Packages
@@ -199,33 +200,8 @@ func (ldap *ldap) Get(objectClass string) ([]*schema.Object, error) {
 		return nil, ErrBadParameter.With("objectClass")
 	}
 
-	// Check connection
-	if ldap.conn == nil {
-		return nil, ErrOutOfOrder.With("Not connected")
-	}
-
-	// Define the search request
-	searchRequest := goldap.NewSearchRequest(
-		ldap.dn, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, 0, 0, false,
-		fmt.Sprint("(&(objectClass=", objectClass, "))"), // The filter to apply
-		nil, // The attributes to retrieve
-		nil,
-	)
-
-	// Perform the search
-	sr, err := ldap.conn.Search(searchRequest)
-	if err != nil {
-		return nil, err
-	}
-
-	// Print the results
-	result := make([]*schema.Object, 0, len(sr.Entries))
-	for _, entry := range sr.Entries {
-		result = append(result, schema.NewObjectFromEntry(entry))
-	}
-
-	// Return success
-	return result, nil
+	// Return the objects
+	return ldap.search(fmt.Sprint("(&(objectClass=", objectClass, "))"))
 }
 
 // Return all users
@@ -238,6 +214,43 @@ func (ldap *ldap) GetGroups() ([]*schema.Object, error) {
 	return ldap.Get(ldap.schema.GroupObjectClass[0])
 }
 
+// Return a user by name, or ErrNotFound
+func (ldap *ldap) GetUser(name string) (*schema.Object, error) {
+	if !schema.IsName(name) {
+		return nil, ErrBadParameter.With("name")
+	}
+	return ldap.getObject(ldap.schema.UserDN(ldap.dn, name))
+}
+
+// Return a group by name, or ErrNotFound
+func (ldap *ldap) GetGroup(name string) (*schema.Object, error) {
+	if !types.IsIdentifier(name) {
+		return nil, ErrBadParameter.With("name")
+	}
+	return ldap.getObject(ldap.schema.GroupDN(ldap.dn, name))
+}
+
+// Return the groups which a user is a member of
+func (ldap *ldap) GetUserGroups(user *schema.Object) ([]*schema.Object, error) {
+	ldap.Lock()
+	defer ldap.Unlock()
+
+	// Check object
+	if user == nil {
+		return nil, ErrBadParameter
+	}
+
+	// Match any of the membership attributes
+	filter := "(|(member=" + goldap.EscapeFilter(user.DN) + ")(uniqueMember=" + goldap.EscapeFilter(user.DN) + ")"
+	if uid := objectValues(user, "uid"); len(uid) > 0 {
+		filter += "(memberUid=" + goldap.EscapeFilter(uid[0]) + ")"
+	}
+	filter += ")"
+
+	// Return the groups
+	return ldap.search("(&(objectClass=" + ldap.schema.GroupObjectClass[0] + ")" + filter + ")")
+}
+
 // Create a group with the given attributes
 func (ldap *ldap) CreateGroup(group string, attrs ...schema.Attr) (*schema.Object, error) {
 	ldap.Lock()
@@ -256,17 +269,19 @@ func (ldap *ldap) CreateGroup(group string, attrs ...schema.Attr) (*schema.Objec
 
 	// If the gid is not set, then set it to the next available gid
 	var nextGid int
-	gid, err := ldap.SearchOne("(&(objectclass=device)(cn=lastgid))")
-	if err != nil {
-		return nil, err
-	} else if gid == nil {
-		return nil, ErrNotImplemented.With("lastgid not found")
-	} else if gid_, err := strconv.ParseInt(gid.Get("serialNumber"), 10, 32); err != nil {
-		return nil, ErrNotImplemented.With("lastgid not found")
-	} else {
-		nextGid = int(gid_) + 1
-		if err := schema.OptGroupId(int(gid_))(o); err != nil {
+	var gid *schema.Object
+	if o.GroupId() < 0 {
+		if gid, err = ldap.SearchOne("(&(objectclass=device)(cn=lastgid))"); err != nil {
 			return nil, err
+		} else if gid == nil {
+			return nil, ErrNotImplemented.With("lastgid not found")
+		} else if gid_, err := strconv.ParseInt(gid.Get("serialNumber"), 10, 32); err != nil {
+			return nil, ErrNotImplemented.With("lastgid not found")
+		} else {
+			nextGid = int(gid_) + 1
+			if err := schema.OptGroupId(int(gid_))(o); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -296,20 +311,66 @@ func (ldap *ldap) CreateGroup(group string, attrs ...schema.Attr) (*schema.Objec
 	return o, nil
 }
 
-// Add a user to a group
+// Add a user to a group. Adding a user which is already a member of the
+// group has no effect
 func (ldap *ldap) AddGroupUser(user, group *schema.Object) error {
-	// Use uniqueMember for groupOfUniqueNames,
-	// use memberUid for posixGroup
-	// use member for groupOfNames or if not posix
-	return ErrNotImplemented
+	ldap.Lock()
+	defer ldap.Unlock()
+
+	// Check objects
+	if user == nil || group == nil {
+		return ErrBadParameter
+	}
+
+	// Check connection
+	if ldap.conn == nil {
+		return ErrOutOfOrder.With("Not connected")
+	}
+
+	// Add the membership attributes which are not yet set
+	modify := goldap.NewModifyRequest(group.DN, []goldap.Control{})
+	for attr, value := range groupMembers(user, group) {
+		if !hasValue(objectValues(group, attr), value) {
+			modify.Add(attr, []string{value})
+		}
+	}
+	if len(modify.Changes) == 0 {
+		return nil
+	}
+
+	// Modify the group
+	return ldap.conn.Modify(modify)
 }
 
-// Remove a user from a group
+// Remove a user from a group. Returns ErrNotFound if the user is not
+// a member of the group
 func (ldap *ldap) RemoveGroupUser(user, group *schema.Object) error {
-	// Use uniqueMember for groupOfUniqueNames,
-	// use memberUid for posixGroup
-	// use member for groupOfNames or if not posix
-	return ErrNotImplemented
+	ldap.Lock()
+	defer ldap.Unlock()
+
+	// Check objects
+	if user == nil || group == nil {
+		return ErrBadParameter
+	}
+
+	// Check connection
+	if ldap.conn == nil {
+		return ErrOutOfOrder.With("Not connected")
+	}
+
+	// Remove the membership attributes which are set
+	modify := goldap.NewModifyRequest(group.DN, []goldap.Control{})
+	for attr, value := range groupMembers(user, group) {
+		if hasValue(objectValues(group, attr), value) {
+			modify.Delete(attr, []string{value})
+		}
+	}
+	if len(modify.Changes) == 0 {
+		return ErrNotFound.Withf("%q in %q", user.DN, group.DN)
+	}
+
+	// Modify the group
+	return ldap.conn.Modify(modify)
 }
 
 // Change a passsord for a user. If the new password is empty, then the password is reset
@@ -339,7 +400,8 @@ func (ldap *ldap) ChangePassword(o *schema.Object, old, new string) (string, err
 	}
 }
 
-// Create a user in a specific group with the given attributes
+// Create a user with the given attributes. The user is added to groups
+// with AddGroupUser
 func (ldap *ldap) CreateUser(name string, attrs ...schema.Attr) (*schema.Object, error) {
 	ldap.Lock()
 	defer ldap.Unlock()
@@ -357,17 +419,19 @@ func (ldap *ldap) CreateUser(name string, attrs ...schema.Attr) (*schema.Object,
 
 	// If the uid is not set, then set it to the next available uid
 	var nextId int
-	uid, err := ldap.SearchOne("(&(objectclass=device)(cn=lastuid))")
-	if err != nil {
-		return nil, err
-	} else if uid == nil {
-		return nil, ErrNotImplemented.With("lastuid not found")
-	} else if uid_, err := strconv.ParseInt(uid.Get("serialNumber"), 10, 32); err != nil {
-		return nil, ErrNotImplemented.With("lastuid not found")
-	} else {
-		nextId = int(uid_) + 1
-		if err := schema.OptUserId(int(uid_))(o); err != nil {
+	var uid *schema.Object
+	if o.UserId() < 0 {
+		if uid, err = ldap.SearchOne("(&(objectclass=device)(cn=lastuid))"); err != nil {
 			return nil, err
+		} else if uid == nil {
+			return nil, ErrNotImplemented.With("lastuid not found")
+		} else if uid_, err := strconv.ParseInt(uid.Get("serialNumber"), 10, 32); err != nil {
+			return nil, ErrNotImplemented.With("lastuid not found")
+		} else {
+			nextId = int(uid_) + 1
+			if err := schema.OptUserId(int(uid_))(o); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -391,8 +455,6 @@ func (ldap *ldap) CreateUser(name string, attrs ...schema.Attr) (*schema.Object,
 		}
 	}
 
-	// TODO: Add the user to a group
-
 	// Return success
 	return o, nil
 }
@@ -426,8 +488,19 @@ func (ldap *ldap) Bind(user *schema.Object, password string) error {
 		return ErrOutOfOrder.With("Not connected")
 	}
 
-	// Bind
-	if err := ldap.conn.Bind(user.DN, password); err != nil {
+	// Check parameters, as an empty password is an unauthenticated bind
+	if user == nil || password == "" {
+		return ErrNotAuthorized.With("Invalid credentials")
+	}
+
+	// Bind as the user, and then rebind with the original user
+	err := ldap.conn.Bind(user.DN, password)
+	if err := ldapBind(ldap.conn, ldap.User(), ldap.password); err != nil {
+		err = errors.Join(err, ldapDisconnect(ldap.conn))
+		ldap.conn = nil
+		return err
+	}
+	if err != nil {
 		if ldapErrorCode(err) == goldap.LDAPResultInvalidCredentials {
 			return ErrNotAuthorized.With("Invalid credentials")
 		} else {
@@ -435,8 +508,6 @@ func (ldap *ldap) Bind(user *schema.Object, password string) error {
 		}
 	}
 
-	// TODO: Rebind with the original user
-
 	// Return success
 	return nil
 }
@@ -470,6 +541,112 @@ func (ldap *ldap) SearchOne(filter string) (*schema.Object, error) {
 ///////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// Return the objects which match a filter. The lock should be held by
+// the caller
+func (ldap *ldap) search(filter string) ([]*schema.Object, error) {
+	// Check connection
+	if ldap.conn == nil {
+		return nil, ErrOutOfOrder.With("Not connected")
+	}
+
+	// Define the search request
+	searchRequest := goldap.NewSearchRequest(
+		ldap.dn, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, 0, 0, false,
+		filter, // The filter to apply
+		nil,    // The attributes to retrieve
+		nil,
+	)
+
+	// Perform the search
+	sr, err := ldap.conn.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the results
+	result := make([]*schema.Object, 0, len(sr.Entries))
+	for _, entry := range sr.Entries {
+		result = append(result, schema.NewObjectFromEntry(entry))
+	}
+
+	// Return success
+	return result, nil
+}
+
+// Return an object by distinguished name, or ErrNotFound
+func (ldap *ldap) getObject(dn string) (*schema.Object, error) {
+	ldap.Lock()
+	defer ldap.Unlock()
+
+	// Check connection
+	if ldap.conn == nil {
+		return nil, ErrOutOfOrder.With("Not connected")
+	}
+
+	// Define the search request
+	searchRequest := goldap.NewSearchRequest(
+		dn, goldap.ScopeBaseObject, goldap.NeverDerefAliases, 1, 0, false,
+		"(objectClass=*)", // The filter to apply
+		nil,               // The attributes to retrieve
+		nil,
+	)
+
+	// Perform the search, return the object
+	sr, err := ldap.conn.Search(searchRequest)
+	if ldapErrorCode(err) == goldap.LDAPResultNoSuchObject {
+		return nil, ErrNotFound.With(dn)
+	} else if err != nil {
+		return nil, err
+	} else if len(sr.Entries) == 0 {
+		return nil, ErrNotFound.With(dn)
+	} else {
+		return schema.NewObjectFromEntry(sr.Entries[0]), nil
+	}
+}
+
+// Return the attributes and values which make a user a member of a group.
+// Use uniqueMember for groupOfUniqueNames, use memberUid for posixGroup,
+// and use member for groupOfNames or if the group is neither of the others
+func groupMembers(user, group *schema.Object) map[string]string {
+	classes := objectValues(group, "objectClass")
+	members := make(map[string]string, 2)
+	if hasValue(classes, "groupOfUniqueNames") {
+		members["uniqueMember"] = user.DN
+	}
+	if hasValue(classes, "posixGroup") {
+		if uid := objectValues(user, "uid"); len(uid) > 0 {
+			members["memberUid"] = uid[0]
+		} else if cn := objectValues(user, "cn"); len(cn) > 0 {
+			members["memberUid"] = cn[0]
+		}
+	}
+	if hasValue(classes, "groupOfNames") || !(hasValue(classes, "groupOfUniqueNames") || hasValue(classes, "posixGroup")) {
+		members["member"] = user.DN
+	}
+	return members
+}
+
+// Return the values of an attribute, where the attribute name is
+// case-insensitive
+func objectValues(o *schema.Object, attr string) []string {
+	for name, values := range o.Values {
+		if strings.EqualFold(name, attr) {
+			return values
+		}
+	}
+	return nil
+}
+
+// Return true if a value is in a set of values, ignoring case
+func hasValue(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
 // Connect to the LDAP server
 func ldapConnect(host string, port int, tls *tls.Config) (*goldap.Conn, error) {
 	var url string
@@ -497,8 +674,9 @@ func ldapBind(conn *goldap.Conn, user, password string) error {
 
 // Return the LDAP error code
 func ldapErrorCode(err error) uint16 {
-	if err, ok := err.(*goldap.Error); ok {
-		return uint16(err.ResultCode)
+	var ldaperr *goldap.Error
+	if errors.As(err, &ldaperr) {
+		return uint16(ldaperr.ResultCode)
 	} else {
 		return 0
 	}
@@ -10,6 +10,17 @@ type Attr func(*Object) error
 ///////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
+// Set any attribute
+func OptAttr(name string, values ...string) Attr {
+	return func(o *Object) error {
+		if name == "" {
+			return fmt.Errorf("OptAttr: invalid name")
+		}
+		o.Set(name, values...)
+		return nil
+	}
+}
+
 // Set description
 func OptDescription(v string) Attr {
 	return func(o *Object) error {
@@ -2,6 +2,7 @@ package schema
 
 import (
 	"fmt"
+	"regexp"
 	"slices"
 
 	// Packages
@@ -71,9 +72,21 @@ const (
 	USER_PARTIAL_SECRETS_ACCOUNT        = 0x04000000
 )
 
+var (
+	// A name which can be the value of a relative distinguished name
+	// without escaping, such as a uid like john.doe
+	reName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._@\-]{0,63}$`)
+)
+
 /////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
+// Returns true if a user or group name can be used in a distinguished
+// name without escaping
+func IsName(name string) bool {
+	return reName.MatchString(name)
+}
+
 // Returns a new group object
 func (s Schema) NewGroup(dn, name string, attrs ...Attr) (*Object, error) {
 	// Check parameters
@@ -82,7 +95,7 @@ func (s Schema) NewGroup(dn, name string, attrs ...Attr) (*Object, error) {
 	}
 
 	// Set attributes
-	group := NewObject(s.groupDN(dn, name))
+	group := NewObject(s.GroupDN(dn, name))
 	group.Set("objectClass", s.GroupObjectClass...)
 	group.Set("cn", name)
 	if !s.isPosix() {
@@ -116,7 +129,7 @@ func (s Schema) NewUser(dn, name string, attrs ...Attr) (*Object, error) {
 	}
 
 	// Set attributes
-	user := NewObject(s.userDN(dn, name))
+	user := NewObject(s.UserDN(dn, name))
 	user.Set("objectClass", s.UserObjectClass...)
 	user.Set("cn", name)
 	if !s.isPosix() {
@@ -143,24 +156,24 @@ func (s Schema) NewUser(dn, name string, attrs ...Attr) (*Object, error) {
 	return user, nil
 }
 
-/////////////////////////////////////////////////////////////////////
-// PRIVATE METHODS
-
-// Returns true if the schema is posix (objectClass includes posixAccount)
-func (s Schema) isPosix() bool {
-	return slices.Contains(s.UserObjectClass, defaultPosixUserObjectClass)
-}
-
 // Returns the group DN for a given name
-func (s Schema) groupDN(dn, name string) string {
+func (s Schema) GroupDN(dn, name string) string {
 	return fmt.Sprintf("cn=%s,ou=%s,%s", name, s.GroupOU, dn)
 }
 
 // Returns the user DN for a given name
-func (s Schema) userDN(dn, name string) string {
+func (s Schema) UserDN(dn, name string) string {
 	if s.isPosix() {
 		return fmt.Sprintf("uid=%s,ou=%s,%s", name, s.UserOU, dn)
 	} else {
 		return fmt.Sprintf("cn=%s,ou=%s,%s", name, s.UserOU, dn)
 	}
 }
+
+/////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Returns true if the schema is posix (objectClass includes posixAccount)
+func (s Schema) isPosix() bool {
+	return slices.Contains(s.UserObjectClass, defaultPosixUserObjectClass)
+}
@@ -18,6 +18,7 @@ import (
 
 // Check interfaces are satisfied
 var _ server.Task = (*ldap)(nil)
+var _ server.Collector = (*ldap)(nil)
 
 /////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
@@ -73,6 +74,17 @@ FOR_LOOP:
 	return result
 }
 
+// Write whether there is a connection to the LDAP server
+func (task *ldap) Collect(ctx context.Context, w server.MetricWriter) {
+	task.Lock()
+	defer task.Unlock()
+	var connected float64
+	if task.conn != nil {
+		connected = 1
+	}
+	w.Gauge("ldap_connected", "Whether there is a connection to the LDAP server", connected)
+}
+
 /*
 
 func (self *ldap) Run(ctx context.Context) error {
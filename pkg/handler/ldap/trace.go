@@ -0,0 +1,76 @@
+package ldap
+
+import (
+	"context"
+
+	// Packages
+	schema "github.com/mutablelogic/go-server/pkg/handler/ldap/schema"
+	trace "github.com/mutablelogic/go-server/pkg/trace"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Operations on the LDAP server from a request, each of which is traced as
+// a client span when tracing is enabled
+
+func (ldap *ldap) getUsers(ctx context.Context) ([]*schema.Object, error) {
+	return trace.Call(ctx, "ldap GetUsers", ldap.GetUsers, ldap.spanAttrs()...)
+}
+
+func (ldap *ldap) getGroups(ctx context.Context) ([]*schema.Object, error) {
+	return trace.Call(ctx, "ldap GetGroups", ldap.GetGroups, ldap.spanAttrs()...)
+}
+
+func (ldap *ldap) getUser(ctx context.Context, name string) (*schema.Object, error) {
+	return trace.Call(ctx, "ldap GetUser", func() (*schema.Object, error) {
+		return ldap.GetUser(name)
+	}, ldap.spanAttrs()...)
+}
+
+func (ldap *ldap) getGroup(ctx context.Context, name string) (*schema.Object, error) {
+	return trace.Call(ctx, "ldap GetGroup", func() (*schema.Object, error) {
+		return ldap.GetGroup(name)
+	}, ldap.spanAttrs()...)
+}
+
+func (ldap *ldap) createUser(ctx context.Context, name string, attrs ...schema.Attr) (*schema.Object, error) {
+	return trace.Call(ctx, "ldap CreateUser", func() (*schema.Object, error) {
+		return ldap.CreateUser(name, attrs...)
+	}, ldap.spanAttrs()...)
+}
+
+func (ldap *ldap) createGroup(ctx context.Context, name string, attrs ...schema.Attr) (*schema.Object, error) {
+	return trace.Call(ctx, "ldap CreateGroup", func() (*schema.Object, error) {
+		return ldap.CreateGroup(name, attrs...)
+	}, ldap.spanAttrs()...)
+}
+
+func (ldap *ldap) changePassword(ctx context.Context, o *schema.Object, old, new string) (string, error) {
+	return trace.Call(ctx, "ldap ChangePassword", func() (string, error) {
+		return ldap.ChangePassword(o, old, new)
+	}, ldap.spanAttrs()...)
+}
+
+func (ldap *ldap) delete(ctx context.Context, o *schema.Object) error {
+	return trace.Do(ctx, "ldap Delete", func() error {
+		return ldap.Delete(o)
+	}, ldap.spanAttrs()...)
+}
+
+func (ldap *ldap) addGroupUser(ctx context.Context, user, group *schema.Object) error {
+	return trace.Do(ctx, "ldap AddGroupUser", func() error {
+		return ldap.AddGroupUser(user, group)
+	}, ldap.spanAttrs()...)
+}
+
+func (ldap *ldap) removeGroupUser(ctx context.Context, user, group *schema.Object) error {
+	return trace.Do(ctx, "ldap RemoveGroupUser", func() error {
+		return ldap.RemoveGroupUser(user, group)
+	}, ldap.spanAttrs()...)
+}
+
+// Return the attributes of a span for the LDAP server
+func (ldap *ldap) spanAttrs() []any {
+	return []any{"server.address", ldap.Host(), "server.port", ldap.Port()}
+}
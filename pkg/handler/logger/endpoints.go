@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	router "github.com/mutablelogic/go-server/pkg/handler/router"
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Check interfaces are satisfied
+var _ server.ServiceEndpoints = (*logger)(nil)
+var _ server.Middleware = (*logger)(nil)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	reReopen = regexp.MustCompile(`^/reopen/?$`)
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - ENDPOINTS
+
+// Add endpoints to the router
+func (service *logger) AddEndpoints(ctx context.Context, r server.Router) {
+	// Path: /reopen
+	// Methods: PUT
+	// Scopes: write
+	r.AddHandlerFuncRe(ctx, reReopen, service.PutReopen, http.MethodPut).(router.Route).
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Reopen the access log file, after it has been rotated").
+		SetResponse(http.StatusOK, nil)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Reopen the access log file
+func (service *logger) PutReopen(w http.ResponseWriter, r *http.Request) {
+	if err := service.Reopen(); err != nil {
+		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Serve OK response with no body
+	httpresponse.Empty(w, http.StatusOK)
+}
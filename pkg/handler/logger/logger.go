@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"log/slog"
 	"os"
 	"strings"
 	"sync"
@@ -21,13 +22,24 @@ import (
 
 // logging configuration
 type Config struct {
-	Flags []string `hcl:"flags,optional" description:"zero or more formatting flags (std, date, time, ms, utc, prefix)"`
+	Flags  []string `hcl:"flags,optional" description:"zero or more formatting flags (std, date, time, ms, utc, prefix)"`
+	Access struct {
+		Format   string `hcl:"format" description:"Format of access log records (json, logfmt), or empty to log a single line with the logger"`
+		Sink     string `hcl:"sink" description:"Destination of access log records (stdout, file, syslog)"`
+		Path     string `hcl:"path" description:"Path to the file, or the unix socket for syslog (default is the local syslog)"`
+		MaxSize  int64  `hcl:"max_size" description:"Size in bytes at which the file is rotated, or zero to disable rotation"`
+		MaxFiles int    `hcl:"max_files" description:"Number of rotated files to keep (default 5)"`
+	} `hcl:"access"`
 }
 
 // logging instance
 type logger struct {
 	sync.Mutex
 	*log.Logger
+
+	// Structured access log records, or nil to log a single line
+	access *slog.Logger
+	sink   sink
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -37,6 +49,11 @@ const (
 	defaultName = "logger"
 )
 
+const (
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
 ///////////////////////////////////////////////////////////////////////////////
 // LIFECYCLE
 
@@ -57,6 +74,23 @@ func (c Config) New() (server.Task, error) {
 		self.Logger = log.New(os.Stderr, "", flags)
 	}
 
+	// Set structured access logging
+	if c.Access.Format != "" || c.Access.Sink != "" {
+		sink, err := newSink(c.Access.Sink, c.Access.Path, c.Access.MaxSize, c.Access.MaxFiles)
+		if err != nil {
+			return nil, err
+		}
+		switch c.Access.Format {
+		case FormatJSON:
+			self.access = slog.New(slog.NewJSONHandler(sink, nil))
+		case "", FormatLogfmt:
+			self.access = slog.New(slog.NewTextHandler(sink, nil))
+		default:
+			return nil, ErrBadParameter.Withf("format: %q", c.Access.Format)
+		}
+		self.sink = sink
+	}
+
 	// Return success
 	return self, nil
 }
@@ -72,8 +106,11 @@ func (c Config) String() string {
 /////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
-func (*logger) Run(ctx context.Context) error {
+func (l *logger) Run(ctx context.Context) error {
 	<-ctx.Done()
+	if l.sink != nil {
+		return l.sink.Close()
+	}
 	return nil
 }
 
@@ -104,6 +141,15 @@ func (l *logger) Printf(ctx context.Context, f string, v ...any) {
 	l.Logger.Printf(f, v...)
 }
 
+// Reopen the destination of access log records, after a file has been
+// moved or removed
+func (l *logger) Reopen() error {
+	if l.sink == nil {
+		return nil
+	}
+	return l.sink.Reopen()
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
@@ -0,0 +1,155 @@
+package logger_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	auth "github.com/mutablelogic/go-server/pkg/handler/auth"
+	logger "github.com/mutablelogic/go-server/pkg/handler/logger"
+	router "github.com/mutablelogic/go-server/pkg/handler/router"
+	assert "github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+func Test_logger_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// Invalid configuration
+	config := logger.Config{}
+	config.Access.Format = "other"
+	_, err := config.New()
+	assert.ErrorIs(err, ErrBadParameter)
+	config = logger.Config{}
+	config.Access.Sink = logger.SinkFile
+	_, err = config.New()
+	assert.ErrorIs(err, ErrBadParameter)
+}
+
+func Test_logger_002(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "access.log")
+
+	// Log JSON records to a file
+	config := logger.Config{}
+	config.Access.Format = logger.FormatJSON
+	config.Access.Sink = logger.SinkFile
+	config.Access.Path = path
+	task, err := config.New()
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Add the endpoints to a router, with the logger and middleware which
+	// authenticates the request
+	r, err := router.Config{}.New()
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	r.(router.Router).AddServiceEndpoints("logger", task.(server.ServiceEndpoints), task.(server.Middleware), testToken{})
+
+	// Make a request
+	req := httptest.NewRequest(http.MethodPut, "/logger/reopen", nil)
+	req.Header.Set("X-Request-Id", "test-id")
+	req.RemoteAddr = "10.0.0.1:1234"
+	resp := httptest.NewRecorder()
+	r.(http.Handler).ServeHTTP(resp, req)
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal("test-id", resp.Header().Get("X-Request-Id"))
+
+	// Read the record
+	var record map[string]any
+	data, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(data, &record))
+	assert.Equal("access", record["msg"])
+	assert.Equal("10.0.0.1", record["remote_addr"])
+	assert.Equal(http.MethodPut, record["method"])
+	assert.Equal("/logger/reopen", record["url"])
+	assert.Equal(float64(http.StatusOK), record["status"])
+	assert.Equal("logger", record["label"])
+	assert.Equal("/logger", record["prefix"])
+	assert.Equal("test", record["token"])
+	assert.Equal("test-id", record["request_id"])
+	assert.Contains(record, "duration")
+}
+
+func Test_logger_003(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "access.log")
+
+	// Log records to a file which is rotated
+	config := logger.Config{}
+	config.Access.Sink = logger.SinkFile
+	config.Access.Path = path
+	config.Access.MaxSize = 100
+	config.Access.MaxFiles = 2
+	task, err := config.New()
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	handler := task.(server.Middleware).Wrap(context.Background(), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	for i := 0; i < 10; i++ {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	// Only two rotated files are kept, and each file holds one record
+	assert.FileExists(path)
+	assert.FileExists(path + ".1")
+	assert.FileExists(path + ".2")
+	assert.NoFileExists(path + ".3")
+	assert.Equal(1, countLines(t, path))
+
+	// Reopen the file after it has been moved
+	assert.NoError(os.Rename(path, path+".moved"))
+	assert.NoError(task.(interface{ Reopen() error }).Reopen())
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(1, countLines(t, path))
+	assert.Equal(1, countLines(t, path+".moved"))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	r, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	n := 0
+	for scanner := bufio.NewScanner(r); scanner.Scan(); {
+		n++
+	}
+	return n
+}
+
+// testToken authenticates every request with a token named "test"
+type testToken struct{}
+
+func (testToken) Label() string {
+	return "token"
+}
+
+func (testToken) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (testToken) Wrap(ctx context.Context, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(w, r.WithContext(auth.WithToken(r.Context(), auth.Token{Name: "test"})))
+	}
+}
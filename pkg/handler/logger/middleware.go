@@ -2,20 +2,43 @@ package logger
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
+	"regexp"
 	"time"
 
 	// Packages
+	"github.com/mutablelogic/go-server/pkg/handler/auth"
 	"github.com/mutablelogic/go-server/pkg/handler/router"
+	"github.com/mutablelogic/go-server/pkg/provider"
+	"github.com/mutablelogic/go-server/pkg/trace"
 )
 
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type loggerContextKey int
+
 ///////////////////////////////////////////////////////////////////////////////
 // GLOBALS
 
+const (
+	_ loggerContextKey = iota
+	contextRequestId
+)
+
+const (
+	headerRequestId = "X-Request-Id"
+	requestIdBytes  = 8
+)
+
 var (
 	remoteAddrHeaders = []string{"CF-Connecting-IP", "X-Real-Ip", "X-Forwarded-For", "True-Client-IP"}
+	reRequestId       = regexp.MustCompile(`^[A-Za-z0-9_\.\-]{1,64}$`)
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -23,11 +46,22 @@ var (
 
 func (l *logger) Wrap(ctx context.Context, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Set the request id, and record the token name
+		id := requestId(r)
+		w.Header().Set(headerRequestId, id)
+		ctx, token := auth.WithTokenRecorder(context.WithValue(r.Context(), contextRequestId, id))
+
 		nw := NewResponseWriter(w)
-		next(nw, r)
+		next(nw, r.WithContext(ctx))
+
+		// Log a structured record
+		if l.access != nil {
+			l.access.LogAttrs(ctx, slog.LevelInfo, "access", accessAttrs(r, nw, id, token())...)
+			return
+		}
 
 		// Print the response
-		result := fmt.Sprintf("%v %v %q -> [%v]", remoteAddr(r), r.Method, r.URL, nw.Status())
+		result := fmt.Sprintf("%v %v %q -> [%v]", RemoteAddr(r), r.Method, r.URL, nw.Status())
 		if sz := nw.Size(); sz > 0 {
 			result += fmt.Sprintf(" %v bytes sent", sz)
 		}
@@ -39,9 +73,11 @@ func (l *logger) Wrap(ctx context.Context, next http.HandlerFunc) http.HandlerFu
 }
 
 ///////////////////////////////////////////////////////////////////////////////
-// PRIVATE METHODS
+// PUBLIC METHODS
 
-func remoteAddr(r *http.Request) string {
+// Return the address of the client, from the headers set by a proxy or
+// otherwise the remote address of the request
+func RemoteAddr(r *http.Request) string {
 	for _, header := range remoteAddrHeaders {
 		if addr := r.Header.Get(header); addr != "" {
 			return addr
@@ -52,3 +88,71 @@ func remoteAddr(r *http.Request) string {
 	}
 	return r.RemoteAddr
 }
+
+// Return the request id from the context, or an empty string
+func RequestId(ctx context.Context) string {
+	if value, ok := ctx.Value(contextRequestId).(string); ok {
+		return value
+	}
+	return ""
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the request id from the request header, or a new request id
+func requestId(r *http.Request) string {
+	if id := r.Header.Get(headerRequestId); reRequestId.MatchString(id) {
+		return id
+	}
+	id := make([]byte, requestIdBytes)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+// Return the attributes of an access log record. Attributes which are
+// not set for a request are omitted
+func accessAttrs(r *http.Request, w ResponseWriter, id, token string) []slog.Attr {
+	url := r.RequestURI
+	if url == "" {
+		url = r.URL.String()
+	}
+	status := w.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	attrs := []slog.Attr{
+		slog.String("remote_addr", RemoteAddr(r)),
+		slog.String("method", r.Method),
+		slog.String("url", url),
+		slog.Int("status", status),
+		slog.Int("size", w.Size()),
+	}
+	if t := router.Time(r.Context()); !t.IsZero() {
+		attrs = append(attrs, slog.Float64("duration", time.Since(t).Seconds()))
+	}
+	for _, attr := range []struct{ key, value string }{
+		{"label", provider.Label(r.Context())},
+		{"host", router.Host(r.Context())},
+		{"prefix", router.Prefix(r.Context())},
+		{"token", token},
+		{"request_id", id},
+		{"trace_id", traceId(r)},
+	} {
+		if attr.value != "" {
+			attrs = append(attrs, slog.String(attr.key, attr.value))
+		}
+	}
+	return attrs
+}
+
+// Return the trace id of a request, or an empty string if the request
+// is not traced
+func traceId(r *http.Request) string {
+	if span := trace.FromContext(r.Context()); span != nil {
+		return span.TraceId
+	}
+	return ""
+}
@@ -76,6 +76,17 @@ func (rw *responseWriter) ReadFrom(r io.Reader) (n int64, err error) {
 	return
 }
 
+// Flush sends any buffered data to the client, when the underlying
+// http.ResponseWriter supports it, so that streamed responses are not
+// delayed by the wrapper
+func (rw *responseWriter) Flush() {
+	if !rw.Written() {
+		// The status will be StatusOK if WriteHeader has not been called yet
+		rw.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
 // Satisfy http.ResponseController support (Go 1.20+)
 func (rw *responseWriter) Unwrap() http.ResponseWriter {
 	return rw.ResponseWriter
@@ -0,0 +1,25 @@
+package logger
+
+import (
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/version"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	// Prefix
+	scopePrefix = version.GitSource + "/scope/"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (l *logger) ScopeWrite() []string {
+	// Return write (reopen) scopes
+	return []string{
+		scopePrefix + l.Label() + "/write",
+		scopePrefix + defaultName + "/write",
+	}
+}
@@ -0,0 +1,224 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"sync"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// sink is a destination for access log records
+type sink interface {
+	io.WriteCloser
+
+	// Reopen the destination, for example after a file has been rotated
+	Reopen() error
+}
+
+// stdout writes records to the standard output
+type stdout struct{}
+
+// file writes records to a file, which is rotated when it exceeds a
+// maximum size
+type file struct {
+	sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	w        *os.File
+	size     int64
+}
+
+// sysLog writes records to syslog
+type sysLog struct {
+	*syslog.Writer
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	SinkStdout = "stdout"
+	SinkFile   = "file"
+	SinkSyslog = "syslog"
+)
+
+const (
+	defaultMaxFiles = 5
+	defaultFileMode = os.FileMode(0640)
+	syslogNetwork   = "unixgram"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a sink of a type, with a path for a file or syslog socket
+func newSink(kind, path string, maxSize int64, maxFiles int) (sink, error) {
+	switch kind {
+	case "", SinkStdout:
+		return stdout{}, nil
+	case SinkFile:
+		return newFile(path, maxSize, maxFiles)
+	case SinkSyslog:
+		return newSyslog(path)
+	default:
+		return nil, ErrBadParameter.Withf("sink: %q", kind)
+	}
+}
+
+// Open a file for appending records, which is rotated when the size is
+// exceeded if maxSize is greater than zero
+func newFile(path string, maxSize int64, maxFiles int) (*file, error) {
+	if path == "" {
+		return nil, ErrBadParameter.With("missing path for file")
+	} else if maxSize < 0 || maxFiles < 0 {
+		return nil, ErrBadParameter.With("max_size and max_files cannot be negative")
+	}
+	f := &file{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+	if f.maxFiles == 0 {
+		f.maxFiles = defaultMaxFiles
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Connect to syslog, either on the local system or through a unix socket
+func newSyslog(path string) (*sysLog, error) {
+	var w *syslog.Writer
+	var err error
+	if path == "" {
+		w, err = syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, defaultName)
+	} else {
+		w, err = syslog.Dial(syslogNetwork, path, syslog.LOG_INFO|syslog.LOG_DAEMON, defaultName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sysLog{w}, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - STDOUT
+
+func (stdout) Write(data []byte) (int, error) {
+	return os.Stdout.Write(data)
+}
+
+func (stdout) Reopen() error {
+	return nil
+}
+
+func (stdout) Close() error {
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - FILE
+
+// Write a record, rotating the file first if the record would exceed the
+// maximum size
+func (f *file) Write(data []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.w == nil {
+		return 0, ErrOutOfOrder.With("file is closed")
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(data)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.w.Write(data)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close and open the file, after it has been moved or removed
+func (f *file) Reopen() error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.w != nil {
+		if err := f.w.Close(); err != nil {
+			return err
+		}
+	}
+	return f.open()
+}
+
+// Close the file
+func (f *file) Close() error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.w == nil {
+		return nil
+	}
+	err := f.w.Close()
+	f.w = nil
+	return err
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - SYSLOG
+
+// Syslog reconnects when a write fails, so there is nothing to reopen
+func (*sysLog) Reopen() error {
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Open the file for appending, and set the current size
+func (f *file) open() error {
+	w, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, defaultFileMode)
+	if err != nil {
+		return err
+	}
+	stat, err := w.Stat()
+	if err != nil {
+		w.Close()
+		return err
+	}
+	f.w = w
+	f.size = stat.Size()
+	return nil
+}
+
+// Rotate the file, so the current file becomes <path>.1 and the oldest
+// file is removed
+func (f *file) rotate() error {
+	if err := f.w.Close(); err != nil {
+		return err
+	}
+	f.w = nil
+	for i := f.maxFiles - 1; i > 0; i-- {
+		if err := os.Rename(rotated(f.path, i), rotated(f.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(f.path, rotated(f.path, 1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return f.open()
+}
+
+// Return the path of a rotated file
+func rotated(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
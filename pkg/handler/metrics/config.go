@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	// Packages
+	server "github.com/mutablelogic/go-server"
+)
+
+////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type Config struct {
+	Buckets []float64 `hcl:"buckets" description:"Upper bounds of the request latency histogram buckets, in seconds"`
+}
+
+// Check interfaces are satisfied
+var _ server.Plugin = Config{}
+
+////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	defaultName = "metrics"
+)
+
+var (
+	defaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Name returns the name of the service
+func (Config) Name() string {
+	return defaultName
+}
+
+// Description returns the description of the service
+func (Config) Description() string {
+	return "serves request and task metrics in prometheus text format"
+}
+
+// Create a new task from the configuration
+func (c Config) New() (server.Task, error) {
+	return New(c)
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	router "github.com/mutablelogic/go-server/pkg/handler/router"
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Check interfaces are satisfied
+var _ server.ServiceEndpoints = (*metrics)(nil)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	reRoot = regexp.MustCompile(`^/?$`)
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - ENDPOINTS
+
+// Add endpoints to the router
+func (service *metrics) AddEndpoints(ctx context.Context, r server.Router) {
+	// Path: /
+	// Methods: GET
+	// Scopes: read
+	r.AddHandlerFuncRe(ctx, reRoot, service.GetMetrics, http.MethodGet).(router.Route).
+		SetScope(service.ScopeRead()...).
+		SetSummary("Get the metrics in prometheus text format")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Get the metrics
+func (service *metrics) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	httpresponse.Text(w, service.Gather(r.Context()), http.StatusOK, httpresponse.ContentTypeKey, contentType)
+}
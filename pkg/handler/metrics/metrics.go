@@ -0,0 +1,199 @@
+/*
+implements middleware which counts requests and measures their latency,
+labelled by route label and status, and an endpoint which serves these
+metrics with the metrics of tasks in prometheus text format
+*/
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	logger "github.com/mutablelogic/go-server/pkg/handler/logger"
+	provider "github.com/mutablelogic/go-server/pkg/provider"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type metrics struct {
+	sync.Mutex
+
+	// Upper bounds of the histogram buckets
+	buckets []float64
+
+	// Requests keyed by route label and status
+	requests map[route]*histogram
+
+	// Number of requests being handled
+	inflight atomic.Int64
+
+	// The registry which gathers metrics from tasks, or nil
+	registry server.Registry
+}
+
+// route is the route label and status of a request
+type route struct {
+	Label  string
+	Status int
+}
+
+// histogram counts observations in buckets
+type histogram struct {
+	Counts []uint64
+	Count  uint64
+	Sum    float64
+}
+
+// Check interfaces are satisfied
+var _ server.Task = (*metrics)(nil)
+var _ server.Middleware = (*metrics)(nil)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	metricRequests = "http_requests_total"
+	metricDuration = "http_request_duration_seconds"
+	metricInflight = "http_requests_in_flight"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a new metrics task from the configuration
+func New(c Config) (*metrics, error) {
+	m := new(metrics)
+	m.requests = make(map[route]*histogram)
+
+	// Set the buckets, which need to be positive and increasing
+	if len(c.Buckets) == 0 {
+		m.buckets = defaultBuckets
+	} else {
+		for i, bucket := range c.Buckets {
+			if bucket <= 0 || (i > 0 && bucket <= c.Buckets[i-1]) {
+				return nil, ErrBadParameter.With("buckets should be positive and increasing")
+			}
+		}
+		m.buckets = slices.Clone(c.Buckets)
+	}
+
+	// Return success
+	return m, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - MIDDLEWARE
+
+// Wrap a handler so that the request is counted and the latency is
+// observed, once the handler has returned
+func (metrics *metrics) Wrap(ctx context.Context, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		metrics.inflight.Add(1)
+		defer metrics.inflight.Add(-1)
+
+		nw := logger.NewResponseWriter(w)
+		next(nw, r)
+
+		status := nw.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		metrics.observe(route{provider.Label(r.Context()), status}, time.Since(start))
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Gather the request metrics, and the metrics from the registry, and
+// return them in prometheus text format
+func (metrics *metrics) Gather(ctx context.Context) string {
+	w := newTextWriter()
+
+	// Write the request metrics
+	metrics.collect(w)
+
+	// Write the metrics from the registry
+	metrics.Lock()
+	registry := metrics.registry
+	metrics.Unlock()
+	if registry != nil {
+		registry.Gather(ctx, w)
+	}
+
+	// Return the text
+	return w.String()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Write the request metrics
+func (metrics *metrics) collect(w *textWriter) {
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	// Sort the routes, so that the output is stable
+	routes := make([]route, 0, len(metrics.requests))
+	for route := range metrics.requests {
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Label != routes[j].Label {
+			return routes[i].Label < routes[j].Label
+		}
+		return routes[i].Status < routes[j].Status
+	})
+
+	// Write the counts and histograms
+	for _, route := range routes {
+		h := metrics.requests[route]
+		labels := []string{"label", route.Label, "status", strconv.Itoa(route.Status)}
+		w.Counter(metricRequests, "Number of requests handled", float64(h.Count), labels...)
+		w.Histogram(metricDuration, "Latency of requests in seconds", metrics.buckets, h.Counts, h.Sum, h.Count, labels...)
+	}
+	w.Gauge(metricInflight, "Number of requests being handled", float64(metrics.inflight.Load()))
+}
+
+// Set the registry which gathers metrics from tasks
+func (metrics *metrics) setRegistry(registry server.Registry) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	metrics.registry = registry
+}
+
+// Record a request for a route, which took a duration
+func (metrics *metrics) observe(key route, d time.Duration) {
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	h, exists := metrics.requests[key]
+	if !exists {
+		h = &histogram{Counts: make([]uint64, len(metrics.buckets))}
+		metrics.requests[key] = h
+	}
+
+	// Count the observation in every bucket with an upper bound which is
+	// not less than the duration, so the counts are cumulative
+	seconds := d.Seconds()
+	for i, bound := range metrics.buckets {
+		if seconds <= bound {
+			h.Counts[i]++
+		}
+	}
+	h.Count++
+	h.Sum += seconds
+}
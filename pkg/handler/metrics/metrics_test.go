@@ -0,0 +1,119 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	metrics "github.com/mutablelogic/go-server/pkg/handler/metrics"
+	router "github.com/mutablelogic/go-server/pkg/handler/router"
+	provider "github.com/mutablelogic/go-server/pkg/provider"
+	assert "github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+func Test_metrics_001(t *testing.T) {
+	assert := assert.New(t)
+	assert.Implements((*server.Plugin)(nil), metrics.Config{})
+
+	// Default configuration
+	task, err := metrics.New(metrics.Config{})
+	assert.NoError(err)
+	assert.NotNil(task)
+
+	// Invalid buckets
+	_, err = metrics.New(metrics.Config{Buckets: []float64{1, 0.5}})
+	assert.ErrorIs(err, ErrBadParameter)
+	_, err = metrics.New(metrics.Config{Buckets: []float64{0}})
+	assert.ErrorIs(err, ErrBadParameter)
+}
+
+func Test_metrics_002(t *testing.T) {
+	assert := assert.New(t)
+	task, err := metrics.New(metrics.Config{Buckets: []float64{0.5, 10}})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Add the endpoints to a router, with the metrics middleware
+	r, err := router.Config{}.New()
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	r.(router.Router).AddServiceEndpoints("metrics", task, task)
+
+	// Make a request, and then get the metrics
+	r.(http.Handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics/", nil))
+	resp := httptest.NewRecorder()
+	r.(http.Handler).ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics/", nil))
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Contains(resp.Header().Get("Content-Type"), "version=0.0.4")
+
+	// The metrics include the requests made before this one
+	body := resp.Body.String()
+	assert.Contains(body, "# TYPE http_requests_total counter\n")
+	assert.Contains(body, `http_requests_total{label="metrics",status="200"} 1`+"\n")
+	assert.Contains(body, "# TYPE http_request_duration_seconds histogram\n")
+	assert.Contains(body, `http_request_duration_seconds_bucket{label="metrics",status="200",le="0.5"} 1`+"\n")
+	assert.Contains(body, `http_request_duration_seconds_bucket{label="metrics",status="200",le="+Inf"} 1`+"\n")
+	assert.Contains(body, `http_request_duration_seconds_count{label="metrics",status="200"} 1`+"\n")
+	assert.Contains(body, "http_requests_in_flight 1\n")
+}
+
+func Test_metrics_003(t *testing.T) {
+	assert := assert.New(t)
+	task, err := metrics.New(metrics.Config{})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Run the metrics task in a provider, with a task which is a collector
+	p := provider.NewProvider(task, testCollector{})
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(p.Run(ctx))
+	}()
+	defer wg.Wait()
+	defer cancel()
+
+	// Metrics are gathered from the collector, with escaped labels
+	assert.Eventually(func() bool {
+		return strings.Contains(task.Gather(ctx), `test_value{name="a\"b\\c"} 42`+"\n")
+	}, time.Second, 10*time.Millisecond)
+	body := task.Gather(ctx)
+	assert.Contains(body, "# HELP test_value Test value\n# TYPE test_value gauge\n")
+	assert.NotContains(body, "invalid")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// COLLECTOR
+
+// testCollector writes a gauge, and metrics which are invalid
+type testCollector struct{}
+
+func (testCollector) Label() string {
+	return "test"
+}
+
+func (testCollector) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (testCollector) Collect(ctx context.Context, w server.MetricWriter) {
+	w.Gauge("test_value", "Test value", 42, "name", `a"b\c`)
+	w.Counter("test_value", "Test value", 1)
+	w.Gauge("invalid-name", "", 1)
+	w.Gauge("invalid_label", "", 1, "label")
+}
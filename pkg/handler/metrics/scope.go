@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/version"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	// Prefix
+	scopePrefix = version.GitSource + "/scope/"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (metrics *metrics) ScopeRead() []string {
+	// Return read (list, get) scopes
+	return []string{
+		scopePrefix + metrics.Label() + "/read",
+		scopePrefix + defaultName + "/read",
+	}
+}
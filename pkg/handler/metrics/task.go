@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"context"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	provider "github.com/mutablelogic/go-server/pkg/provider"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return the label for the task
+func (metrics *metrics) Label() string {
+	// TODO
+	return defaultName
+}
+
+// Run the task until the context is cancelled. Metrics are gathered from
+// the provider which runs the task, when it is a registry
+func (metrics *metrics) Run(ctx context.Context) error {
+	if registry, ok := provider.Provider(ctx).(server.Registry); ok {
+		metrics.setRegistry(registry)
+		defer metrics.setRegistry(nil)
+	}
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// textWriter collects metric samples and writes them in prometheus
+// text format, grouped by metric name
+type textWriter struct {
+	families map[string]*family
+}
+
+// family is a set of samples for a metric name
+type family struct {
+	Help    string
+	Type    string
+	Samples []sample
+}
+
+// sample is a metric value, where the name of the sample is the name of
+// the family with a suffix
+type sample struct {
+	Suffix string
+	Labels []string
+	Value  float64
+}
+
+// Check interfaces are satisfied
+var _ server.MetricWriter = (*textWriter)(nil)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+var (
+	reMetricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	reLabelName  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	escapeHelp   = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	escapeLabel  = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func newTextWriter() *textWriter {
+	return &textWriter{families: make(map[string]*family)}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Write a counter
+func (w *textWriter) Counter(name, help string, value float64, labels ...string) {
+	if f := w.family(name, help, typeCounter, labels); f != nil {
+		f.Samples = append(f.Samples, sample{"", labels, value})
+	}
+}
+
+// Write a gauge
+func (w *textWriter) Gauge(name, help string, value float64, labels ...string) {
+	if f := w.family(name, help, typeGauge, labels); f != nil {
+		f.Samples = append(f.Samples, sample{"", labels, value})
+	}
+}
+
+// Write a histogram with cumulative counts for the upper bounds of the
+// buckets, and the total count and sum of the observations
+func (w *textWriter) Histogram(name, help string, bounds []float64, counts []uint64, sum float64, count uint64, labels ...string) {
+	f := w.family(name, help, typeHistogram, labels)
+	if f == nil || len(bounds) != len(counts) {
+		return
+	}
+	for i, bound := range bounds {
+		f.Samples = append(f.Samples, sample{"_bucket", append(copyLabels(labels), "le", formatValue(bound)), float64(counts[i])})
+	}
+	f.Samples = append(f.Samples,
+		sample{"_bucket", append(copyLabels(labels), "le", "+Inf"), float64(count)},
+		sample{"_sum", labels, sum},
+		sample{"_count", labels, float64(count)},
+	)
+}
+
+// Return the metrics in prometheus text format, sorted by name
+func (w *textWriter) String() string {
+	names := make([]string, 0, len(w.families))
+	for name := range w.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		f := w.families[name]
+		if f.Help != "" {
+			b.WriteString("# HELP " + name + " " + escapeHelp.Replace(f.Help) + "\n")
+		}
+		b.WriteString("# TYPE " + name + " " + f.Type + "\n")
+		for _, sample := range f.Samples {
+			b.WriteString(name + sample.Suffix)
+			if len(sample.Labels) > 0 {
+				b.WriteString("{")
+				for i := 0; i < len(sample.Labels); i += 2 {
+					if i > 0 {
+						b.WriteString(",")
+					}
+					b.WriteString(sample.Labels[i] + `="` + escapeLabel.Replace(sample.Labels[i+1]) + `"`)
+				}
+				b.WriteString("}")
+			}
+			b.WriteString(" " + formatValue(sample.Value) + "\n")
+		}
+	}
+	return b.String()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the family for a metric name, creating it if it does not exist.
+// Returns nil if the name or labels are invalid, or the family has a
+// different type, in which case the sample is dropped
+func (w *textWriter) family(name, help, kind string, labels []string) *family {
+	if !reMetricName.MatchString(name) || len(labels)%2 != 0 {
+		return nil
+	}
+	for i := 0; i < len(labels); i += 2 {
+		if !reLabelName.MatchString(labels[i]) {
+			return nil
+		}
+	}
+	f, exists := w.families[name]
+	if !exists {
+		f = &family{Help: help, Type: kind}
+		w.families[name] = f
+	} else if f.Type != kind {
+		return nil
+	}
+	return f
+}
+
+// Return a copy of the labels, so that appending does not modify them
+func copyLabels(labels []string) []string {
+	return append(make([]string, 0, len(labels)+2), labels...)
+}
+
+// Format a value
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, +1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,205 @@
+package nginx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	// Packages
+	folders "github.com/mutablelogic/go-server/pkg/handler/nginx/folders"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// BatchOp is a change to a configuration, which is applied with other
+// changes as a batch
+type BatchOp struct {
+	Op       string         `json:"op"`                 // create, enable, disable or delete
+	Name     string         `json:"name"`               // Name of the configuration
+	Body     string         `json:"body,omitempty"`     // Body for create
+	Template string         `json:"template,omitempty"` // Template for create
+	Params   map[string]any `json:"params,omitempty"`   // Template parameters for create
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	BatchCreate  = "create"
+	BatchEnable  = "enable"
+	BatchDisable = "disable"
+	BatchDelete  = "delete"
+)
+
+const (
+	defaultStagingPattern = ".staging-"     // Hidden folder in the ConfigPath
+	defaultHealthDelay    = 2 * time.Second // Time to wait before checking nginx after a reload
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Apply a batch of changes to the configurations. The changes are made
+// to a staged copy of the configurations, which is tested before it is
+// swapped in and nginx is reloaded. If the reload fails or nginx is not
+// running afterwards, the previous configurations are restored
+func (nginx *nginx) Apply(ctx context.Context, ops ...BatchOp) error {
+	nginx.lock.Lock()
+	defer nginx.lock.Unlock()
+
+	if len(ops) == 0 {
+		return ErrBadParameter.With("no operations")
+	}
+
+	// Create the staging folder, which is removed afterwards
+	staging, err := os.MkdirTemp(nginx.configPath, defaultStagingPattern)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	// Stage the configurations and apply the changes
+	if err := nginx.linkConfig(staging); err != nil {
+		return err
+	}
+	staged, err := nginx.folders.Stage(staging)
+	if err != nil {
+		return err
+	}
+	for i, op := range ops {
+		if err := nginx.applyOp(staged, op); err != nil {
+			return fmt.Errorf("operation %d (%s %q): %w", i, op.Op, op.Name, err)
+		}
+	}
+
+	// Write the certificates which the staged configurations refer to, so
+	// that they can be tested, then commit the staged configurations. The
+	// certificates which are not referred to by the configurations in use
+	// afterwards are removed
+	if err := nginx.syncCertificates(nginx.folders, staged); err != nil {
+		return errors.Join(err, nginx.syncCertificates(nginx.folders))
+	} else if err := nginx.commit(ctx, staging, staged); err != nil {
+		return errors.Join(err, nginx.syncCertificates(nginx.folders))
+	}
+	return nginx.syncCertificates(nginx.folders)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Link the contents of the configuration path into the staging folder,
+// except the configuration folders which are copied, so that the staged
+// nginx.conf includes the staged configurations
+func (nginx *nginx) linkConfig(staging string) error {
+	skip := map[string]bool{
+		filepath.Base(nginx.folders.Available.Root): true,
+		filepath.Base(nginx.folders.Enabled.Root):   true,
+	}
+	entries, err := os.ReadDir(nginx.configPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if skip[entry.Name()] || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if err := os.Symlink(filepath.Join(nginx.configPath, entry.Name()), filepath.Join(staging, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	// Return success
+	return nil
+}
+
+// Test the staged configurations, then swap them in and reload, restoring
+// the previous configurations on failure
+func (nginx *nginx) commit(ctx context.Context, staging string, staged *folders.Config) error {
+	if err := nginx.testConfig(staging); err != nil {
+		return err
+	} else if err := nginx.folders.Swap(staged); err != nil {
+		return errors.Join(err, nginx.folders.Reload())
+	} else if err := nginx.reloadWithTrace(ctx); err != nil {
+		return errors.Join(err, nginx.rollback(ctx, staged))
+	} else if err := nginx.health(ctx); err != nil {
+		return errors.Join(err, nginx.rollback(ctx, staged))
+	}
+
+	// Return success
+	return nil
+}
+
+// Apply a change to the staged configurations
+func (nginx *nginx) applyOp(staged *folders.Config, op BatchOp) error {
+	switch op.Op {
+	case BatchCreate:
+		if op.Body != "" && op.Template != "" {
+			return ErrBadParameter.With("cannot use both body and template")
+		} else if op.Body == "" && op.Template == "" {
+			return ErrBadParameter.With("missing body or template")
+		}
+		body := []byte(op.Body)
+		if op.Template != "" {
+			if rendered, err := nginx.templates.Render(op.Template, op.Params); err != nil {
+				return err
+			} else {
+				body = rendered
+			}
+		}
+		if err := staged.Create(op.Name, body); err != nil {
+			return err
+		}
+		return staged.SetParams(op.Name, op.Template, op.Params)
+	case BatchEnable:
+		if tmpl := staged.Template(op.Name); tmpl == nil {
+			return ErrNotFound.Withf("configuration: %q", op.Name)
+		} else if tmpl.Enabled {
+			return nil
+		}
+		return staged.Enable(op.Name)
+	case BatchDisable:
+		if tmpl := staged.Template(op.Name); tmpl == nil {
+			return ErrNotFound.Withf("configuration: %q", op.Name)
+		} else if !tmpl.Enabled {
+			return nil
+		}
+		return staged.Disable(op.Name)
+	case BatchDelete:
+		return staged.Delete(op.Name)
+	default:
+		return ErrBadParameter.Withf("invalid operation %q", op.Op)
+	}
+}
+
+// Check nginx is still running a short time after a reload
+func (nginx *nginx) health(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+	case <-time.After(defaultHealthDelay):
+	}
+	if err := nginx.run.Signal(syscall.Signal(0)); err != nil {
+		return ErrInternalAppError.Withf("nginx is not running: %v", err)
+	}
+	return nil
+}
+
+// Restore the previous configurations, and reload nginx. Returns an error
+// if nginx is not running, in which case the previous configurations are
+// used when it is restarted
+func (nginx *nginx) rollback(ctx context.Context, staged *folders.Config) error {
+	if err := nginx.folders.Swap(staged); err != nil {
+		return err
+	} else if nginx.run.Pid() == 0 {
+		return ErrInternalAppError.With("nginx is not running, the previous configurations are restored for when it restarts")
+	}
+	return nginx.reloadWithTrace(ctx)
+}
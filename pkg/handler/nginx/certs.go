@@ -0,0 +1,150 @@
+package nginx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	// Packages
+	folders "github.com/mutablelogic/go-server/pkg/handler/nginx/folders"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	certDirMode  = 0700
+	certFileMode = 0600
+	certExt      = ".pem"
+)
+
+var (
+	reSerial   = regexp.MustCompile(`^[0-9]+$`)
+	reCertFile = regexp.MustCompile(`^([0-9]+)` + regexp.QuoteMeta(certExt) + `$`)
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the path of the file for a certificate with a serial number, which
+// is used for both the ssl_certificate and ssl_certificate_key directives.
+// The file is written by syncCertificates once a configuration which refers
+// to it is committed
+func (nginx *nginx) certificate(serial string) (string, error) {
+	if nginx.certManager == nil {
+		return "", ErrNotImplemented.With("no certificate manager")
+	} else if !reSerial.MatchString(serial) {
+		return "", ErrBadParameter.Withf("invalid serial number %q", serial)
+	}
+
+	// Check the certificate
+	if cert, err := nginx.certManager.Read(serial); err != nil {
+		return "", err
+	} else if cert.IsCA() {
+		return "", ErrBadParameter.Withf("certificate %q is a certificate authority", serial)
+	}
+
+	// Return the path
+	return nginx.certPath(serial), nil
+}
+
+// Write the certificates which the configurations refer to, and remove the
+// certificates which are no longer referred to
+func (nginx *nginx) syncCertificates(configs ...*folders.Config) error {
+	serials, err := nginx.certReferences(configs...)
+	if err != nil {
+		return err
+	}
+
+	// Write the certificates which do not exist
+	var result error
+	for serial := range serials {
+		if _, err := os.Stat(nginx.certPath(serial)); errors.Is(err, os.ErrNotExist) {
+			result = errors.Join(result, nginx.writeCertificate(serial))
+		} else if err != nil {
+			result = errors.Join(result, err)
+		}
+	}
+
+	// Remove the certificates which are not referred to
+	entries, err := os.ReadDir(filepath.Join(nginx.configPath, defaultCertPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return result
+	} else if err != nil {
+		return errors.Join(result, err)
+	}
+	for _, entry := range entries {
+		if match := reCertFile.FindStringSubmatch(entry.Name()); match == nil || entry.IsDir() {
+			continue
+		} else if _, exists := serials[match[1]]; !exists {
+			result = errors.Join(result, os.Remove(nginx.certPath(match[1])))
+		}
+	}
+
+	// Return any errors
+	return result
+}
+
+// Return the serial numbers of the certificates which the configurations
+// refer to
+func (nginx *nginx) certReferences(configs ...*folders.Config) (map[string]bool, error) {
+	prefix := filepath.Join(nginx.configPath, defaultCertPath) + string(filepath.Separator)
+	re, err := regexp.Compile(regexp.QuoteMeta(prefix) + `([0-9]+)` + regexp.QuoteMeta(certExt))
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool)
+	for _, config := range configs {
+		for _, tmpl := range config.Templates() {
+			body, err := config.Render(tmpl.Name)
+			if err != nil {
+				return nil, err
+			}
+			for _, match := range re.FindAllStringSubmatch(string(body), -1) {
+				result[match[1]] = true
+			}
+		}
+	}
+	return result, nil
+}
+
+// Write the certificate and private key with a serial number
+func (nginx *nginx) writeCertificate(serial string) error {
+	if nginx.certManager == nil {
+		return ErrNotImplemented.With("no certificate manager")
+	}
+	cert, err := nginx.certManager.Read(serial)
+	if err != nil {
+		return err
+	}
+
+	// Create the folder for certificates
+	if err := os.MkdirAll(filepath.Join(nginx.configPath, defaultCertPath), certDirMode); err != nil {
+		return err
+	}
+
+	// Write the certificate and private key
+	path := nginx.certPath(serial)
+	fh, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, certFileMode)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	if err := cert.WriteCertificate(fh); err != nil {
+		return errors.Join(err, os.Remove(path))
+	} else if err := cert.WritePrivateKey(fh); err != nil {
+		return errors.Join(err, os.Remove(path))
+	}
+
+	// Return success
+	return nil
+}
+
+// Return the path of the file for a certificate with a serial number
+func (nginx *nginx) certPath(serial string) string {
+	return filepath.Join(nginx.configPath, defaultCertPath, serial+certExt)
+}
@@ -9,7 +9,6 @@ import (
 	"os/exec"
 	"path/filepath"
 	"sync"
-	"syscall"
 	"time"
 
 	// Packages
@@ -22,9 +21,12 @@ import (
 ///////////////////////////////////////////////////////////////////////////////
 // TYPES
 
-// Cmd represents the lifecycle of a command
+// Cmd represents the lifecycle of a command. The process state is guarded by
+// a mutex, so that it can be read while the command is running
 type Cmd struct {
+	sync.RWMutex
 	cmd       *exec.Cmd
+	state     *os.ProcessState
 	path      string
 	args, env []string
 	wd        string
@@ -35,11 +37,8 @@ type Cmd struct {
 	// Stderr callback function
 	Err CallbackFn
 
-	// The time the command was started
-	Start time.Time
-
-	// The time the command was stopped
-	Stop time.Time
+	// The time the command was started and stopped
+	start, stop time.Time
 }
 
 // Callback output from the command. Newlines are embedded and should be removed
@@ -78,59 +77,24 @@ func New(cmd string, args ...string) (*Cmd, error) {
 func (c *Cmd) Run() error {
 	var wg sync.WaitGroup
 
-	// If the command is already running, return an error
-	if c.Pid() > 0 {
-		return ErrOutOfOrder.Withf("Command is already running: %q", c.path)
-	}
-
-	// Create a new command
-	c.cmd = exec.Command(c.path, c.args...)
-	if len(c.env) > 0 {
-		c.cmd.Env = c.env
-	}
-
-	// Set the working directory
-	c.cmd.Dir = c.wd
-
-	// Pipes for reading stdout and stderr
-	if c.Out != nil {
-		stdout, err := c.cmd.StdoutPipe()
-		if err != nil {
-			return err
-		}
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			c.read(stdout, c.Out)
-		}()
-	}
-	if c.Err != nil {
-		stderr, err := c.cmd.StderrPipe()
-		if err != nil {
-			return err
-		}
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			c.read(stderr, c.Err)
-		}()
-	}
-
-	// Start command, mark start and stop times
-	c.Start = time.Now()
-	c.Stop = time.Time{}
-	defer func() {
-		c.Stop = time.Now()
-	}()
-	if err := c.cmd.Start(); err != nil {
+	// Start the command
+	cmd, err := c.run(&wg)
+	if err != nil {
 		return err
 	}
 
 	// Wait for stdout and stderr to be closed
 	wg.Wait()
 
-	// Wait for command to exit, return any errors
-	return c.cmd.Wait()
+	// Wait for command to exit, and mark the stop time
+	err = cmd.Wait()
+	c.Lock()
+	defer c.Unlock()
+	c.state = cmd.ProcessState
+	c.stop = time.Now()
+
+	// Return any errors
+	return err
 }
 
 // Path returns the path of the executable
@@ -160,45 +124,128 @@ func (c *Cmd) SetDir(dir string) {
 	c.wd = dir
 }
 
-// Return whether command has exited
+// Return the time the command was last started, or zero if it has
+// not been started
+func (c *Cmd) StartTime() time.Time {
+	c.RLock()
+	defer c.RUnlock()
+	return c.start
+}
+
+// Return the time the command last stopped, or zero if it has not stopped
+func (c *Cmd) StopTime() time.Time {
+	c.RLock()
+	defer c.RUnlock()
+	return c.stop
+}
+
+// Return whether command has exited, or was terminated by a signal
 func (c *Cmd) Exited() bool {
-	if c.cmd == nil {
-		return false
-	} else if c.cmd.ProcessState == nil {
-		return false
+	c.RLock()
+	defer c.RUnlock()
+	return c.state != nil
+}
+
+// Return the exit code of the command, or -1 if it has not exited or
+// was terminated by a signal
+func (c *Cmd) ExitCode() int {
+	c.RLock()
+	defer c.RUnlock()
+	if c.state == nil {
+		return -1
 	} else {
-		return c.cmd.ProcessState.Exited()
+		return c.state.ExitCode()
 	}
 }
 
 // Return the pid of the process or 0
 func (c *Cmd) Pid() int {
-	if c.cmd == nil {
-		return 0
-	} else if c.cmd.Process == nil {
-		return 0
-	} else if c.Exited() {
-		return 0
-	} else {
-		return c.cmd.Process.Pid
-	}
+	c.RLock()
+	defer c.RUnlock()
+	return c.pid()
 }
 
 // Send signal to the process
 func (c *Cmd) Signal(s os.Signal) error {
-	pid := c.Pid()
-	if pid == 0 || c.Exited() {
+	c.RLock()
+	defer c.RUnlock()
+	if c.pid() == 0 {
+		return ErrOutOfOrder.With("Cannot signal exited process")
+	} else if err := c.cmd.Process.Signal(s); errors.Is(err, os.ErrProcessDone) {
 		return ErrOutOfOrder.With("Cannot signal exited process")
-	} else if err := syscall.Kill(pid, s.(syscall.Signal)); err != nil {
-		return err
 	} else {
-		return nil
+		return err
 	}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// Create and start the command, which should not already be running. The
+// wait group is incremented for each output which is read
+func (c *Cmd) run(wg *sync.WaitGroup) (*exec.Cmd, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	// If the command is already running, return an error
+	if c.pid() > 0 {
+		return nil, ErrOutOfOrder.Withf("Command is already running: %q", c.path)
+	}
+
+	// Create a new command
+	cmd := exec.Command(c.path, c.args...)
+	if len(c.env) > 0 {
+		cmd.Env = c.env
+	}
+
+	// Set the working directory
+	cmd.Dir = c.wd
+
+	// Pipes for reading stdout and stderr
+	if c.Out != nil {
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.read(stdout, c.Out)
+		}()
+	}
+	if c.Err != nil {
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			return nil, err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.read(stderr, c.Err)
+		}()
+	}
+
+	// Start command, mark start and stop times
+	c.cmd, c.state = cmd, nil
+	c.start, c.stop = time.Now(), time.Time{}
+	if err := cmd.Start(); err != nil {
+		c.stop = time.Now()
+		return nil, err
+	}
+
+	// Return the running command
+	return cmd, nil
+}
+
+// Return the pid of the process or 0. The command should be locked
+func (c *Cmd) pid() int {
+	if c.cmd == nil || c.cmd.Process == nil || c.state != nil {
+		return 0
+	} else {
+		return c.cmd.Process.Pid
+	}
+}
+
 // isFileExecAny returns true if the file mode is executable by any user
 func isFileExecAny(mode os.FileMode) bool {
 	return mode&0111 != 0
@@ -208,6 +255,10 @@ func (t *Cmd) read(r io.ReadCloser, fn CallbackFn) {
 	buf := bufio.NewReader(r)
 	for {
 		if line, err := buf.ReadBytes('\n'); errors.Is(err, io.EOF) {
+			// Output the last line when it is not terminated by a newline
+			if len(line) > 0 {
+				fn(line)
+			}
 			return
 		} else if err != nil && t.Err != nil {
 			t.Err([]byte(err.Error()))
@@ -2,10 +2,16 @@ package nginx_test
 
 import (
 	"bytes"
+	"errors"
+	"sync"
+	"syscall"
 	"testing"
 
 	// Packages
 	cmd "github.com/mutablelogic/go-server/pkg/handler/nginx/cmd"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
 )
 
 func Test_Cmd_000(t *testing.T) {
@@ -54,3 +60,67 @@ func Test_Cmd_003(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func Test_Cmd_004(t *testing.T) {
+	// A command which is terminated by a signal has exited, and can be run again
+	killed, err := cmd.New("sh", "-c", "kill -KILL $$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := killed.Run(); err == nil {
+		t.Error("Expected an error")
+	}
+	if !killed.Exited() || killed.Pid() != 0 || killed.ExitCode() != -1 {
+		t.Error("Expected command to have exited", killed.Exited(), killed.Pid(), killed.ExitCode())
+	}
+	if err := killed.Run(); errors.Is(err, ErrOutOfOrder) {
+		t.Error("Expected command to run again")
+	}
+
+	// The exit code of a command
+	exited, err := cmd.New("sh", "-c", "exit 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exited.Run(); err == nil {
+		t.Error("Expected an error")
+	} else if exited.ExitCode() != 3 {
+		t.Error("Expected exit code 3, got", exited.ExitCode())
+	}
+}
+
+func Test_Cmd_005(t *testing.T) {
+	// The state of a command can be read while it is run again
+	sleep, err := cmd.New("sh", "-c", "sleep 0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				if sleep.Pid() > 0 && sleep.StartTime().IsZero() {
+					t.Error("Expected a start time")
+				}
+				sleep.Exited()
+				sleep.Signal(syscall.Signal(0))
+			}
+		}
+	}()
+	for i := 0; i < 2; i++ {
+		if err := sleep.Run(); err != nil {
+			t.Error(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+	if !sleep.Exited() || sleep.StopTime().Before(sleep.StartTime()) {
+		t.Error("Expected command to have exited")
+	}
+}
@@ -17,14 +17,16 @@ import (
 // TYPES
 
 type Config struct {
-	BinaryPath string            `hcl:"binary_path" description:"Path to nginx binary"`
-	ConfigPath string            `hcl:"config" description:"Path to persistent configuration"`
-	DataPath   string            `hcl:"data" description:"Path to ephermeral data directory"`
-	LogPath    string            `hcl:"log" description:"Path to log directory"`
-	LogRotate  time.Duration     `hcl:"log_rotate_period" description:"TODO: Period for log rotations (1d)"`
-	LogKeep    time.Duration     `hcl:"log_keep_period" description:"TODO: Period for log deletions (28d)"`
-	Env        map[string]string `hcl:"env" description:"Environment variables to set"`
-	Directives map[string]string `hcl:"directives" description:"Directives to set in nginx configuration"`
+	BinaryPath   string            `hcl:"binary_path" description:"Path to nginx binary"`
+	ConfigPath   string            `hcl:"config" description:"Path to persistent configuration"`
+	DataPath     string            `hcl:"data" description:"Path to ephermeral data directory"`
+	LogPath      string            `hcl:"log" description:"Path to log directory"`
+	LogRotate    time.Duration     `hcl:"log_rotate_period" description:"TODO: Period for log rotations (1d)"`
+	LogKeep      time.Duration     `hcl:"log_keep_period" description:"TODO: Period for log deletions (28d)"`
+	Env          map[string]string `hcl:"env" description:"Environment variables to set"`
+	Directives   map[string]string `hcl:"directives" description:"Directives to set in nginx configuration"`
+	CertManager  CertManager       `hcl:"certmanager" description:"Certificate manager, which provides certificates by serial number for templates"`
+	RestartLimit uint              `hcl:"restart_limit" description:"Number of times nginx can exit unexpectedly in succession before the task fails (5)"`
 
 	// Private fields
 	deletePaths []string
@@ -43,7 +45,10 @@ const (
 	defaultConfExt       = ".conf"
 	defaultConfDirMode   = 0755
 	defaultConfRecursive = true
-	defaultLogPath       = "logs" // Relative to the ConfigPath
+	defaultTemplatePath  = "templates" // Relative to the ConfigPath
+	defaultTemplateExt   = ".tmpl"
+	defaultCertPath      = "certs" // Relative to the ConfigPath
+	defaultLogPath       = "logs"  // Relative to the ConfigPath
 )
 
 ///////////////////////////////////////////////////////////////////////////////
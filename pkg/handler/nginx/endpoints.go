@@ -5,11 +5,16 @@ import (
 	"errors"
 	"net/http"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	// Packages
 	server "github.com/mutablelogic/go-server"
+	events "github.com/mutablelogic/go-server/pkg/handler/nginx/events"
 	folders "github.com/mutablelogic/go-server/pkg/handler/nginx/folders"
+	status "github.com/mutablelogic/go-server/pkg/handler/nginx/status"
+	templates "github.com/mutablelogic/go-server/pkg/handler/nginx/templates"
 	router "github.com/mutablelogic/go-server/pkg/handler/router"
 	httprequest "github.com/mutablelogic/go-server/pkg/httprequest"
 	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
@@ -22,14 +27,26 @@ import (
 // TYPES
 
 type responseHealth struct {
-	Version string `json:"version"`
-	Uptime  uint64 `json:"uptime"`
+	Version  string         `json:"version"`
+	Uptime   uint64         `json:"uptime"`
+	Running  bool           `json:"running"`
+	Restarts uint           `json:"restarts"`
+	ExitCode *int           `json:"exit_code,omitempty"` // Exit code when nginx last exited
+	Stderr   []string       `json:"stderr,omitempty"`    // Most recent lines of stderr
+	Status   *status.Status `json:"status,omitempty"`    // Counters from the stub_status page
 }
 
 type responseTemplate struct {
-	Name    string `json:"name,omitempty"`
-	Enabled *bool  `json:"enabled,omitempty"` // Can be used for PATCH
-	Body    string `json:"body,omitempty"`    // Can be used for PATCH
+	Name     string         `json:"name,omitempty"`
+	Hash     string         `json:"hash,omitempty"`
+	Enabled  *bool          `json:"enabled,omitempty"`  // Can be used for PATCH
+	Body     string         `json:"body,omitempty"`     // Can be used for PATCH
+	Template string         `json:"template,omitempty"` // Can be used for PATCH
+	Params   map[string]any `json:"params,omitempty"`   // Can be used for PATCH
+}
+
+type requestBatch struct {
+	Ops []BatchOp `json:"ops"`
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -42,14 +59,24 @@ var _ server.ServiceEndpoints = (*nginx)(nil)
 // GLOBALS
 
 const (
-	jsonIndent = 2
+	jsonIndent         = 2
+	headerETag         = "ETag"
+	headerCacheControl = "Cache-Control"
+	headerIfMatch      = "If-Match"
+	headerLastEventId  = "Last-Event-ID"
+	noTransform        = "no-transform"
 )
 
 var (
 	reRoot       = regexp.MustCompile(`^/?$`)
-	reAction     = regexp.MustCompile(`^/(test|reload|reopen)/?$`)
+	reAction     = regexp.MustCompile(`^/(?P<action>test|reload|reopen)/?$`)
 	reListConfig = regexp.MustCompile(`^/config/?$`)
-	reConfig     = regexp.MustCompile(`^/config/(.*)$`)
+	reConfig     = regexp.MustCompile(`^/config/(?P<name>.*)$`)
+	reListTmpl   = regexp.MustCompile(`^/template/?$`)
+	reTmpl       = regexp.MustCompile(`^/template/(?P<name>.*)$`)
+	reBatch      = regexp.MustCompile(`^/batch/?$`)
+	reLogs       = regexp.MustCompile(`^/logs/?$`)
+	reEvents     = regexp.MustCompile(`^/events/?$`)
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -60,44 +87,95 @@ func (service *nginx) AddEndpoints(ctx context.Context, r server.Router) {
 	// Path: /
 	// Methods: GET
 	// Scopes: read
-	// Description: Get nginx status (version, uptime, available and enabled configurations)
 	r.AddHandlerFuncRe(ctx, reRoot, service.GetHealth, http.MethodGet).(router.Route).
-		SetScope(service.ScopeRead()...)
+		SetScope(service.ScopeRead()...).
+		SetSummary("Get nginx status (version, uptime, restarts, recent errors and connection counters)").
+		SetResponse(http.StatusOK, responseHealth{})
 
 	// Path: /(test|reload|reopen)
 	// Methods: PUT
 	// Scopes: write
-	// Description: Test, reload and reopen nginx configuration
 	r.AddHandlerFuncRe(ctx, reAction, service.PutAction, http.MethodPut).(router.Route).
-		SetScope(service.ScopeWrite()...)
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Test, reload and reopen nginx configuration").
+		SetResponse(http.StatusOK, nil)
+
+	// Path: /logs
+	// Methods: GET
+	// Scopes: read
+	r.AddHandlerFuncRe(ctx, reLogs, service.StreamLogs, http.MethodGet).(router.Route).
+		SetScope(service.ScopeRead()...).
+		SetSummary("Stream nginx output and log file lines as server-sent events").
+		SetResponse(http.StatusOK, events.Event{})
+
+	// Path: /events
+	// Methods: GET
+	// Scopes: read
+	r.AddHandlerFuncRe(ctx, reEvents, service.StreamEvents, http.MethodGet).(router.Route).
+		SetScope(service.ScopeRead()...).
+		SetSummary("Stream nginx lifecycle events as server-sent events").
+		SetResponse(http.StatusOK, events.Event{})
 
 	// Path: /config
 	// Methods: GET
 	// Scopes: read
-	// Description: Read the current set of configurations
 	r.AddHandlerFuncRe(ctx, reListConfig, service.ListConfig, http.MethodGet).(router.Route).
-		SetScope(service.ScopeRead()...)
+		SetScope(service.ScopeRead()...).
+		SetSummary("Read the current set of configurations").
+		SetResponse(http.StatusOK, []responseTemplate{})
 
 	// Path: /config
 	// Methods: POST
 	// Scopes: write
-	// Description: Create a new configuration
 	r.AddHandlerFuncRe(ctx, reListConfig, service.CreateConfig, http.MethodPost).(router.Route).
-		SetScope(service.ScopeWrite()...)
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Create a new configuration").
+		SetRequest(responseTemplate{}).
+		SetResponse(http.StatusOK, responseTemplate{})
 
 	// Path: /config/{id}
 	// Methods: GET
 	// Scopes: read
-	// Description: Read a configuration
 	r.AddHandlerFuncRe(ctx, reConfig, service.ReadConfig, http.MethodGet).(router.Route).
-		SetScope(service.ScopeRead()...)
+		SetScope(service.ScopeRead()...).
+		SetSummary("Read a configuration").
+		SetResponse(http.StatusOK, responseTemplate{})
 
 	// Path: /config/{id}
 	// Methods: DELETE, POST, PATCH
 	// Scopes: write
-	// Description: Modify a configuration
 	r.AddHandlerFuncRe(ctx, reConfig, service.WriteConfig, http.MethodDelete, http.MethodPatch).(router.Route).
-		SetScope(service.ScopeWrite()...)
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Modify a configuration").
+		SetRequest(responseTemplate{}).
+		SetResponse(http.StatusOK, nil).
+		SetResponse(http.StatusNotModified, nil).
+		SetResponse(http.StatusPreconditionFailed, nil)
+
+	// Path: /batch
+	// Methods: POST
+	// Scopes: write
+	r.AddHandlerFuncRe(ctx, reBatch, service.ApplyBatch, http.MethodPost).(router.Route).
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Apply a batch of changes to configurations, which are tested and rolled back on failure").
+		SetRequest(requestBatch{}).
+		SetResponse(http.StatusOK, []responseTemplate{})
+
+	// Path: /template
+	// Methods: GET
+	// Scopes: read
+	r.AddHandlerFuncRe(ctx, reListTmpl, service.ListTemplate, http.MethodGet).(router.Route).
+		SetScope(service.ScopeRead()...).
+		SetSummary("Read the templates for configurations, and their parameters").
+		SetResponse(http.StatusOK, []templates.Template{})
+
+	// Path: /template/{id}
+	// Methods: GET
+	// Scopes: read
+	r.AddHandlerFuncRe(ctx, reTmpl, service.ReadTemplate, http.MethodGet).(router.Route).
+		SetScope(service.ScopeRead()...).
+		SetSummary("Read a template and its parameters").
+		SetResponse(http.StatusOK, templates.Template{})
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -105,10 +183,29 @@ func (service *nginx) AddEndpoints(ctx context.Context, r server.Router) {
 
 // Get nginx status
 func (service *nginx) GetHealth(w http.ResponseWriter, r *http.Request) {
-	httpresponse.JSON(w, responseHealth{
-		Version: string(service.Version()),
-		Uptime:  uint64(time.Since(service.run.Start).Seconds()),
-	}, http.StatusOK, jsonIndent)
+	restarts, exitCode := service.supervision()
+	response := responseHealth{
+		Version:  string(service.Version()),
+		Running:  service.run.Pid() > 0,
+		Restarts: restarts,
+		ExitCode: exitCode,
+		Stderr:   service.stderr(defaultStderrLines),
+		Status:   service.status(r.Context()),
+	}
+	if response.Running {
+		response.Uptime = uint64(time.Since(service.run.StartTime()).Seconds())
+	}
+	httpresponse.JSON(w, response, http.StatusOK, jsonIndent)
+}
+
+// Stream log lines
+func (service *nginx) StreamLogs(w http.ResponseWriter, r *http.Request) {
+	service.stream(w, r, service.logs)
+}
+
+// Stream lifecycle events
+func (service *nginx) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	service.stream(w, r, service.lifecycle)
 }
 
 // Do nginx action
@@ -116,17 +213,17 @@ func (service *nginx) PutAction(w http.ResponseWriter, r *http.Request) {
 	urlParameters := router.Params(r.Context())
 	switch urlParameters[0] {
 	case "test":
-		if err := service.Test(); err != nil {
+		if err := service.testWithTrace(r.Context()); err != nil {
 			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 	case "reload":
-		if err := service.Reload(); err != nil {
+		if err := service.reloadWithTrace(r.Context()); err != nil {
 			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 	case "reopen":
-		if err := service.Reopen(); err != nil {
+		if err := service.reopenWithTrace(r.Context()); err != nil {
 			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
 			return
 		}
@@ -164,31 +261,61 @@ func (service *nginx) ReadConfig(w http.ResponseWriter, r *http.Request) {
 		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	} else {
-		httpresponse.JSON(w, response, http.StatusOK, jsonIndent)
+		// The response is not compressed, so that the tag remains strong
+		httpresponse.JSON(w, response, http.StatusOK, jsonIndent, headerETag, etag(tmpl.Hash), headerCacheControl, noTransform)
 	}
 }
 
 // Create a new configuration
 func (service *nginx) CreateConfig(w http.ResponseWriter, r *http.Request) {
 	var create responseTemplate
+
+	// Read the request before locking
 	if err := httprequest.Body(&create, r); err != nil {
 		httpresponse.Error(w, http.StatusBadRequest, err.Error())
 		return
-	} else if create.Name == "" {
+	}
+
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	if create.Name == "" {
 		httpresponse.Error(w, http.StatusBadRequest, "Missing name")
 		return
-	} else if create.Body == "" {
-		httpresponse.Error(w, http.StatusBadRequest, "Missing body")
+	} else if create.Body == "" && create.Template == "" {
+		httpresponse.Error(w, http.StatusBadRequest, "Missing body or template")
+		return
+	} else if create.Body != "" && create.Template != "" {
+		httpresponse.Error(w, http.StatusBadRequest, "Cannot use both body and template")
 		return
 	} else if tmpl := service.folders.Template(create.Name); tmpl != nil {
 		httpresponse.Error(w, http.StatusConflict)
 		return
 	}
 
-	// Create the configuration
+	// Render the template with the parameters
+	if create.Template != "" {
+		if body, err := service.templates.Render(create.Template, create.Params); err != nil {
+			httpresponse.Error(w, errorStatus(err), err.Error())
+			return
+		} else {
+			create.Body = string(body)
+		}
+	}
+
+	// Create the configuration, and keep the template and parameters so that
+	// it can be rendered again. Then write the certificates it refers to
 	if err := service.folders.Create(create.Name, []byte(create.Body)); err != nil {
 		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
 		return
+	} else if err := service.folders.SetParams(create.Name, create.Template, create.Params); err != nil {
+		err = errors.Join(err, service.folders.Delete(create.Name))
+		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	} else if err := service.syncCertificates(service.folders); err != nil {
+		err = errors.Join(err, service.folders.Delete(create.Name), service.syncCertificates(service.folders))
+		httpresponse.Error(w, errorStatus(err), err.Error())
+		return
 	}
 
 	// Check if we need to enable the configuration
@@ -196,12 +323,11 @@ func (service *nginx) CreateConfig(w http.ResponseWriter, r *http.Request) {
 		if err := service.folders.Enable(create.Name); err != nil {
 			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
 			return
-		} else if err := service.Test(); err != nil {
+		} else if err := service.testWithTrace(r.Context()); err != nil {
 			// Rollback
-			err = errors.Join(err, service.folders.Delete(create.Name))
-			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+			serveError(w, errors.Join(err, service.folders.Delete(create.Name), service.syncCertificates(service.folders)))
 			return
-		} else if err := service.Reload(); err != nil {
+		} else if err := service.reloadWithTrace(r.Context()); err != nil {
 			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
 			return
 		}
@@ -222,17 +348,34 @@ func (service *nginx) CreateConfig(w http.ResponseWriter, r *http.Request) {
 
 // PATCH or DELETE a configuration
 func (service *nginx) WriteConfig(w http.ResponseWriter, r *http.Request) {
+	// Read the request before locking
+	var patch responseTemplate
+	if r.Method == http.MethodPatch {
+		if err := httprequest.Body(&patch, r); err != nil {
+			httpresponse.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	// Check the configuration exists, and has not been changed since it was
+	// read when there is an If-Match header
 	urlParameters := router.Params(r.Context())
 	templ := service.folders.Template(urlParameters[0])
 	if templ == nil {
 		httpresponse.Error(w, http.StatusNotFound)
 		return
+	} else if !ifMatch(r.Header.Get(headerIfMatch), templ.Hash) {
+		httpresponse.Error(w, http.StatusPreconditionFailed, "Configuration has been changed")
+		return
 	}
 	switch r.Method {
 	case http.MethodDelete:
 		service.DeleteConfig(templ, w, r)
 	case http.MethodPatch:
-		service.PatchConfig(templ, w, r)
+		service.PatchConfig(templ, patch, w, r)
 	default:
 		httpresponse.Error(w, http.StatusMethodNotAllowed, r.Method)
 	}
@@ -245,15 +388,19 @@ func (service *nginx) DeleteConfig(tmpl *folders.Template, w http.ResponseWriter
 		if err := service.folders.Disable(tmpl.Name); err != nil {
 			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
 			return
-		} else if err := service.Reload(); err != nil {
+		} else if err := service.reloadWithTrace(r.Context()); err != nil {
 			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 	}
 
-	// Delete it
+	// Delete it, and remove the certificates which are no longer referred to
 	if err := service.folders.Delete(tmpl.Name); err != nil {
 		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	} else if err := service.syncCertificates(service.folders); err != nil {
+		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	// Respond with no content
@@ -261,85 +408,253 @@ func (service *nginx) DeleteConfig(tmpl *folders.Template, w http.ResponseWriter
 }
 
 // Update a configuration
-func (service *nginx) PatchConfig(tmpl *folders.Template, w http.ResponseWriter, r *http.Request) {
-	var patch responseTemplate
+func (service *nginx) PatchConfig(tmpl *folders.Template, patch responseTemplate, w http.ResponseWriter, r *http.Request) {
 	var modified bool
-	if err := httprequest.Body(&patch, r); err != nil {
-		httpresponse.Error(w, http.StatusBadRequest, err.Error())
-		return
+
+	// Template - render the template again, with a new template or parameters
+	source, params := "", map[string]any(nil)
+	if patch.Template != "" || patch.Params != nil {
+		if patch.Body != "" {
+			httpresponse.Error(w, http.StatusBadRequest, "Cannot use both body and template")
+			return
+		}
+		source, params = tmpl.Source, tmpl.Params
+		if patch.Template != "" {
+			source = patch.Template
+		}
+		if patch.Params != nil {
+			params = patch.Params
+		}
+		if source == "" {
+			httpresponse.Error(w, http.StatusBadRequest, "Configuration is not rendered from a template")
+			return
+		}
+		if body, err := service.templates.Render(source, params); err != nil {
+			httpresponse.Error(w, errorStatus(err), err.Error())
+			return
+		} else {
+			patch.Body = string(body)
+		}
 	}
 
-	// Body - currently only disabled configurations can have their body changed TODO: change this
+	// Restore the previous body, parameters and enabled state when the
+	// update fails, and then the certificates
+	var previous []byte
+	rollback := func(err error) error {
+		if previous != nil {
+			err = errors.Join(err, service.folders.Write(tmpl.Name, previous), service.folders.SetParams(tmpl.Name, tmpl.Source, tmpl.Params))
+		}
+		if updated := service.folders.Template(tmpl.Name); updated != nil && updated.Enabled != tmpl.Enabled {
+			if tmpl.Enabled {
+				err = errors.Join(err, service.folders.Enable(tmpl.Name))
+			} else {
+				err = errors.Join(err, service.folders.Disable(tmpl.Name))
+			}
+		}
+		return errors.Join(err, service.syncCertificates(service.folders))
+	}
+
+	// Body - write the certificates which the body refers to, and remove
+	// those which are no longer referred to
 	if patch.Body != "" {
-		if patch.Enabled != nil && tmpl.Enabled {
-			httpresponse.Error(w, http.StatusForbidden, "Cannot change body of enabled configuration")
+		body, err := service.folders.Render(tmpl.Name)
+		if err != nil {
+			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 		if err := service.folders.Write(tmpl.Name, []byte(patch.Body)); err != nil && errors.Is(err, ErrNotModified) {
 			modified = false
 		} else if err != nil {
-			httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+			serveError(w, rollback(err))
 			return
 		} else {
-			// Force update
-			modified = true
-			patch.Enabled = &tmpl.Enabled
+			modified, previous = true, body
+			if err := service.syncCertificates(service.folders); err != nil {
+				serveError(w, rollback(err))
+				return
+			}
+		}
+
+		// Keep the template and parameters, or remove them when the body
+		// has been replaced
+		if err := service.folders.SetParams(tmpl.Name, source, params); err != nil {
+			serveError(w, rollback(err))
+			return
 		}
 	}
 
-	// Enabled
+	// Enabled - enable or disable the configuration
+	enabled := tmpl.Enabled
 	if patch.Enabled != nil {
-		modified = true
-		switch *patch.Enabled {
-		case true:
-			if !tmpl.Enabled {
-				if err := service.folders.Enable(tmpl.Name); err != nil {
-					httpresponse.Error(w, http.StatusInternalServerError, err.Error())
-					return
-				}
-			}
-			if err := service.Test(); err != nil {
-				// Rollback
-				err = errors.Join(err, service.folders.Disable(tmpl.Name))
-				httpresponse.Error(w, http.StatusInternalServerError, err.Error())
-				return
-			} else if err := service.Reload(); err != nil {
-				httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		modified, enabled = true, *patch.Enabled
+		if enabled && !tmpl.Enabled {
+			if err := service.folders.Enable(tmpl.Name); err != nil {
+				serveError(w, rollback(err))
 				return
 			}
-		case false:
-			if tmpl.Enabled {
-				if err := service.folders.Disable(tmpl.Name); err != nil {
-					httpresponse.Error(w, http.StatusInternalServerError, err.Error())
-					return
-				}
-			}
-			if err := service.Reload(); err != nil {
-				httpresponse.Error(w, http.StatusInternalServerError, err.Error())
+		} else if !enabled && tmpl.Enabled {
+			if err := service.folders.Disable(tmpl.Name); err != nil {
+				serveError(w, rollback(err))
 				return
 			}
 		}
 	}
 
-	// Return OK or Not Modified
+	// Test the configuration once when it is enabled, and reload it,
+	// restoring the previous configuration on failure
 	if modified {
-		httpresponse.Empty(w, http.StatusOK)
+		if enabled {
+			if err := service.testWithTrace(r.Context()); err != nil {
+				serveError(w, rollback(err))
+				return
+			}
+		}
+		if err := service.reloadWithTrace(r.Context()); err != nil {
+			serveError(w, rollback(err))
+			return
+		}
+	}
+
+	// Return OK or Not Modified, with the hash of the configuration
+	if updated := service.folders.Template(tmpl.Name); updated == nil {
+		httpresponse.Error(w, http.StatusNotFound, tmpl.Name)
+	} else if modified {
+		httpresponse.Empty(w, http.StatusOK, headerETag, etag(updated.Hash))
+	} else {
+		httpresponse.Empty(w, http.StatusNotModified, headerETag, etag(updated.Hash))
+	}
+}
+
+// Apply a batch of changes, and respond with the configurations
+func (service *nginx) ApplyBatch(w http.ResponseWriter, r *http.Request) {
+	var batch requestBatch
+	if err := httprequest.Body(&batch, r); err != nil {
+		httpresponse.Error(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err := service.Apply(r.Context(), batch.Ops...); err != nil {
+		serveError(w, err)
+		return
+	}
+	service.ListConfig(w, r)
+}
+
+// List templates
+func (service *nginx) ListTemplate(w http.ResponseWriter, r *http.Request) {
+	if result, err := service.templates.Templates(); err != nil {
+		httpresponse.Error(w, http.StatusInternalServerError, err.Error())
 	} else {
-		httpresponse.Empty(w, http.StatusNotModified)
+		httpresponse.JSON(w, result, http.StatusOK, jsonIndent)
+	}
+}
+
+// Return a single template
+func (service *nginx) ReadTemplate(w http.ResponseWriter, r *http.Request) {
+	urlParameters := router.Params(r.Context())
+	if tmpl, err := service.templates.Template(urlParameters[0]); err != nil {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+	} else {
+		httpresponse.JSON(w, tmpl, http.StatusOK, jsonIndent)
 	}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// Stream events as server-sent events until the request is cancelled, the
+// server is draining, or the client does not keep up. The buffered events after the Last-Event-ID header
+// are sent first, or all buffered events when there is no header
+func (service *nginx) stream(w http.ResponseWriter, r *http.Request, ring *events.Ring) {
+	var since uint64
+	if header := r.Header.Get(headerLastEventId); header != "" {
+		if id, err := strconv.ParseUint(header, 10, 64); err != nil {
+			httpresponse.Error(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		} else {
+			since = id
+		}
+	}
+
+	// Subscribe to events
+	buffered, ch, cancel := ring.Subscribe(since)
+	defer cancel()
+
+	// Write buffered and then new events, until the request is cancelled
+	// or the server is draining
+	stream := httpresponse.NewTextStreamWithContext(r.Context(), w)
+	defer stream.Close()
+	for _, event := range buffered {
+		stream.WriteWithId(strconv.FormatUint(event.Id, 10), event.Type, event)
+	}
+	for {
+		select {
+		case <-stream.Done():
+			return
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			stream.WriteWithId(strconv.FormatUint(event.Id, 10), event.Type, event)
+		}
+	}
+}
+
 func (service *nginx) tmplToResponse(tmpl *folders.Template) (*responseTemplate, error) {
 	if body, err := service.folders.Render(tmpl.Name); err != nil {
 		return nil, err
 	} else {
 		return &responseTemplate{
-			Name:    tmpl.Name,
-			Enabled: &tmpl.Enabled,
-			Body:    string(body),
+			Name:     tmpl.Name,
+			Hash:     tmpl.Hash,
+			Enabled:  &tmpl.Enabled,
+			Body:     string(body),
+			Template: tmpl.Source,
+			Params:   tmpl.Params,
 		}, nil
 	}
 }
+
+// Serve an error, with the output from nginx when a configuration test fails
+func serveError(w http.ResponseWriter, err error) {
+	var test *testError
+	if errors.As(err, &test) {
+		httpresponse.ErrorWith(w, http.StatusBadRequest, test, err.Error())
+	} else {
+		httpresponse.Error(w, errorStatus(err), err.Error())
+	}
+}
+
+// Return the entity tag for a hash
+func etag(hash string) string {
+	return strconv.Quote(hash)
+}
+
+// Return true if an If-Match header is empty, or matches a hash. The strong
+// comparison is used, so weak tags do not match
+func ifMatch(header, hash string) bool {
+	if header = strings.TrimSpace(header); header == "" || header == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); strings.HasPrefix(tag, "W/") {
+			// Weak tags never match
+			continue
+		} else if tag == etag(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the status for an error
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrBadParameter):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrDuplicateEntry):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
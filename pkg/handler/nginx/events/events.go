@@ -0,0 +1,137 @@
+/*
+Keeps a bounded buffer of events, which subscribers can resume from
+*/
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Event is a log line or lifecycle event, with an identifier which increases
+// for each event
+type Event struct {
+	Id      uint64    `json:"id"`
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Source  string    `json:"source,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// Ring is a bounded buffer of events, where the oldest events are
+// discarded when it is full
+type Ring struct {
+	sync.Mutex
+	events []Event
+	next   uint64
+	subs   map[chan Event]bool
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Events which can be queued for a subscriber, before it is closed
+	subscriberCap = 100
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a buffer which keeps the most recent events, up to a size
+func New(size int) *Ring {
+	return &Ring{
+		events: make([]Event, 0, max(size, 1)),
+		next:   1,
+		subs:   make(map[chan Event]bool),
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (e Event) String() string {
+	data, _ := json.MarshalIndent(e, "", "  ")
+	return string(data)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Emit an event with a type, source and message, and send it to subscribers.
+// A subscriber which is not keeping up is closed, and can resume from the
+// last event it received
+func (r *Ring) Emit(kind, source, message string) Event {
+	r.Lock()
+	defer r.Unlock()
+
+	// Add the event, discarding the oldest event when full
+	event := Event{Id: r.next, Time: time.Now(), Type: kind, Source: source, Message: message}
+	r.next++
+	if len(r.events) == cap(r.events) {
+		copy(r.events, r.events[1:])
+		r.events = r.events[:len(r.events)-1]
+	}
+	r.events = append(r.events, event)
+
+	// Send to subscribers
+	for ch := range r.subs {
+		select {
+		case ch <- event:
+		default:
+			delete(r.subs, ch)
+			close(ch)
+		}
+	}
+
+	// Return the event
+	return event
+}
+
+// Return the buffered events after an identifier. If the identifier is
+// zero, or from after the most recent event, all buffered events are
+// returned
+func (r *Ring) Since(id uint64) []Event {
+	r.Lock()
+	defer r.Unlock()
+	return r.since(id)
+}
+
+// Subscribe to events after an identifier, returning the buffered events,
+// a channel for new events, and a function to unsubscribe. The channel is
+// closed when the subscriber does not keep up
+func (r *Ring) Subscribe(id uint64) ([]Event, <-chan Event, func()) {
+	r.Lock()
+	defer r.Unlock()
+
+	ch := make(chan Event, subscriberCap)
+	r.subs[ch] = true
+	return r.since(id), ch, func() {
+		r.Lock()
+		defer r.Unlock()
+		if r.subs[ch] {
+			delete(r.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func (r *Ring) since(id uint64) []Event {
+	if id >= r.next {
+		id = 0
+	}
+	result := make([]Event, 0, len(r.events))
+	for _, event := range r.events {
+		if event.Id > id {
+			result = append(result, event)
+		}
+	}
+	return result
+}
@@ -0,0 +1,66 @@
+package events_test
+
+import (
+	"testing"
+
+	// Packages
+	events "github.com/mutablelogic/go-server/pkg/handler/nginx/events"
+	assert "github.com/stretchr/testify/assert"
+)
+
+func Test_events_001(t *testing.T) {
+	assert := assert.New(t)
+	ring := events.New(3)
+
+	// Events have increasing identifiers
+	for i := 0; i < 5; i++ {
+		event := ring.Emit("stderr", "", "line")
+		assert.Equal(uint64(i+1), event.Id)
+	}
+
+	// Only the most recent events are kept
+	since := ring.Since(0)
+	if assert.Len(since, 3) {
+		assert.Equal(uint64(3), since[0].Id)
+		assert.Equal(uint64(5), since[2].Id)
+	}
+
+	// Resume after an identifier
+	assert.Len(ring.Since(4), 1)
+	assert.Len(ring.Since(5), 0)
+
+	// An identifier from after the most recent event returns all events
+	assert.Len(ring.Since(100), 3)
+}
+
+func Test_events_002(t *testing.T) {
+	assert := assert.New(t)
+	ring := events.New(10)
+	ring.Emit("start", "", "")
+
+	// Subscribe, and receive buffered and new events
+	buffered, ch, cancel := ring.Subscribe(0)
+	assert.Len(buffered, 1)
+	ring.Emit("reload", "", "")
+	event := <-ch
+	assert.Equal("reload", event.Type)
+	assert.Equal(uint64(2), event.Id)
+
+	// Unsubscribe closes the channel
+	cancel()
+	_, ok := <-ch
+	assert.False(ok)
+	cancel()
+
+	// A subscriber which does not keep up is closed
+	_, ch, cancel = ring.Subscribe(0)
+	defer cancel()
+	for i := 0; i < 200; i++ {
+		ring.Emit("stderr", "", "line")
+	}
+	n := 0
+	for range ch {
+		n++
+	}
+	assert.Equal(100, n)
+}
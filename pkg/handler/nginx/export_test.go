@@ -0,0 +1,57 @@
+package nginx
+
+import (
+	// Packages
+	events "github.com/mutablelogic/go-server/pkg/handler/nginx/events"
+	folders "github.com/mutablelogic/go-server/pkg/handler/nginx/folders"
+)
+
+// Read lines from a log file after an offset, and return the new offset
+// and the lines which were read
+func ReadLog(path string, offset int64) (int64, []string) {
+	nginx := &nginx{logs: events.New(defaultEventBuffer)}
+	offset = nginx.readLog(path, offset)
+	var lines []string
+	for _, event := range nginx.logs.Since(0) {
+		lines = append(lines, event.Message)
+	}
+	return offset, lines
+}
+
+// Write chunks of output from nginx, and return the lines which were emitted
+// before and after the process exits
+func LogLines(chunks ...string) ([]string, []string) {
+	nginx := &nginx{logs: events.New(defaultEventBuffer)}
+	write, flush := nginx.logLines(LogStdout)
+	for _, chunk := range chunks {
+		write([]byte(chunk))
+	}
+	var lines []string
+	for _, event := range nginx.logs.Since(0) {
+		lines = append(lines, event.Message)
+	}
+	flush()
+	var flushed []string
+	for _, event := range nginx.logs.Since(0)[len(lines):] {
+		flushed = append(flushed, event.Message)
+	}
+	return lines, flushed
+}
+
+var (
+	IfMatch = ifMatch
+)
+
+const (
+	LogLineMax = defaultLogLineMax
+)
+
+// Remove the certificates in the configuration path which are not referred
+// to by the configurations, and return the serial numbers which are
+func SyncCertificates(configPath string, configs ...*folders.Config) (map[string]bool, error) {
+	nginx := &nginx{configPath: configPath}
+	if err := nginx.syncCertificates(configs...); err != nil {
+		return nil, err
+	}
+	return nginx.certReferences(configs...)
+}
@@ -29,9 +29,11 @@ type Config struct {
 
 // Template represents a configuration template
 type Template struct {
-	Name    string // Name should be unique
-	Hash    string // Hash of the file contents
-	Enabled bool   // Flag indicating if the template is enabled
+	Name    string         // Name should be unique
+	Hash    string         // Hash of the file contents
+	Enabled bool           // Flag indicating if the template is enabled
+	Source  string         // Template which the file was rendered from, or empty
+	Params  map[string]any // Parameters which the file was rendered with
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -100,6 +102,10 @@ func (c *Config) Templates() []*Template {
 		if _, exists := c.Enabled.Files[file.Hash]; exists {
 			tmpl.Enabled = true
 		}
+		if params := c.readParams(file.Path); params != nil {
+			tmpl.Source = params.Source
+			tmpl.Params = params.Params
+		}
 		result = append(result, tmpl)
 	}
 	return result
@@ -210,6 +216,7 @@ func (c *Config) Delete(name string) error {
 
 	// Delete from enabled and available
 	var result error
+	result = errors.Join(result, c.SetParams(name, "", nil))
 	if file, exists := c.Enabled.Files[tmpl.Hash]; exists {
 		result = errors.Join(result, file.Remove())
 	}
@@ -1,6 +1,8 @@
 package folders_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	// Packages
@@ -126,3 +128,126 @@ func Test_config_003(t *testing.T) {
 	assert.Equal(templates[0].Name, "default.conf")
 	assert.False(templates[0].Enabled)
 }
+
+func Test_config_004(t *testing.T) {
+	assert := assert.New(t)
+
+	// Make a config object
+	config, err := folders.New(t.TempDir(), t.TempDir(), ".conf", true)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+
+	// Parameters can only be set for an existing configuration
+	assert.Error(config.SetParams("site.conf", "site.tmpl", nil))
+
+	// Set the template and parameters, which are not a configuration
+	assert.NoError(config.Create("sites/site.conf", []byte("server {}\n")))
+	assert.NoError(config.SetParams("sites/site.conf", "site.tmpl", map[string]any{"Name": "value"}))
+	assert.NoError(config.Reload())
+	templates := config.Templates()
+	if assert.Len(templates, 1) {
+		assert.Equal("site.tmpl", templates[0].Source)
+		assert.Equal(map[string]any{"Name": "value"}, templates[0].Params)
+	}
+
+	// Remove the template and parameters
+	assert.NoError(config.SetParams("sites/site.conf", "", nil))
+	if tmpl := config.Template("sites/site.conf"); assert.NotNil(tmpl) {
+		assert.Empty(tmpl.Source)
+		assert.Nil(tmpl.Params)
+	}
+
+	// Parameters are deleted with the configuration
+	assert.NoError(config.SetParams("sites/site.conf", "site.tmpl", nil))
+	assert.NoError(config.Delete("sites/site.conf"))
+	assert.NoError(config.Create("sites/site.conf", []byte("server {}\n")))
+	if tmpl := config.Template("sites/site.conf"); assert.NotNil(tmpl) {
+		assert.Empty(tmpl.Source)
+	}
+}
+
+func Test_config_005(t *testing.T) {
+	assert := assert.New(t)
+
+	// Make a config object with an enabled configuration, in linked folders
+	root, live := t.TempDir(), t.TempDir()
+	available, enabled, err := folders.Link(live, 0755)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	config, err := folders.New(available, enabled, ".conf", true)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	assert.NoError(config.Create("a.conf", []byte("server {}\n")))
+	assert.NoError(config.SetParams("a.conf", "a.tmpl", nil))
+	assert.NoError(config.Enable("a.conf"))
+
+	// Stage the configuration and change the copy
+	staged, err := config.Stage(root)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	if tmpl := staged.Template("a.conf"); assert.NotNil(tmpl) {
+		assert.True(tmpl.Enabled)
+		assert.Equal("a.tmpl", tmpl.Source)
+	}
+	assert.NoError(staged.Delete("a.conf"))
+	assert.NoError(staged.Create("b.conf", []byte("server {}\n")))
+	assert.NoError(staged.Enable("b.conf"))
+	assert.NotNil(config.Template("a.conf"))
+	assert.Nil(config.Template("b.conf"))
+
+	// Swap in the staged configuration, which replaces the link
+	link, err := os.Readlink(filepath.Join(live, ".folders"))
+	assert.NoError(err)
+	assert.NoError(config.Swap(staged))
+	if other, err := os.Readlink(filepath.Join(live, ".folders")); assert.NoError(err) {
+		assert.NotEqual(link, other)
+		assert.NoDirExists(filepath.Join(live, link))
+	}
+	assert.Equal(available, config.Available.Root)
+	assert.Equal(enabled, config.Enabled.Root)
+	assert.Nil(config.Template("a.conf"))
+	if tmpl := config.Template("b.conf"); assert.NotNil(tmpl) {
+		assert.True(tmpl.Enabled)
+	}
+
+	// Swap again to restore the previous configuration
+	assert.NoError(config.Swap(staged))
+	assert.Nil(config.Template("b.conf"))
+	if tmpl := config.Template("a.conf"); assert.NotNil(tmpl) {
+		assert.True(tmpl.Enabled)
+		assert.Equal("a.tmpl", tmpl.Source)
+	}
+}
+
+func Test_config_006(t *testing.T) {
+	assert := assert.New(t)
+
+	// Existing available and enabled folders are moved into linked folders
+	root := t.TempDir()
+	assert.NoError(os.Mkdir(filepath.Join(root, "available"), 0755))
+	assert.NoError(os.WriteFile(filepath.Join(root, "available", "a.conf"), []byte("server {}\n"), 0644))
+	available, enabled, err := folders.Link(root, 0755)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	assert.Equal(filepath.Join(root, "available"), available)
+	assert.Equal(filepath.Join(root, "enabled"), enabled)
+	if link, err := os.Readlink(available); assert.NoError(err) {
+		assert.Equal(filepath.Join(".folders", "available"), link)
+	}
+	config, err := folders.New(available, enabled, ".conf", true)
+	if assert.NoError(err) {
+		assert.NotNil(config.Template("a.conf"))
+	}
+
+	// Linking again does not change the folders
+	available2, enabled2, err := folders.Link(root, 0755)
+	assert.NoError(err)
+	assert.Equal(available, available2)
+	assert.Equal(enabled, enabled2)
+	assert.FileExists(filepath.Join(available, "a.conf"))
+}
@@ -63,15 +63,21 @@ func (f Folder) String() string {
 func enumerate(root, ext string, recursive bool) ([]*File, error) {
 	var result []*File
 
+	// The root can be a link
+	dir, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+
 	ext = strings.ToLower(ext)
-	if err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		// Skip errors
 		if err != nil {
 			return err
 		}
 
 		// Ignore hidden files
-		if strings.HasPrefix(d.Name(), ".") && path != root {
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
 			if d.IsDir() {
 				return filepath.SkipDir
 			} else {
@@ -80,7 +86,7 @@ func enumerate(root, ext string, recursive bool) ([]*File, error) {
 		}
 
 		// Recurse into directories
-		if d.IsDir() && path != root {
+		if d.IsDir() && path != dir {
 			if recursive {
 				return nil
 			} else {
@@ -107,7 +113,7 @@ func enumerate(root, ext string, recursive bool) ([]*File, error) {
 		}
 
 		// Read the file
-		if relpath, err := filepath.Rel(root, path); err == nil {
+		if relpath, err := filepath.Rel(dir, path); err == nil {
 			if file, err := NewFile(root, relpath); err == nil {
 				result = append(result, file)
 			}
@@ -0,0 +1,80 @@
+package folders
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// params is the template and parameters which a configuration was rendered
+// from, which are stored in a hidden file alongside the configuration in
+// the available folder
+type params struct {
+	Source string         `json:"template"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	paramsExt = ".json"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Set the template and parameters which a configuration was rendered from,
+// so that it can be rendered again. An empty source removes them
+func (c *Config) SetParams(name, source string, values map[string]any) error {
+	if tmpl := c.templateByName(name); tmpl == nil {
+		return ErrNotFound.Withf("template: %q", name)
+	}
+
+	// Remove the parameters
+	path := c.pathForParams(name)
+	if source == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	// Write the parameters
+	data, err := json.MarshalIndent(params{Source: source, Params: values}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the path to the parameters for a configuration, which is hidden
+// so that it is not enumerated as a configuration
+func (c *Config) pathForParams(name string) string {
+	dir, file := filepath.Split(name)
+	return filepath.Join(c.Available.Root, dir, "."+file+paramsExt)
+}
+
+// Read the parameters for a configuration, or return nil if the
+// configuration was not rendered from a template
+func (c *Config) readParams(name string) *params {
+	data, err := os.ReadFile(c.pathForParams(name))
+	if err != nil {
+		return nil
+	}
+	var result params
+	if err := json.Unmarshal(data, &result); err != nil || result.Source == "" {
+		return nil
+	}
+	return &result
+}
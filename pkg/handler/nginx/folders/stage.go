@@ -0,0 +1,209 @@
+package folders
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	swapName = ".swap"
+	linkName = ".folders"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Stage copies the available and enabled folders, including any parameters,
+// into a root folder and returns the configuration for the copy, which can
+// be changed without affecting this configuration
+func (c *Config) Stage(root string) (*Config, error) {
+	available := filepath.Join(root, filepath.Base(c.Available.Root))
+	enabled := filepath.Join(root, filepath.Base(c.Enabled.Root))
+	if available == enabled {
+		enabled = filepath.Join(root, "enabled")
+	}
+	if err := copyDir(available, c.Available.Root, c.DirMode); err != nil {
+		return nil, err
+	} else if err := copyDir(enabled, c.Enabled.Root, c.DirMode); err != nil {
+		return nil, err
+	}
+
+	// Create the configuration for the copy
+	staged, err := New(available, enabled, c.Ext, c.Recursive)
+	if err != nil {
+		return nil, err
+	}
+	staged.DirMode = c.DirMode
+
+	// Return success
+	return staged, nil
+}
+
+// Link creates available and enabled folders within a root folder, which
+// are links into a single folder, so that both can be replaced at once with
+// Swap. Existing available and enabled folders are moved into the single
+// folder. Returns the paths of the available and enabled folders
+func Link(root string, perm fs.FileMode) (string, string, error) {
+	link := filepath.Join(root, linkName)
+	if _, err := os.Lstat(link); errors.Is(err, fs.ErrNotExist) {
+		if dir, err := os.MkdirTemp(root, linkName+"-"); err != nil {
+			return "", "", err
+		} else if err := os.Chmod(dir, perm); err != nil {
+			return "", "", err
+		} else if err := os.Symlink(filepath.Base(dir), link); err != nil {
+			return "", "", err
+		}
+	} else if err != nil {
+		return "", "", err
+	}
+
+	// Link the available and enabled folders
+	var result []string
+	for _, name := range []string{"available", "enabled"} {
+		path, target := filepath.Join(root, name), filepath.Join(linkName, name)
+		if info, err := os.Lstat(path); errors.Is(err, fs.ErrNotExist) {
+			if err := os.MkdirAll(filepath.Join(root, target), perm); err != nil {
+				return "", "", err
+			}
+		} else if err != nil {
+			return "", "", err
+		} else if info.IsDir() {
+			// Move the existing folder, replacing an empty folder
+			os.Remove(filepath.Join(root, target))
+			if err := os.Rename(path, filepath.Join(root, target)); err != nil {
+				return "", "", err
+			}
+		} else if dest, err := os.Readlink(path); err != nil {
+			return "", "", err
+		} else if dest != target {
+			return "", "", ErrBadParameter.Withf("unexpected link: %q", path)
+		} else {
+			result = append(result, path)
+			continue
+		}
+		if err := os.Symlink(target, path); err != nil {
+			return "", "", err
+		}
+		result = append(result, path)
+	}
+
+	// Return the paths
+	return result[0], result[1], nil
+}
+
+// Swap the available and enabled folders with those of another configuration,
+// and reload both. The folders of this configuration should have been created
+// with Link, so that both are replaced at once by replacing the link. Swapping
+// again restores the original files
+func (c *Config) Swap(other *Config) error {
+	root := filepath.Dir(c.Available.Root)
+	available, enabled := filepath.Base(c.Available.Root), filepath.Base(c.Enabled.Root)
+	link := filepath.Join(root, linkName)
+	if filepath.Dir(c.Enabled.Root) != root {
+		return ErrBadParameter.With("available and enabled folders are not linked")
+	}
+	current, err := os.Readlink(link)
+	if err != nil {
+		return ErrBadParameter.Withf("available and enabled folders are not linked: %v", err)
+	}
+
+	// Move the folders of the other configuration into a new folder
+	next, err := os.MkdirTemp(root, linkName+"-")
+	if err != nil {
+		return err
+	} else if err := os.Chmod(next, c.DirMode); err != nil {
+		return errors.Join(err, os.Remove(next))
+	}
+	if err := os.Rename(other.Available.Root, filepath.Join(next, available)); err != nil {
+		return errors.Join(err, os.Remove(next))
+	} else if err := os.Rename(other.Enabled.Root, filepath.Join(next, enabled)); err != nil {
+		return errors.Join(err, os.Rename(filepath.Join(next, available), other.Available.Root), os.Remove(next))
+	}
+
+	// Replace the link, which replaces both folders at once
+	tmp := filepath.Join(root, swapName+linkName)
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(next), tmp); err == nil {
+		err = os.Rename(tmp, link)
+	}
+	if err != nil {
+		return errors.Join(err,
+			os.Rename(filepath.Join(next, available), other.Available.Root),
+			os.Rename(filepath.Join(next, enabled), other.Enabled.Root),
+			os.Remove(next),
+		)
+	}
+
+	// Move the previous folders into the other configuration
+	previous := filepath.Join(root, current)
+	if err := errors.Join(
+		os.Rename(filepath.Join(previous, available), other.Available.Root),
+		os.Rename(filepath.Join(previous, enabled), other.Enabled.Root),
+	); err != nil {
+		return err
+	} else if err := os.Remove(previous); err != nil {
+		return err
+	}
+
+	// Reload both configurations
+	return errors.Join(c.Reload(), other.Reload())
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Copy the files and folders within src to dst, which is created. The src
+// folder can be a link
+func copyDir(dst, src string, perm fs.FileMode) error {
+	src, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, perm)
+		} else if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return copyFile(target, path, info.Mode().Perm())
+	})
+}
+
+// Copy a file with a file mode
+func copyFile(dst, src string, perm fs.FileMode) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return errors.Join(err, w.Close())
+	}
+	return w.Close()
+}
@@ -1,5 +1,13 @@
 package nginx
 
+import (
+	"context"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	certmanager "github.com/mutablelogic/go-server/pkg/handler/certmanager"
+)
+
 ///////////////////////////////////////////////////////////////////////////////
 // TYPES
 
@@ -10,6 +18,10 @@ type Nginx interface {
 	// test the configuration and then reload it (the SIGHUP signal)
 	Reload() error
 
+	// apply a batch of changes to the configurations, test and reload,
+	// restoring the previous configurations on failure
+	Apply(context.Context, ...BatchOp) error
+
 	// reopen log files (the SIGUSR1 signal)
 	Reopen() error
 
@@ -25,3 +37,12 @@ type Nginx interface {
 	// return logfile path
 	LogPath() string
 }
+
+// CertManager provides certificates and private keys, which are written
+// to the configuration folder while a configuration refers to them
+type CertManager interface {
+	server.Task
+
+	// Return a certificate by serial number
+	Read(string) (certmanager.Cert, error)
+}
@@ -0,0 +1,167 @@
+package nginx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+// Log line types
+const (
+	LogStdout = "stdout" // Output from nginx
+	LogStderr = "stderr" // Errors from nginx, including the error log
+	LogAccess = "access" // Line from an access log file
+	LogError  = "error"  // Line from an error log file
+)
+
+// Lifecycle event types
+const (
+	EventStart   = "start"   // nginx was started
+	EventStop    = "stop"    // nginx exited
+	EventRestart = "restart" // nginx is restarted after an unexpected exit
+	EventReload  = "reload"  // The configuration was reloaded
+	EventReopen  = "reopen"  // The log files were reopened
+	EventTest    = "test"    // A configuration test failed
+)
+
+const (
+	defaultEventBuffer  = 1000        // Number of events which can be resumed
+	defaultLogExt       = ".log"      // Extension of log files in the LogPath
+	defaultTailInterval = time.Second // Interval between reading log files
+	defaultLogLineMax   = 64 * 1024   // Maximum length of a line read from a log file
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return a callback which emits each line of output from nginx, and a
+// function which emits an incomplete line when the process exits. Output
+// can be split anywhere, so an incomplete line is prepended to the next
+// output, unless it is longer than the maximum length
+func (nginx *nginx) logLines(kind string) (func([]byte), func()) {
+	var lock sync.Mutex
+	var partial []byte
+	emit := func(data []byte) {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				nginx.logs.Emit(kind, "", line)
+			}
+		}
+	}
+	write := func(data []byte) {
+		lock.Lock()
+		defer lock.Unlock()
+		data = append(partial, data...)
+		i := bytes.LastIndexByte(data, '\n')
+		if len(data)-i-1 > defaultLogLineMax {
+			i = len(data) - 1
+		}
+		emit(data[:i+1])
+		partial = append([]byte(nil), data[i+1:]...)
+	}
+	flush := func() {
+		lock.Lock()
+		defer lock.Unlock()
+		emit(partial)
+		partial = nil
+	}
+	return write, flush
+}
+
+// Read lines added to the log files until the context is cancelled. Lines
+// already in the log files are skipped
+func (nginx *nginx) tailLogs(ctx context.Context) {
+	offsets := make(map[string]int64)
+	nginx.readLogs(offsets, true)
+
+	ticker := time.NewTicker(defaultTailInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			nginx.readLogs(offsets, false)
+		}
+	}
+}
+
+// Read complete lines from the log files after the offsets, and update the
+// offsets. When skip is true, files which have not been read before are
+// read from the end
+func (nginx *nginx) readLogs(offsets map[string]int64, skip bool) {
+	files, err := filepath.Glob(filepath.Join(nginx.logPath, "*"+defaultLogExt))
+	if err != nil {
+		return
+	}
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		offset, exists := offsets[path]
+		if !exists && skip {
+			offsets[path] = info.Size()
+			continue
+		} else if info.Size() < offset {
+			// The file was truncated or replaced
+			offset = 0
+		}
+		if info.Size() > offset {
+			offsets[path] = nginx.readLog(path, offset)
+		}
+	}
+}
+
+// Read complete lines from a log file after an offset, and return the
+// offset after the last complete line. Lines longer than the maximum length,
+// including an incomplete line, are split
+func (nginx *nginx) readLog(path string, offset int64) int64 {
+	kind := LogAccess
+	if strings.Contains(filepath.Base(path), LogError) {
+		kind = LogError
+	}
+
+	// Open the file at the offset
+	r, err := os.Open(path)
+	if err != nil {
+		return offset
+	}
+	defer r.Close()
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return offset
+	}
+
+	// Emit complete lines
+	reader := bufio.NewReaderSize(r, defaultLogLineMax)
+	for {
+		data, err := reader.ReadSlice('\n')
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			// Incomplete lines are read again, unless the buffer is full
+			return offset
+		}
+		offset += int64(len(data))
+		if line := strings.TrimSpace(string(data)); line != "" {
+			nginx.logs.Emit(kind, filepath.Base(path), line)
+		}
+	}
+}
+
+// Emit a lifecycle event
+func (nginx *nginx) emit(kind string, err error) {
+	if err != nil {
+		nginx.lifecycle.Emit(kind, "", err.Error())
+	} else {
+		nginx.lifecycle.Emit(kind, "", "")
+	}
+}
@@ -7,12 +7,16 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
+	"text/template"
 
 	// Packages
 
 	cmd "github.com/mutablelogic/go-server/pkg/handler/nginx/cmd"
+	events "github.com/mutablelogic/go-server/pkg/handler/nginx/events"
 	folders "github.com/mutablelogic/go-server/pkg/handler/nginx/folders"
+	templates "github.com/mutablelogic/go-server/pkg/handler/nginx/templates"
 	provider "github.com/mutablelogic/go-server/pkg/provider"
 )
 
@@ -23,8 +27,23 @@ type nginx struct {
 	// The command to run nginx
 	run *cmd.Cmd
 
-	// The command to test nginx configuration
-	test *cmd.Cmd
+	// The flags and environment for nginx, used to test a configuration
+	flags func(configDir, prefix string) []string
+	env   map[string]string
+
+	// Serializes changes to the configurations
+	lock sync.Mutex
+
+	// Log lines and lifecycle events, which can be streamed
+	logs      *events.Ring
+	lifecycle *events.Ring
+
+	// The number of unexpected exits in succession before the task fails,
+	// and the number of restarts and last exit code, guarded by the state lock
+	restartLimit uint
+	state        sync.Mutex
+	restarts     uint
+	exitCode     *int
 
 	// The persistent configuration path
 	configPath string
@@ -41,10 +60,23 @@ type nginx struct {
 	// The available and enabled configuration folders
 	folders *folders.Config
 
+	// The templates for configurations
+	templates *templates.Templates
+
+	// The certificate manager for templates, or nil
+	certManager CertManager
+
 	// The temporarily created paths which should be removed on exit
 	deletePaths []string
 }
 
+// testError is returned when nginx rejects a configuration, with the
+// output from the test
+type testError struct {
+	err    error
+	Output []string `json:"output"`
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // LIFECYCLE
 
@@ -78,15 +110,10 @@ func New(c *Config) (*nginx, error) {
 		task.logPath = logDir
 	}
 
-	// Create an available folder in the persistent data directory
-	availablePath := filepath.Join(configDir, "available")
-	if err := os.MkdirAll(availablePath, defaultConfDirMode); err != nil {
-		return nil, err
-	}
-
-	// Create an enabled folder in the persistent data directory
-	enabledPath := filepath.Join(configDir, "enabled")
-	if err := os.MkdirAll(enabledPath, defaultConfDirMode); err != nil {
+	// Create available and enabled folders in the persistent data directory,
+	// which are linked so that both can be swapped at once
+	availablePath, enabledPath, err := folders.Link(configDir, defaultConfDirMode)
+	if err != nil {
 		return nil, err
 	}
 
@@ -98,32 +125,52 @@ func New(c *Config) (*nginx, error) {
 		task.folders = folders
 	}
 
+	// Create a templates folder in the persistent data directory, where
+	// templates can refer to certificates
+	templatePath := filepath.Join(configDir, defaultTemplatePath)
+	if err := os.MkdirAll(templatePath, defaultConfDirMode); err != nil {
+		return nil, err
+	} else if templates, err := templates.New(templatePath, defaultTemplateExt, template.FuncMap{
+		"certificate": task.certificate,
+	}); err != nil {
+		return nil, err
+	} else {
+		task.templates = templates
+		task.certManager = c.CertManager
+	}
+
 	// Create a new command to run the server. Use prefix to ensure that
 	// the document root is contained within the temporary directory
+	// The configuration is tested with the same flags and environment
 	if run, err := cmd.New(c.ExecFile(), c.Flags(task.configPath, task.configPath)...); err != nil {
 		return nil, err
-	} else if test, err := cmd.New(c.ExecFile(), c.Flags(task.configPath, task.configPath)...); err != nil {
-		return nil, err
 	} else {
 		task.run = run
-		task.test = test
-		task.test.SetArgs("-t", "-q")
+		task.flags = c.Flags
+		task.env = c.Env
 	}
 
 	// Add the environment variables
 	if err := task.run.SetEnv(c.Env); err != nil {
 		return nil, err
-	} else if err := task.test.SetEnv(c.Env); err != nil {
-		return nil, err
 	}
 
 	// Set the working directory to the ephemeral data directory
 	task.run.SetDir(task.dataPath)
-	task.test.SetDir(task.dataPath)
 
 	// Set the paths which should be deleted on exit
 	task.deletePaths = c.deletePaths
 
+	// Create the buffers for log lines and lifecycle events
+	task.logs = events.New(defaultEventBuffer)
+	task.lifecycle = events.New(defaultEventBuffer)
+
+	// Set the restart limit
+	task.restartLimit = c.RestartLimit
+	if task.restartLimit == 0 {
+		task.restartLimit = defaultRestartLimit
+	}
+
 	// Return success
 	return task, nil
 }
@@ -146,9 +193,9 @@ func (nginx *nginx) LogPath() string {
 	return nginx.logPath
 }
 
-// Test configuration
+// Test configuration, returning the output from nginx if the test fails
 func (nginx *nginx) Test() error {
-	return nginx.test.Run()
+	return nginx.testConfig(nginx.configPath)
 }
 
 // Test the configuration and then reload it (the SIGHUP signal)
@@ -159,17 +206,29 @@ func (nginx *nginx) Reload() error {
 	}
 
 	// Test the configuration
-	if err := nginx.test.Run(); err != nil {
+	if err := nginx.Test(); err != nil {
 		return err
 	}
 
 	// Signal the server to reload
-	return nginx.run.Signal(syscall.SIGHUP)
+	if err := nginx.run.Signal(syscall.SIGHUP); err != nil {
+		return err
+	}
+	nginx.emit(EventReload, nil)
+
+	// Return success
+	return nil
 }
 
 // Reopen log files (the SIGUSR1 signal)
 func (nginx *nginx) Reopen() error {
-	return nginx.run.Signal(syscall.SIGUSR1)
+	if err := nginx.run.Signal(syscall.SIGUSR1); err != nil {
+		return err
+	}
+	nginx.emit(EventReopen, nil)
+
+	// Return success
+	return nil
 }
 
 // Version returns the nginx version string
@@ -183,9 +242,62 @@ func (nginx *nginx) Version() string {
 	return string(bytes.TrimSpace(nginx.version))
 }
 
+/////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (err *testError) Error() string {
+	if len(err.Output) == 0 {
+		return err.err.Error()
+	}
+	return err.err.Error() + ": " + strings.Join(err.Output, "; ")
+}
+
+func (err *testError) Unwrap() error {
+	return err.err
+}
+
 /////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// Test the configuration in a folder, which is also the prefix. The output
+// is logged, and returned in the error if the test fails
+func (nginx *nginx) testConfig(configDir string) error {
+	var output []string
+
+	// Create the command
+	test, err := cmd.New(nginx.run.Path(), nginx.flags(configDir, configDir)...)
+	if err != nil {
+		return err
+	} else if err := test.SetEnv(nginx.env); err != nil {
+		return err
+	}
+	test.SetArgs("-t", "-q")
+	test.SetDir(nginx.dataPath)
+
+	// Collect the output, and log it with the output from nginx
+	fn := func(data []byte) {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				output = append(output, line)
+			}
+		}
+		if nginx.run.Err != nil {
+			nginx.run.Err(data)
+		}
+	}
+	test.Out, test.Err = fn, fn
+
+	// Run the test
+	if err := test.Run(); err != nil {
+		err := &testError{err: err, Output: output}
+		nginx.emit(EventTest, err)
+		return err
+	}
+
+	// Return success
+	return nil
+}
+
 func (nginx *nginx) log(ctx context.Context, line string) {
 	line = strings.TrimSpace(line)
 	if logger := provider.Logger(ctx); logger != nil {
@@ -2,8 +2,14 @@ package nginx_test
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -11,7 +17,13 @@ import (
 	// Packages
 	nginx "github.com/mutablelogic/go-server/pkg/handler/nginx"
 	cmd "github.com/mutablelogic/go-server/pkg/handler/nginx/cmd"
+	folders "github.com/mutablelogic/go-server/pkg/handler/nginx/folders"
+	router "github.com/mutablelogic/go-server/pkg/handler/router"
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
 	assert "github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
 )
 
 func Test_nginx_001(t *testing.T) {
@@ -63,6 +75,300 @@ func Test_nginx_003(t *testing.T) {
 	assert.NoError(err)
 }
 
+func Test_nginx_004(t *testing.T) {
+	var wg sync.WaitGroup
+
+	// Create a new task
+	assert := assert.New(t)
+	task, err := nginx.New(&nginx.Config{
+		BinaryPath: BinaryExec(t),
+	})
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+
+	// Run the task, and cancel and wait for it to finish
+	ctx, cancel := context.WithCancel(context.Background())
+	defer wg.Wait()
+	defer cancel()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := task.Run(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	time.Sleep(500 * time.Millisecond)
+
+	// A batch which fails the test is not applied
+	err = task.Apply(ctx, nginx.BatchOp{Op: nginx.BatchCreate, Name: "bad.conf", Body: "invalid"}, nginx.BatchOp{Op: nginx.BatchEnable, Name: "bad.conf"})
+	assert.Error(err)
+	_, err = os.Stat(filepath.Join(task.ConfigPath(), "available", "bad.conf"))
+	assert.ErrorIs(err, os.ErrNotExist)
+
+	// A valid batch is applied
+	err = task.Apply(ctx, nginx.BatchOp{Op: nginx.BatchCreate, Name: "good.conf", Body: "# empty\n"}, nginx.BatchOp{Op: nginx.BatchEnable, Name: "good.conf"})
+	assert.NoError(err)
+	_, err = os.Stat(filepath.Join(task.ConfigPath(), "enabled", "good.conf"))
+	assert.NoError(err)
+}
+
+func Test_nginx_005(t *testing.T) {
+	var wg sync.WaitGroup
+
+	// Create a new task, and add the endpoints to a router
+	assert := assert.New(t)
+	task, err := nginx.New(&nginx.Config{
+		BinaryPath: BinaryExec(t),
+	})
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	r, err := router.Config{}.New()
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	r.(router.Router).AddServiceEndpoints("nginx", task)
+
+	// Run the task, and cancel and wait for it to finish
+	ctx, cancel := context.WithCancel(context.Background())
+	defer wg.Wait()
+	defer cancel()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := task.Run(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	time.Sleep(500 * time.Millisecond)
+
+	do := func(method, etag, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/nginx/config/default.conf", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if etag != "" {
+			req.Header.Set("If-Match", etag)
+		}
+		resp := httptest.NewRecorder()
+		r.(http.Handler).ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Read the enabled configuration
+	resp := do(http.MethodGet, "", "")
+	assert.Equal(http.StatusOK, resp.Code)
+	etag := resp.Header().Get("ETag")
+	assert.NotEmpty(etag)
+
+	// A changed configuration is not written
+	resp = do(http.MethodPatch, `"0000"`, `{"body":"# empty\n"}`)
+	assert.Equal(http.StatusPreconditionFailed, resp.Code)
+
+	// A configuration which fails the test is restored, with the output
+	resp = do(http.MethodPatch, etag, `{"body":"invalid"}`)
+	assert.Equal(http.StatusBadRequest, resp.Code)
+	var response httpresponse.ErrorResponse
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &response))
+	assert.NotEmpty(response.Detail)
+	assert.Equal(etag, do(http.MethodGet, "", "").Header().Get("ETag"))
+
+	// A valid configuration is written, and nginx is reloaded
+	resp = do(http.MethodPatch, etag, `{"body":"# empty\n"}`)
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.NotEqual(etag, resp.Header().Get("ETag"))
+
+	// A disabled configuration which fails the test when it is enabled is
+	// restored, and remains disabled
+	resp = do(http.MethodPatch, resp.Header().Get("ETag"), `{"enabled":false}`)
+	assert.Equal(http.StatusOK, resp.Code)
+	etag = resp.Header().Get("ETag")
+	resp = do(http.MethodPatch, etag, `{"body":"invalid","enabled":true}`)
+	assert.Equal(http.StatusBadRequest, resp.Code)
+	resp = do(http.MethodGet, "", "")
+	assert.Equal(etag, resp.Header().Get("ETag"))
+	var config map[string]any
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &config))
+	assert.NotEqual(true, config["enabled"])
+}
+
+func Test_nginx_006(t *testing.T) {
+	var wg sync.WaitGroup
+
+	// Create a new task, and add the endpoints to a router
+	assert := assert.New(t)
+	task, err := nginx.New(&nginx.Config{
+		BinaryPath: BinaryExec(t),
+	})
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	r, err := router.Config{}.New()
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	r.(router.Router).AddServiceEndpoints("nginx", task)
+
+	// Run the task, and cancel and wait for it to finish
+	ctx, cancel := context.WithCancel(context.Background())
+	defer wg.Wait()
+	defer cancel()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := task.Run(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	time.Sleep(500 * time.Millisecond)
+	assert.NoError(task.Reload())
+
+	stream := func(lastEventId string) string {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequest(http.MethodGet, "/nginx/events", nil).WithContext(ctx)
+		if lastEventId != "" {
+			req.Header.Set("Last-Event-ID", lastEventId)
+		}
+		resp := &streamRecorder{httptest.NewRecorder()}
+		r.(http.Handler).ServeHTTP(resp, req)
+		return resp.Body.String()
+	}
+
+	// The buffered events are streamed
+	body := stream("")
+	assert.Contains(body, "id: 1\nevent: start\n")
+	assert.Contains(body, "id: 2\nevent: reload\n")
+
+	// Resume after an event
+	body = stream("1")
+	assert.NotContains(body, "event: start")
+	assert.Contains(body, "event: reload")
+}
+
+func Test_nginx_007(t *testing.T) {
+	assert := assert.New(t)
+
+	// A binary which returns a version, and otherwise exits unexpectedly
+	bin := filepath.Join(t.TempDir(), "nginx")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\nif [ \"$1\" = \"-v\" ]; then echo 'nginx version: nginx/1.25.0' >&2; exit 0; fi\necho 'failed' >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	task, err := nginx.New(&nginx.Config{
+		BinaryPath:   bin,
+		RestartLimit: 2,
+	})
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	r, err := router.Config{}.New()
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	r.(router.Router).AddServiceEndpoints("nginx", task)
+
+	// The task fails after it is restarted twice
+	err = task.Run(context.Background())
+	assert.ErrorIs(err, ErrInternalAppError)
+
+	// The health details include the restarts, exit code and stderr
+	req := httptest.NewRequest(http.MethodGet, "/nginx/", nil)
+	resp := httptest.NewRecorder()
+	r.(http.Handler).ServeHTTP(resp, req)
+	assert.Equal(http.StatusOK, resp.Code)
+	var health struct {
+		Running  bool     `json:"running"`
+		Restarts uint     `json:"restarts"`
+		ExitCode *int     `json:"exit_code"`
+		Stderr   []string `json:"stderr"`
+	}
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &health))
+	assert.False(health.Running)
+	assert.Equal(uint(2), health.Restarts)
+	if assert.NotNil(health.ExitCode) {
+		assert.Equal(1, *health.ExitCode)
+	}
+	assert.Equal([]string{"failed", "failed", "failed"}, health.Stderr)
+}
+
+func Test_nginx_008(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "access.log")
+
+	// Complete lines are read, and an incomplete line is read again
+	assert.NoError(os.WriteFile(path, []byte("a\nb\nc"), 0600))
+	offset, lines := nginx.ReadLog(path, 0)
+	assert.Equal(int64(4), offset)
+	assert.Equal([]string{"a", "b"}, lines)
+	offset, lines = nginx.ReadLog(path, offset)
+	assert.Equal(int64(4), offset)
+	assert.Empty(lines)
+
+	// An incomplete line longer than the maximum length is split
+	long := strings.Repeat("x", nginx.LogLineMax+1)
+	assert.NoError(os.WriteFile(path, []byte(long), 0600))
+	offset, lines = nginx.ReadLog(path, 0)
+	assert.Equal(int64(nginx.LogLineMax), offset)
+	assert.Equal([]string{long[:nginx.LogLineMax]}, lines)
+}
+
+func Test_nginx_009(t *testing.T) {
+	assert := assert.New(t)
+
+	// If-Match uses the strong comparison
+	for header, match := range map[string]bool{
+		``:               true,
+		`*`:              true,
+		`"abc"`:          true,
+		` "xyz", "abc" `: true,
+		`W/"abc"`:        false,
+		`"xyz", W/"abc"`: false,
+		`abc`:            false,
+		`"abcd"`:         false,
+	} {
+		assert.Equal(match, nginx.IfMatch(header, "abc"), header)
+	}
+}
+
+func Test_nginx_010(t *testing.T) {
+	assert := assert.New(t)
+	root := t.TempDir()
+	certs := filepath.Join(root, "certs")
+	available, enabled := filepath.Join(root, "available"), filepath.Join(root, "enabled")
+	for _, dir := range []string{certs, available, enabled} {
+		assert.NoError(os.Mkdir(dir, 0700))
+	}
+	for _, name := range []string{"1.pem", "2.pem", "other.txt"} {
+		assert.NoError(os.WriteFile(filepath.Join(certs, name), nil, 0600))
+	}
+	config, err := folders.New(available, enabled, ".conf", false)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+
+	// Certificates which are not referred to are removed
+	assert.NoError(config.Create("a.conf", []byte("ssl_certificate "+filepath.Join(certs, "1.pem")+";\n")))
+	serials, err := nginx.SyncCertificates(root, config)
+	assert.NoError(err)
+	assert.Equal(map[string]bool{"1": true}, serials)
+	assert.FileExists(filepath.Join(certs, "1.pem"))
+	assert.NoFileExists(filepath.Join(certs, "2.pem"))
+	assert.FileExists(filepath.Join(certs, "other.txt"))
+
+	// A certificate which does not exist is written from the certificate
+	// manager
+	assert.NoError(config.Create("b.conf", []byte("ssl_certificate "+filepath.Join(certs, "3.pem")+";\n")))
+	_, err = nginx.SyncCertificates(root, config)
+	assert.ErrorIs(err, ErrNotImplemented)
+
+	// Certificates are removed when the configurations are deleted
+	assert.NoError(config.Delete("a.conf"))
+	assert.NoError(config.Delete("b.conf"))
+	serials, err = nginx.SyncCertificates(root, config)
+	assert.NoError(err)
+	assert.Empty(serials)
+	assert.NoFileExists(filepath.Join(certs, "1.pem"))
+}
+
 func BinaryExec(t *testing.T) string {
 	var version string
 
@@ -96,3 +402,31 @@ func BinaryExec(t *testing.T) string {
 	// Return binary path
 	return bin
 }
+
+// streamRecorder does not record informational responses, as a server
+// writes them before the response
+type streamRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (w *streamRecorder) WriteHeader(code int) {
+	if code >= 200 {
+		w.ResponseRecorder.WriteHeader(code)
+	}
+}
+
+func Test_nginx_011(t *testing.T) {
+	assert := assert.New(t)
+
+	// Lines split across output are joined, and the incomplete line is
+	// emitted when the process exits
+	lines, flushed := nginx.LogLines("a\nb", "c\n", "\nd", "e")
+	assert.Equal([]string{"a", "bc"}, lines)
+	assert.Equal([]string{"de"}, flushed)
+
+	// An incomplete line longer than the maximum length is not kept
+	long := strings.Repeat("x", nginx.LogLineMax+1)
+	lines, flushed = nginx.LogLines(long)
+	assert.Equal([]string{long}, lines)
+	assert.Empty(flushed)
+}
@@ -0,0 +1,114 @@
+/*
+Reads the counters from the nginx stub_status page
+*/
+package status
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Status are the counters from the stub_status page
+type Status struct {
+	Active   uint64 `json:"active"`   // Active client connections, including waiting
+	Accepts  uint64 `json:"accepts"`  // Accepted client connections
+	Handled  uint64 `json:"handled"`  // Handled client connections
+	Requests uint64 `json:"requests"` // Client requests
+	Reading  uint64 `json:"reading"`  // Connections where the request header is being read
+	Writing  uint64 `json:"writing"`  // Connections where the response is being written
+	Waiting  uint64 `json:"waiting"`  // Idle client connections waiting for a request
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// The path of the stub_status page
+	Path = "/status"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (s Status) String() string {
+	data, _ := json.MarshalIndent(s, "", "  ")
+	return string(data)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Read the stub_status page from a server listening on a unix socket
+func Read(ctx context.Context, socket string) (*Status, error) {
+	client := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	// Make the request
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost"+Path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrUnexpectedResponse.With(resp.Status)
+	}
+
+	// Parse the response
+	return Parse(resp.Body)
+}
+
+// Parse the stub_status page, which is in the following format:
+//
+//	Active connections: 291
+//	server accepts handled requests
+//	 16630948 16630948 31070465
+//	Reading: 6 Writing: 179 Waiting: 106
+func Parse(r io.Reader) (*Status, error) {
+	var lines []string
+	for scanner := bufio.NewScanner(r); scanner.Scan(); {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) != 4 {
+		return nil, ErrUnexpectedResponse.With("unexpected stub_status format")
+	}
+
+	// Parse the values
+	status := new(Status)
+	if _, err := fmt.Sscanf(lines[0], "Active connections: %d", &status.Active); err != nil {
+		return nil, ErrUnexpectedResponse.Withf("%q: %v", lines[0], err)
+	} else if lines[1] != "server accepts handled requests" {
+		return nil, ErrUnexpectedResponse.Withf("%q", lines[1])
+	} else if _, err := fmt.Sscanf(lines[2], "%d %d %d", &status.Accepts, &status.Handled, &status.Requests); err != nil {
+		return nil, ErrUnexpectedResponse.Withf("%q: %v", lines[2], err)
+	} else if _, err := fmt.Sscanf(lines[3], "Reading: %d Writing: %d Waiting: %d", &status.Reading, &status.Writing, &status.Waiting); err != nil {
+		return nil, ErrUnexpectedResponse.Withf("%q: %v", lines[3], err)
+	}
+
+	// Return success
+	return status, nil
+}
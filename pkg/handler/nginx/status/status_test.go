@@ -0,0 +1,67 @@
+package status_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	// Packages
+	status "github.com/mutablelogic/go-server/pkg/handler/nginx/status"
+	assert "github.com/stretchr/testify/assert"
+)
+
+const (
+	stubStatus = "Active connections: 291 \nserver accepts handled requests\n 16630948 16630948 31070465 \nReading: 6 Writing: 179 Waiting: 106 \n"
+)
+
+func Test_status_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// Parse the counters
+	s, err := status.Parse(strings.NewReader(stubStatus))
+	if assert.NoError(err) {
+		assert.Equal(status.Status{Active: 291, Accepts: 16630948, Handled: 16630948, Requests: 31070465, Reading: 6, Writing: 179, Waiting: 106}, *s)
+	}
+
+	// Unexpected formats
+	_, err = status.Parse(strings.NewReader(""))
+	assert.Error(err)
+	_, err = status.Parse(strings.NewReader(strings.Replace(stubStatus, "Reading", "Sleeping", 1)))
+	assert.Error(err)
+	_, err = status.Parse(strings.NewReader(strings.Replace(stubStatus, "291", "many", 1)))
+	assert.Error(err)
+}
+
+func Test_status_002(t *testing.T) {
+	assert := assert.New(t)
+
+	// Serve the stub_status page on a unix socket
+	socket := filepath.Join(t.TempDir(), "status.sock")
+	listener, err := net.Listen("unix", socket)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != status.Path {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(stubStatus))
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	// Read the counters
+	s, err := status.Read(context.Background(), socket)
+	if assert.NoError(err) {
+		assert.Equal(uint64(291), s.Active)
+		assert.Equal(uint64(106), s.Waiting)
+	}
+
+	// A missing socket is an error
+	_, err = status.Read(context.Background(), filepath.Join(t.TempDir(), "missing.sock"))
+	assert.Error(err)
+}
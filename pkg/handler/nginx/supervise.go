@@ -0,0 +1,141 @@
+package nginx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	// Packages
+	status "github.com/mutablelogic/go-server/pkg/handler/nginx/status"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	defaultRestartLimit    = 5                      // Unexpected exits in succession before the task fails
+	defaultRestartDelay    = time.Second            // Delay before the first restart, which doubles
+	defaultRestartMaxDelay = 30 * time.Second       // Maximum delay before a restart
+	defaultRestartReset    = time.Minute            // Time nginx runs for before exits are no longer in succession
+	defaultStatusConf      = "status.conf"          // Relative to the ConfigPath
+	defaultStatusSocket    = "status.sock"          // Relative to the DataPath
+	defaultStatusTimeout   = 500 * time.Millisecond // Timeout for reading the stub_status page
+	defaultStderrLines     = 10                     // Number of stderr lines in the health details
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Start nginx in the background, and return a channel which receives the
+// error when it exits
+func (nginx *nginx) start(wg *sync.WaitGroup) <-chan error {
+	ch := make(chan error, 1)
+
+	// Remove a socket left behind when nginx did not exit cleanly
+	os.Remove(nginx.statusSocket())
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		nginx.emit(EventStart, nil)
+		err := nginx.run.Run()
+		nginx.setExitCode(nginx.run.ExitCode())
+		nginx.emit(EventStop, err)
+		ch <- err
+	}()
+	return ch
+}
+
+// Return the number of unexpected exits in succession, and the delay before
+// nginx is restarted, which doubles with each exit. Returns an error when
+// nginx has exited too many times in succession
+func (nginx *nginx) restart(exits uint, err error) (uint, time.Duration, error) {
+	if time.Since(nginx.run.StartTime()) >= defaultRestartReset {
+		exits = 0
+	}
+	exits++
+	if exits > nginx.restartLimit {
+		return exits, 0, ErrInternalAppError.Withf("nginx exited %d times in succession: %v", exits, err)
+	}
+	delay := defaultRestartDelay
+	for i := uint(1); i < exits && delay < defaultRestartMaxDelay; i++ {
+		delay *= 2
+	}
+	return exits, min(delay, defaultRestartMaxDelay), nil
+}
+
+// Record the exit code when nginx exits
+func (nginx *nginx) setExitCode(code int) {
+	nginx.state.Lock()
+	defer nginx.state.Unlock()
+	nginx.exitCode = &code
+}
+
+// Record a restart
+func (nginx *nginx) addRestart() {
+	nginx.state.Lock()
+	defer nginx.state.Unlock()
+	nginx.restarts++
+}
+
+// Return the number of restarts and the exit code when nginx last exited,
+// or nil if it has not exited
+func (nginx *nginx) supervision() (uint, *int) {
+	nginx.state.Lock()
+	defer nginx.state.Unlock()
+	return nginx.restarts, nginx.exitCode
+}
+
+// Return the most recent lines of stderr from nginx
+func (nginx *nginx) stderr(n int) []string {
+	var result []string
+	for _, event := range nginx.logs.Since(0) {
+		if event.Type == LogStderr {
+			result = append(result, event.Message)
+		}
+	}
+	if len(result) > n {
+		result = result[len(result)-n:]
+	}
+	return result
+}
+
+// Return the path to the socket for the stub_status page
+func (nginx *nginx) statusSocket() string {
+	return filepath.Join(nginx.dataPath, defaultStatusSocket)
+}
+
+// Write the configuration which serves the stub_status page on a socket,
+// which is included from nginx.conf
+func (nginx *nginx) writeStatusConf() error {
+	conf := fmt.Sprintf("# Serves the stub_status page, which is read by the nginx handler\n"+
+		"server {\n"+
+		"    listen      unix:%s;\n"+
+		"    access_log  off;\n"+
+		"    location = %s {\n"+
+		"        stub_status;\n"+
+		"    }\n"+
+		"}\n", nginx.statusSocket(), status.Path)
+	return os.WriteFile(filepath.Join(nginx.configPath, defaultStatusConf), []byte(conf), 0644)
+}
+
+// Read the stub_status page, or return nil if nginx is not running or the
+// page cannot be read
+func (nginx *nginx) status(ctx context.Context) *status.Status {
+	if nginx.run.Pid() == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, defaultStatusTimeout)
+	defer cancel()
+	if result, err := status.Read(ctx, nginx.statusSocket()); err != nil {
+		return nil
+	} else {
+		return result
+	}
+}
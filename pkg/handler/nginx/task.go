@@ -3,6 +3,7 @@ package nginx
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"sync"
 	"syscall"
@@ -10,6 +11,7 @@ import (
 
 	// Packages
 	server "github.com/mutablelogic/go-server"
+	provider "github.com/mutablelogic/go-server/pkg/provider"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -17,6 +19,7 @@ import (
 
 // Check interfaces are satisfied
 var _ server.Task = (*nginx)(nil)
+var _ server.Collector = (*nginx)(nil)
 
 /////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
@@ -54,6 +57,8 @@ func (task *nginx) Run(ctx context.Context) error {
 		return err
 	} else if err := task.folders.Reload(); err != nil {
 		return err
+	} else if err := task.writeStatusConf(); err != nil {
+		return err
 	}
 
 	// Get the version of nginx
@@ -63,51 +68,75 @@ func (task *nginx) Run(ctx context.Context) error {
 		task.version = version
 	}
 
-	// Add stdout, stderr for the nginx commands
-	fn := func(data []byte) {
+	// Add stdout, stderr for the nginx commands, which are logged and
+	// can be streamed
+	stdout, flushStdout := task.logLines(LogStdout)
+	stderr, flushStderr := task.logLines(LogStderr)
+	flush := func() {
+		flushStdout()
+		flushStderr()
+	}
+	task.run.Out = func(data []byte) {
 		task.log(ctx, string(data))
+		stdout(data)
+	}
+	task.run.Err = func(data []byte) {
+		task.log(ctx, string(data))
+		stderr(data)
 	}
-	task.run.Out, task.run.Err = fn, fn
-	task.test.Out, task.test.Err = fn, fn
 
-	// Run the nginx server in the background
+	// Read lines added to the log files in the background
+	tailCtx, tailCancel := context.WithCancel(ctx)
+	defer tailCancel()
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := task.run.Run(); err != nil {
-			result = errors.Join(result, err)
-		}
+		task.tailLogs(tailCtx)
 	}()
 
-	// Runloop for nginx
-	timer := time.NewTicker(time.Second)
-	defer timer.Stop()
+	// Run the nginx server in the background, and restart it with an
+	// increasing delay when it exits unexpectedly, until it has exited
+	// too many times in succession
+	var exits uint
+	var restart <-chan time.Time
+	exited := task.start(&wg)
 RUN_LOOP:
 	for {
 		select {
 		case <-ctx.Done():
 			break RUN_LOOP
-		case <-timer.C:
-			if task.run.Exited() {
+		case exitErr := <-exited:
+			exited = nil
+			flush()
+			if n, delay, err := task.restart(exits, exitErr); err != nil {
+				result = errors.Join(result, err)
 				break RUN_LOOP
+			} else {
+				task.log(ctx, fmt.Sprintf("nginx exited unexpectedly (%v), restarting in %v", exitErr, delay))
+				exits, restart = n, time.After(delay)
 			}
+		case <-restart:
+			restart = nil
+			task.addRestart()
+			task.emit(EventRestart, nil)
+			exited = task.start(&wg)
 		}
 	}
 
-	// Perform shutdown, escalating signals
-	checkTicker := time.NewTicker(500 * time.Millisecond)
+	// Perform shutdown when nginx is running, escalating signals
 	signalTicker := time.NewTimer(100 * time.Millisecond)
 	termSignal := syscall.SIGQUIT
-	defer checkTicker.Stop()
 	defer signalTicker.Stop()
 
 FOR_LOOP:
-	for {
+	for exited != nil {
 		select {
-		case <-checkTicker.C:
-			if task.run.Exited() {
-				break FOR_LOOP
+		case err := <-exited:
+			flush()
+			if err != nil {
+				result = errors.Join(result, err)
 			}
+			break FOR_LOOP
 		case <-signalTicker.C:
 			if !task.run.Exited() {
 				if err := task.run.Signal(termSignal); err != nil {
@@ -130,9 +159,35 @@ FOR_LOOP:
 		}
 	}
 
-	// Wait for nginx to exit
+	// Stop reading the log files, and wait for nginx to exit
+	tailCancel()
 	wg.Wait()
 
 	// Return any errors
 	return result
 }
+
+// Write whether nginx is running, the time since it was started, the number
+// of restarts and the counters from the stub_status page
+func (task *nginx) Collect(ctx context.Context, w server.MetricWriter) {
+	var up, uptime float64
+	if task.run.Pid() > 0 {
+		up, uptime = 1, time.Since(task.run.StartTime()).Seconds()
+	}
+	restarts, _ := task.supervision()
+	label := provider.Label(ctx)
+	w.Gauge("nginx_up", "Whether nginx is running", up, "task", label)
+	w.Gauge("nginx_uptime_seconds", "Time since nginx was started", uptime, "task", label)
+	w.Counter("nginx_restarts_total", "Number of restarts after nginx exited unexpectedly", float64(restarts), "task", label)
+
+	// Write the stub_status counters
+	if status := task.status(ctx); status != nil {
+		w.Gauge("nginx_connections_active", "Active client connections", float64(status.Active), "task", label)
+		w.Gauge("nginx_connections_reading", "Connections where the request header is being read", float64(status.Reading), "task", label)
+		w.Gauge("nginx_connections_writing", "Connections where the response is being written", float64(status.Writing), "task", label)
+		w.Gauge("nginx_connections_waiting", "Idle client connections", float64(status.Waiting), "task", label)
+		w.Counter("nginx_connections_accepted_total", "Accepted client connections", float64(status.Accepts), "task", label)
+		w.Counter("nginx_connections_handled_total", "Handled client connections", float64(status.Handled), "task", label)
+		w.Counter("nginx_http_requests_total", "Client requests", float64(status.Requests), "task", label)
+	}
+}
@@ -0,0 +1,316 @@
+/*
+Renders nginx configurations from templates in a folder, with parameters
+*/
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	// Packages
+	types "github.com/mutablelogic/go-server/pkg/types"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Templates is a folder of templates, which are rendered with text/template
+type Templates struct {
+	// Root path
+	Root string
+
+	// File extension of the templates
+	Ext string
+
+	// Additional functions for the templates
+	funcs template.FuncMap
+}
+
+// Template describes a template, and the parameters it refers to
+type Template struct {
+	Name   string   `json:"name"`
+	Params []string `json:"params,omitempty"`
+	Body   string   `json:"body,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Characters which are not allowed in parameter values, as they could
+	// end a directive or block, or start a comment or quoted string
+	reservedChars = ";{}#\"'\\`\r\n"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a folder of templates, with additional functions which can be
+// used in the templates
+func New(root, ext string, funcs template.FuncMap) (*Templates, error) {
+	if stat, err := os.Stat(root); err != nil {
+		return nil, err
+	} else if !stat.IsDir() {
+		return nil, ErrBadParameter.Withf("not a directory: %q", root)
+	}
+
+	// Set the functions, which include the default functions
+	t := &Templates{Root: root, Ext: ext, funcs: template.FuncMap{
+		"required": required,
+		"default":  defaultValue,
+	}}
+	for name, fn := range funcs {
+		t.funcs[name] = fn
+	}
+
+	// Return success
+	return t, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (t Template) String() string {
+	data, _ := json.MarshalIndent(t, "", "  ")
+	return string(data)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return all templates, without the body, sorted by name
+func (t *Templates) Templates() ([]*Template, error) {
+	var result []*Template
+	if err := filepath.WalkDir(t.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if d.IsDir() {
+			if strings.HasPrefix(d.Name(), ".") && path != t.Root {
+				return filepath.SkipDir
+			}
+			return nil
+		} else if t.Ext != "" && filepath.Ext(path) != t.Ext {
+			return nil
+		}
+		name, err := filepath.Rel(t.Root, path)
+		if err != nil {
+			return err
+		}
+		if tmpl, err := t.Template(name); err != nil {
+			return err
+		} else {
+			tmpl.Body = ""
+			result = append(result, tmpl)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// Return a template by name, with the body and the parameters it refers to
+func (t *Templates) Template(name string) (*Template, error) {
+	tmpl, body, err := t.parse(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{
+		Name:   name,
+		Params: fields(tmpl.Tree.Root),
+		Body:   string(body),
+	}, nil
+}
+
+// Render a template with parameters, where missing parameters are empty.
+// Returns an error if a parameter is not referred to by the template, a
+// value could change the structure of the configuration, or a required
+// parameter is missing
+func (t *Templates) Render(name string, params map[string]any) ([]byte, error) {
+	tmpl, _, err := t.parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the parameters, which need to be referred to by the template
+	fields := fields(tmpl.Tree.Root)
+	for key, value := range params {
+		if !types.IsIdentifier(key) {
+			return nil, ErrBadParameter.Withf("invalid parameter name %q", key)
+		} else if !slices.Contains(fields, key) {
+			return nil, ErrBadParameter.Withf("unknown parameter %q", key)
+		} else if err := checkValue(value); err != nil {
+			return nil, ErrBadParameter.Withf("parameter %q: %v", key, err)
+		}
+	}
+
+	// Missing parameters are empty
+	values := make(map[string]any, len(fields))
+	for _, field := range fields {
+		if value, exists := params[field]; exists && value != nil {
+			values[field] = value
+		} else {
+			values[field] = ""
+		}
+	}
+
+	// Render the template
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, values); err != nil {
+		return nil, ErrBadParameter.Withf("%s: %v", name, err)
+	}
+
+	// Return success
+	return buf.Bytes(), nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Read and parse a template
+func (t *Templates) parse(name string) (*template.Template, []byte, error) {
+	if err := t.checkName(name); err != nil {
+		return nil, nil, err
+	}
+	body, err := os.ReadFile(filepath.Join(t.Root, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrNotFound.Withf("template: %q", name)
+	} else if err != nil {
+		return nil, nil, err
+	}
+	tmpl, err := template.New(name).Funcs(t.funcs).Parse(string(body))
+	if err != nil {
+		return nil, nil, ErrBadParameter.Withf("%s: %v", name, err)
+	}
+	return tmpl, body, nil
+}
+
+// Check a template name is a file within the root folder
+func (t *Templates) checkName(name string) error {
+	if !filepath.IsLocal(name) {
+		return ErrBadParameter.Withf("invalid template name %q", name)
+	} else if t.Ext != "" && filepath.Ext(name) != t.Ext {
+		return ErrBadParameter.Withf("invalid or missing file extension %q", name)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if !types.IsFilename(part) {
+			return ErrBadParameter.Withf("invalid template name %q", name)
+		}
+	}
+	return nil
+}
+
+// Check a parameter value is a string, number, boolean or list of these,
+// and strings do not contain reserved characters
+func checkValue(value any) error {
+	switch value := value.(type) {
+	case nil, bool, float64, int, json.Number:
+		return nil
+	case string:
+		if strings.ContainsAny(value, reservedChars) {
+			return ErrBadParameter.Withf("reserved character in %q", value)
+		}
+		return nil
+	case []any:
+		for _, v := range value {
+			if _, ok := v.([]any); ok {
+				return ErrBadParameter.With("nested list")
+			} else if err := checkValue(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return ErrBadParameter.Withf("unsupported value %T", value)
+	}
+}
+
+// Return the top-level fields which a template refers to, sorted by name.
+// Fields within range and with blocks refer to other values, so only
+// variables which start with $ are used within them
+func fields(node parse.Node) []string {
+	set := make(map[string]bool)
+	var walk func(node parse.Node, top bool)
+	walk = func(node parse.Node, top bool) {
+		switch node := node.(type) {
+		case *parse.ListNode:
+			if node != nil {
+				for _, n := range node.Nodes {
+					walk(n, top)
+				}
+			}
+		case *parse.ActionNode:
+			walk(node.Pipe, top)
+		case *parse.PipeNode:
+			if node != nil {
+				for _, cmd := range node.Cmds {
+					walk(cmd, top)
+				}
+			}
+		case *parse.CommandNode:
+			for _, arg := range node.Args {
+				walk(arg, top)
+			}
+		case *parse.FieldNode:
+			if top {
+				set[node.Ident[0]] = true
+			}
+		case *parse.VariableNode:
+			if len(node.Ident) > 1 && node.Ident[0] == "$" {
+				set[node.Ident[1]] = true
+			}
+		case *parse.IfNode:
+			walk(node.Pipe, top)
+			walk(node.List, top)
+			walk(node.ElseList, top)
+		case *parse.RangeNode:
+			walk(node.Pipe, top)
+			walk(node.List, false)
+			walk(node.ElseList, top)
+		case *parse.WithNode:
+			walk(node.Pipe, top)
+			walk(node.List, false)
+			walk(node.ElseList, top)
+		}
+	}
+	walk(node, true)
+
+	// Return sorted fields
+	result := make([]string, 0, len(set))
+	for field := range set {
+		result = append(result, field)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Return an error if a required value is missing
+func required(name string, value any) (any, error) {
+	if value == nil || value == "" {
+		return nil, ErrBadParameter.Withf("missing parameter %q", name)
+	}
+	return value, nil
+}
+
+// Return a default value if a value is missing
+func defaultValue(def, value any) any {
+	if value == nil || value == "" {
+		return def
+	}
+	return value
+}
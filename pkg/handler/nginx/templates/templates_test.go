@@ -0,0 +1,116 @@
+package templates_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	// Packages
+	templates "github.com/mutablelogic/go-server/pkg/handler/nginx/templates"
+	assert "github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+func Test_templates_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// Read the embedded templates
+	tmpl, err := templates.New("../conf/templates", ".tmpl", template.FuncMap{
+		"certificate": func(serial string) string {
+			return "/certs/" + serial + ".pem"
+		},
+	})
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	list, err := tmpl.Templates()
+	assert.NoError(err)
+	if assert.Len(list, 1) {
+		assert.Equal("reverse-proxy.tmpl", list[0].Name)
+		assert.Equal([]string{"Certificate", "Port", "ServerName", "UpstreamHost", "UpstreamPort"}, list[0].Params)
+		assert.Empty(list[0].Body)
+	}
+
+	// Render the reverse proxy template
+	body, err := tmpl.Render("reverse-proxy.tmpl", map[string]any{
+		"ServerName":   "example.com www.example.com",
+		"UpstreamHost": "127.0.0.1",
+		"UpstreamPort": float64(8080),
+		"Certificate":  "1234",
+	})
+	if assert.NoError(err) {
+		assert.Contains(string(body), "server_name  example.com www.example.com;")
+		assert.Contains(string(body), "proxy_pass         http://127.0.0.1:8080;")
+		assert.Contains(string(body), "listen       80;")
+		assert.Contains(string(body), "ssl_certificate     /certs/1234.pem;")
+	}
+
+	// Without a certificate, TLS is not configured
+	body, err = tmpl.Render("reverse-proxy.tmpl", map[string]any{
+		"ServerName":   "example.com",
+		"UpstreamHost": "127.0.0.1",
+	})
+	if assert.NoError(err) {
+		assert.NotContains(string(body), "ssl")
+		assert.Contains(string(body), "proxy_pass         http://127.0.0.1:80;")
+	}
+}
+
+func Test_templates_002(t *testing.T) {
+	assert := assert.New(t)
+	tmpl, err := templates.New("../conf/templates", ".tmpl", nil)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+
+	// Missing required parameter
+	_, err = tmpl.Render("reverse-proxy.tmpl", map[string]any{"ServerName": "example.com"})
+	assert.ErrorIs(err, ErrBadParameter)
+
+	// Unknown parameter
+	_, err = tmpl.Render("reverse-proxy.tmpl", map[string]any{"ServerName": "example.com", "UpstreamHost": "localhost", "Other": "value"})
+	assert.ErrorIs(err, ErrBadParameter)
+
+	// Values which could change the structure of the configuration
+	for _, value := range []any{"localhost; include /etc/passwd", "localhost }", "local\nhost", map[string]any{}, []any{[]any{}}} {
+		_, err = tmpl.Render("reverse-proxy.tmpl", map[string]any{"ServerName": "example.com", "UpstreamHost": value})
+		assert.ErrorIs(err, ErrBadParameter, value)
+	}
+
+	// Missing or invalid template names
+	_, err = tmpl.Render("other.tmpl", nil)
+	assert.ErrorIs(err, ErrNotFound)
+	_, err = tmpl.Render("../templates/reverse-proxy.tmpl", nil)
+	assert.ErrorIs(err, ErrBadParameter)
+}
+
+func Test_templates_003(t *testing.T) {
+	assert := assert.New(t)
+	root := t.TempDir()
+	assert.NoError(os.WriteFile(filepath.Join(root, "list.tmpl"), []byte(strings.Join([]string{
+		`{{ range .Names }}{{ . }} {{ $.Suffix }}{{ end }}`,
+		`{{ with .Other }}{{ .Field }}{{ end }}`,
+	}, "\n")), 0644))
+
+	tmpl, err := templates.New(root, ".tmpl", nil)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+
+	// Fields within range and with blocks are not parameters
+	list, err := tmpl.Templates()
+	assert.NoError(err)
+	if assert.Len(list, 1) {
+		assert.Equal([]string{"Names", "Other", "Suffix"}, list[0].Params)
+	}
+
+	// Render lists
+	body, err := tmpl.Render("list.tmpl", map[string]any{"Names": []any{"a", "b"}, "Suffix": "c"})
+	if assert.NoError(err) {
+		assert.Equal("a cb c\n", string(body))
+	}
+}
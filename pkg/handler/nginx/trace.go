@@ -0,0 +1,26 @@
+package nginx
+
+import (
+	"context"
+
+	// Packages
+	trace "github.com/mutablelogic/go-server/pkg/trace"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Commands and signals for nginx from a request, each of which is traced as
+// a client span when tracing is enabled
+
+func (nginx *nginx) testWithTrace(ctx context.Context) error {
+	return trace.Do(ctx, "nginx test", nginx.Test, "process.executable.path", nginx.run.Path())
+}
+
+func (nginx *nginx) reloadWithTrace(ctx context.Context) error {
+	return trace.Do(ctx, "nginx reload", nginx.Reload, "process.pid", nginx.run.Pid())
+}
+
+func (nginx *nginx) reopenWithTrace(ctx context.Context) error {
+	return trace.Do(ctx, "nginx reopen", nginx.Reopen, "process.pid", nginx.run.Pid())
+}
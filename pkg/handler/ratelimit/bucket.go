@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// bucket is a token bucket, which holds up to burst tokens and is refilled
+// at rate tokens per second. Each request takes one token
+type bucket struct {
+	Key    string    `json:"key"`
+	Scope  string    `json:"scope,omitempty"`
+	Rate   float64   `json:"rate"`
+	Burst  int       `json:"burst"`
+	Tokens float64   `json:"tokens"`
+	Time   time.Time `json:"access_time"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a full bucket
+func newBucket(key, scope string, limit Limit, now time.Time) *bucket {
+	return &bucket{
+		Key:    key,
+		Scope:  scope,
+		Rate:   limit.Rate,
+		Burst:  limit.Burst,
+		Tokens: float64(limit.Burst),
+		Time:   now,
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Take a token from the bucket, and return true if a token was taken.
+// Otherwise, return the time until a token is available
+func (b *bucket) Take(now time.Time) (bool, time.Duration) {
+	b.refill(now)
+	if b.Tokens >= 1 {
+		b.Tokens -= 1
+		return true, 0
+	}
+	return false, b.until(1)
+}
+
+// Return the number of whole tokens remaining
+func (b *bucket) Remaining() int {
+	return int(math.Floor(b.Tokens))
+}
+
+// Return the time until the bucket is full
+func (b *bucket) Reset() time.Duration {
+	return b.until(float64(b.Burst))
+}
+
+// Return true if the bucket is full at a time, and so can be removed
+func (b *bucket) Full(now time.Time) bool {
+	b.refill(now)
+	return b.Tokens >= float64(b.Burst)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Add the tokens for the time since the last refill
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.Time); elapsed > 0 {
+		b.Tokens = math.Min(float64(b.Burst), b.Tokens+elapsed.Seconds()*b.Rate)
+		b.Time = now
+	}
+}
+
+// Return the time until the bucket holds a number of tokens
+func (b *bucket) until(tokens float64) time.Duration {
+	if b.Tokens >= tokens {
+		return 0
+	}
+	return time.Duration((tokens - b.Tokens) / b.Rate * float64(time.Second))
+}
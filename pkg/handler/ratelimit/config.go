@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	// Packages
+	server "github.com/mutablelogic/go-server"
+)
+
+////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type Config struct {
+	Key    string           `hcl:"key" description:"Key for request buckets: token, addr or label (default token, which uses addr for requests without a token)"`
+	Rate   float64          `hcl:"rate" description:"Sustained number of requests per second (default 10)"`
+	Burst  int              `hcl:"burst" description:"Maximum number of requests in a burst (default 20)"`
+	Scopes map[string]Limit `hcl:"scopes" description:"Rate and burst for routes with a scope, which replace the default"`
+}
+
+type Limit struct {
+	Rate  float64 `hcl:"rate" description:"Sustained number of requests per second"`
+	Burst int     `hcl:"burst" description:"Maximum number of requests in a burst"`
+}
+
+// Check interfaces are satisfied
+var _ server.Plugin = Config{}
+
+////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	defaultName  = "ratelimit"
+	defaultRate  = 10
+	defaultBurst = 20
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Name returns the name of the service
+func (Config) Name() string {
+	return defaultName
+}
+
+// Description returns the description of the service
+func (Config) Description() string {
+	return "rate limits requests with token buckets"
+}
+
+// Create a new task from the configuration
+func (c Config) New() (server.Task, error) {
+	return New(c)
+}
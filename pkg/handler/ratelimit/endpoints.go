@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	router "github.com/mutablelogic/go-server/pkg/handler/router"
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Check interfaces are satisfied
+var _ server.ServiceEndpoints = (*ratelimit)(nil)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	jsonIndent = 2
+)
+
+var (
+	reRoot = regexp.MustCompile(`^/?$`)
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - ENDPOINTS
+
+// Add endpoints to the router
+func (service *ratelimit) AddEndpoints(ctx context.Context, r server.Router) {
+	// Path: /
+	// Methods: GET
+	// Scopes: read
+	r.AddHandlerFuncRe(ctx, reRoot, service.ListBuckets, http.MethodGet).(router.Route).
+		SetScope(service.ScopeRead()...).
+		SetSummary("Get the current state of the buckets")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Get the buckets
+func (service *ratelimit) ListBuckets(w http.ResponseWriter, r *http.Request) {
+	httpresponse.JSON(w, service.Buckets(), http.StatusOK, jsonIndent)
+}
@@ -0,0 +1,238 @@
+/*
+implements middleware which limits the rate of requests with token buckets,
+keyed by the authorization token name, the client address or the route label
+*/
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	auth "github.com/mutablelogic/go-server/pkg/handler/auth"
+	logger "github.com/mutablelogic/go-server/pkg/handler/logger"
+	router "github.com/mutablelogic/go-server/pkg/handler/router"
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+	provider "github.com/mutablelogic/go-server/pkg/provider"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type ratelimit struct {
+	sync.Mutex
+
+	// How buckets are keyed
+	key string
+
+	// The default limit, and limits for routes with a scope
+	limit  Limit
+	scopes map[string]Limit
+
+	// Buckets keyed by key and scope
+	buckets map[string]*bucket
+}
+
+// state is the state of a bucket after a request
+type state struct {
+	Burst     int
+	Remaining int
+	Reset     time.Duration
+}
+
+// Check interfaces are satisfied
+var _ server.Task = (*ratelimit)(nil)
+var _ server.Middleware = (*ratelimit)(nil)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	KeyToken = "token"
+	KeyAddr  = "addr"
+	KeyLabel = "label"
+)
+
+const (
+	headerRetryAfter = "Retry-After"
+	headerLimit      = "RateLimit-Limit"
+	headerRemaining  = "RateLimit-Remaining"
+	headerReset      = "RateLimit-Reset"
+	purgeInterval    = time.Minute
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a new rate limiter from the configuration
+func New(c Config) (*ratelimit, error) {
+	r := new(ratelimit)
+	r.buckets = make(map[string]*bucket)
+
+	// Set the key
+	switch c.Key {
+	case "":
+		r.key = KeyToken
+	case KeyToken, KeyAddr, KeyLabel:
+		r.key = c.Key
+	default:
+		return nil, ErrBadParameter.Withf("key: %q", c.Key)
+	}
+
+	// Set the default limit
+	if limit, err := newLimit(c.Rate, c.Burst); err != nil {
+		return nil, err
+	} else {
+		r.limit = limit
+	}
+
+	// Set the limits for scopes, where the rate and burst are required
+	r.scopes = make(map[string]Limit, len(c.Scopes))
+	for scope, limit := range c.Scopes {
+		if limit.Rate <= 0 || limit.Burst <= 0 {
+			return nil, ErrBadParameter.Withf("scope %q: rate and burst are required", scope)
+		}
+		r.scopes[scope] = limit
+	}
+
+	// Return success
+	return r, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - MIDDLEWARE
+
+// Wrap a handler so that requests are rejected with status 429 when
+// the bucket for the request is empty
+func (ratelimit *ratelimit) Wrap(ctx context.Context, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scope, limit := ratelimit.limitForScopes(router.Scope(r.Context()))
+		bucket, ok, retry := ratelimit.take(ratelimit.keyForRequest(r), scope, limit, time.Now())
+
+		// Set the headers
+		w.Header().Set(headerLimit, strconv.Itoa(bucket.Burst))
+		w.Header().Set(headerRemaining, strconv.Itoa(bucket.Remaining))
+		w.Header().Set(headerReset, seconds(bucket.Reset))
+
+		// Reject the request
+		if !ok {
+			w.Header().Set(headerRetryAfter, seconds(retry))
+			httpresponse.Error(w, http.StatusTooManyRequests, "retry after "+seconds(retry)+"s")
+			return
+		}
+
+		// Handle the request
+		next(w, r)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return the state of the buckets, sorted by key and scope
+func (ratelimit *ratelimit) Buckets() []bucket {
+	ratelimit.Lock()
+	defer ratelimit.Unlock()
+
+	// Copy the buckets, refilling them to the current time
+	now := time.Now()
+	result := make([]bucket, 0, len(ratelimit.buckets))
+	for _, bucket := range ratelimit.buckets {
+		bucket.refill(now)
+		result = append(result, *bucket)
+	}
+
+	// Sort the buckets
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Key != result[j].Key {
+			return result[i].Key < result[j].Key
+		}
+		return result[i].Scope < result[j].Scope
+	})
+
+	// Return the result
+	return result
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Take a token from a bucket, creating the bucket if it does not exist.
+// Returns the state of the bucket, whether a token was taken and
+// otherwise the time until a token is available
+func (ratelimit *ratelimit) take(key, scope string, limit Limit, now time.Time) (state, bool, time.Duration) {
+	ratelimit.Lock()
+	defer ratelimit.Unlock()
+
+	b, exists := ratelimit.buckets[key+"\x00"+scope]
+	if !exists {
+		b = newBucket(key, scope, limit, now)
+		ratelimit.buckets[key+"\x00"+scope] = b
+	}
+	ok, retry := b.Take(now)
+	return state{b.Burst, b.Remaining(), b.Reset()}, ok, retry
+}
+
+// Remove buckets which are full, and so have the same state as a
+// new bucket
+func (ratelimit *ratelimit) purge(now time.Time) {
+	ratelimit.Lock()
+	defer ratelimit.Unlock()
+	for key, bucket := range ratelimit.buckets {
+		if bucket.Full(now) {
+			delete(ratelimit.buckets, key)
+		}
+	}
+}
+
+// Return the key for a request
+func (ratelimit *ratelimit) keyForRequest(r *http.Request) string {
+	switch ratelimit.key {
+	case KeyToken:
+		if name := auth.TokenName(r.Context()); name != "" {
+			return KeyToken + ":" + name
+		}
+	case KeyLabel:
+		return KeyLabel + ":" + provider.Label(r.Context())
+	}
+	return KeyAddr + ":" + logger.RemoteAddr(r)
+}
+
+// Return the limit for the scopes of a route, which is the limit for the
+// first scope which has a limit, or else the default limit
+func (ratelimit *ratelimit) limitForScopes(scopes []string) (string, Limit) {
+	for _, scope := range scopes {
+		if limit, exists := ratelimit.scopes[scope]; exists {
+			return scope, limit
+		}
+	}
+	return "", ratelimit.limit
+}
+
+// Return a limit, with defaults for zero values
+func newLimit(rate float64, burst int) (Limit, error) {
+	if rate < 0 || burst < 0 {
+		return Limit{}, ErrBadParameter.With("rate and burst cannot be negative")
+	}
+	if rate == 0 {
+		rate = defaultRate
+	}
+	if burst == 0 {
+		burst = defaultBurst
+	}
+	return Limit{Rate: rate, Burst: burst}, nil
+}
+
+// Return a duration as a whole number of seconds, rounded up
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,162 @@
+package ratelimit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	auth "github.com/mutablelogic/go-server/pkg/handler/auth"
+	ratelimit "github.com/mutablelogic/go-server/pkg/handler/ratelimit"
+	router "github.com/mutablelogic/go-server/pkg/handler/router"
+	version "github.com/mutablelogic/go-server/pkg/version"
+	assert "github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+func Test_ratelimit_001(t *testing.T) {
+	assert := assert.New(t)
+	assert.Implements((*server.Plugin)(nil), ratelimit.Config{})
+
+	// Default configuration
+	limiter, err := ratelimit.New(ratelimit.Config{})
+	assert.NoError(err)
+	assert.NotNil(limiter)
+
+	// Invalid configuration
+	_, err = ratelimit.New(ratelimit.Config{Key: "other"})
+	assert.ErrorIs(err, ErrBadParameter)
+	_, err = ratelimit.New(ratelimit.Config{Rate: -1})
+	assert.ErrorIs(err, ErrBadParameter)
+	_, err = ratelimit.New(ratelimit.Config{Scopes: map[string]ratelimit.Limit{"scope": {Rate: 1}}})
+	assert.ErrorIs(err, ErrBadParameter)
+}
+
+func Test_ratelimit_002(t *testing.T) {
+	assert := assert.New(t)
+
+	// Limit by client address, with a burst of two requests
+	limiter, err := ratelimit.New(ratelimit.Config{
+		Key:   ratelimit.KeyAddr,
+		Rate:  0.001,
+		Burst: 2,
+	})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	handler := limiter.Wrap(context.Background(), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// Make a request from an address
+	do := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Real-Ip", addr)
+		resp := httptest.NewRecorder()
+		handler(resp, req)
+		return resp
+	}
+
+	// The first two requests are allowed
+	resp := do("10.0.0.1")
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal("2", resp.Header().Get("RateLimit-Limit"))
+	assert.Equal("1", resp.Header().Get("RateLimit-Remaining"))
+	resp = do("10.0.0.1")
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal("0", resp.Header().Get("RateLimit-Remaining"))
+
+	// The third request is rejected
+	resp = do("10.0.0.1")
+	assert.Equal(http.StatusTooManyRequests, resp.Code)
+	assert.Equal("1000", resp.Header().Get("Retry-After"))
+	assert.Equal("2000", resp.Header().Get("RateLimit-Reset"))
+
+	// Another address has a separate bucket
+	resp = do("10.0.0.2")
+	assert.Equal(http.StatusOK, resp.Code)
+}
+
+func Test_ratelimit_003(t *testing.T) {
+	assert := assert.New(t)
+
+	// Limit by token, with a limit for the routes of the rate limiter
+	scope := version.GitSource + "/scope/ratelimit/read"
+	limiter, err := ratelimit.New(ratelimit.Config{
+		Rate:  0.001,
+		Burst: 10,
+		Scopes: map[string]ratelimit.Limit{
+			scope: {Rate: 0.001, Burst: 1},
+		},
+	})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Add the endpoints to a router, with middleware which sets the token
+	// and the rate limiter
+	r, err := router.Config{}.New()
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	r.(router.Router).AddServiceEndpoints("ratelimit", limiter, testToken{}, limiter)
+	handler := r.(http.Handler)
+
+	// Make a request with a token
+	do := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/ratelimit/", nil)
+		req.Header.Set("Token", token)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// The scope allows one request for each token
+	resp := do("a")
+	assert.Equal(http.StatusOK, resp.Code)
+	resp = do("a")
+	assert.Equal(http.StatusTooManyRequests, resp.Code)
+	assert.NotEmpty(resp.Header().Get("Retry-After"))
+	resp = do("b")
+	assert.Equal(http.StatusOK, resp.Code)
+
+	// Check the state of the buckets
+	var buckets []struct {
+		Key   string `json:"key"`
+		Scope string `json:"scope"`
+		Burst int    `json:"burst"`
+	}
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &buckets))
+	if assert.Len(buckets, 2) {
+		assert.Equal("token:a", buckets[0].Key)
+		assert.Equal("token:b", buckets[1].Key)
+		assert.Equal(scope, buckets[1].Scope)
+		assert.Equal(1, buckets[1].Burst)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// MIDDLEWARE
+
+// testToken sets the token name from a header
+type testToken struct{}
+
+func (testToken) Label() string {
+	return "token"
+}
+
+func (testToken) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (testToken) Wrap(ctx context.Context, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(w, r.WithContext(auth.WithToken(r.Context(), auth.Token{Name: r.Header.Get("Token")})))
+	}
+}
@@ -0,0 +1,25 @@
+package ratelimit
+
+import (
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/version"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	// Prefix
+	scopePrefix = version.GitSource + "/scope/"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (ratelimit *ratelimit) ScopeRead() []string {
+	// Return read (list, get) scopes
+	return []string{
+		scopePrefix + ratelimit.Label() + "/read",
+		scopePrefix + defaultName + "/read",
+	}
+}
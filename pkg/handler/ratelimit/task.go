@@ -0,0 +1,30 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return the label for the task
+func (ratelimit *ratelimit) Label() string {
+	// TODO
+	return defaultName
+}
+
+// Run the task until the context is cancelled, removing buckets which
+// are full
+func (ratelimit *ratelimit) Run(ctx context.Context) error {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			ratelimit.purge(now)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package router
+
+import (
+	"container/list"
+	"sync"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// routeCache is a bounded LRU cache of matched routes, keyed on method,
+// host and path
+type routeCache struct {
+	sync.Mutex
+
+	// Maximum number of entries in the cache
+	cap int
+
+	// Map key to list element, and the list of entries with the most
+	// recently used at the front
+	entries map[string]*list.Element
+	lru     *list.List
+
+	// Cache hits and misses
+	hits, misses uint64
+}
+
+// cacheEntry is a matched route and status code
+type cacheEntry struct {
+	key   string
+	route *matchedRoute
+	code  int
+}
+
+// CacheStats is the current state of the cache
+type CacheStats struct {
+	Size   int    `json:"size"`
+	Cap    int    `json:"cap"`
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a new route cache with a maximum number of entries. If the capacity
+// is zero or less, then routes are never cached
+func newRouteCache(cap int) *routeCache {
+	cache := new(routeCache)
+	cache.cap = max(cap, 0)
+	cache.entries = make(map[string]*list.Element, cache.cap)
+	cache.lru = list.New()
+	return cache
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Get a matched route and status code from the cache. Returns false if the
+// route is not cached. The returned route is a copy, flagged as cached
+func (cache *routeCache) Get(method, host, path string) (*matchedRoute, int, bool) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	// Miss
+	elem, exists := cache.entries[cacheKey(method, host, path)]
+	if !exists {
+		cache.misses++
+		return nil, 0, false
+	}
+
+	// Hit - move to the front of the list
+	cache.hits++
+	cache.lru.MoveToFront(elem)
+	entry := elem.Value.(*cacheEntry)
+	if entry.route == nil {
+		return nil, entry.code, true
+	}
+	route := *entry.route
+	route.cached = true
+	return &route, entry.code, true
+}
+
+// Set a matched route and status code in the cache, evicting the least
+// recently used entry if the cache is full
+func (cache *routeCache) Set(method, host, path string, route *matchedRoute, code int) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	// Caching is disabled
+	if cache.cap == 0 {
+		return
+	}
+
+	// Replace an existing entry
+	key := cacheKey(method, host, path)
+	if elem, exists := cache.entries[key]; exists {
+		elem.Value = &cacheEntry{key, route, code}
+		cache.lru.MoveToFront(elem)
+		return
+	}
+
+	// Evict the least recently used entry
+	if cache.lru.Len() >= cache.cap {
+		if elem := cache.lru.Back(); elem != nil {
+			delete(cache.entries, elem.Value.(*cacheEntry).key)
+			cache.lru.Remove(elem)
+		}
+	}
+
+	// Add the entry
+	cache.entries[key] = cache.lru.PushFront(&cacheEntry{key, route, code})
+}
+
+// Remove all entries from the cache, which is called when the routes change
+func (cache *routeCache) Clear() {
+	cache.Lock()
+	defer cache.Unlock()
+	clear(cache.entries)
+	cache.lru.Init()
+}
+
+// Return the cache size, capacity, hits and misses
+func (cache *routeCache) Stats() CacheStats {
+	cache.Lock()
+	defer cache.Unlock()
+	return CacheStats{
+		Size:   cache.lru.Len(),
+		Cap:    cache.cap,
+		Hits:   cache.hits,
+		Misses: cache.misses,
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func cacheKey(method, host, path string) string {
+	return method + " " + host + " " + path
+}
@@ -3,13 +3,16 @@ package router
 import (
 	// Packages
 	server "github.com/mutablelogic/go-server"
+	trace "github.com/mutablelogic/go-server/pkg/trace"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
 // TYPES
 
 type Config struct {
-	Services ServiceConfig `hcl:"services"`
+	Services  ServiceConfig  `hcl:"services"`
+	CacheSize int            `hcl:"cache_size" description:"Maximum number of matched routes to cache, or -1 to disable the cache"`
+	Tracer    trace.Exporter `hcl:"tracer" description:"Tracer which receives a span for each request, middleware and handler"`
 }
 
 type ServiceConfig map[string]struct {
@@ -26,6 +29,7 @@ var _ server.Plugin = Config{}
 const (
 	defaultName      = "router"
 	defaultCap       = 10
+	defaultCacheSize = 1024
 	pathSep          = "/"
 	hostSep          = "."
 	envRequestPrefix = "REQUEST_PREFIX"
@@ -27,6 +27,7 @@ const (
 	contextScope
 	contextMethod
 	contextTime
+	contextService
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -147,6 +148,19 @@ func Method(ctx context.Context) []string {
 ////////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// Return a context with the service which is adding endpoints
+func withService(ctx context.Context, service *serviceEndpoints) context.Context {
+	return context.WithValue(ctx, contextService, service)
+}
+
+// Return the service which is adding endpoints, or nil if not defined
+func serviceFrom(ctx context.Context) *serviceEndpoints {
+	if value, ok := ctx.Value(contextService).(*serviceEndpoints); ok {
+		return value
+	}
+	return nil
+}
+
 func str(ctx context.Context, key RouterContextKey) string {
 	if value, ok := ctx.Value(key).(string); ok {
 		return value
@@ -8,8 +8,28 @@ import (
 	// Packages
 	server "github.com/mutablelogic/go-server"
 	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+	provider "github.com/mutablelogic/go-server/pkg/provider"
 )
 
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// reloader is implemented by a provider which can reload the configuration
+type reloader interface {
+	server.Logger
+	Reload(context.Context) error
+}
+
+type responseRouter struct {
+	Scopes []string   `json:"scopes"`
+	Cache  CacheStats `json:"cache"`
+}
+
+type responseOpenAPI struct {
+	Prefix string   `json:"prefix"`
+	Labels []string `json:"labels,omitempty"`
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // GLOBALS
 
@@ -18,7 +38,11 @@ const (
 )
 
 var (
-	reRoot = regexp.MustCompile(`^/?$`)
+	reRoot          = regexp.MustCompile(`^/?$`)
+	reReload        = regexp.MustCompile(`^/reload/?$`)
+	reOpenAPI       = regexp.MustCompile(`^/openapi\.json$`)
+	reOpenAPIList   = regexp.MustCompile(`^/openapi/?$`)
+	reOpenAPIPrefix = regexp.MustCompile(`^/openapi(?P<prefix>/.*)\.json$`)
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -29,15 +53,92 @@ func (service *router) AddEndpoints(ctx context.Context, r server.Router) {
 	// Path: /
 	// Methods: GET
 	// Scopes: read
-	// Description: Get router services
-	r.AddHandlerFuncRe(ctx, reRoot, service.GetScopes, http.MethodGet).(Route).
-		SetScope(service.ScopeRead()...)
+	r.AddHandlerFuncRe(ctx, reRoot, service.GetRouter, http.MethodGet).(Route).
+		SetScope(service.ScopeRead()...).
+		SetSummary("Get router scopes and route cache statistics").
+		SetResponse(http.StatusOK, responseRouter{})
+
+	// Path: /reload
+	// Methods: POST
+	// Scopes: write
+	r.AddHandlerFuncRe(ctx, reReload, service.Reload, http.MethodPost).(Route).
+		SetScope(service.ScopeWrite()...).
+		SetSummary("Reload the configuration in the background").
+		SetResponse(http.StatusAccepted, nil)
+
+	// Path: /openapi.json
+	// Methods: GET
+	// Scopes: read
+	r.AddHandlerFuncRe(ctx, reOpenAPI, service.GetOpenAPI, http.MethodGet).(Route).
+		SetScope(service.ScopeRead()...).
+		SetSummary("Get an OpenAPI document for all services").
+		SetResponse(http.StatusOK, OpenAPI{})
+
+	// Path: /openapi
+	// Methods: GET
+	// Scopes: read
+	r.AddHandlerFuncRe(ctx, reOpenAPIList, service.ListOpenAPI, http.MethodGet).(Route).
+		SetScope(service.ScopeRead()...).
+		SetSummary("List the prefixes which have an OpenAPI document, and the services at each prefix").
+		SetResponse(http.StatusOK, []responseOpenAPI{})
+
+	// Path: /openapi/{prefix}.json
+	// Methods: GET
+	// Scopes: read
+	r.AddHandlerFuncRe(ctx, reOpenAPIPrefix, service.GetOpenAPI, http.MethodGet).(Route).
+		SetScope(service.ScopeRead()...).
+		SetSummary("Get an OpenAPI document for the services at a prefix").
+		SetResponse(http.StatusOK, OpenAPI{})
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
-// Get registered scopes
-func (service *router) GetScopes(w http.ResponseWriter, r *http.Request) {
-	httpresponse.JSON(w, service.Scopes(), http.StatusOK, jsonIndent)
+// Get registered scopes and route cache statistics
+func (service *router) GetRouter(w http.ResponseWriter, r *http.Request) {
+	httpresponse.JSON(w, responseRouter{
+		Scopes: service.Scopes(),
+		Cache:  service.CacheStats(),
+	}, http.StatusOK, jsonIndent)
+}
+
+// Get an OpenAPI document for the services at a prefix, or for all services
+// when there is no prefix
+func (service *router) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	var prefix string
+	if params := Params(r.Context()); len(params) > 0 {
+		prefix = canonicalPrefix(params[0])
+	}
+	doc := service.OpenAPI(prefix)
+	if prefix != "" && len(doc.Paths) == 0 {
+		httpresponse.Error(w, http.StatusNotFound, "no routes with prefix "+prefix)
+		return
+	}
+	httpresponse.JSON(w, doc, http.StatusOK, jsonIndent)
+}
+
+// List the prefixes which have an OpenAPI document, and the services at
+// each prefix
+func (service *router) ListOpenAPI(w http.ResponseWriter, r *http.Request) {
+	httpresponse.JSON(w, service.openapiPrefixes(), http.StatusOK, jsonIndent)
+}
+
+// Reload the configuration. The reload happens in the background, as tasks
+// serving this request may be restarted
+func (service *router) Reload(w http.ResponseWriter, r *http.Request) {
+	p, ok := service.provider.Load().(reloader)
+	if !ok {
+		httpresponse.Error(w, http.StatusNotImplemented, "reload is not supported")
+		return
+	}
+
+	// Reload in the background
+	go func(ctx context.Context) {
+		if err := p.Reload(ctx); err != nil {
+			p.Print(ctx, "Reload: ", err)
+		}
+	}(provider.WithLabel(context.Background(), service.Label()))
+
+	// Respond with accepted
+	httpresponse.Empty(w, http.StatusAccepted)
 }
@@ -21,6 +21,13 @@ type Router interface {
 
 	// Return all known scopes
 	Scopes() []string
+
+	// Return the route cache size, capacity, hits and misses
+	CacheStats() CacheStats
+
+	// Return an OpenAPI document for the routes of the services at a
+	// prefix, or for all routes when the prefix is empty
+	OpenAPI(string) *OpenAPI
 }
 
 type Route interface {
@@ -28,4 +35,14 @@ type Route interface {
 
 	// Set scope
 	SetScope(...string) Route
+
+	// Set a summary of the route, for documentation
+	SetSummary(string) Route
+
+	// Set the type of the request body, for documentation
+	SetRequest(any) Route
+
+	// Set the type of the response body for a status code, for
+	// documentation. The value can be nil for a response without a body
+	SetResponse(int, any) Route
 }
@@ -0,0 +1,178 @@
+package router
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	// Packages
+	openapi "github.com/mutablelogic/go-server/pkg/handler/router/openapi"
+	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
+	version "github.com/mutablelogic/go-server/pkg/version"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// OpenAPI is a document which describes the routes
+type OpenAPI = openapi.Document
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	openapiTitle   = "go-server"
+	openapiVersion = "0.0.0"
+)
+
+var (
+	// Methods which are documented for a route which allows any method
+	openapiMethods = []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodPatch, http.MethodDelete}
+
+	// Methods which do not have a request body
+	openapiNoBody = []string{http.MethodGet, http.MethodHead, http.MethodDelete}
+
+	// Type of error responses
+	typeErrorResponse = reflect.TypeOf(httpresponse.ErrorResponse{})
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return an OpenAPI document for the routes of a service with a prefix, or
+// for all routes when the prefix is empty, with an operation for each
+// method of each route. The operations are tagged with the label of the
+// service, and scopes are security requirements. Routes which cannot be
+// represented as a path template are omitted
+func (router *router) OpenAPI(prefix string) *OpenAPI {
+	doc := openapi.New(openapiTitle, openapiVersion)
+	if version.GitTag != "" {
+		doc.Info.Version = version.GitTag
+	}
+
+	// Add the routes in a stable order
+	for _, route := range router.routes() {
+		if prefix != "" && canonicalPrefix(route.prefix) != canonicalPrefix(prefix) {
+			continue
+		}
+		path, params, err := route.openapiPath()
+		if err != nil {
+			continue
+		}
+		methods := route.methods
+		if len(methods) == 0 {
+			methods = openapiMethods
+		}
+		for _, method := range methods {
+			doc.Add(path, method, route.openapiOperation(doc, method, params))
+		}
+	}
+
+	// Return the document
+	return doc
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the prefixes of the routes, which each have an OpenAPI document,
+// with the labels of the services at each prefix, sorted by prefix
+func (router *router) openapiPrefixes() []responseOpenAPI {
+	var result []responseOpenAPI
+	index := make(map[string]int)
+	for _, route := range router.routes() {
+		prefix := canonicalPrefix(route.prefix)
+		i, exists := index[prefix]
+		if !exists {
+			i = len(result)
+			index[prefix] = i
+			result = append(result, responseOpenAPI{Prefix: prefix})
+		}
+		if route.label != "" && !slices.Contains(result[i].Labels, route.label) {
+			result[i].Labels = append(result[i].Labels, route.label)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Prefix < result[j].Prefix
+	})
+	return result
+}
+
+// Return all routes, sorted by host, prefix and the order they were added
+func (router *router) routes() []*route {
+	router.RLock()
+	defer router.RUnlock()
+
+	var result []*route
+	hosts := make([]string, 0, len(router.host))
+	for host := range router.host {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		prefixes := make([]string, 0, len(router.host[host].prefix))
+		for prefix := range router.host[host].prefix {
+			prefixes = append(prefixes, prefix)
+		}
+		sort.Strings(prefixes)
+		for _, prefix := range prefixes {
+			result = append(result, router.host[host].prefix[prefix].handlers...)
+		}
+	}
+	return result
+}
+
+// Return the path template and parameters for a route
+func (r *route) openapiPath() (string, []*openapi.Parameter, error) {
+	path, params := r.path, []*openapi.Parameter(nil)
+	if r.re != nil {
+		var err error
+		if path, params, err = openapi.Path(r.re); err != nil {
+			return "", nil, err
+		}
+	}
+	if !strings.HasPrefix(path, pathSep) {
+		path = pathSep + path
+	}
+	return strings.TrimSuffix(r.prefix, pathSep) + path, params, nil
+}
+
+// Return the operation for a method of a route
+func (r *route) openapiOperation(doc *openapi.Document, method string, params []*openapi.Parameter) *openapi.Operation {
+	op := &openapi.Operation{
+		Summary:    r.summary,
+		Parameters: params,
+		Responses:  make(map[string]*openapi.Response, len(r.responses)+1),
+		Security:   openapi.Security(r.scopes...),
+		Host:       strings.TrimPrefix(r.host, hostSep),
+	}
+	if r.label != "" {
+		op.Tags = []string{r.label}
+	}
+
+	// Set the request body, for methods which have a body
+	if r.request != nil && !slices.Contains(openapiNoBody, method) {
+		op.RequestBody = &openapi.RequestBody{Required: true, Content: doc.Body(r.request)}
+	}
+
+	// Set the responses, or any successful response when there are none
+	for code, rt := range r.responses {
+		op.Responses[strconv.Itoa(code)] = &openapi.Response{
+			Description: http.StatusText(code),
+			Content:     doc.Body(rt),
+		}
+	}
+	if len(r.responses) == 0 {
+		op.Responses["2XX"] = &openapi.Response{Description: "Success"}
+	}
+	op.Responses["default"] = &openapi.Response{
+		Description: "Error",
+		Content:     doc.Body(typeErrorResponse),
+	}
+
+	// Return the operation
+	return op
+}
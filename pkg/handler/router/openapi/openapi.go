@@ -0,0 +1,161 @@
+/*
+implements an OpenAPI 3.1 document, with schemas for request and response
+bodies which are generated from Go types, and paths which are generated
+from the regular expressions which match routes
+*/
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	// Names of the component schemas, keyed by type
+	types map[reflect.Type]string
+}
+
+// Info describes the API
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem is the set of operations for a path, keyed by lowercase method
+type PathItem map[string]*Operation
+
+// Operation is a method on a path
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Host        string                `json:"x-host,omitempty"`
+}
+
+// Parameter is a parameter in a path
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody is the body of a request
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response is the response for a status code
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components are the schemas and security schemes which are referenced
+// by operations
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is a method of authorization
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	Version         = "3.1.0"
+	ContentTypeJSON = "application/json"
+	SecurityBearer  = "bearer"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// New returns an empty document, with bearer token authorization
+func New(title, version string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version},
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]*SecurityScheme{
+				SecurityBearer: {Type: "http", Scheme: SecurityBearer},
+			},
+		},
+		types: make(map[reflect.Type]string),
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (d *Document) String() string {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Add an operation for a path and method
+func (d *Document) Add(path, method string, op *Operation) {
+	item, exists := d.Paths[path]
+	if !exists {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	(*item)[strings.ToLower(method)] = op
+}
+
+// Body returns the content of a JSON body for a type, or nil if the type
+// is nil
+func (d *Document) Body(rt reflect.Type) map[string]*MediaType {
+	if rt == nil {
+		return nil
+	}
+	return map[string]*MediaType{
+		ContentTypeJSON: {Schema: d.Schema(rt)},
+	}
+}
+
+// Security returns the security requirements for a set of scopes, where
+// any one of the scopes authorizes the operation. Returns nil if there are
+// no scopes
+func Security(scopes ...string) []map[string][]string {
+	if len(scopes) == 0 {
+		return nil
+	}
+	result := make([]map[string][]string, 0, len(scopes))
+	for _, scope := range scopes {
+		result = append(result, map[string][]string{SecurityBearer: {scope}})
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+package openapi_test
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	// Packages
+	openapi "github.com/mutablelogic/go-server/pkg/handler/router/openapi"
+	assert "github.com/stretchr/testify/assert"
+)
+
+type testEmbedded struct {
+	Id string `json:"id"`
+}
+
+type testObject struct {
+	testEmbedded
+	Name     string            `json:"name"`
+	Count    int               `json:"count,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	Created  time.Time         `json:"created"`
+	Child    *testObject       `json:"child,omitempty"`
+	Ignored  string            `json:"-"`
+	internal string
+}
+
+func Test_openapi_001(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		re     string
+		path   string
+		params []string
+	}{
+		{`^/?$`, ``, nil},
+		{`^/hello/?$`, `/hello`, nil},
+		{`^/hello$`, `/hello`, nil},
+		{`^/u/(?P<user>[a-z]+)/?$`, `/u/{user}`, []string{"user"}},
+		{`^/g/([a-z]+)/([a-z]+)/?$`, `/g/{param1}/{param2}`, []string{"param1", "param2"}},
+		{`^/(?P<serial>[0-9]+)/(?P<type>cert|key)\.pem$`, `/{serial}/{type}.pem`, []string{"serial", "type"}},
+	}
+	for _, test := range tests {
+		path, params, err := openapi.Path(regexp.MustCompile(test.re))
+		if !assert.NoError(err, test.re) {
+			continue
+		}
+		assert.Equal(test.path, path, test.re)
+		assert.Len(params, len(test.params), test.re)
+		for i, param := range params {
+			assert.Equal(test.params[i], param.Name)
+			assert.Equal("path", param.In)
+			assert.True(param.Required)
+		}
+	}
+}
+
+func Test_openapi_002(t *testing.T) {
+	assert := assert.New(t)
+	for _, re := range []string{`^/hello/?world$`, `^/(a|b)+$`, `^/hello.*$`} {
+		_, _, err := openapi.Path(regexp.MustCompile(re))
+		assert.Error(err, re)
+	}
+}
+
+func Test_openapi_003(t *testing.T) {
+	assert := assert.New(t)
+	doc := openapi.New("test", "1.0.0")
+
+	// Slice of named structs references a component
+	schema := doc.Schema(reflect.TypeOf([]testObject{}))
+	assert.Equal("array", schema.Type)
+	assert.Equal("#/components/schemas/openapi_test.testObject", schema.Items.Ref)
+
+	// The component flattens embedded structs and skips hidden fields
+	component, exists := doc.Components.Schemas["openapi_test.testObject"]
+	if assert.True(exists) {
+		assert.Equal("object", component.Type)
+		assert.Len(component.Properties, 7)
+		assert.Equal("string", component.Properties["id"].Type)
+		assert.Equal("integer", component.Properties["count"].Type)
+		assert.Equal("array", component.Properties["tags"].Type)
+		assert.Equal("object", component.Properties["meta"].Type)
+		assert.Equal("date-time", component.Properties["created"].Format)
+		assert.Equal("#/components/schemas/openapi_test.testObject", component.Properties["child"].Ref)
+		assert.ElementsMatch([]string{"id", "name", "created"}, component.Required)
+	}
+}
+
+func Test_openapi_004(t *testing.T) {
+	assert := assert.New(t)
+	doc := openapi.New("test", "1.0.0")
+
+	// Each scope is an alternative requirement
+	assert.Nil(openapi.Security())
+	assert.Equal([]map[string][]string{
+		{openapi.SecurityBearer: {"read"}},
+		{openapi.SecurityBearer: {"write"}},
+	}, openapi.Security("read", "write"))
+
+	// Operations are keyed by lowercase method
+	doc.Add("/hello", "GET", &openapi.Operation{Summary: "Hello"})
+	if assert.Contains(doc.Paths, "/hello") {
+		assert.Equal("Hello", (*doc.Paths["/hello"])["get"].Summary)
+	}
+	assert.Contains(doc.String(), `"openapi": "3.1.0"`)
+}
@@ -0,0 +1,84 @@
+package openapi
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Path returns a path template and path parameters for a regular expression
+// which matches a path. Each capture group is a parameter, which is named
+// by the name of the group or otherwise by its position. An optional
+// trailing slash is removed. Returns an error if the expression cannot
+// be represented as a path template
+func Path(re *regexp.Regexp) (string, []*Parameter, error) {
+	node, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var path strings.Builder
+	var params []*Parameter
+	if err := walk(node, &path, &params); err != nil {
+		return "", nil, fmt.Errorf("%q: %w", re, err)
+	}
+	return path.String(), params, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func walk(node *syntax.Regexp, path *strings.Builder, params *[]*Parameter) error {
+	switch node.Op {
+	case syntax.OpBeginText, syntax.OpEndText, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpEmptyMatch:
+		return nil
+	case syntax.OpLiteral:
+		path.WriteString(string(node.Rune))
+	case syntax.OpConcat:
+		for i, sub := range node.Sub {
+			if sub.Op == syntax.OpQuest && !isEnd(node.Sub[i+1:]) {
+				return ErrBadParameter.With("optional expression")
+			}
+			if err := walk(sub, path, params); err != nil {
+				return err
+			}
+		}
+	case syntax.OpCapture:
+		name := node.Name
+		if name == "" {
+			name = fmt.Sprint("param", len(*params)+1)
+		}
+		path.WriteString("{" + name + "}")
+		*params = append(*params, &Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string", Pattern: "^" + node.Sub[0].String() + "$"},
+		})
+	case syntax.OpQuest:
+		if node.Sub[0].Op == syntax.OpLiteral && string(node.Sub[0].Rune) == "/" {
+			return nil
+		}
+		return ErrBadParameter.With("optional expression")
+	default:
+		return ErrBadParameter.Withf("unsupported expression %q", node)
+	}
+	return nil
+}
+
+// Return true if the expressions only match the end of the text
+func isEnd(nodes []*syntax.Regexp) bool {
+	for _, node := range nodes {
+		if node.Op != syntax.OpEndText && node.Op != syntax.OpEndLine && node.Op != syntax.OpEmptyMatch {
+			return false
+		}
+	}
+	return true
+}
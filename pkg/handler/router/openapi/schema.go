@@ -0,0 +1,188 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Schema is a JSON Schema for a value
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	refPrefix      = "#/components/schemas/"
+	tagJSON        = "json"
+	tagDescription = "description"
+)
+
+var (
+	typeTime          = reflect.TypeOf(time.Time{})
+	typeBytes         = reflect.TypeOf([]byte{})
+	typeMarshaler     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	typeTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Schema returns the schema for a type, as it is encoded as JSON. Named
+// struct types are added to the components of the document and referenced
+func (d *Document) Schema(rt reflect.Type) *Schema {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+
+	// Types with a special encoding
+	switch {
+	case rt == typeTime:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rt == typeBytes:
+		return &Schema{Type: "string", Format: "byte"}
+	case isMarshaler(rt) && !hasJSONTags(rt):
+		return &Schema{Type: "string"}
+	}
+
+	switch rt.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: d.Schema(rt.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.Schema(rt.Elem())}
+	case reflect.Struct:
+		if rt.Name() == "" {
+			return d.schemaForStruct(rt)
+		}
+		return &Schema{Ref: refPrefix + d.component(rt)}
+	}
+
+	// Any value
+	return &Schema{}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the name of the component for a named struct type, adding the
+// component if it does not exist
+func (d *Document) component(rt reflect.Type) string {
+	if name, exists := d.types[rt]; exists {
+		return name
+	}
+
+	// Name the component by the package and the type
+	name := path.Base(rt.PkgPath()) + "." + rt.Name()
+	d.types[rt] = name
+
+	// Set the schema after the name, so that recursive types are references
+	d.Components.Schemas[name] = d.schemaForStruct(rt)
+	return name
+}
+
+// Return the schema for the exported fields of a struct, where embedded
+// structs without a name are flattened
+func (d *Document) schemaForStruct(rt reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, omitempty, skip := jsonName(field)
+		if skip {
+			continue
+		}
+
+		// Flatten an embedded struct
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded := d.schemaForStruct(ft)
+				for key, property := range embedded.Properties {
+					schema.Properties[key] = property
+				}
+				schema.Required = append(schema.Required, embedded.Required...)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		// Set the property
+		property := d.Schema(field.Type)
+		if description := field.Tag.Get(tagDescription); description != "" && property.Ref == "" {
+			property.Description = description
+		}
+		schema.Properties[name] = property
+		if !omitempty {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// Return the JSON name for a field, whether it is omitted when empty, and
+// whether the field is skipped
+func jsonName(field reflect.StructField) (string, bool, bool) {
+	if !field.IsExported() && !field.Anonymous {
+		return "", false, true
+	}
+	tag := field.Tag.Get(tagJSON)
+	if tag == "-" {
+		return "", false, true
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	return name, strings.Contains(","+opts+",", ",omitempty,"), false
+}
+
+// Return true if a type, or a pointer to the type, encodes itself
+func isMarshaler(rt reflect.Type) bool {
+	for _, t := range []reflect.Type{rt, reflect.PointerTo(rt)} {
+		if t.Implements(typeMarshaler) || t.Implements(typeTextMarshaler) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return true if a type is a struct with fields which have JSON tags, so
+// that a type which encodes itself is assumed to encode the fields. Other
+// types which encode themselves are assumed to encode a string
+func hasJSONTags(rt reflect.Type) bool {
+	if rt.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < rt.NumField(); i++ {
+		if _, exists := rt.Field(i).Tag.Lookup(tagJSON); exists {
+			return true
+		}
+	}
+	return false
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"net/http"
 	"regexp"
-	"slices"
 	"strings"
+
+	// Packages
+	provider "github.com/mutablelogic/go-server/pkg/provider"
+	trace "github.com/mutablelogic/go-server/pkg/trace"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -82,12 +85,8 @@ func (router *reqrouter) AddHandlerRe(ctx context.Context, prefix string, path *
 }
 
 func (router *reqs) AddHandler(ctx context.Context, path string, handler http.HandlerFunc, methods ...string) *route {
-	// Add any middleware to the handler, in reverse order
-	middleware := Middleware(ctx)
-	slices.Reverse(middleware)
-	for _, middleware := range middleware {
-		handler = middleware.Wrap(ctx, handler)
-	}
+	// Add any middleware to the handler
+	handler = wrapMiddleware(ctx, handler)
 
 	// Create the route
 	route := NewRouteWithPath(ctx, router.host, router.prefix, path, methods...)
@@ -104,11 +103,7 @@ func (router *reqs) AddHandler(ctx context.Context, path string, handler http.Ha
 
 func (router *reqs) AddHandlerRe(ctx context.Context, path *regexp.Regexp, handler http.HandlerFunc, methods ...string) *route {
 	// Add any middleware to the handler
-	middleware := Middleware(ctx)
-	slices.Reverse(middleware)
-	for _, middleware := range middleware {
-		handler = middleware.Wrap(ctx, handler)
-	}
+	handler = wrapMiddleware(ctx, handler)
 
 	// Create the route
 	route := NewRouteWithRegexp(ctx, router.host, router.prefix, path, methods...)
@@ -126,6 +121,18 @@ func (router *reqs) AddHandlerRe(ctx context.Context, path *regexp.Regexp, handl
 ///////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// Wrap a handler with the middleware in the context, where the first
+// middleware is the outermost. The handler and each middleware are traced
+// with a span when the request is traced
+func wrapMiddleware(ctx context.Context, handler http.HandlerFunc) http.HandlerFunc {
+	handler = trace.Handler("handler "+provider.Label(ctx), handler)
+	middleware := Middleware(ctx)
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = trace.Handler("middleware "+middleware[i].Label(), middleware[i].Wrap(ctx, handler))
+	}
+	return handler
+}
+
 // Returns a set of requests for a path
 func (router *reqrouter) matchHandlers(path string) ([]*route, string) {
 	// We assume that the path starts with a "/"
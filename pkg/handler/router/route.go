@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"reflect"
 	"regexp"
 	"slices"
 	"strings"
@@ -26,6 +27,14 @@ type route struct {
 	handler http.HandlerFunc
 	methods []string
 	scopes  []string
+
+	// The service which added the route, or nil
+	service *serviceEndpoints
+
+	// Documentation for the route
+	summary   string
+	request   reflect.Type
+	responses map[int]reflect.Type
 }
 
 // matchedRoute is a route which has been matched by the router
@@ -45,7 +54,7 @@ type matchedRoute struct {
 	// The route that has been matched
 	*route
 
-	// TODO: Whether the result was from the cache
+	// Whether the result was from the cache
 	cached bool
 }
 
@@ -59,6 +68,7 @@ var _ Route = (*route)(nil)
 func NewRoute(ctx context.Context, host, prefix string, methods ...string) *route {
 	route := new(route)
 	route.label = provider.Label(ctx)
+	route.service = serviceFrom(ctx)
 	route.host = host
 	route.prefix = prefix
 	route.methods = methods
@@ -164,6 +174,11 @@ func (r *matchedRoute) Path() string {
 	return r.request
 }
 
+// Return true if the route was matched from the cache
+func (r *matchedRoute) Cached() bool {
+	return r.cached
+}
+
 func (r *route) SetScope(scope ...string) Route {
 	for _, s := range scope {
 		if !slices.Contains(r.scopes, s) {
@@ -173,6 +188,28 @@ func (r *route) SetScope(scope ...string) Route {
 	return r
 }
 
+// Set a summary of the route, for documentation
+func (r *route) SetSummary(summary string) Route {
+	r.summary = summary
+	return r
+}
+
+// Set the type of the request body, for documentation
+func (r *route) SetRequest(v any) Route {
+	r.request = reflect.TypeOf(v)
+	return r
+}
+
+// Set the type of the response body for a status code, for documentation.
+// The value can be nil for a response without a body
+func (r *route) SetResponse(code int, v any) Route {
+	if r.responses == nil {
+		r.responses = make(map[int]reflect.Type, 1)
+	}
+	r.responses[code] = reflect.TypeOf(v)
+	return r
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
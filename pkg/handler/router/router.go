@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	// Packages
@@ -14,6 +17,7 @@ import (
 	httpresponse "github.com/mutablelogic/go-server/pkg/httpresponse"
 	fcgi "github.com/mutablelogic/go-server/pkg/httpserver/fcgi"
 	provider "github.com/mutablelogic/go-server/pkg/provider"
+	trace "github.com/mutablelogic/go-server/pkg/trace"
 	"golang.org/x/exp/maps"
 )
 
@@ -22,17 +26,39 @@ import (
 
 type router struct {
 	// Map host to a request router. If host key is empty, then it's a default router
-	// where the host is not matched
+	// where the host is not matched. Guarded by the lock, as routes are
+	// added while requests are served
+	sync.RWMutex
 	host map[string]*reqrouter
+
+	// Cache of matched routes
+	cache *routeCache
+
+	// Exporter for request spans, or nil if requests are not traced
+	tracer trace.Exporter
+
+	// The provider which is running the router
+	provider atomic.Value
+
+	// The services which have added endpoints, guarded by the lock
+	services []*serviceEndpoints
 }
 
 // represents a set of handlers to be considered for a request
 type reqhandlers []*route
 
+// represents a service which adds endpoints with a host, prefix and middleware
+type serviceEndpoints struct {
+	host, prefix string
+	service      server.ServiceEndpoints
+	middleware   []server.Middleware
+}
+
 // Ensure interfaces is implemented
 var _ http.Handler = (*router)(nil)
 var _ server.Router = (*router)(nil)
 var _ Router = (*router)(nil)
+var _ server.Replacer = (*router)(nil)
 
 ///////////////////////////////////////////////////////////////////////////////
 // LIFECYCLE
@@ -42,6 +68,16 @@ func New(c Config) (server.Task, error) {
 	r := new(router)
 	r.host = make(map[string]*reqrouter, defaultCap)
 
+	// Set the route cache
+	if c.CacheSize == 0 {
+		r.cache = newRouteCache(defaultCacheSize)
+	} else {
+		r.cache = newRouteCache(c.CacheSize)
+	}
+
+	// Set the tracer
+	r.tracer = c.Tracer
+
 	// Add services
 	for key, service := range c.Services {
 		r.AddServiceEndpoints(key, service.Service, service.Middleware...)
@@ -58,6 +94,15 @@ func New(c Config) (server.Task, error) {
 func (router *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := WithTime(r.Context(), time.Now())
 
+	// Start a span for the request, continuing the trace of the caller
+	if router.tracer != nil {
+		var span *trace.Span
+		ctx, span = startRequestSpan(trace.WithExporter(ctx, router.tracer), r)
+		sw := newStatusWriter(w)
+		defer endRequestSpan(span, sw)
+		w = sw
+	}
+
 	// Process FastCGI environment - remove the REQUEST_PREFIX from the request path
 	// and set the host from SERVER_NAME
 	path := r.URL.Path
@@ -70,11 +115,9 @@ func (router *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Match the route (TODO: From cache)
+	// Match the route, from the cache if possible
 	matchedRoute, code := router.Match(r.Method, canonicalHost(r.Host), path)
 
-	// TODO: Cache the route if not already cached
-
 	// Close the body after return
 	defer r.Body.Close()
 
@@ -88,6 +131,9 @@ func (router *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case http.StatusMethodNotAllowed:
 		httpresponse.Error(w, code, "method not allowed:", r.Method)
 	case http.StatusOK:
+		if span := trace.FromContext(ctx); span != nil && matchedRoute.label != "" {
+			span.Name = r.Method + " " + matchedRoute.label
+		}
 		r = r.Clone(WithRoute(ctx, matchedRoute))
 		r.URL.Path = matchedRoute.request
 		matchedRoute.route.handler(w, r)
@@ -105,13 +151,17 @@ func (router *router) AddHandlerFunc(ctx context.Context, path string, handler h
 	if !strings.HasPrefix(path, pathSep) {
 		path = pathSep + path
 	}
+	router.Lock()
+	defer router.Unlock()
+
 	// Create a new request router for the host
 	key := canonicalHost(Host(ctx))
 	if _, exists := router.host[key]; !exists {
 		router.host[key] = newReqRouter(key)
 	}
 
-	// Add the handler to the set of requests
+	// Add the handler to the set of requests, then invalidate the route cache
+	defer router.cache.Clear()
 	return router.host[key].AddHandler(ctx, canonicalPrefix(Prefix(ctx)), path, handler, methods...)
 }
 
@@ -120,71 +170,52 @@ func (router *router) AddHandlerRe(ctx context.Context, path *regexp.Regexp, han
 }
 
 func (router *router) AddHandlerFuncRe(ctx context.Context, path *regexp.Regexp, handler http.HandlerFunc, methods ...string) server.Route {
+	router.Lock()
+	defer router.Unlock()
+
 	// Create a new request router for the host
 	key := canonicalHost(Host(ctx))
 	if _, exists := router.host[key]; !exists {
 		router.host[key] = newReqRouter(key)
 	}
 
-	// Add the handler to the set of requests
+	// Add the handler to the set of requests, then invalidate the route cache
+	defer router.cache.Clear()
 	return router.host[key].AddHandlerRe(ctx, canonicalPrefix(Prefix(ctx)), path, handler.ServeHTTP, methods...)
 }
 
 // Match handlers for a given method, host and path, Returns the match
 // and the status code, which can be 200, 308, 404 or 405. If the
-// status code is 308, then the path is the redirect path.
+// status code is 308, then the path is the redirect path. Results are
+// returned from the route cache where possible.
 func (router *router) Match(method, host, path string) (*matchedRoute, int) {
-	var results reqhandlers
-
-	// Check for host and path
 	host = canonicalHost(host)
-	for key, r := range router.host {
-		if key != "" && !strings.HasSuffix(host, key) {
-			continue
-		}
-		if handlers, redirect := r.matchHandlers(path); len(handlers) > 0 {
-			results = append(results, handlers...)
-		} else if redirect != "" {
-			// Bail out to redirect
-			return NewMatchedRoute(nil, "", "", redirect), http.StatusPermanentRedirect
-		}
-	}
 
-	// Bail out if no results
-	if len(results) == 0 {
-		return nil, http.StatusNotFound
-	}
-
-	// If it's an OPTIONS request, then return the route which returns allowed methods
-	if method == http.MethodOptions {
-		return NewMatchedRoute(newOptionsRoute(results), method, host, path), http.StatusOK
+	// Return from the cache
+	if route, code, exists := router.cache.Get(method, host, path); exists {
+		return route, code
 	}
 
-	// Sort results by prefix and path length, with longest first
-	sort.Sort(results)
-
-	// Match method
-	for _, r := range results {
-		// Return the first method which matches
-		if !r.MatchMethod(method) {
-			continue
-		}
+	// Match the route and cache the result. The read lock is held until the
+	// result is cached, so that a route added meanwhile clears it
+	router.RLock()
+	defer router.RUnlock()
+	route, code := router.match(method, host, path)
+	router.cache.Set(method, host, path, route, code)
 
-		// Determine the path
-		path := strings.TrimPrefix(path, r.prefix)
-		if path == "" || !strings.HasPrefix(path, pathSep) {
-			path = pathSep + path
-		}
-
-		// Return the route
-		return NewMatchedRoute(r, method, host, path, r.MatchRe(path)...), http.StatusOK
-	}
+	// Return the result
+	return route, code
+}
 
-	// We had a match but not for the method
-	return nil, http.StatusMethodNotAllowed
+// Return the route cache size, capacity, hits and misses
+func (router *router) CacheStats() CacheStats {
+	return router.cache.Stats()
 }
 
 func (router *router) Scopes() []string {
+	router.RLock()
+	defer router.RUnlock()
+
 	scopes := make(map[string]bool)
 	for _, r := range router.host {
 		for _, h := range r.prefix {
@@ -225,22 +256,197 @@ func (router *router) AddServiceEndpoints(hostprefix string, service server.Serv
 	router.addServiceEndpoints(parts[0], parts[1], service, middleware...)
 }
 
+// Replace a service or middleware with another task while the router is
+// running. The endpoints of the services which refer to it are added again,
+// and requests in progress complete with the previous handlers. Returns false
+// if the task is the tracer, or the other task cannot be used in its place
+func (router *router) Replace(from, to server.Task) bool {
+	if router.tracer != nil && any(router.tracer) == any(from) {
+		return false
+	}
+	service, isService := to.(server.ServiceEndpoints)
+	middleware, isMiddleware := to.(server.Middleware)
+
+	// Make a copy of each service which refers to the task, which refers to
+	// the other task instead
+	router.RLock()
+	services := slices.Clone(router.services)
+	router.RUnlock()
+	replaced := make(map[*serviceEndpoints]*serviceEndpoints, len(services))
+	for _, endpoints := range services {
+		var modified bool
+		replacement := &serviceEndpoints{endpoints.host, endpoints.prefix, endpoints.service, slices.Clone(endpoints.middleware)}
+		if server.Task(replacement.service) == from {
+			if !isService {
+				return false
+			}
+			replacement.service, modified = service, true
+		}
+		for i := range replacement.middleware {
+			if server.Task(replacement.middleware[i]) == from {
+				if !isMiddleware {
+					return false
+				}
+				replacement.middleware[i], modified = middleware, true
+			}
+		}
+		if modified {
+			replaced[endpoints] = replacement
+		}
+	}
+	if len(replaced) == 0 {
+		return true
+	}
+
+	// Add the endpoints of the copies to a separate set of routes
+	staging := newStagingRouter()
+	for _, endpoints := range replaced {
+		staging.addEndpoints(endpoints)
+	}
+
+	// Swap the routes of the services for the new routes, then invalidate
+	// the route cache
+	router.Lock()
+	defer router.Unlock()
+	router.removeRoutes(func(route *route) bool {
+		_, exists := replaced[route.service]
+		return exists
+	})
+	router.mergeRoutes(staging.host)
+	for i, endpoints := range router.services {
+		if replacement, exists := replaced[endpoints]; exists {
+			router.services[i] = replacement
+		}
+	}
+	router.cache.Clear()
+
+	// Return success
+	return true
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// Match handlers for a given method, canonical host and path
+func (router *router) match(method, host, path string) (*matchedRoute, int) {
+	var results reqhandlers
+
+	// Check for host and path
+	for key, r := range router.host {
+		if key != "" && !strings.HasSuffix(host, key) {
+			continue
+		}
+		if handlers, redirect := r.matchHandlers(path); len(handlers) > 0 {
+			results = append(results, handlers...)
+		} else if redirect != "" {
+			// Bail out to redirect
+			return NewMatchedRoute(nil, "", "", redirect), http.StatusPermanentRedirect
+		}
+	}
+
+	// Bail out if no results
+	if len(results) == 0 {
+		return nil, http.StatusNotFound
+	}
+
+	// If it's an OPTIONS request, then return the route which returns allowed methods
+	if method == http.MethodOptions {
+		return NewMatchedRoute(newOptionsRoute(results), method, host, path), http.StatusOK
+	}
+
+	// Sort results by prefix and path length, with longest first
+	sort.Sort(results)
+
+	// Match method
+	for _, r := range results {
+		// Return the first method which matches
+		if !r.MatchMethod(method) {
+			continue
+		}
+
+		// Determine the path
+		path := strings.TrimPrefix(path, r.prefix)
+		if path == "" || !strings.HasPrefix(path, pathSep) {
+			path = pathSep + path
+		}
+
+		// Return the route
+		return NewMatchedRoute(r, method, host, path, r.MatchRe(path)...), http.StatusOK
+	}
+
+	// We had a match but not for the method
+	return nil, http.StatusMethodNotAllowed
+}
+
 // Add a set of endpoints to the router with a prefix and middleware
 func (router *router) addServiceEndpoints(host, prefix string, service server.ServiceEndpoints, middleware ...server.Middleware) {
+	endpoints := &serviceEndpoints{host, prefix, service, middleware}
+
+	// Retain the service, so the endpoints can be added again
+	router.Lock()
+	router.services = append(router.services, endpoints)
+	router.Unlock()
+
+	// Add the endpoints
+	router.addEndpoints(endpoints)
+}
+
+// Call a service to add its endpoints, with the host, prefix and middleware
+// in the context
+func (router *router) addEndpoints(endpoints *serviceEndpoints) {
 	// Set the context
-	ctx := WithHostPrefix(context.Background(), canonicalHost(host), prefix)
-	if len(middleware) > 0 {
-		ctx = WithMiddleware(ctx, middleware...)
+	ctx := WithHostPrefix(context.Background(), canonicalHost(endpoints.host), endpoints.prefix)
+	ctx = withService(ctx, endpoints)
+	if len(endpoints.middleware) > 0 {
+		ctx = WithMiddleware(ctx, endpoints.middleware...)
 	}
-	if label := service.Label(); label != "" {
+	if label := endpoints.service.Label(); label != "" {
 		ctx = provider.WithLabel(ctx, label)
 	}
 
 	// Call the service to add the endpoints
-	service.AddEndpoints(ctx, router)
+	endpoints.service.AddEndpoints(ctx, router)
+}
+
+// Return a router without a route cache, which is used to add routes before
+// they are merged into a running router
+func newStagingRouter() *router {
+	r := new(router)
+	r.host = make(map[string]*reqrouter, defaultCap)
+	r.cache = newRouteCache(0)
+	return r
+}
+
+// Remove the routes which match a function, and any prefixes without routes.
+// The router should be locked
+func (router *router) removeRoutes(fn func(*route) bool) {
+	for _, r := range router.host {
+		for key, reqs := range r.prefix {
+			reqs.handlers = slices.DeleteFunc(reqs.handlers, fn)
+			if len(reqs.handlers) == 0 {
+				delete(r.prefix, key)
+			}
+		}
+	}
+}
+
+// Add routes to the router for each host and prefix. The router should be
+// locked
+func (router *router) mergeRoutes(host map[string]*reqrouter) {
+	for key, r := range host {
+		dest, exists := router.host[key]
+		if !exists {
+			router.host[key] = r
+			continue
+		}
+		for prefix, reqs := range r.prefix {
+			if existing, exists := dest.prefix[prefix]; exists {
+				existing.handlers = append(existing.handlers, reqs.handlers...)
+			} else {
+				dest.prefix[prefix] = reqs
+			}
+		}
+	}
 }
 
 // Return prefix from context, always starts with a '/'
@@ -2,10 +2,12 @@ package router_test
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"sync"
 	"testing"
 
 	server "github.com/mutablelogic/go-server"
@@ -336,3 +338,275 @@ func Test_router_009(t *testing.T) {
 		}
 	})
 }
+
+func Test_router_010(t *testing.T) {
+	assert := assert.New(t)
+	task, err := router.Config{}.New()
+	assert.NoError(err)
+
+	// Handle only the path /hello
+	task.(router.Router).AddHandlerFuncRe(context.Background(), regexp.MustCompile("^/hello/(.*)$"), func(w http.ResponseWriter, r *http.Request) {
+		// TODO
+	})
+
+	// First match is not from the cache, second is
+	match, code := task.(router.Router).Match("GET", "", "/hello/world")
+	assert.Equal(200, code)
+	if assert.NotNil(match) {
+		assert.False(match.Cached())
+	}
+	match, code = task.(router.Router).Match("GET", "", "/hello/world")
+	assert.Equal(200, code)
+	if assert.NotNil(match) {
+		assert.True(match.Cached())
+		assert.Equal([]string{"world"}, match.Parameters())
+	}
+	stats := task.(router.Router).CacheStats()
+	assert.Equal(1, stats.Size)
+	assert.Equal(uint64(1), stats.Hits)
+	assert.Equal(uint64(1), stats.Misses)
+
+	// Not found is cached, and the cache is invalidated when a handler is added
+	_, code = task.(router.Router).Match("GET", "", "/goodbye")
+	assert.Equal(404, code)
+	task.(router.Router).AddHandlerFunc(context.Background(), "/goodbye", func(w http.ResponseWriter, r *http.Request) {
+		// TODO
+	})
+	assert.Equal(0, task.(router.Router).CacheStats().Size)
+	_, code = task.(router.Router).Match("GET", "", "/goodbye")
+	assert.Equal(200, code)
+}
+
+func Test_router_011(t *testing.T) {
+	assert := assert.New(t)
+	task, err := router.Config{CacheSize: 2}.New()
+	assert.NoError(err)
+
+	task.(router.Router).AddHandlerFuncRe(context.Background(), regexp.MustCompile("^/(.*)$"), func(w http.ResponseWriter, r *http.Request) {
+		// TODO
+	})
+
+	// Least recently used entry is evicted
+	for _, path := range []string{"/a", "/b", "/a", "/c", "/a"} {
+		_, code := task.(router.Router).Match("GET", "", path)
+		assert.Equal(200, code)
+	}
+	stats := task.(router.Router).CacheStats()
+	assert.Equal(2, stats.Size)
+	assert.Equal(2, stats.Cap)
+	assert.Equal(uint64(2), stats.Hits)
+	assert.Equal(uint64(3), stats.Misses)
+
+	// Disable the cache
+	task, err = router.Config{CacheSize: -1}.New()
+	assert.NoError(err)
+	task.(router.Router).Match("GET", "", "/a")
+	task.(router.Router).Match("GET", "", "/a")
+	assert.Equal(0, task.(router.Router).CacheStats().Size)
+	assert.Equal(uint64(0), task.(router.Router).CacheStats().Hits)
+}
+
+func Test_router_012(t *testing.T) {
+	assert := assert.New(t)
+	task, err := router.Config{}.New()
+	assert.NoError(err)
+
+	type hello struct {
+		Name string `json:"name"`
+	}
+
+	// Add documented routes
+	ctx := router.WithPrefix(context.Background(), "/api")
+	task.(router.Router).AddHandlerFuncRe(ctx, regexp.MustCompile(`^/hello/(?P<name>[a-z]+)/?$`), func(w http.ResponseWriter, r *http.Request) {
+		// TODO
+	}, http.MethodGet, http.MethodPut).(router.Route).
+		SetScope("read").
+		SetSummary("Hello").
+		SetRequest(hello{}).
+		SetResponse(http.StatusOK, hello{})
+	task.(router.Router).AddHandlerFuncRe(ctx, regexp.MustCompile(`^/(a|b)+$`), func(w http.ResponseWriter, r *http.Request) {
+		// TODO
+	})
+
+	// Routes which are not path templates are omitted
+	doc := task.(router.Router).OpenAPI("")
+	assert.Len(doc.Paths, 1)
+	item, exists := doc.Paths["/api/hello/{name}"]
+	if !assert.True(exists) {
+		return
+	}
+	assert.Len(*item, 2)
+
+	// The request body is only documented for methods with a body
+	get, put := (*item)["get"], (*item)["put"]
+	if assert.NotNil(get) && assert.NotNil(put) {
+		assert.Equal("Hello", get.Summary)
+		assert.Nil(get.RequestBody)
+		assert.NotNil(put.RequestBody)
+		assert.Len(get.Parameters, 1)
+		assert.Equal("name", get.Parameters[0].Name)
+		assert.Equal([]map[string][]string{{"bearer": {"read"}}}, get.Security)
+		assert.Contains(get.Responses, "200")
+		assert.Contains(get.Responses, "default")
+	}
+	assert.Contains(doc.Components.Schemas, "router_test.hello")
+
+	// The document for a prefix only has the routes with the prefix
+	task.(router.Router).AddHandlerFuncRe(router.WithPrefix(context.Background(), "/other"), regexp.MustCompile(`^/other$`), func(w http.ResponseWriter, r *http.Request) {
+		// TODO
+	})
+	assert.Len(task.(router.Router).OpenAPI("").Paths, 2)
+	assert.Len(task.(router.Router).OpenAPI("api").Paths, 1)
+	assert.Contains(task.(router.Router).OpenAPI("/other").Paths, "/other/other")
+	assert.Empty(task.(router.Router).OpenAPI("/missing").Paths)
+}
+
+func Test_router_013(t *testing.T) {
+	assert := assert.New(t)
+	task, err := router.Config{}.New()
+	assert.NoError(err)
+
+	// Routes are added while requests are matched
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			task.(router.Router).AddHandlerFunc(context.Background(), fmt.Sprint("/", i), func(w http.ResponseWriter, r *http.Request) {})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			task.(router.Router).Match("GET", "", fmt.Sprint("/", i))
+			task.(router.Router).Scopes()
+		}
+	}()
+	wg.Wait()
+
+	// A route which was not found is matched once it is added
+	_, code := task.(router.Router).Match("GET", "", "/hello")
+	assert.Equal(http.StatusNotFound, code)
+	task.(router.Router).AddHandlerFunc(context.Background(), "/hello", func(w http.ResponseWriter, r *http.Request) {})
+	_, code = task.(router.Router).Match("GET", "", "/hello")
+	assert.Equal(http.StatusOK, code)
+}
+
+func Test_router_014(t *testing.T) {
+	assert := assert.New(t)
+	task, err := router.Config{}.New()
+	assert.NoError(err)
+
+	// Serve the router endpoints, and a service with a prefix
+	task.(router.Router).AddServiceEndpoints("/router", task.(server.ServiceEndpoints))
+	task.(router.Router).AddHandlerFuncRe(router.WithPrefix(context.Background(), "/api"), regexp.MustCompile(`^/hello$`), func(w http.ResponseWriter, r *http.Request) {
+		// TODO
+	}, http.MethodGet)
+	server := httptest.NewServer(task.(http.Handler))
+	defer server.Close()
+
+	get := func(path string) (int, string) {
+		res, err := server.Client().Get(server.URL + path)
+		if !assert.NoError(err) {
+			return 0, ""
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	// The prefixes which have a document are listed
+	code, body := get("/router/openapi")
+	assert.Equal(http.StatusOK, code)
+	assert.Contains(body, `"prefix": "/api"`)
+	assert.Contains(body, `"prefix": "/router"`)
+
+	// The document for a prefix only has the routes with the prefix
+	code, body = get("/router/openapi/api.json")
+	assert.Equal(http.StatusOK, code)
+	assert.Contains(body, `"/api/hello"`)
+	assert.NotContains(body, `"/router/openapi.json"`)
+
+	// The document for all services has all the routes
+	code, body = get("/router/openapi.json")
+	assert.Equal(http.StatusOK, code)
+	assert.Contains(body, `"/api/hello"`)
+	assert.Contains(body, `"/router/openapi.json"`)
+
+	// There is no document for a prefix without routes
+	code, _ = get("/router/openapi/missing.json")
+	assert.Equal(http.StatusNotFound, code)
+}
+
+func Test_router_015(t *testing.T) {
+	assert := assert.New(t)
+	task, err := router.Config{}.New()
+	assert.NoError(err)
+
+	// Add a service, and a route which is not added by a service
+	a, b := &testService{value: "a"}, &testService{value: "b"}
+	replacer := task.(server.Replacer)
+	task.(router.Router).AddServiceEndpoints("/test", a)
+	task.(router.Router).AddHandlerFunc(router.WithPrefix(context.Background(), "/other"), "/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("other"))
+	})
+	server := httptest.NewServer(task.(http.Handler))
+	defer server.Close()
+
+	get := func(path string) (int, string) {
+		res, err := server.Client().Get(server.URL + path)
+		if !assert.NoError(err) {
+			return 0, ""
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+	code, body := get("/test/")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("a", body)
+
+	// Replace the service, which removes the routes of the previous service
+	assert.True(replacer.Replace(a, b))
+	code, body = get("/test/")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("b", body)
+	code, _ = get("/test/a")
+	assert.Equal(http.StatusNotFound, code)
+	code, _ = get("/test/b")
+	assert.Equal(http.StatusOK, code)
+	code, body = get("/other/")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("other", body)
+
+	// A service cannot be replaced by a task which is not a service
+	assert.False(replacer.Replace(b, new(testTask)))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// TEST SERVICE
+
+// testTask runs until cancelled
+type testTask struct{}
+
+// testService responds with its value, and has a route named by its value
+type testService struct {
+	testTask
+	value string
+}
+
+func (*testTask) Label() string {
+	return "test"
+}
+
+func (*testTask) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (service *testService) AddEndpoints(ctx context.Context, r server.Router) {
+	r.AddHandlerFuncRe(ctx, regexp.MustCompile(`^/$`), func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(service.value))
+	})
+	r.AddHandlerFuncRe(ctx, regexp.MustCompile(`^/`+service.value+`$`), func(w http.ResponseWriter, r *http.Request) {})
+}
@@ -5,6 +5,7 @@ import (
 
 	// Packages
 	server "github.com/mutablelogic/go-server"
+	provider "github.com/mutablelogic/go-server/pkg/provider"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -24,6 +25,12 @@ func (router *router) Label() string {
 
 // Run the router until the context is cancelled
 func (router *router) Run(ctx context.Context) error {
+	// Set the provider, which is used to reload the configuration
+	if provider := provider.Provider(ctx); provider != nil {
+		router.provider.Store(provider)
+	}
+
+	// Wait until cancelled
 	<-ctx.Done()
 	return nil
 }
@@ -0,0 +1,82 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	// Packages
+	trace "github.com/mutablelogic/go-server/pkg/trace"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// statusWriter records the status of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func newStatusWriter(w http.ResponseWriter) *statusWriter {
+	return &statusWriter{ResponseWriter: w}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush the response, if the underlying writer can be flushed
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Return the underlying writer, for http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Start a server span for a request, which is a child of the traceparent
+// in the request header
+func startRequestSpan(ctx context.Context, r *http.Request) (context.Context, *trace.Span) {
+	return trace.Start(trace.Extract(ctx, r.Header), r.Method, trace.KindServer,
+		"http.request.method", r.Method,
+		"url.path", r.URL.Path,
+		"server.address", r.Host,
+	)
+}
+
+// End a server span with the status of the response. Server errors are
+// recorded as errors on the span
+func endRequestSpan(span *trace.Span, w *statusWriter) {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	span.Set("http.response.status_code", status)
+	if status >= http.StatusInternalServerError {
+		span.SetError(errors.New(http.StatusText(status)))
+	}
+	span.End()
+}
@@ -0,0 +1,153 @@
+package tokenjar
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+
+	// Packages
+	scrypt "golang.org/x/crypto/scrypt"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// crypt encrypts and decrypts the persistent storage with AES-GCM, using a
+// key derived from a passphrase and a random salt
+type crypt struct {
+	key  string
+	salt []byte
+	aead cipher.AEAD
+}
+
+// encrypted is the format of the persistent storage when encrypted. The
+// salt is stored so the key can be derived from the passphrase again, and
+// the nonce is different for every write
+type encrypted struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	cryptVersion = 1
+	cryptSaltLen = 16
+	cryptKeyLen  = 32
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create encryption from a passphrase
+func newCrypt(key string) (*crypt, error) {
+	if key == "" {
+		return nil, ErrBadParameter.With("missing key")
+	}
+	return &crypt{key: key}, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Decrypt a value from the reader, returning ErrNotAuthorized if the key is
+// not correct or the data has been modified
+func (c *crypt) Read(r io.Reader, v any) error {
+	var file encrypted
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return err
+	} else if file.Version != cryptVersion {
+		return ErrBadParameter.Withf("unsupported version: %v", file.Version)
+	}
+
+	// Derive the key when the salt changes
+	if c.aead == nil || !bytes.Equal(c.salt, file.Salt) {
+		if err := c.derive(file.Salt); err != nil {
+			return err
+		}
+	}
+
+	// Decrypt the data
+	if len(file.Nonce) != c.aead.NonceSize() {
+		return ErrBadParameter.With("invalid nonce")
+	}
+	data, err := c.aead.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return ErrNotAuthorized.With("unable to decrypt, invalid key")
+	}
+
+	// Decode the value
+	return json.Unmarshal(data, v)
+}
+
+// Encrypt a value to the writer
+func (c *crypt) Write(w io.Writer, v any) error {
+	// Derive the key with a new salt if not yet set
+	if c.aead == nil {
+		salt := make([]byte, cryptSaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		} else if err := c.derive(salt); err != nil {
+			return err
+		}
+	}
+
+	// Encode the value
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	// Encrypt the data with a new nonce
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(encrypted{
+		Version: cryptVersion,
+		Salt:    c.salt,
+		Nonce:   nonce,
+		Data:    c.aead.Seal(nil, nonce, data, nil),
+	})
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Derive the encryption key from the passphrase and salt
+func (c *crypt) derive(salt []byte) error {
+	if len(salt) != cryptSaltLen {
+		return ErrBadParameter.With("invalid salt")
+	}
+	key, err := scrypt.Key([]byte(c.key), salt, scryptN, scryptR, scryptP, cryptKeyLen)
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	// Set the salt and cipher
+	c.salt = salt
+	c.aead = aead
+
+	// Return success
+	return nil
+}
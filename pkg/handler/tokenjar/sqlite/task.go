@@ -0,0 +1,34 @@
+package sqlite
+
+import (
+	"context"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	provider "github.com/mutablelogic/go-server/pkg/provider"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Check interfaces are satisfied
+var _ server.Collector = (*tokenjar)(nil)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+func (jar *tokenjar) Label() string {
+	// TODO
+	return defaultName
+}
+
+func (jar *tokenjar) Run(ctx context.Context) error {
+	// Wait until cancelled, then close the database
+	<-ctx.Done()
+	return jar.Close()
+}
+
+// Write the number of tokens in the jar
+func (jar *tokenjar) Collect(ctx context.Context, w server.MetricWriter) {
+	w.Gauge("tokenjar_tokens", "Number of tokens in the jar", float64(len(jar.Tokens())), "task", provider.Label(ctx))
+}
@@ -0,0 +1,292 @@
+/*
+implements a token jar that stores tokens in an sqlite database, so that
+every change is written to disk in a transaction. Token values are stored
+as hashes, so the tokens in the database cannot be used for authentication
+*/
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	// Package imports
+	_ "github.com/mattn/go-sqlite3"
+	server "github.com/mutablelogic/go-server"
+	auth "github.com/mutablelogic/go-server/pkg/handler/auth"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type tokenjar struct {
+	db *sql.DB
+}
+
+// scanner is implemented by sql.Row and sql.Rows
+type scanner interface {
+	Scan(...any) error
+}
+
+var _ auth.TokenJar = (*tokenjar)(nil)
+var _ server.Task = (*tokenjar)(nil)
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	defaultFilename = "tokenauth.sqlite"
+	defaultOptions  = "_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+)
+
+const (
+	sqlCreate = `
+		CREATE TABLE IF NOT EXISTS token (
+			hash        TEXT PRIMARY KEY,
+			name        TEXT NOT NULL,
+			expire_time INTEGER,
+			access_time INTEGER,
+			modify_time INTEGER,
+			modifier    TEXT NOT NULL DEFAULT '',
+			scopes      TEXT NOT NULL DEFAULT '[]'
+		);
+		CREATE INDEX IF NOT EXISTS token_name ON token (name);
+	`
+	sqlColumns = `hash, name, expire_time, access_time, modify_time, modifier, scopes`
+	sqlPurge   = `DELETE FROM token WHERE expire_time IS NOT NULL AND expire_time < ?`
+	sqlList    = `SELECT ` + sqlColumns + ` FROM token ORDER BY name`
+	sqlByHash  = `SELECT ` + sqlColumns + ` FROM token WHERE hash = ?`
+	sqlByName  = `SELECT ` + sqlColumns + ` FROM token WHERE name = ? LIMIT 1`
+	sqlAccess  = `UPDATE token SET access_time = ? WHERE hash = ?`
+	sqlInsert  = `INSERT INTO token (` + sqlColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	sqlUpdate  = `UPDATE token SET name = ?, expire_time = ?, access_time = ?, modify_time = ?, modifier = ?, scopes = ? WHERE hash IN (?, ?)`
+	sqlDelete  = `DELETE FROM token WHERE hash IN (?, ?)`
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a new tokenjar, with the specified path. If the path is empty,
+// the tokenjar will be in-memory only. Expired tokens are removed.
+func New(c Config) (*tokenjar, error) {
+	j := new(tokenjar)
+
+	// Set the database source
+	dsn := "file::memory:?" + defaultOptions
+	if c.DataPath != "" {
+		if stat, err := os.Stat(c.DataPath); err != nil {
+			return nil, err
+		} else if !stat.IsDir() {
+			return nil, ErrBadParameter.Withf("not a directory: %v", c.DataPath)
+		} else {
+			dsn = "file:" + filepath.Join(c.DataPath, defaultFilename) + "?" + defaultOptions
+		}
+	}
+
+	// Open the database, with a single connection so that writes are
+	// serialized and an in-memory database is not lost
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	j.db = db
+
+	// Create the schema and remove expired tokens
+	if err := j.do(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(sqlCreate); err != nil {
+			return err
+		}
+		_, err := tx.Exec(sqlPurge, time.Now().UnixNano())
+		return err
+	}); err != nil {
+		return nil, errors.Join(err, db.Close())
+	}
+
+	// Return success
+	return j, nil
+}
+
+// Close the database
+func (jar *tokenjar) Close() error {
+	return jar.db.Close()
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return all tokens, sorted by name. The value of each token is the
+// hash of the value
+func (jar *tokenjar) Tokens() []auth.Token {
+	var result []auth.Token
+
+	rows, err := jar.db.Query(sqlList)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if token, err := scanToken(rows); err == nil {
+			result = append(result, token)
+		}
+	}
+
+	// Return the result
+	return result
+}
+
+// Return a token from the jar.
+// The method should update the access time of the token.
+// If token is not found, return an empty token.
+func (jar *tokenjar) GetWithValue(value string) auth.Token {
+	var result auth.Token
+
+	hash := auth.HashValue(value)
+	if err := jar.do(func(tx *sql.Tx) error {
+		if token, err := scanToken(tx.QueryRow(sqlByHash, hash)); err != nil {
+			return err
+		} else {
+			token.Time = time.Now()
+			result = token
+		}
+		_, err := tx.Exec(sqlAccess, result.Time.UnixNano(), hash)
+		return err
+	}); err != nil {
+		// Return an empty token - not found
+		return auth.Token{}
+	}
+
+	// Return the token
+	return result
+}
+
+// Return a token from the jar by name.
+// The method does not update the access time of the token.
+// If token is not found, return an empty token.
+func (jar *tokenjar) GetWithName(name string) auth.Token {
+	if token, err := scanToken(jar.db.QueryRow(sqlByName, name)); err != nil {
+		return auth.Token{}
+	} else {
+		return token
+	}
+}
+
+// Put a token into the jar, assuming it does not yet exist.
+func (jar *tokenjar) Create(token auth.Token) error {
+	if token.Value == "" {
+		return ErrBadParameter
+	}
+
+	// Encode the scopes
+	scopes, err := json.Marshal(append([]string{}, token.Scope...))
+	if err != nil {
+		return err
+	}
+
+	// Insert the token if it does not already exist
+	hash := auth.HashValue(token.Value)
+	return jar.do(func(tx *sql.Tx) error {
+		if _, err := scanToken(tx.QueryRow(sqlByHash, hash)); err == nil {
+			return ErrDuplicateEntry
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		_, err := tx.Exec(sqlInsert, hash, token.Name, toTime(token.Expire), toTime(time.Now()), toTime(token.Modified), token.Modifier, string(scopes))
+		return err
+	})
+}
+
+// Update an existing token in the jar, assuming it already exists.
+func (jar *tokenjar) Update(token auth.Token) error {
+	if token.Value == "" {
+		return ErrBadParameter
+	}
+
+	// Encode the scopes
+	scopes, err := json.Marshal(append([]string{}, token.Scope...))
+	if err != nil {
+		return err
+	}
+
+	// Update the token, which is either keyed by value or by hash
+	return jar.do(func(tx *sql.Tx) error {
+		if result, err := tx.Exec(sqlUpdate, token.Name, toTime(token.Expire), toTime(time.Now()), toTime(token.Modified), token.Modifier, string(scopes), auth.HashValue(token.Value), token.Value); err != nil {
+			return err
+		} else if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// Remove a token from the jar, which is either keyed by value or by hash
+func (jar *tokenjar) Delete(key string) error {
+	return jar.do(func(tx *sql.Tx) error {
+		if result, err := tx.Exec(sqlDelete, auth.HashValue(key), key); err != nil {
+			return err
+		} else if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Run a function in a transaction, which is rolled back on error
+func (jar *tokenjar) do(fn func(*sql.Tx) error) error {
+	tx, err := jar.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+// Read a token from a row
+func scanToken(row scanner) (auth.Token, error) {
+	var token auth.Token
+	var expire, access, modify sql.NullInt64
+	var scopes string
+	if err := row.Scan(&token.Value, &token.Name, &expire, &access, &modify, &token.Modifier, &scopes); err != nil {
+		return auth.Token{}, err
+	} else if err := json.Unmarshal([]byte(scopes), &token.Scope); err != nil {
+		return auth.Token{}, err
+	}
+	token.Expire = fromTime(expire)
+	token.Time = fromTime(access)
+	token.Modified = fromTime(modify)
+	if len(token.Scope) == 0 {
+		token.Scope = nil
+	}
+	return token, nil
+}
+
+// Convert a time to a column value, which is NULL for a zero time
+func toTime(t time.Time) sql.NullInt64 {
+	if t.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+// Convert a column value to a time
+func fromTime(v sql.NullInt64) time.Time {
+	if !v.Valid {
+		return time.Time{}
+	}
+	return time.Unix(0, v.Int64)
+}
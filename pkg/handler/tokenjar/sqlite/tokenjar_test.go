@@ -0,0 +1,120 @@
+package sqlite_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/handler/auth"
+	"github.com/mutablelogic/go-server/pkg/handler/tokenjar/sqlite"
+	"github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+func Test_sqlite_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// Create an in-memory token jar
+	tokens, err := sqlite.New(sqlite.Config{})
+	assert.NoError(err)
+	assert.NotNil(tokens)
+
+	// Run the token jar
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(tokens.Run(ctx))
+	}()
+
+	// Add a token
+	token := auth.NewToken("test", 16, time.Hour, ScopeRead)
+	assert.NoError(tokens.Create(token))
+	assert.ErrorIs(tokens.Create(token), ErrDuplicateEntry)
+
+	// Get a token by value
+	token2 := tokens.GetWithValue(token.Value)
+	assert.Equal(token.Name, token2.Name)
+	assert.Equal(token.Scope, token2.Scope)
+	assert.True(token.Expire.Equal(token2.Expire))
+	assert.False(token2.Time.IsZero())
+
+	// The value is not returned, and cannot be used to get the token
+	assert.NotEqual(token.Value, token2.Value)
+	assert.True(tokens.GetWithValue(token2.Value).IsZero())
+
+	// Get a token by name
+	token3 := tokens.GetWithName(token.Name)
+	assert.Equal(token2.Value, token3.Value)
+	assert.True(tokens.GetWithName("other").IsZero())
+
+	// Update a token by value and by the returned value
+	token.Scope = []string{ScopeRead, ScopeWrite}
+	assert.NoError(tokens.Update(token))
+	assert.Equal([]string{ScopeRead, ScopeWrite}, tokens.GetWithName(token.Name).Scope)
+	token3.Name = "test2"
+	token3.Scope = nil
+	assert.NoError(tokens.Update(token3))
+	assert.Len(tokens.Tokens(), 1)
+	assert.Nil(tokens.GetWithName("test2").Scope)
+	assert.ErrorIs(tokens.Update(auth.NewToken("other", 16, 0)), ErrNotFound)
+
+	// Remove a token
+	assert.NoError(tokens.Delete(token3.Value))
+	assert.ErrorIs(tokens.Delete(token.Value), ErrNotFound)
+	assert.Empty(tokens.Tokens())
+
+	// Cancel the context and wait
+	cancel()
+	wg.Wait()
+}
+
+func Test_sqlite_002(t *testing.T) {
+	assert := assert.New(t)
+	path := t.TempDir()
+
+	// Create a persistent token jar, and add tokens
+	tokens, err := sqlite.New(sqlite.Config{DataPath: path})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	token := auth.NewToken("test", 16, 0, ScopeRead)
+	assert.NoError(tokens.Create(token))
+	expired := auth.NewToken("expired", 16, time.Millisecond)
+	assert.NoError(tokens.Create(expired))
+	assert.NoError(tokens.Close())
+	time.Sleep(10 * time.Millisecond)
+
+	// The token value does not appear in the database
+	files, err := filepath.Glob(filepath.Join(path, "*"))
+	assert.NoError(err)
+	assert.NotEmpty(files)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		assert.NoError(err)
+		assert.False(strings.Contains(string(data), token.Value), file)
+	}
+
+	// Re-open the token jar, the expired token is removed
+	tokens, err = sqlite.New(sqlite.Config{DataPath: path})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	defer tokens.Close()
+	assert.Equal("test", tokens.GetWithValue(token.Value).Name)
+	assert.True(tokens.GetWithName("expired").IsZero())
+	assert.Len(tokens.Tokens(), 1)
+}
@@ -6,9 +6,16 @@ import (
 	"time"
 
 	// Packages
+	server "github.com/mutablelogic/go-server"
 	provider "github.com/mutablelogic/go-server/pkg/provider"
 )
 
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Check interfaces are satisfied
+var _ server.Collector = (*tokenjar)(nil)
+
 ////////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
@@ -42,3 +49,8 @@ func (jar *tokenjar) Run(ctx context.Context) error {
 		}
 	}
 }
+
+// Write the number of tokens in the jar
+func (jar *tokenjar) Collect(ctx context.Context, w server.MetricWriter) {
+	w.Gauge("tokenjar_tokens", "Number of tokens in the jar", float64(len(jar.Tokens())), "task", provider.Label(ctx))
+}
@@ -1,6 +1,8 @@
 /*
 implements a token jar that stores tokens into memory, and potentially a file
-on the file system
+on the file system. When a key is set, the file is encrypted and token values
+are stored as hashes, so the tokens in the file cannot be used for
+authentication
 */
 package tokenjar
 
@@ -33,6 +35,13 @@ type tokenjar struct {
 	// The filename to persist the tokens to
 	filename string
 
+	// Encryption of the persistent storage, or nil if the storage is
+	// plaintext
+	crypt *crypt
+
+	// A plaintext file to remove once the tokens are written encrypted
+	plaintext string
+
 	// Tokens keyed by the token value
 	jar map[string]*auth.Token
 
@@ -47,25 +56,40 @@ var _ server.Task = (*tokenjar)(nil)
 // GLOBALS
 
 const (
-	defaultCap           = 20
-	defaultFilename      = "tokenauth.json"
-	defaultWriteInterval = time.Minute
+	defaultCap               = 20
+	defaultFilename          = "tokenauth.json"
+	defaultEncryptedFilename = "tokenauth.enc"
+	defaultWriteInterval     = time.Minute
+	defaultFileMode          = os.FileMode(0600)
 )
 
 ////////////////////////////////////////////////////////////////////////////////
 // LIFECYCLE
 
 // Create a new tokenjar, with the specified path. If the path is empty,
-// the tokenjar will be in-memory only.
+// the tokenjar will be in-memory only. If a key is set, the tokens are
+// encrypted on disk, and a plaintext file is replaced by an encrypted one
 func New(c Config) (*tokenjar, error) {
 	j := new(tokenjar)
 
+	// Set encryption
+	if c.Key != "" {
+		if crypt, err := newCrypt(c.Key); err != nil {
+			return nil, err
+		} else {
+			j.crypt = crypt
+		}
+	}
+
 	// Set filepath for persistent storage
 	if c.DataPath != "" {
 		if stat, err := os.Stat(c.DataPath); err != nil {
 			return nil, err
 		} else if !stat.IsDir() {
 			return nil, ErrBadParameter.Withf("not a directory: %v", c.DataPath)
+		} else if j.crypt != nil {
+			j.filename = filepath.Join(c.DataPath, defaultEncryptedFilename)
+			j.plaintext = filepath.Join(c.DataPath, defaultFilename)
 		} else {
 			j.filename = filepath.Join(c.DataPath, defaultFilename)
 		}
@@ -88,6 +112,25 @@ func New(c Config) (*tokenjar, error) {
 		return nil, err
 	} else {
 		tokens = tokens_
+		j.plaintext = ""
+	}
+
+	// Read the tokens from a plaintext file when there is no encrypted
+	// file, which is replaced by the encrypted file on the next write
+	if j.plaintext == "" {
+		// Do nothing
+	} else if _, err := os.Stat(j.plaintext); os.IsNotExist(err) {
+		j.plaintext = ""
+	} else if err != nil {
+		return nil, err
+	} else if tokens_, err := readPlaintext(j.plaintext); err != nil {
+		return nil, err
+	} else {
+		for _, token := range tokens_ {
+			token.Value = auth.HashValue(token.Value)
+		}
+		tokens = tokens_
+		j.modified = true
 	}
 
 	// Create the token jar
@@ -161,7 +204,7 @@ func (jar *tokenjar) GetWithValue(key string) auth.Token {
 	jar.Lock()
 	defer jar.Unlock()
 
-	if token, ok := jar.jar[key]; ok {
+	if token, ok := jar.jar[jar.key(key)]; ok {
 		token.Time = time.Now()
 		jar.modified = true
 
@@ -198,6 +241,7 @@ func (jar *tokenjar) Create(token auth.Token) error {
 	if token.Value == "" {
 		return ErrBadParameter
 	}
+	token.Value = jar.key(token.Value)
 	if _, ok := jar.jar[token.Value]; ok {
 		return ErrDuplicateEntry
 	}
@@ -220,7 +264,7 @@ func (jar *tokenjar) Update(token auth.Token) error {
 	if token.Value == "" {
 		return ErrBadParameter
 	}
-	dest, ok := jar.jar[token.Value]
+	dest, ok := jar.jar[jar.find(token.Value)]
 	if !ok {
 		return ErrNotFound
 	}
@@ -230,6 +274,8 @@ func (jar *tokenjar) Update(token auth.Token) error {
 	dest.Time = time.Now()
 	dest.Expire = token.Expire
 	dest.Scope = append([]string{}, token.Scope...)
+	dest.Modified = token.Modified
+	dest.Modifier = token.Modifier
 	jar.modified = true
 
 	// Return success
@@ -242,7 +288,7 @@ func (jar *tokenjar) Delete(key string) error {
 	defer jar.Unlock()
 
 	// Check if the token already exists
-	if _, ok := jar.jar[key]; !ok {
+	if key = jar.find(key); key == "" {
 		return ErrNotFound
 	} else {
 		delete(jar.jar, key)
@@ -253,7 +299,9 @@ func (jar *tokenjar) Delete(key string) error {
 	return nil
 }
 
-// Write the tokens to persistent storage
+// Write the tokens to persistent storage. The tokens are written to a
+// temporary file which replaces the existing file, so a failed write does
+// not lose the existing tokens
 func (jar *tokenjar) Write() error {
 	jar.Lock()
 	defer jar.Unlock()
@@ -264,34 +312,51 @@ func (jar *tokenjar) Write() error {
 	}
 
 	// Open the file for writing
-	w, err := os.Create(jar.filename)
+	tmp := jar.filename + ".tmp"
+	w, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaultFileMode)
 	if err != nil {
 		return err
 	}
-	defer w.Close()
 
-	// Write the tokens, unset modified flag
+	// Write the tokens
 	tokens := make([]*auth.Token, 0, len(jar.jar))
 	for _, token := range jar.jar {
 		writable := *token
 		writable.SetWrite()
 		tokens = append(tokens, &writable)
 	}
-	if err := json.NewEncoder(w).Encode(tokens); err != nil {
-		return errors.Join(err, os.Remove(jar.filename))
+	if jar.crypt != nil {
+		err = jar.crypt.Write(w, tokens)
 	} else {
-		jar.modified = false
+		err = json.NewEncoder(w).Encode(tokens)
+	}
+	if err := errors.Join(err, w.Close()); err != nil {
+		return errors.Join(err, os.Remove(tmp))
+	} else if err := os.Rename(tmp, jar.filename); err != nil {
+		return errors.Join(err, os.Remove(tmp))
+	}
+
+	// Unset modified flag, and remove any plaintext file which has been
+	// replaced
+	jar.modified = false
+	if jar.plaintext != "" {
+		if err := os.Remove(jar.plaintext); err != nil {
+			return err
+		}
+		jar.plaintext = ""
 	}
 
 	// Return success
 	return nil
 }
 
-// Run the token jar
+// Read the tokens from persistent storage
 func (jar *tokenjar) Read() ([]*auth.Token, error) {
 	// NOP if there is no filename
 	if jar.filename == "" {
 		return nil, nil
+	} else if jar.crypt == nil {
+		return readPlaintext(jar.filename)
 	}
 
 	// Open the file for reading
@@ -301,6 +366,48 @@ func (jar *tokenjar) Read() ([]*auth.Token, error) {
 	}
 	defer r.Close()
 
+	// Read the tokens
+	var tokens []*auth.Token
+	if err := jar.crypt.Read(r, &tokens); err != nil {
+		return nil, err
+	}
+
+	// Return success
+	return tokens, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the key for a token value, which is a hash of the value when the
+// jar is encrypted
+func (jar *tokenjar) key(value string) string {
+	if jar.crypt != nil {
+		return auth.HashValue(value)
+	}
+	return value
+}
+
+// Return the key for a token value or a key returned by the jar, or an
+// empty string if the token is not found
+func (jar *tokenjar) find(value string) string {
+	if _, ok := jar.jar[jar.key(value)]; ok {
+		return jar.key(value)
+	} else if _, ok := jar.jar[value]; ok {
+		return value
+	}
+	return ""
+}
+
+// Read tokens from a plaintext file
+func readPlaintext(filename string) ([]*auth.Token, error) {
+	// Open the file for reading
+	r, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
 	// Read the tokens
 	var tokens []*auth.Token
 	if err := json.NewDecoder(r).Decode(&tokens); err != nil {
@@ -2,6 +2,8 @@ package tokenjar_test
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 
@@ -9,6 +11,9 @@ import (
 	"github.com/mutablelogic/go-server/pkg/handler/auth"
 	"github.com/mutablelogic/go-server/pkg/handler/tokenjar"
 	"github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
 )
 
 const (
@@ -74,3 +79,86 @@ func Test_tokenjar_002(t *testing.T) {
 	cancel()
 	wg.Wait()
 }
+
+func Test_tokenjar_003(t *testing.T) {
+	assert := assert.New(t)
+	path := t.TempDir()
+
+	// Create an encrypted token jar
+	tokens, err := tokenjar.New(tokenjar.Config{
+		DataPath: path,
+		Key:      "secret",
+	})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Add a token, and write it to disk
+	token := auth.NewToken("test", 16, 0, ScopeRead)
+	assert.NoError(tokens.Create(token))
+	assert.NoError(tokens.Write())
+
+	// The value is not returned, and cannot be used to get the token
+	token2 := tokens.GetWithName("test")
+	assert.NotEqual(token.Value, token2.Value)
+	assert.Equal("test", tokens.GetWithValue(token.Value).Name)
+	assert.True(tokens.GetWithValue(token2.Value).IsZero())
+
+	// The file does not contain the token name or value
+	data, err := os.ReadFile(filepath.Join(path, "tokenauth.enc"))
+	assert.NoError(err)
+	assert.NotContains(string(data), token.Value)
+	assert.NotContains(string(data), token2.Value)
+	assert.NotContains(string(data), ScopeRead)
+
+	// Re-open the token jar
+	tokens, err = tokenjar.New(tokenjar.Config{
+		DataPath: path,
+		Key:      "secret",
+	})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.Equal("test", tokens.GetWithValue(token.Value).Name)
+
+	// Remove the token with the returned value
+	assert.NoError(tokens.Delete(token2.Value))
+	assert.True(tokens.GetWithName("test").IsZero())
+
+	// Re-open the token jar with the wrong key
+	_, err = tokenjar.New(tokenjar.Config{
+		DataPath: path,
+		Key:      "other",
+	})
+	assert.ErrorIs(err, ErrNotAuthorized)
+}
+
+func Test_tokenjar_004(t *testing.T) {
+	assert := assert.New(t)
+	path := t.TempDir()
+
+	// Create a plaintext token jar
+	tokens, err := tokenjar.New(tokenjar.Config{
+		DataPath: path,
+	})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	token := auth.NewToken("test", 16, 0, ScopeRead)
+	assert.NoError(tokens.Create(token))
+	assert.NoError(tokens.Write())
+
+	// Re-open the token jar with a key, which replaces the plaintext file
+	tokens, err = tokenjar.New(tokenjar.Config{
+		DataPath: path,
+		Key:      "secret",
+	})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.Equal("test", tokens.GetWithValue(token.Value).Name)
+	assert.FileExists(filepath.Join(path, "tokenauth.json"))
+	assert.NoError(tokens.Write())
+	assert.NoFileExists(filepath.Join(path, "tokenauth.json"))
+	assert.FileExists(filepath.Join(path, "tokenauth.enc"))
+}
@@ -0,0 +1,48 @@
+package tracer
+
+import (
+	"time"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+)
+
+////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type Config struct {
+	Endpoint string        `hcl:"endpoint" description:"OTLP/HTTP endpoint which receives spans, for example http://localhost:4318/v1/traces"`
+	Path     string        `hcl:"path" description:"File which receives spans, one JSON object per line"`
+	Service  string        `hcl:"service" description:"Service name for spans (default go-server)"`
+	Interval time.Duration `hcl:"interval" description:"Interval between sending spans to the endpoint (default 5s)"`
+}
+
+// Check interfaces are satisfied
+var _ server.Plugin = Config{}
+
+////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	defaultName     = "tracer"
+	defaultService  = "go-server"
+	defaultInterval = 5 * time.Second
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Name returns the name of the service
+func (Config) Name() string {
+	return defaultName
+}
+
+// Description returns the description of the service
+func (Config) Description() string {
+	return "exports request spans to an OTLP/HTTP endpoint or a file"
+}
+
+// Create a new task from the configuration
+func (c Config) New() (server.Task, error) {
+	return New(c)
+}
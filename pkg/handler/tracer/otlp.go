@@ -0,0 +1,181 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	// Packages
+	trace "github.com/mutablelogic/go-server/pkg/trace"
+	version "github.com/mutablelogic/go-server/pkg/version"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// otlp sends spans to an OTLP/HTTP endpoint with JSON encoding
+type otlp struct {
+	endpoint *url.URL
+	service  string
+	client   *http.Client
+}
+
+// The request body, which is the JSON encoding of the OTLP trace
+// protocol buffers
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttr `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceId           string      `json:"traceId"`
+	SpanId            string      `json:"spanId"`
+	ParentSpanId      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []otlpAttr  `json:"attributes,omitempty"`
+	Status            *otlpStatus `json:"status,omitempty"`
+}
+
+type otlpAttr struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	otlpTimeout     = 10 * time.Second
+	otlpStatusError = 2
+)
+
+var (
+	otlpKind = map[trace.Kind]int{
+		trace.KindInternal: 1,
+		trace.KindServer:   2,
+		trace.KindClient:   3,
+	}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+func newOTLP(endpoint *url.URL, service string) *otlp {
+	return &otlp{
+		endpoint: endpoint,
+		service:  service,
+		client:   &http.Client{Timeout: otlpTimeout},
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Send spans to the endpoint
+func (o *otlp) Send(spans []trace.Span) error {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: version.GitSource}}
+	for _, span := range spans {
+		scope.Spans = append(scope.Spans, otlpSpanFrom(span))
+	}
+	body, err := json.Marshal(otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource:   otlpResource{Attributes: []otlpAttr{newAttr("service.name", o.service)}},
+			ScopeSpans: []otlpScopeSpans{scope},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Send the request
+	resp, err := o.client.Post(o.endpoint.String(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return ErrUnexpectedResponse.Withf("%s: %s", o.endpoint, resp.Status)
+	}
+
+	// Return success
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return a span in OTLP encoding, with the attributes sorted by key
+func otlpSpanFrom(span trace.Span) otlpSpan {
+	result := otlpSpan{
+		TraceId:           span.TraceId,
+		SpanId:            span.SpanId,
+		ParentSpanId:      span.ParentId,
+		Name:              span.Name,
+		Kind:              otlpKind[span.Kind],
+		StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+	}
+	keys := make([]string, 0, len(span.Attributes))
+	for key := range span.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result.Attributes = append(result.Attributes, newAttr(key, span.Attributes[key]))
+	}
+	if span.Error != "" {
+		result.Status = &otlpStatus{Code: otlpStatusError, Message: span.Error}
+	}
+	return result
+}
+
+// Return an attribute, where integers are encoded as strings
+func newAttr(key string, value any) otlpAttr {
+	switch v := value.(type) {
+	case string:
+		return otlpAttr{key, map[string]any{"stringValue": v}}
+	case bool:
+		return otlpAttr{key, map[string]any{"boolValue": v}}
+	case int:
+		return otlpAttr{key, map[string]any{"intValue": strconv.Itoa(v)}}
+	case int64:
+		return otlpAttr{key, map[string]any{"intValue": strconv.FormatInt(v, 10)}}
+	case float64:
+		return otlpAttr{key, map[string]any{"doubleValue": v}}
+	default:
+		return otlpAttr{key, map[string]any{"stringValue": fmt.Sprint(v)}}
+	}
+}
@@ -0,0 +1,37 @@
+package tracer
+
+import (
+	"context"
+	"time"
+
+	// Packages
+	provider "github.com/mutablelogic/go-server/pkg/provider"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return the label for the task
+func (t *tracer) Label() string {
+	// TODO
+	return defaultName
+}
+
+// Run the task until the context is cancelled, sending spans to the
+// endpoint periodically
+func (t *tracer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return t.Close()
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				if logger := provider.Logger(ctx); logger != nil {
+					logger.Print(ctx, err)
+				}
+			}
+		}
+	}
+}
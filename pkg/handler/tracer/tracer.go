@@ -0,0 +1,151 @@
+/*
+implements an exporter for spans, which writes each span to a file as a line
+of JSON, or sends batches of spans to an OTLP/HTTP endpoint
+*/
+package tracer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	trace "github.com/mutablelogic/go-server/pkg/trace"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type tracer struct {
+	sync.Mutex
+
+	// The service name
+	service string
+
+	// The file which receives spans, or nil
+	file *os.File
+	enc  *json.Encoder
+
+	// The endpoint which receives spans, or nil, and the spans which have
+	// not yet been sent
+	endpoint *otlp
+	interval time.Duration
+	queue    []trace.Span
+}
+
+// Check interfaces are satisfied
+var _ server.Task = (*tracer)(nil)
+var _ trace.Exporter = (*tracer)(nil)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	defaultFileMode = os.FileMode(0640)
+	maxQueue        = 4096
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create a new tracer from the configuration
+func New(c Config) (*tracer, error) {
+	t := new(tracer)
+	t.service = c.Service
+	if t.service == "" {
+		t.service = defaultService
+	}
+	t.interval = c.Interval
+	if t.interval == 0 {
+		t.interval = defaultInterval
+	} else if t.interval < 0 {
+		return nil, ErrBadParameter.With("interval cannot be negative")
+	}
+
+	// Check for an endpoint or a path
+	if c.Endpoint == "" && c.Path == "" {
+		return nil, ErrBadParameter.With("endpoint or path is required")
+	}
+
+	// Set the endpoint
+	if c.Endpoint != "" {
+		if endpoint, err := url.Parse(c.Endpoint); err != nil {
+			return nil, err
+		} else if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+			return nil, ErrBadParameter.Withf("endpoint: %q", c.Endpoint)
+		} else {
+			t.endpoint = newOTLP(endpoint, t.service)
+		}
+	}
+
+	// Open the file for appending
+	if c.Path != "" {
+		if file, err := os.OpenFile(c.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, defaultFileMode); err != nil {
+			return nil, err
+		} else {
+			t.file = file
+			t.enc = json.NewEncoder(file)
+		}
+	}
+
+	// Return success
+	return t, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Export a span, which is written to the file and queued for the endpoint.
+// When the queue is full, the oldest spans are dropped
+func (t *tracer) Export(span trace.Span) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.enc != nil {
+		t.enc.Encode(span)
+	}
+	if t.endpoint != nil {
+		if len(t.queue) >= maxQueue {
+			t.queue = t.queue[1:]
+		}
+		t.queue = append(t.queue, span)
+	}
+}
+
+// Send the queued spans to the endpoint
+func (t *tracer) Flush() error {
+	t.Lock()
+	spans := t.queue
+	t.queue = nil
+	t.Unlock()
+
+	if t.endpoint == nil || len(spans) == 0 {
+		return nil
+	}
+	return t.endpoint.Send(spans)
+}
+
+// Flush the queued spans, and close the file
+func (t *tracer) Close() error {
+	var result error
+	if err := t.Flush(); err != nil {
+		result = errors.Join(result, err)
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	if t.file != nil {
+		if err := t.file.Close(); err != nil {
+			result = errors.Join(result, err)
+		}
+		t.file, t.enc = nil, nil
+	}
+	return result
+}
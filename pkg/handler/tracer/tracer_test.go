@@ -0,0 +1,178 @@
+package tracer_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	router "github.com/mutablelogic/go-server/pkg/handler/router"
+	tracer "github.com/mutablelogic/go-server/pkg/handler/tracer"
+	trace "github.com/mutablelogic/go-server/pkg/trace"
+	assert "github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+func Test_tracer_001(t *testing.T) {
+	assert := assert.New(t)
+	assert.Implements((*server.Plugin)(nil), tracer.Config{})
+
+	// Invalid configuration
+	_, err := tracer.New(tracer.Config{})
+	assert.ErrorIs(err, ErrBadParameter)
+	_, err = tracer.New(tracer.Config{Endpoint: "ftp://localhost/"})
+	assert.ErrorIs(err, ErrBadParameter)
+	_, err = tracer.New(tracer.Config{Path: "/tmp", Interval: -1})
+	assert.ErrorIs(err, ErrBadParameter)
+}
+
+func Test_tracer_002(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "spans.json")
+
+	// Write spans to a file
+	exporter, err := tracer.New(tracer.Config{Path: path})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Add a service to a router which traces requests, with middleware
+	r, err := router.Config{Tracer: exporter}.New()
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	r.(router.Router).AddServiceEndpoints("test", testService{}, testMiddleware{})
+
+	// Make a request which continues a trace
+	req := httptest.NewRequest(http.MethodGet, "/test/", nil)
+	req.Header.Set(trace.HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp := httptest.NewRecorder()
+	r.(http.Handler).ServeHTTP(resp, req)
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.NoError(exporter.Close())
+
+	// Spans are written as they end, so the request span is last
+	spans := readSpans(t, path)
+	if !assert.Len(spans, 4) {
+		t.SkipNow()
+	}
+	outbound, handler, middleware, request := spans[0], spans[1], spans[2], spans[3]
+	assert.Equal("GET test", request.Name)
+	assert.Equal(trace.KindServer, request.Kind)
+	assert.Equal("00f067aa0ba902b7", request.ParentId)
+	assert.Equal(float64(http.StatusOK), request.Attributes["http.response.status_code"])
+	assert.Equal("middleware test-middleware", middleware.Name)
+	assert.Equal(request.SpanId, middleware.ParentId)
+	assert.Equal("handler test", handler.Name)
+	assert.Equal(middleware.SpanId, handler.ParentId)
+	assert.Equal("outbound", outbound.Name)
+	assert.Equal(trace.KindClient, outbound.Kind)
+	assert.Equal(handler.SpanId, outbound.ParentId)
+	for _, span := range spans {
+		assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", span.TraceId)
+	}
+}
+
+func Test_tracer_003(t *testing.T) {
+	assert := assert.New(t)
+
+	// Receive spans on an endpoint
+	var body map[string]any
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("application/json", r.Header.Get("Content-Type"))
+		data, err := io.ReadAll(r.Body)
+		assert.NoError(err)
+		assert.NoError(json.Unmarshal(data, &body))
+	}))
+	defer endpoint.Close()
+	exporter, err := tracer.New(tracer.Config{Endpoint: endpoint.URL + "/v1/traces", Service: "test"})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Send a span with an error
+	ctx := trace.WithExporter(context.Background(), exporter)
+	trace.Do(ctx, "outbound", func() error { return ErrNotFound }, "count", 1)
+	assert.NoError(exporter.Flush())
+
+	// Check the request body
+	resourceSpans := body["resourceSpans"].([]any)[0].(map[string]any)
+	assert.Equal(`[map[key:service.name value:map[stringValue:test]]]`, fmt.Sprint(resourceSpans["resource"].(map[string]any)["attributes"]))
+	span := resourceSpans["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	assert.Equal("outbound", span["name"])
+	assert.Equal(float64(3), span["kind"])
+	assert.Regexp(regexp.MustCompile(`^[0-9a-f]{32}$`), span["traceId"])
+	assert.Regexp(regexp.MustCompile(`^[0-9]+$`), span["startTimeUnixNano"])
+	assert.Equal(`[map[key:count value:map[intValue:1]]]`, fmt.Sprint(span["attributes"]))
+	assert.Equal(float64(2), span["status"].(map[string]any)["code"])
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func readSpans(t *testing.T, path string) []trace.Span {
+	t.Helper()
+	r, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var result []trace.Span
+	for scanner := bufio.NewScanner(r); scanner.Scan(); {
+		var span trace.Span
+		if err := json.Unmarshal(scanner.Bytes(), &span); err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, span)
+	}
+	return result
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// SERVICE
+
+// testService has a handler which makes an outbound call
+type testService struct{}
+
+func (testService) Label() string {
+	return "test"
+}
+
+func (testService) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (service testService) AddEndpoints(ctx context.Context, r server.Router) {
+	r.AddHandlerFuncRe(ctx, regexp.MustCompile(`^/$`), func(w http.ResponseWriter, r *http.Request) {
+		trace.Do(r.Context(), "outbound", func() error { return nil })
+		w.WriteHeader(http.StatusOK)
+	}, http.MethodGet)
+}
+
+// testMiddleware passes requests to the next handler
+type testMiddleware struct{}
+
+func (testMiddleware) Label() string {
+	return "test-middleware"
+}
+
+func (testMiddleware) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (testMiddleware) Wrap(ctx context.Context, next http.HandlerFunc) http.HandlerFunc {
+	return next
+}
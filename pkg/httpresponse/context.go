@@ -0,0 +1,39 @@
+package httpresponse
+
+import (
+	"context"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type ResponseContextKey int
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	_ ResponseContextKey = iota
+	contextDrain
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// WithDrain returns a context with a channel which is closed when the server
+// begins to drain connections on shutdown
+func WithDrain(ctx context.Context, ch <-chan struct{}) context.Context {
+	return context.WithValue(ctx, contextDrain, ch)
+}
+
+// Drain returns a channel which is closed when the server begins to drain
+// connections on shutdown, or nil if not defined. Long-running responses,
+// such as a text stream, should complete when the channel is closed so
+// that the server can shutdown without closing the connection
+func Drain(ctx context.Context) <-chan struct{} {
+	if value, ok := ctx.Value(contextDrain).(<-chan struct{}); ok {
+		return value
+	} else {
+		return nil
+	}
+}
@@ -2,6 +2,7 @@ package httpresponse
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -15,13 +16,15 @@ import (
 
 // TextStream implements a stream of text events
 type TextStream struct {
-	wg  sync.WaitGroup
-	w   io.Writer
-	ch  chan *textevent
-	err error
+	wg   sync.WaitGroup
+	w    io.Writer
+	ch   chan *textevent
+	done chan struct{}
+	err  error
 }
 
 type textevent struct {
+	id   string
 	name string
 	data []any
 }
@@ -35,6 +38,7 @@ const (
 
 var (
 	strPing    = "ping"
+	strId      = []byte("id: ")
 	strEvent   = []byte("event: ")
 	strData    = []byte("data: ")
 	strNewline = []byte("\n")
@@ -46,6 +50,13 @@ var (
 // Create a new text stream with mimetype text/event-stream
 // Additional header tuples can be provided as a series of key-value pairs
 func NewTextStream(w http.ResponseWriter, tuples ...string) *TextStream {
+	return NewTextStreamWithContext(context.Background(), w, tuples...)
+}
+
+// Create a new text stream which ends when the context is cancelled, or the
+// server begins to drain connections on shutdown, so that clients reconnect
+// to another server. The Done channel is closed when the stream ends
+func NewTextStreamWithContext(ctx context.Context, w http.ResponseWriter, tuples ...string) *TextStream {
 	// Check parameters
 	if w == nil {
 		return nil
@@ -58,6 +69,7 @@ func NewTextStream(w http.ResponseWriter, tuples ...string) *TextStream {
 	self := new(TextStream)
 	self.w = w
 	self.ch = make(chan *textevent)
+	self.done = make(chan struct{})
 
 	// Set the default content type
 	w.Header().Set(ContentTypeKey, ContentTypeTextStream)
@@ -74,6 +86,7 @@ func NewTextStream(w http.ResponseWriter, tuples ...string) *TextStream {
 	self.wg.Add(1)
 	go func() {
 		defer self.wg.Done()
+		defer close(self.done)
 
 		// Create a ticker for ping messages
 		ticker := time.NewTimer(100 * time.Millisecond)
@@ -89,8 +102,12 @@ func NewTextStream(w http.ResponseWriter, tuples ...string) *TextStream {
 				self.emit(evt)
 				ticker.Reset(defaultKeepAlive)
 			case <-ticker.C:
-				self.err = errors.Join(self.err, self.emit(&textevent{strPing, nil}))
+				self.err = errors.Join(self.err, self.emit(&textevent{name: strPing}))
 				ticker.Reset(defaultKeepAlive)
+			case <-ctx.Done():
+				return
+			case <-Drain(ctx):
+				return
 			}
 		}
 	}()
@@ -117,17 +134,42 @@ func (s *TextStream) Close() error {
 // Write a text event to the stream, and one or more optional data objects
 // which are encoded as JSON
 func (s *TextStream) Write(name string, data ...any) {
-	s.ch <- &textevent{name, data}
+	s.send(&textevent{name: name, data: data})
+}
+
+// Write a text event to the stream with an identifier, which a client
+// sends in the Last-Event-ID header when it reconnects
+func (s *TextStream) WriteWithId(id, name string, data ...any) {
+	s.send(&textevent{id: id, name: name, data: data})
+}
+
+// Done returns a channel which is closed when the stream ends, after which
+// events are no longer written
+func (s *TextStream) Done() <-chan struct{} {
+	return s.done
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// send an event to be written, unless the stream has ended
+func (s *TextStream) send(e *textevent) {
+	select {
+	case s.ch <- e:
+	case <-s.done:
+	}
+}
+
 // emit an event to the stream
 func (s *TextStream) emit(e *textevent) error {
 	var result error
 
-	// Write the event to the stream
+	// Write the identifier and event to the stream
+	if e.id != "" {
+		if err := s.write(strId, []byte(e.id), strNewline); err != nil {
+			return err
+		}
+	}
 	if e.name != "" {
 		if err := s.write(strEvent, []byte(e.name), strNewline); err != nil {
 			return err
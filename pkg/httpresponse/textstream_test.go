@@ -1,6 +1,7 @@
 package httpresponse_test
 
 import (
+	"context"
 	"net/http/httptest"
 	"testing"
 	"time"
@@ -76,3 +77,49 @@ func Test_textstream_001(t *testing.T) {
 	})
 
 }
+
+func Test_textstream_002(t *testing.T) {
+	assert := assert.New(t)
+	resp := &streamRecorder{httptest.NewRecorder()}
+	ts := httpresponse.NewTextStream(resp)
+	assert.NotNil(ts)
+
+	// An event with an identifier
+	ts.WriteWithId("1", "foo", "bar")
+	assert.NoError(ts.Close())
+	assert.Equal(200, resp.Code)
+	assert.Contains(resp.Body.String(), "id: 1\n"+"event: foo\n"+"data: \"bar\"\n\n")
+}
+
+func Test_textstream_003(t *testing.T) {
+	assert := assert.New(t)
+	resp := &streamRecorder{httptest.NewRecorder()}
+	drain := make(chan struct{})
+	ts := httpresponse.NewTextStreamWithContext(httpresponse.WithDrain(context.Background(), drain), resp)
+	assert.NotNil(ts)
+	ts.Write("foo")
+
+	// The stream ends when draining begins, and events are no longer written
+	close(drain)
+	select {
+	case <-ts.Done():
+	case <-time.After(time.Second):
+		assert.Fail("stream did not end")
+	}
+	ts.Write("bar")
+	assert.NoError(ts.Close())
+	assert.Contains(resp.Body.String(), "event: foo\n\n")
+	assert.NotContains(resp.Body.String(), "bar")
+}
+
+// streamRecorder does not record informational responses, as a server
+// writes them before the response
+type streamRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (w *streamRecorder) WriteHeader(code int) {
+	if code >= 200 {
+		w.ResponseRecorder.WriteHeader(code)
+	}
+}
@@ -0,0 +1,240 @@
+package httpserver
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// certs selects a certificate from a certificate manager for each TLS
+// handshake, by the server name requested by the client. Certificates
+// are reloaded when certificates are added to or removed from the
+// certificate manager, so certificates can be rotated without restarting
+// the server
+type certs struct {
+	sync.RWMutex
+	manager  CertManager
+	serial   string           // Serial number of the default certificate
+	fallback *tls.Certificate // Default certificate from key and cert files
+
+	// Certificates keyed by serial number and issuer serial number, and
+	// certificates keyed by host name or IP address
+	certs map[string]*tls.Certificate
+	hosts map[string]*tls.Certificate
+	def   *tls.Certificate
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Create certificates from a certificate manager, with the serial number of
+// a default certificate and a fallback certificate, which are used when no
+// certificate matches the server name. Either can be empty
+func newCerts(manager CertManager, serial string, fallback *tls.Certificate) *certs {
+	c := new(certs)
+	c.manager = manager
+	c.serial = serial
+	c.fallback = fallback
+	c.certs = make(map[string]*tls.Certificate)
+	c.hosts = make(map[string]*tls.Certificate)
+	c.def = fallback
+	return c
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Return the certificate for a TLS handshake. The server name is matched
+// against the host names of the certificates, including wildcard names. When
+// the client does not send a server name, the local IP address is matched
+// instead. Otherwise, the default certificate is returned
+func (c *certs) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name == "" && hello.Conn != nil {
+		if host, _, err := net.SplitHostPort(hello.Conn.LocalAddr().String()); err == nil {
+			name = host
+		}
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+	if cert, exists := c.hosts[name]; exists {
+		return cert, nil
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if cert, exists := c.hosts["*"+name[i:]]; exists {
+			return cert, nil
+		}
+	}
+	if c.def != nil {
+		return c.def, nil
+	}
+	return nil, ErrNotFound.Withf("no certificate for %q", hello.ServerName)
+}
+
+// Return the number of certificates which can be selected
+func (c *certs) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+	n := 0
+	for _, cert := range c.certs {
+		if cert != nil {
+			n++
+		}
+	}
+	return n
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Load certificates from the certificate manager which have been added or
+// re-issued, and remove certificates which have been deleted. Expired
+// certificates and certificates without a private key are ignored. Returns
+// true if the certificates changed
+func (c *certs) load() (bool, error) {
+	var result error
+
+	// Index the certificate authorities, in order to add the issuer to
+	// the certificate chain
+	list := c.manager.List()
+	issuers := make(map[string]string, len(list))
+	for _, cert := range list {
+		if cert.IsCA() {
+			issuers[cert.KeyId()] = cert.Serial()
+		}
+	}
+
+	// Read certificates which have not yet been loaded. The key includes
+	// the issuer, so the chain is updated when the issuer is renewed
+	c.RLock()
+	loaded := c.certs
+	c.RUnlock()
+	certs := make(map[string]*tls.Certificate, len(list))
+	for _, cert := range list {
+		if cert.IsCA() {
+			continue
+		}
+		issuer := issuers[cert.IssuerKeyId()]
+		key := cert.Serial() + "/" + issuer
+		if tlscert, exists := loaded[key]; exists {
+			certs[key] = tlscert
+		} else if tlscert, err := c.read(cert.Serial(), issuer); err != nil {
+			result = errors.Join(result, err)
+		} else {
+			certs[key] = tlscert
+		}
+	}
+
+	// Determine if the certificates have changed
+	changed := len(certs) != len(loaded)
+	for key := range certs {
+		if _, exists := loaded[key]; !exists {
+			changed = true
+		}
+	}
+
+	// The serial number of the default certificate refers to the renewed
+	// certificate once it has been renewed
+	serial := c.serial
+	if serial != "" {
+		if cert, err := c.manager.Read(serial); err == nil {
+			serial = cert.Serial()
+		}
+	}
+
+	// Index the certificates by host, preferring the certificate which
+	// expires last
+	now := time.Now()
+	hosts := make(map[string]*tls.Certificate)
+	def := c.fallback
+	for key, cert := range certs {
+		if cert == nil || now.After(cert.Leaf.NotAfter) {
+			continue
+		}
+		if serial != "" && strings.HasPrefix(key, serial+"/") {
+			def = cert
+		}
+		for _, host := range certHosts(cert.Leaf) {
+			if other, exists := hosts[host]; !exists || cert.Leaf.NotAfter.After(other.Leaf.NotAfter) {
+				hosts[host] = cert
+			}
+		}
+	}
+	if c.serial != "" && def == c.fallback {
+		result = errors.Join(result, ErrNotFound.Withf("default certificate %q", c.serial))
+	}
+
+	// Set the certificates
+	c.Lock()
+	defer c.Unlock()
+	c.certs = certs
+	c.hosts = hosts
+	c.def = def
+
+	// Return any errors
+	return changed, result
+}
+
+// Read a certificate and private key from the certificate manager, and
+// append the issuer to the certificate chain. Returns nil if the certificate
+// has no private key
+func (c *certs) read(serial, issuer string) (*tls.Certificate, error) {
+	var certPEM, keyPEM bytes.Buffer
+	if cert, err := c.manager.Read(serial); err != nil {
+		return nil, err
+	} else if err := cert.WritePrivateKey(&keyPEM); errors.Is(err, ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else if err := cert.WriteCertificate(&certPEM); err != nil {
+		return nil, err
+	}
+	if issuer != "" {
+		if ca, err := c.manager.Read(issuer); err != nil {
+			return nil, err
+		} else if err := ca.WriteCertificate(&certPEM); err != nil {
+			return nil, err
+		}
+	}
+
+	// Create the certificate
+	cert, err := tls.X509KeyPair(certPEM.Bytes(), keyPEM.Bytes())
+	if err != nil {
+		return nil, err
+	} else if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, err
+	} else {
+		cert.Leaf = leaf
+	}
+
+	// Return success
+	return &cert, nil
+}
+
+// Return the host names and IP addresses for a certificate, or the
+// common name if there are none
+func certHosts(cert *x509.Certificate) []string {
+	hosts := make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses))
+	for _, name := range cert.DNSNames {
+		hosts = append(hosts, strings.ToLower(name))
+	}
+	for _, ip := range cert.IPAddresses {
+		hosts = append(hosts, ip.String())
+	}
+	if len(hosts) == 0 && cert.Subject.CommonName != "" {
+		hosts = append(hosts, strings.ToLower(cert.Subject.CommonName))
+	}
+	return hosts
+}
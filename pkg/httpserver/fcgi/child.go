@@ -152,6 +152,7 @@ func (r *response) Close() error {
 }
 
 type child struct {
+	ctx     context.Context // base context for requests
 	conn    *conn
 	handler http.Handler
 
@@ -159,14 +160,22 @@ type child struct {
 	requests map[uint16]*request // keyed by request ID
 }
 
-func newChild(rwc io.ReadWriteCloser, handler http.Handler) *child {
+func newChild(ctx context.Context, rwc io.ReadWriteCloser, handler http.Handler) *child {
 	return &child{
+		ctx:      ctx,
 		conn:     newConn(rwc),
 		handler:  handler,
 		requests: make(map[uint16]*request),
 	}
 }
 
+// idle returns true if there are no in-flight requests on the connection
+func (c *child) idle() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.requests) == 0
+}
+
 func (c *child) serve() {
 	defer c.conn.Close()
 	defer c.cleanUp()
@@ -291,7 +300,7 @@ func (c *child) serveRequest(req *request, body io.ReadCloser) {
 	} else {
 		httpReq.Body = body
 		//withoutUsedEnvVars := filterOutUsedEnvVars(req.params)
-		envVarCtx := context.WithValue(httpReq.Context(), envVarsContextKey{}, req.params)
+		envVarCtx := context.WithValue(c.ctx, envVarsContextKey{}, req.params)
 		httpReq = httpReq.WithContext(envVarCtx)
 		c.handler.ServeHTTP(r, httpReq)
 	}
@@ -352,7 +361,7 @@ func Serve(l net.Listener, handler http.Handler) error {
 		if err != nil {
 			return err
 		}
-		c := newChild(rw, handler)
+		c := newChild(context.Background(), rw, handler)
 		go c.serve()
 	}
 }
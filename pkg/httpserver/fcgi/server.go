@@ -10,6 +10,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 )
 
 // A Server defines parameters for running an FCGI server.
@@ -31,12 +32,23 @@ type Server struct {
 	DirMode os.FileMode
 	Mode    os.FileMode
 
+	// BaseContext optionally returns the base context for requests, or
+	// context.Background() is used if nil
+	BaseContext func(net.Listener) context.Context
+
 	// Private variables to flag shutdown
 	listener net.Listener
 	ctx      context.Context
 	cancel   context.CancelFunc
+
+	// Open connections
+	mu    sync.Mutex
+	conns map[*child]struct{}
 }
 
+// Interval between checking for idle connections on shutdown
+const shutdownPollInterval = 100 * time.Millisecond
+
 func (s *Server) ListenAndServe() error {
 	var wg sync.WaitGroup
 
@@ -132,6 +144,12 @@ func (s *Server) ListenAndServe() error {
 	// Set up semapore which when closed ends the loop
 	s.ctx, s.cancel = context.WithCancel(context.Background())
 
+	// Set the base context for requests
+	base := context.Background()
+	if s.BaseContext != nil {
+		base = s.BaseContext(s.listener)
+	}
+
 	// Continue accepting requests until shutdown
 FOR_LOOP:
 	for {
@@ -145,11 +163,13 @@ FOR_LOOP:
 			} else if err != nil {
 				return err
 			}
-			c := newChild(rw, s.Handler)
+			c := newChild(base, rw, s.Handler)
+			s.track(c, true)
 			wg.Add(1)
 			go func() {
+				defer wg.Done()
+				defer s.track(c, false)
 				c.serve()
-				wg.Done()
 			}()
 		}
 	}
@@ -161,6 +181,8 @@ FOR_LOOP:
 	return nil
 }
 
+// Close stops accepting connections, but does not wait for in-flight
+// requests to complete
 func (s *Server) Close() error {
 	var result error
 	if s.cancel != nil {
@@ -169,3 +191,68 @@ func (s *Server) Close() error {
 	}
 	return result
 }
+
+// Shutdown stops accepting connections, and closes each connection once
+// it has no in-flight requests. If the context is done before all the
+// connections are closed, the remaining connections are closed, and the
+// number of connections which were force-closed is returned
+func (s *Server) Shutdown(ctx context.Context) (int, error) {
+	result := s.Close()
+
+	// Close idle connections until there are none left
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if s.closeIdle() == 0 {
+			return 0, result
+		}
+		select {
+		case <-ctx.Done():
+			return s.closeAll(), result
+		case <-ticker.C:
+		}
+	}
+}
+
+// Add or remove a connection
+func (s *Server) track(c *child, add bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns == nil {
+		s.conns = make(map[*child]struct{})
+	}
+	if add {
+		s.conns[c] = struct{}{}
+	} else {
+		delete(s.conns, c)
+	}
+}
+
+// Close connections with no in-flight requests, and return the number of
+// connections which are still active
+func (s *Server) closeIdle() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var active int
+	for c := range s.conns {
+		if c.idle() {
+			c.conn.Close()
+			delete(s.conns, c)
+		} else {
+			active++
+		}
+	}
+	return active
+}
+
+// Close all connections, and return the number of connections closed
+func (s *Server) closeAll() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.conns)
+	for c := range s.conns {
+		c.conn.Close()
+		delete(s.conns, c)
+	}
+	return n
+}
@@ -14,10 +14,12 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	// Packages
 	"github.com/mutablelogic/go-server"
+	"github.com/mutablelogic/go-server/pkg/httpresponse"
 	fcgi "github.com/mutablelogic/go-server/pkg/httpserver/fcgi"
 	"github.com/mutablelogic/go-server/pkg/provider"
 
@@ -32,13 +34,19 @@ import (
 type Config struct {
 	Listen string `hcl:"listen" description:"Network address and port to listen on, or path to file socket"`
 	TLS    struct {
-		Key  string `hcl:"key" description:"Path to private key (if provided, tls.cert is also required)"`
-		Cert string `hcl:"cert" description:"Path to certificate (if provided, tls.key is also required)"`
+		Key         string        `hcl:"key" description:"Path to private key (if provided, tls.cert is also required)"`
+		Cert        string        `hcl:"cert" description:"Path to certificate (if provided, tls.key is also required)"`
+		CertManager CertManager   `hcl:"certmanager" description:"Certificate manager, which provides certificates selected by server name"`
+		Default     string        `hcl:"default" description:"Serial number of the certificate to use when no certificate matches the server name"`
+		Reload      time.Duration `hcl:"reload" description:"Interval for reloading certificates from the certificate manager (default 1m)"`
 	} `hcl:"tls"`
-	Timeout time.Duration `hcl:"timeout" description:"Read request timeout"`
-	Owner   string        `hcl:"owner" description:"User ID of the file socket (if listen is a file socket)"`
-	Group   string        `hcl:"group" description:"Group ID of the file socket (if listen is a file socket)"`
-	Router  http.Handler  `hcl:"router" description:"HTTP router for requests"`
+	Timeout    time.Duration `hcl:"timeout" description:"Read request timeout"`
+	Drain      time.Duration `hcl:"drain" description:"Time to wait for in-flight requests to complete on shutdown, before connections are closed (default 10s)"`
+	Ready      string        `hcl:"ready" description:"Path for readiness checks, which responds with 503 Service Unavailable when the server is draining"`
+	ReadyDelay time.Duration `hcl:"ready_delay" description:"Time to respond to readiness checks as not ready on shutdown, before draining begins (default 5s when ready is set)"`
+	Owner      string        `hcl:"owner" description:"User ID of the file socket (if listen is a file socket)"`
+	Group      string        `hcl:"group" description:"Group ID of the file socket (if listen is a file socket)"`
+	Router     http.Handler  `hcl:"router" description:"HTTP router for requests"`
 }
 
 // Server interface
@@ -53,12 +61,32 @@ type Server interface {
 
 	// Return the router associated with the server
 	Router() http.Handler
+
+	// Return true if the server is accepting requests, or false if the
+	// server has not started or is draining connections on shutdown
+	Ready() bool
 }
 
 // http server instance
 type httpserver struct {
-	fcgi *fcgi.Server
-	http *http.Server
+	fcgi   *fcgi.Server
+	http   *http.Server
+	router http.Handler
+
+	// Certificates from a certificate manager, and the reload interval
+	certs  *certs
+	reload time.Duration
+
+	// Drain timeout, delay before draining begins, readiness flag, and a
+	// channel which is closed when draining begins
+	drain      time.Duration
+	readyDelay time.Duration
+	ready      atomic.Bool
+	draining   chan struct{}
+
+	// Open network connections
+	mu    sync.Mutex
+	conns map[net.Conn]http.ConnState
 }
 
 // Check interfaces are satisfied
@@ -69,15 +97,18 @@ var _ server.Task = (*httpserver)(nil)
 // GLOBALS
 
 const (
-	defaultName      = "httpserver"
-	defaultTimeout   = 10 * time.Second
-	defaultListen    = ":http"
-	defaultListenTLS = ":https"
-	defaultMode      = os.FileMode(0600)
-	defaultDirMode   = os.FileMode(0700)
-	groupMode        = os.FileMode(0060)
-	groupDirMode     = os.FileMode(0070)
-	allMode          = os.FileMode(0777)
+	defaultName         = "httpserver"
+	defaultTimeout      = 10 * time.Second
+	defaultDrainTimeout = 10 * time.Second
+	defaultReadyDelay   = 5 * time.Second
+	defaultReload       = time.Minute
+	defaultListen       = ":http"
+	defaultListenTLS    = ":https"
+	defaultMode         = os.FileMode(0600)
+	defaultDirMode      = os.FileMode(0700)
+	groupMode           = os.FileMode(0060)
+	groupDirMode        = os.FileMode(0070)
+	allMode             = os.FileMode(0777)
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -101,10 +132,21 @@ func (c Config) New() (server.Task, error) {
 	if c.Router == nil {
 		c.Router = http.DefaultServeMux
 	}
+	self.router = c.Router
+	self.drain = c.drainTimeout()
+	self.readyDelay = c.readyDelay()
+	self.draining = make(chan struct{})
+	self.conns = make(map[net.Conn]http.ConnState)
+
+	// Respond to readiness checks
+	handler := c.Router
+	if c.Ready != "" {
+		handler = self.readyHandler(c.Ready, handler)
+	}
 
 	// Set defaults
 	if c.Listen == "" {
-		if c.TLS.Cert != "" || c.TLS.Key != "" {
+		if c.TLS.Cert != "" || c.TLS.Key != "" || c.TLS.CertManager != nil {
 			c.Listen = defaultListenTLS
 		} else {
 			c.Listen = defaultListen
@@ -119,16 +161,32 @@ func (c Config) New() (server.Task, error) {
 		}
 
 		// Read the TLS configuration
-		tls, err := c.tls()
+		config, err := c.tls()
 		if err != nil {
 			return nil, err
 		}
 
+		// Select certificates from the certificate manager by server name,
+		// falling back to the certificate from the key and cert files
+		if c.TLS.CertManager != nil {
+			var fallback *tls.Certificate
+			if config != nil {
+				fallback = &config.Certificates[0]
+			}
+			self.certs = newCerts(c.TLS.CertManager, c.TLS.Default, fallback)
+			self.reload = c.reload()
+			config = &tls.Config{
+				GetCertificate: self.certs.GetCertificate,
+			}
+		}
+
 		// Create net server
 		addr := fmt.Sprintf("%s:%d", host, port)
-		if http, err := netserver(addr, tls, c.timeout(), c.Router); err != nil {
+		if http, err := netserver(addr, config, c.timeout(), handler); err != nil {
 			return nil, err
 		} else {
+			http.BaseContext = self.baseContext
+			http.ConnState = self.connState
 			self.http = http
 		}
 	} else if abs, err := filepath.Abs(c.Listen); err != nil {
@@ -139,9 +197,10 @@ func (c Config) New() (server.Task, error) {
 			return nil, err
 		} else if group, err := c.group(); err != nil {
 			return nil, err
-		} else if fcgi, err := fcgiserver(abs, owner, group, c.fileMode(), c.dirMode(), c.Router); err != nil {
+		} else if fcgi, err := fcgiserver(abs, owner, group, c.fileMode(), c.dirMode(), handler); err != nil {
 			return nil, err
 		} else {
+			fcgi.BaseContext = self.baseContext
 			self.fcgi = fcgi
 		}
 	}
@@ -159,7 +218,7 @@ func (c Config) String() string {
 	return string(data)
 }
 
-func (h httpserver) String() string {
+func (h *httpserver) String() string {
 	var server struct {
 		Type string `json:"type"`
 		Addr string `json:"addr"`
@@ -188,26 +247,52 @@ func (self *httpserver) Run(ctx context.Context) error {
 	child, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	// Stop server on context cancel
+	// Stop server on context cancel, draining connections
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		<-child.Done()
-		if err := self.stop(); err != nil {
+		closed, err := self.stop()
+		if err != nil {
 			result = errors.Join(result, err)
 		}
+		if log := provider.Logger(ctx); log != nil && closed > 0 {
+			log.Printf(ctx, "Stopped %v server on %q: %d connection(s) force-closed", self.Type(), self.Addr(), closed)
+		}
 	}()
 
+	// Load certificates, and reload them when they change
+	if self.certs != nil {
+		self.loadWithLogging(ctx, true)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(self.reload)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-child.Done():
+					return
+				case <-ticker.C:
+					self.loadWithLogging(ctx, false)
+				}
+			}
+		}()
+	}
+
 	// Log the server is running
 	if log := provider.Logger(ctx); log != nil {
 		log.Printf(ctx, "Starting %v server on %q", self.Type(), self.Addr())
 	}
+	self.ready.Store(true)
 
-	// Run server in foreground, cancel when done
+	// Run server in foreground, cancel when done. The server is not ready
+	// once it stops serving, so there is no delay when stopping it
 	if err := self.runInForeground(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		result = errors.Join(result, err)
 		cancel()
 	}
+	self.ready.Store(false)
 
 	// Wait for gorutines to finish
 	wg.Wait()
@@ -224,11 +309,12 @@ func (self *httpserver) Label() string {
 
 // Return the router for the server
 func (self *httpserver) Router() http.Handler {
-	if self.fcgi != nil {
-		return self.fcgi.Handler
-	} else {
-		return self.http.Handler
-	}
+	return self.router
+}
+
+// Return true if the server is accepting requests
+func (self *httpserver) Ready() bool {
+	return self.ready.Load()
 }
 
 // Return the type of server
@@ -259,12 +345,42 @@ func (c Config) timeout() time.Duration {
 	}
 }
 
+// Return the drain timeout value from the configuration
+func (c Config) drainTimeout() time.Duration {
+	if c.Drain > 0 {
+		return c.Drain
+	} else {
+		return defaultDrainTimeout
+	}
+}
+
+// Return the delay between responding to readiness checks as not ready and
+// draining from the configuration, which is only used with readiness checks
+func (c Config) readyDelay() time.Duration {
+	if c.Ready == "" {
+		return 0
+	} else if c.ReadyDelay > 0 {
+		return c.ReadyDelay
+	} else {
+		return defaultReadyDelay
+	}
+}
+
+// Return the certificate reload interval from the configuration
+func (c Config) reload() time.Duration {
+	if c.TLS.Reload > 0 {
+		return c.TLS.Reload
+	} else {
+		return defaultReload
+	}
+}
+
 // Return the TLS configuration
 func (c Config) tls() (*tls.Config, error) {
 	if c.TLS.Cert == "" || c.TLS.Key == "" {
 		return nil, nil
 	}
-	if cert, err := tls.LoadX509KeyPair(c.TLS.Cert, c.TLS.Cert); err != nil {
+	if cert, err := tls.LoadX509KeyPair(c.TLS.Cert, c.TLS.Key); err != nil {
 		return nil, fmt.Errorf("LoadX509KeyPair: %w", err)
 	} else {
 		return &tls.Config{
@@ -371,15 +487,86 @@ func (self *httpserver) runInForeground() error {
 	}
 }
 
-// Stop the server
-func (self *httpserver) stop() error {
+// Stop the server. If the server is serving, it is flagged as not ready and
+// continues to accept connections until the ready delay has passed, so that
+// load balancers stop sending requests. It then stops accepting connections,
+// and in-flight requests are given until the drain timeout to complete before
+// the connections are closed. Returns the number of connections which were
+// force-closed
+func (self *httpserver) stop() (int, error) {
+	if self.ready.Swap(false) {
+		time.Sleep(self.readyDelay)
+	}
+	close(self.draining)
+
+	// Shutdown gracefully, and close if the timeout is exceeded
+	ctx, cancel := context.WithTimeout(context.Background(), self.drain)
+	defer cancel()
 	if self.fcgi != nil {
-		return self.fcgi.Close()
+		return self.fcgi.Shutdown(ctx)
+	}
+	if err := self.http.Shutdown(ctx); errors.Is(err, context.DeadlineExceeded) {
+		closed := self.open()
+		return closed, self.http.Close()
 	} else {
-		return self.http.Close()
+		return 0, err
+	}
+}
+
+// Load certificates from the certificate manager, and log the number of
+// certificates when they change, and any errors
+func (self *httpserver) loadWithLogging(ctx context.Context, force bool) {
+	changed, err := self.certs.load()
+	log := provider.Logger(ctx)
+	if log == nil {
+		return
+	}
+	if changed || force {
+		log.Printf(ctx, "Loaded %d certificate(s) for %v server on %q", self.certs.Len(), self.Type(), self.Addr())
+	}
+	if err != nil {
+		log.Print(ctx, "Certificates: ", err)
 	}
 }
 
+// Return the base context for requests, which signals when draining begins
+func (self *httpserver) baseContext(net.Listener) context.Context {
+	return httpresponse.WithDrain(context.Background(), self.draining)
+}
+
+// Record the state of network connections
+func (self *httpserver) connState(conn net.Conn, state http.ConnState) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(self.conns, conn)
+	default:
+		self.conns[conn] = state
+	}
+}
+
+// Return the number of open network connections
+func (self *httpserver) open() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return len(self.conns)
+}
+
+// Respond to readiness checks on a path, and pass other requests to the
+// router
+func (self *httpserver) readyHandler(path string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			next.ServeHTTP(w, r)
+		} else if self.Ready() {
+			httpresponse.Empty(w, http.StatusOK)
+		} else {
+			httpresponse.Error(w, http.StatusServiceUnavailable, "server is draining")
+		}
+	})
+}
+
 // Create a fastcgi file socket server
 func fcgiserver(path string, uid, gid int, fileMode os.FileMode, dirMode os.FileMode, handler http.Handler) (*fcgi.Server, error) {
 	fcgi := new(fcgi.Server)
@@ -1,10 +1,26 @@
 package httpserver_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	// Packages
+	certmanager "github.com/mutablelogic/go-server/pkg/handler/certmanager"
+	cert "github.com/mutablelogic/go-server/pkg/handler/certmanager/cert"
+	certstore "github.com/mutablelogic/go-server/pkg/handler/certmanager/certstore"
+	"github.com/mutablelogic/go-server/pkg/httpresponse"
 	"github.com/mutablelogic/go-server/pkg/httpserver"
+	"github.com/mutablelogic/go-server/pkg/provider"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,3 +39,245 @@ func Test_httpserver_002(t *testing.T) {
 	assert.NotNil(server)
 	t.Log(server)
 }
+
+func Test_httpserver_003(t *testing.T) {
+	assert := assert.New(t)
+
+	// Handlers which complete, stream until draining begins, or hang
+	router := http.NewServeMux()
+	router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		httpresponse.Empty(w, http.StatusOK)
+	})
+	router.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		stream := httpresponse.NewTextStreamWithContext(r.Context(), w)
+		defer stream.Close()
+		<-stream.Done()
+	})
+	router.HandleFunc("/hang", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	// Create the server
+	task, err := httpserver.Config{Listen: "localhost:0", Drain: 500 * time.Millisecond, Ready: "/ready", ReadyDelay: 200 * time.Millisecond, Router: router}.New()
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	server := task.(httpserver.Server)
+	assert.False(server.Ready())
+
+	// Run the server
+	log := new(testLogger)
+	ctx, cancel := context.WithCancel(provider.WithLogger(context.Background(), log))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(server.Run(ctx))
+	}()
+
+	// Wait for the server to be ready
+	url := "http://" + server.Addr()
+	assert.Eventually(func() bool {
+		resp, err := http.Get(url + "/ready")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+	assert.True(server.Ready())
+
+	// Make requests, then shutdown the server
+	var requests sync.WaitGroup
+	status := make(map[string]error)
+	var lock sync.Mutex
+	for _, path := range []string{"/slow", "/stream", "/hang"} {
+		requests.Add(1)
+		go func(path string) {
+			defer requests.Done()
+			resp, err := http.Get(url + path)
+			if err == nil {
+				_, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			status[path] = err
+		}(path)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	// Server should be unready immediately, and respond to readiness checks
+	// until draining begins
+	assert.Eventually(func() bool {
+		return !server.Ready()
+	}, 100*time.Millisecond, 10*time.Millisecond)
+	if resp, err := http.Get(url + "/ready"); assert.NoError(err) {
+		resp.Body.Close()
+		assert.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	// Wait for the requests and the server to complete
+	requests.Wait()
+	wg.Wait()
+
+	// The slow request and stream should complete, and the hanging request
+	// should be force-closed
+	assert.NoError(status["/slow"])
+	assert.NoError(status["/stream"])
+	assert.Error(status["/hang"])
+	assert.Contains(log.String(), "1 connection(s) force-closed")
+}
+
+func Test_httpserver_004(t *testing.T) {
+	assert := assert.New(t)
+
+	// Create a certificate manager with a CA and certificates
+	store, err := certstore.New(certstore.Config{DataPath: t.TempDir()})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	manager, err := certmanager.New(certmanager.Config{CertStorage: store})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	ca, err := manager.CreateCA(t.Name())
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	localhost, err := manager.CreateSignedCert("localhost", ca, cert.OptHosts("localhost", "127.0.0.1"))
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	_, err = manager.CreateSignedCert("wildcard", ca, cert.OptHosts("*.test.internal"))
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Trust the CA
+	var pem bytes.Buffer
+	assert.NoError(ca.WriteCertificate(&pem))
+	roots := x509.NewCertPool()
+	assert.True(roots.AppendCertsFromPEM(pem.Bytes()))
+
+	// Create the server, with the localhost certificate as the default
+	config := httpserver.Config{Listen: "localhost:0", Router: http.NewServeMux()}
+	config.TLS.CertManager = manager
+	config.TLS.Default = localhost.Serial()
+	config.TLS.Reload = 10 * time.Millisecond
+	task, err := config.New()
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	server := task.(httpserver.Server)
+
+	// Run the server
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(server.Run(ctx))
+	}()
+	defer wg.Wait()
+	defer cancel()
+
+	// Return the subject of the certificate for a server name
+	subject := func(name string) string {
+		conn, err := tls.Dial("tcp", server.Addr(), &tls.Config{RootCAs: roots, ServerName: name})
+		if err != nil {
+			return ""
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+
+	// Select certificates by server name, and use the default otherwise
+	assert.Eventually(func() bool {
+		return subject("localhost") == "localhost"
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal("wildcard", subject("a.test.internal"))
+	assert.Equal("", subject("a.b.test.internal"))
+	assert.Equal("", subject("other.internal"))
+
+	// Add a certificate, which is loaded without restarting the server
+	other, err := manager.CreateSignedCert("other", ca, cert.OptHosts("other.internal"))
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.Eventually(func() bool {
+		return subject("other.internal") == "other"
+	}, time.Second, 10*time.Millisecond)
+
+	// Remove the certificate
+	assert.NoError(manager.Delete(other))
+	assert.Eventually(func() bool {
+		return subject("other.internal") == ""
+	}, time.Second, 10*time.Millisecond)
+
+	// The default certificate is used for other server names, and is
+	// still the default once it has been renewed
+	serial := func(name string) string {
+		conn, err := tls.Dial("tcp", server.Addr(), &tls.Config{InsecureSkipVerify: true, ServerName: name})
+		if err != nil {
+			return ""
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.String()
+	}
+	assert.Equal(localhost.Serial(), serial("other.internal"))
+	renewed, err := manager.Renew(localhost)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.Eventually(func() bool {
+		return serial("other.internal") == renewed.Serial()
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_httpserver_005(t *testing.T) {
+	assert := assert.New(t)
+
+	// Occupy a port, so the server fails to listen
+	listener, err := net.Listen("tcp", "localhost:0")
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	defer listener.Close()
+
+	// Create the server with a long ready delay
+	task, err := httpserver.Config{Listen: listener.Addr().String(), Ready: "/ready", ReadyDelay: 5 * time.Second, Router: http.NewServeMux()}.New()
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	server := task.(httpserver.Server)
+
+	// The server was never serving, so returns without waiting for the
+	// ready delay
+	now := time.Now()
+	assert.Error(server.Run(context.Background()))
+	assert.Less(time.Since(now), time.Second)
+	assert.False(server.Ready())
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// LOGGER
+
+type testLogger struct {
+	sync.Mutex
+	strings.Builder
+}
+
+func (l *testLogger) Print(ctx context.Context, args ...any) {
+	l.Lock()
+	defer l.Unlock()
+	l.WriteString(fmt.Sprintln(args...))
+}
+
+func (l *testLogger) Printf(ctx context.Context, format string, args ...any) {
+	l.Lock()
+	defer l.Unlock()
+	l.WriteString(fmt.Sprintf(format, args...) + "\n")
+}
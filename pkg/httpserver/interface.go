@@ -0,0 +1,19 @@
+package httpserver
+
+import (
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	certmanager "github.com/mutablelogic/go-server/pkg/handler/certmanager"
+)
+
+// CertManager provides certificates and private keys for serving
+// HTTPS requests
+type CertManager interface {
+	server.Task
+
+	// Return all certificates
+	List() []certmanager.Cert
+
+	// Return a certificate by serial number
+	Read(string) (certmanager.Cert, error)
+}
@@ -25,6 +25,13 @@ type Node interface {
 	Append(Node) Node
 }
 
+// Positioner is implemented by nodes which record where they are defined
+// in a file, which is used when reporting errors
+type Positioner interface {
+	// Return the position, or an empty string if the position is not known
+	Pos() string
+}
+
 type jsonNode struct {
 	Type     string `json:"type"`
 	Name     string `json:"name,omitempty"`
@@ -15,9 +15,11 @@ type valueNode struct {
 	V any
 	P Node
 	C []Node
+	R string
 }
 
 var _ Node = (*valueNode)(nil)
+var _ Positioner = (*valueNode)(nil)
 
 /////////////////////////////////////////////////////////////////////
 // LIFECYCLE
@@ -83,6 +85,16 @@ func (r *valueNode) Key() string {
 	return r.K
 }
 
+// Return the position of the value in a file
+func (r *valueNode) Pos() string {
+	return r.R
+}
+
+// Set the position of the value in a file
+func (r *valueNode) SetPos(pos string) {
+	r.R = pos
+}
+
 func (r *valueNode) Value(ctx *Context) (any, error) {
 	if ctx == nil || ctx.eval == nil {
 		return nil, ErrInternalAppError.With("Missing context evaluation function")
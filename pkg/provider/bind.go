@@ -0,0 +1,287 @@
+package provider
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	// Packages
+	"github.com/djthorpe/go-tablewriter/pkg/meta"
+	"github.com/mutablelogic/go-server/pkg/types"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// fieldPath is the path to a value which is decoded, such as
+// nginx-handler.main.listen, and the positions of values in the file
+// they were parsed from. The path includes the position when it is
+// formatted, so that errors refer to the file and line
+type fieldPath struct {
+	name string
+	pos  map[string]string
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	typeDuration = reflect.TypeOf(time.Duration(0))
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Decode an evaluated value into a configuration, which should be a pointer
+// to a struct. References to other configurations are resolved into tasks
+// using the resolve function. When validating, the resolve function can
+// return a nil task, and the reference is checked but not set
+func decode(label types.Label, dest reflect.Value, src any, pos map[string]string, resolve ResolveFunc, validate bool) error {
+	if dest.Kind() != reflect.Ptr || dest.Elem().Kind() != reflect.Struct {
+		return ErrInternalAppError.Withf("%s: cannot decode into %v", label, dest.Type())
+	}
+	return decodeValue(fieldPath{string(label), pos}, dest.Elem(), src, resolve, validate)
+}
+
+// Decode a value, where the path is used for error reporting
+func decodeValue(path fieldPath, dest reflect.Value, src any, resolve ResolveFunc, validate bool) error {
+	// Set zero value for nil
+	if src == nil {
+		dest.Set(reflect.Zero(dest.Type()))
+		return nil
+	}
+
+	// Resolve references to other configurations
+	if ref, ok := src.(types.Label); ok {
+		return decodeRef(path, dest, ref, resolve, validate)
+	}
+
+	// Durations are strings
+	if dest.Type() == typeDuration {
+		if src, ok := src.(string); !ok {
+			return ErrBadParameter.Withf("%s: expected duration", path)
+		} else if duration, err := time.ParseDuration(src); err != nil {
+			return ErrBadParameter.Withf("%s: %v", path, err)
+		} else {
+			dest.SetInt(int64(duration))
+			return nil
+		}
+	}
+
+	switch dest.Kind() {
+	case reflect.Ptr:
+		value := reflect.New(dest.Type().Elem())
+		if err := decodeValue(path, value.Elem(), src, resolve, validate); err != nil {
+			return err
+		}
+		dest.Set(value)
+	case reflect.String:
+		if src, ok := src.(string); !ok {
+			return ErrBadParameter.Withf("%s: expected string", path)
+		} else {
+			dest.SetString(src)
+		}
+	case reflect.Bool:
+		if src, ok := src.(bool); !ok {
+			return ErrBadParameter.Withf("%s: expected bool", path)
+		} else {
+			dest.SetBool(src)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if src, ok := toInt(src); !ok {
+			return ErrBadParameter.Withf("%s: expected integer", path)
+		} else if dest.OverflowInt(src) {
+			return ErrBadParameter.Withf("%s: integer out of range", path)
+		} else {
+			dest.SetInt(src)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if src, ok := toInt(src); !ok || src < 0 {
+			return ErrBadParameter.Withf("%s: expected unsigned integer", path)
+		} else if dest.OverflowUint(uint64(src)) {
+			return ErrBadParameter.Withf("%s: integer out of range", path)
+		} else {
+			dest.SetUint(uint64(src))
+		}
+	case reflect.Float32, reflect.Float64:
+		if src, ok := toFloat(src); !ok {
+			return ErrBadParameter.Withf("%s: expected number", path)
+		} else {
+			dest.SetFloat(src)
+		}
+	case reflect.Slice:
+		src, ok := src.([]any)
+		if !ok {
+			return ErrBadParameter.Withf("%s: expected array", path)
+		}
+		value := reflect.MakeSlice(dest.Type(), len(src), len(src))
+		for i, elem := range src {
+			if err := decodeValue(path.Index(i), value.Index(i), elem, resolve, validate); err != nil {
+				return err
+			}
+		}
+		dest.Set(value)
+	case reflect.Map:
+		src, ok := src.(map[string]any)
+		if !ok || dest.Type().Key().Kind() != reflect.String {
+			return ErrBadParameter.Withf("%s: expected map", path)
+		}
+		value := reflect.MakeMapWithSize(dest.Type(), len(src))
+		for key, elem := range src {
+			v := reflect.New(dest.Type().Elem()).Elem()
+			if err := decodeValue(path.Join(key), v, elem, resolve, validate); err != nil {
+				return err
+			}
+			value.SetMapIndex(reflect.ValueOf(key).Convert(dest.Type().Key()), v)
+		}
+		dest.Set(value)
+	case reflect.Struct:
+		src, ok := src.(map[string]any)
+		if !ok {
+			return ErrBadParameter.Withf("%s: expected block", path)
+		}
+		return decodeStruct(path, dest, src, resolve, validate)
+	case reflect.Interface:
+		if dest.NumMethod() != 0 {
+			return ErrBadParameter.Withf("%s: expected reference to %v", path, dest.Type())
+		}
+		dest.Set(reflect.ValueOf(src))
+	default:
+		return ErrBadParameter.Withf("%s: unsupported type %v", path, dest.Type())
+	}
+
+	// Return success
+	return nil
+}
+
+// Decode a block into a struct, where the keys are the hcl or json field names
+func decodeStruct(path fieldPath, dest reflect.Value, src map[string]any, resolve ResolveFunc, validate bool) error {
+	rt, err := meta.NewType(dest.Type(), strings.Split(tagNames, ",")...)
+	if err != nil {
+		return err
+	}
+
+	// Index the fields by name
+	fields := make(map[string]meta.Field)
+	for _, field := range rt.Fields() {
+		fields[field.Name()] = field
+	}
+
+	// Set the fields
+	for key, value := range src {
+		field, exists := fields[key]
+		if !exists {
+			return ErrBadParameter.Withf("%s: unknown field %q", path, key)
+		}
+		if err := decodeValue(path.Join(key), dest.FieldByIndex(field.Index()), value, resolve, validate); err != nil {
+			return err
+		}
+	}
+
+	// Check for required fields
+	for _, field := range rt.Fields() {
+		if _, exists := src[field.Name()]; !exists && field.Is(tagRequired) {
+			return ErrBadParameter.Withf("%s: missing required field %q", path, field.Name())
+		}
+	}
+
+	// Return success
+	return nil
+}
+
+// Decode a reference to another configuration, which should be a task
+// which satisfies the destination type. When validating, the resolve
+// function can return a nil task, and the reference is checked but not set
+func decodeRef(path fieldPath, dest reflect.Value, ref types.Label, resolve ResolveFunc, validate bool) error {
+	if resolve == nil {
+		return ErrBadParameter.Withf("%s: unable to resolve %q", path, ref)
+	} else if dest.Kind() != reflect.Interface {
+		return ErrBadParameter.Withf("%s: %q cannot be used as %v", path, ref, dest.Type())
+	}
+	task, err := resolve(ref)
+	if err != nil {
+		return err
+	} else if task == nil && validate {
+		return nil
+	} else if task == nil {
+		return ErrNotFound.Withf("%s: %q", path, ref)
+	}
+
+	// Check the task can be assigned to the destination
+	value := reflect.ValueOf(task)
+	if !value.Type().AssignableTo(dest.Type()) {
+		return ErrBadParameter.Withf("%s: %q cannot be used as %v", path, ref, dest.Type())
+	}
+	dest.Set(value)
+
+	// Return success
+	return nil
+}
+
+// Return the path, prefixed by the position of the value, or the closest
+// enclosing value, when it is known
+func (path fieldPath) String() string {
+	for name := path.name; name != ""; {
+		if pos, exists := path.pos[name]; exists {
+			return pos + ": " + path.name
+		}
+		if i := strings.LastIndexAny(name, types.LabelSeparator+"["); i >= 0 {
+			name = name[:i]
+		} else {
+			break
+		}
+	}
+	return path.name
+}
+
+// Return the path to a field or map key
+func (path fieldPath) Join(key string) fieldPath {
+	return fieldPath{path.name + types.LabelSeparator + key, path.pos}
+}
+
+// Return the path to an array element
+func (path fieldPath) Index(i int) fieldPath {
+	return fieldPath{path.name + "[" + strconv.Itoa(i) + "]", path.pos}
+}
+
+// Return a whole number as an int64
+func toInt(v any) (int64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, true
+		}
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v <= math.MaxInt64 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+// Return a number as a float64
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f, true
+		}
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
@@ -18,6 +18,7 @@ const (
 	_ ProviderContextKey = iota
 	contextLabel
 	contextLogger
+	contextProvider
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -50,3 +51,17 @@ func Logger(ctx context.Context) server.Logger {
 		return nil
 	}
 }
+
+// WithProvider returns a context with the given provider
+func WithProvider(ctx context.Context, provider server.Provider) context.Context {
+	return context.WithValue(ctx, contextProvider, provider)
+}
+
+// Provider returns the provider from the context, or nil if not defined
+func Provider(ctx context.Context) server.Provider {
+	if value, ok := ctx.Value(contextProvider).(server.Provider); ok {
+		return value
+	} else {
+		return nil
+	}
+}
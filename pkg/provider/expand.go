@@ -1,9 +1,20 @@
 package provider
 
-import "regexp"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/types"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
 
 var (
 	reEval = regexp.MustCompile(`\$\{([^\}]+)\}|\$([a-zA-Z0-9_\.]+)`)
+	reExpr = regexp.MustCompile(`\$?\$\{([^\}]*)\}`)
 )
 
 // Expand replaces ${var} or $var in the string based on the mapping function.
@@ -17,3 +28,55 @@ func Expand(s string, mapping func(string) string) string {
 		}
 	})
 }
+
+// expand evaluates ${ expr } expressions in a string using the mapping
+// function. If the string consists of a single expression, then the value
+// is returned as-is. Otherwise, values are interpolated into the string.
+// The sequence $${ is replaced with ${ and is not evaluated. Unlike Expand,
+// $var is not evaluated, as it is commonly used in nginx directives.
+func expand(s string, mapping func(string) (any, error)) (any, error) {
+	matches := reExpr.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+
+	// Return a single expression without interpolation
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) && s[1] == '{' {
+		return mapping(strings.TrimSpace(s[matches[0][2]:matches[0][3]]))
+	}
+
+	// Interpolate values into the string
+	var result strings.Builder
+	var last int
+	for _, match := range matches {
+		result.WriteString(s[last:match[0]])
+		last = match[1]
+
+		// Escaped expression
+		if s[match[0]+1] == '$' {
+			result.WriteString(s[match[0]+1 : match[1]])
+			continue
+		}
+
+		// Evaluate the expression
+		expr := strings.TrimSpace(s[match[2]:match[3]])
+		value, err := mapping(expr)
+		if err != nil {
+			return nil, err
+		}
+		switch value := value.(type) {
+		case nil:
+			// Empty value
+		case types.Label:
+			return nil, ErrBadParameter.Withf("reference %q cannot be used within a string", expr)
+		case string, bool, fmt.Stringer:
+			result.WriteString(fmt.Sprint(value))
+		default:
+			return nil, ErrBadParameter.Withf("%q cannot be used within a string", expr)
+		}
+	}
+	result.WriteString(s[last:])
+
+	// Return the interpolated string
+	return result.String(), nil
+}
@@ -0,0 +1,311 @@
+package hcl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	// Packages
+	hcl "github.com/hashicorp/hcl/v2"
+	hclsyntax "github.com/hashicorp/hcl/v2/hclsyntax"
+	ast "github.com/mutablelogic/go-server/pkg/provider/ast"
+	types "github.com/mutablelogic/go-server/pkg/types"
+	cty "github.com/zclconf/go-cty/cty"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Function which provides a default value for a variable
+	funcDefault = "default"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Read a HCL file and return a root node, which has the same structure as
+// the tree returned by the JSON parser. Each top-level block is a plugin
+// configuration, where the block type and labels are joined to make the
+// label (for example, nginx-handler "main" {} has the label nginx-handler.main).
+// The filename is used when reporting errors.
+func Parse(r io.Reader, filename string) (ast.Node, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the file
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, ErrBadParameter.With(diags.Error())
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, ErrInternalAppError.Withf("%s: unexpected body", filename)
+	}
+
+	// Attributes are not allowed at the top level
+	for _, attr := range sortedAttributes(body) {
+		return nil, ErrBadParameter.Withf("%v: unexpected attribute %q, expected a block", attr.NameRange, attr.Name)
+	}
+
+	// Add blocks to the root node
+	root := ast.NewMapNode(nil)
+	for _, block := range body.Blocks {
+		label := types.NewLabel(block.Type, block.Labels...)
+		if label == "" {
+			return nil, ErrBadParameter.Withf("%v: invalid label %q", block.DefRange(), strings.Join(append([]string{block.Type}, block.Labels...), types.LabelSeparator))
+		}
+		child, err := ast.NewMapValueNode(root, string(label))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", block.DefRange(), err)
+		}
+		child.SetPos(block.DefRange().String())
+		if err := parseBody(root.Append(child).Append(ast.NewMapNode(child)), src, block.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	// Return success
+	return root, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Add attributes and blocks from a body to a map node. Blocks with labels
+// become nested maps, keyed by the block type and then by each label
+func parseBody(node ast.Node, src []byte, body *hclsyntax.Body) error {
+	// Attributes
+	for _, attr := range sortedAttributes(body) {
+		child, err := ast.NewMapValueNode(node, attr.Name)
+		if err != nil {
+			return fmt.Errorf("%v: %w", attr.NameRange, err)
+		}
+		child.SetPos(attr.SrcRange.String())
+		if err := parseExpr(node.Append(child), src, attr.Expr); err != nil {
+			return err
+		}
+	}
+
+	// Blocks
+	for _, block := range body.Blocks {
+		var exists bool
+		parent := node
+		for _, key := range append([]string{block.Type}, block.Labels...) {
+			if child := mapWithKey(parent, key); child != nil {
+				parent, exists = child, true
+			} else if child, err := ast.NewMapValueNode(parent, key); err != nil {
+				return fmt.Errorf("%v: %w", block.DefRange(), err)
+			} else {
+				child.SetPos(block.DefRange().String())
+				parent, exists = parent.Append(child).Append(ast.NewMapNode(child)), false
+			}
+		}
+		if exists {
+			return ErrDuplicateEntry.Withf("%v: duplicate block %q", block.DefRange(), strings.Join(append([]string{block.Type}, block.Labels...), " "))
+		}
+		if err := parseBody(parent, src, block.Body); err != nil {
+			return err
+		}
+	}
+
+	// Return success
+	return nil
+}
+
+// Append an expression to a node
+func parseExpr(node ast.Node, src []byte, expr hclsyntax.Expression) error {
+	switch expr := expr.(type) {
+	case *hclsyntax.LiteralValueExpr:
+		if value, err := literal(expr.Val, expr.SrcRange); err != nil {
+			return err
+		} else {
+			node.Append(ast.NewValueNode(node, value))
+		}
+	case *hclsyntax.UnaryOpExpr:
+		// Negative numbers
+		operand, ok := expr.Val.(*hclsyntax.LiteralValueExpr)
+		if !ok || expr.Op != hclsyntax.OpNegate || operand.Val.Type() != cty.Number {
+			return ErrBadParameter.Withf("%v: unsupported expression", expr.SrcRange)
+		}
+		if value, err := literal(operand.Val.Negate(), expr.SrcRange); err != nil {
+			return err
+		} else {
+			node.Append(ast.NewValueNode(node, value))
+		}
+	case *hclsyntax.TemplateExpr, *hclsyntax.TemplateWrapExpr, *hclsyntax.ScopeTraversalExpr, *hclsyntax.FunctionCallExpr:
+		if value, err := template(src, expr); err != nil {
+			return err
+		} else {
+			node.Append(ast.NewValueNode(node, value))
+		}
+	case *hclsyntax.TupleConsExpr:
+		array := node.Append(ast.NewArrayNode(node))
+		for _, expr := range expr.Exprs {
+			if err := parseExpr(array, src, expr); err != nil {
+				return err
+			}
+		}
+	case *hclsyntax.ObjectConsExpr:
+		object := node.Append(ast.NewMapNode(node))
+		for _, item := range expr.Items {
+			key, err := objectKey(item.KeyExpr)
+			if err != nil {
+				return err
+			}
+			child, err := ast.NewMapValueNode(object, key)
+			if err != nil {
+				return fmt.Errorf("%v: %w", item.KeyExpr.Range(), err)
+			}
+			child.SetPos(hcl.RangeBetween(item.KeyExpr.Range(), item.ValueExpr.Range()).String())
+			if err := parseExpr(object.Append(child), src, item.ValueExpr); err != nil {
+				return err
+			}
+		}
+	default:
+		return ErrBadParameter.Withf("%v: unsupported expression", expr.Range())
+	}
+
+	// Return success
+	return nil
+}
+
+// Return a template as a string, with variables and references in the
+// form ${ expr }, which are evaluated when the configuration is bound
+func template(src []byte, expr hclsyntax.Expression) (string, error) {
+	switch expr := expr.(type) {
+	case *hclsyntax.LiteralValueExpr:
+		if expr.Val.Type() != cty.String || expr.Val.IsNull() {
+			return "", ErrBadParameter.Withf("%v: expected string", expr.SrcRange)
+		}
+		// Escape any expressions in the literal
+		return strings.ReplaceAll(expr.Val.AsString(), "${", "$${"), nil
+	case *hclsyntax.TemplateExpr:
+		var result strings.Builder
+		for _, part := range expr.Parts {
+			if value, err := template(src, part); err != nil {
+				return "", err
+			} else {
+				result.WriteString(value)
+			}
+		}
+		return result.String(), nil
+	case *hclsyntax.TemplateWrapExpr:
+		return template(src, expr.Wrapped)
+	case *hclsyntax.ScopeTraversalExpr:
+		if path, err := traversal(expr); err != nil {
+			return "", err
+		} else {
+			return "${ " + path + " }", nil
+		}
+	case *hclsyntax.FunctionCallExpr:
+		// default(var.name, "value") provides a default value
+		if expr.Name != funcDefault || len(expr.Args) != 2 || expr.ExpandFinal {
+			return "", ErrBadParameter.Withf("%v: unsupported function %q", expr.NameRange, expr.Name)
+		}
+		ref, ok := expr.Args[0].(*hclsyntax.ScopeTraversalExpr)
+		if !ok {
+			return "", ErrBadParameter.Withf("%v: expected variable", expr.Args[0].Range())
+		}
+		path, err := traversal(ref)
+		if err != nil {
+			return "", err
+		}
+		value, ok := expr.Args[1].(*hclsyntax.LiteralValueExpr)
+		if !ok {
+			if template, isTemplate := expr.Args[1].(*hclsyntax.TemplateExpr); isTemplate && template.IsStringLiteral() {
+				value, ok = template.Parts[0].(*hclsyntax.LiteralValueExpr)
+			}
+		}
+		if !ok || value.Val.IsNull() {
+			return "", ErrBadParameter.Withf("%v: expected literal value", expr.Args[1].Range())
+		}
+		if value, err := literal(value.Val, value.SrcRange); err != nil {
+			return "", err
+		} else if str := fmt.Sprint(value); strings.ContainsAny(str, "{}") {
+			return "", ErrBadParameter.Withf("%v: invalid default value", expr.Args[1].Range())
+		} else {
+			return "${ " + path + " :- " + str + " }", nil
+		}
+	default:
+		return "", ErrBadParameter.Withf("%v: unsupported expression", expr.Range())
+	}
+}
+
+// Return a traversal (for example, var.name) as a string
+func traversal(expr *hclsyntax.ScopeTraversalExpr) (string, error) {
+	parts := make([]string, 0, len(expr.Traversal))
+	for _, step := range expr.Traversal {
+		switch step := step.(type) {
+		case hcl.TraverseRoot:
+			parts = append(parts, step.Name)
+		case hcl.TraverseAttr:
+			parts = append(parts, step.Name)
+		default:
+			return "", ErrBadParameter.Withf("%v: unsupported traversal", step.SourceRange())
+		}
+	}
+	return strings.Join(parts, types.LabelSeparator), nil
+}
+
+// Return a literal value
+func literal(value cty.Value, rng hcl.Range) (any, error) {
+	if value.IsNull() {
+		return nil, nil
+	}
+	switch value.Type() {
+	case cty.String:
+		return value.AsString(), nil
+	case cty.Bool:
+		return value.True(), nil
+	case cty.Number:
+		return json.Number(value.AsBigFloat().Text('g', -1)), nil
+	default:
+		return nil, ErrBadParameter.Withf("%v: unsupported value", rng)
+	}
+}
+
+// Return the key for an object item
+func objectKey(expr hclsyntax.Expression) (string, error) {
+	if key, ok := expr.(*hclsyntax.ObjectConsKeyExpr); ok {
+		if keyword := hcl.ExprAsKeyword(key.Wrapped); keyword != "" && !key.ForceNonLiteral {
+			return keyword, nil
+		}
+		expr = key.Wrapped
+	}
+	if template, ok := expr.(*hclsyntax.TemplateExpr); ok && template.IsStringLiteral() {
+		if value, ok := template.Parts[0].(*hclsyntax.LiteralValueExpr); ok {
+			return value.Val.AsString(), nil
+		}
+	}
+	return "", ErrBadParameter.Withf("%v: unsupported key", expr.Range())
+}
+
+// Return a map node which is the value for a key, or nil
+func mapWithKey(node ast.Node, key string) ast.Node {
+	for _, child := range node.Children() {
+		if child.Key() == key && len(child.Children()) == 1 && child.Children()[0].Type() == ast.Map {
+			return child.Children()[0]
+		}
+	}
+	return nil
+}
+
+// Return attributes in the order they appear in the file
+func sortedAttributes(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	result := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		result = append(result, attr)
+	}
+	slices.SortFunc(result, func(a, b *hclsyntax.Attribute) int {
+		return a.SrcRange.Start.Byte - b.SrcRange.Start.Byte
+	})
+	return result
+}
@@ -0,0 +1,55 @@
+package hcl_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/provider/hcl"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parser_001(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := os.Open("../../../etc/hcl/nginx-proxy.hcl")
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	defer r.Close()
+
+	tree, err := hcl.Parse(r, r.Name())
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.NotNil(tree)
+
+	// Check the labels
+	var labels []string
+	for _, child := range tree.Children() {
+		labels = append(labels, child.Key())
+	}
+	assert.Equal([]string{"var", "logger.main", "nginx-handler.main", "tokenjar-handler.main", "auth-handler.main", "router.main", "httpserver"}, labels)
+	t.Log(tree)
+}
+
+func Test_parser_002(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		src string
+		err string
+	}{
+		{`a = 1`, "test.hcl:1,1-2"},
+		{`plugin { a = 1 + 2 }`, "test.hcl:1,14-19"},
+		{`plugin { a = upper("x") }`, "test.hcl:1,14-19"},
+		{"plugin {\n tls {}\n tls {}\n}", "test.hcl:3,2-5"},
+		{"plugin \"a\" {}\nplugin \"a\" {}", "test.hcl:2,1-11"},
+		{`plugin {`, "test.hcl:1"},
+	}
+	for _, test := range tests {
+		_, err := hcl.Parse(strings.NewReader(test.src), "test.hcl")
+		assert.ErrorContains(err, test.err, test.src)
+	}
+}
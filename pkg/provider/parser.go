@@ -4,11 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 
 	// Packages
 	"github.com/mutablelogic/go-server"
 	"github.com/mutablelogic/go-server/pkg/provider/ast"
 	"github.com/mutablelogic/go-server/pkg/provider/dep"
+	"github.com/mutablelogic/go-server/pkg/provider/hcl"
 	"github.com/mutablelogic/go-server/pkg/provider/json"
 	"github.com/mutablelogic/go-server/pkg/types"
 
@@ -19,8 +24,22 @@ import (
 type Parser struct {
 	plugin  map[string]server.Plugin
 	configs map[types.Label]ast.Node
+	vars    *variables
+
+	// Positions of values in the files they were parsed from, keyed by path
+	pos map[string]string
+
+	// Evaluated values and dependencies for each label, and the order in
+	// which tasks should be created
+	values map[types.Label]any
+	deps   map[types.Label][]types.Label
+	order  []types.Label
 }
 
+// ResolveFunc returns the task for a label, when a configuration references
+// another configuration
+type ResolveFunc func(types.Label) (server.Task, error)
+
 ////////////////////////////////////////////////////////////////////////////////
 // LIFECYCLE
 
@@ -28,10 +47,12 @@ func NewParser(plugins ...server.Plugin) (*Parser, error) {
 	parser := new(Parser)
 	parser.plugin = make(map[string]server.Plugin, len(plugins))
 	parser.configs = make(map[types.Label]ast.Node, len(plugins)*2)
+	parser.vars = NewVariables()
+	parser.pos = make(map[string]string)
 
 	for _, plugin := range plugins {
 		name := plugin.Name()
-		if name == labelVar {
+		if name == labelVar || name == labelEnv {
 			return nil, ErrBadParameter.Withf("plugin cannot be named %q", name)
 		} else if _, exists := parser.plugin[name]; exists {
 			return nil, ErrDuplicateEntry.Withf("plugin %q already exists", plugin.Name())
@@ -49,14 +70,176 @@ func NewParser(plugins ...server.Plugin) (*Parser, error) {
 
 // Append configurations from a JSON file
 func (p *Parser) ParseJSON(r io.Reader) error {
-	var result error
-
 	// Parse JSON into a tree
 	tree, err := json.Parse(r, nil)
 	if err != nil {
 		return err
 	}
 
+	// Append the configurations
+	return p.parse(tree)
+}
+
+// Append configurations from a HCL file. The name of the file is used
+// when reporting errors, if the reader has a Name method (for example,
+// an *os.File)
+func (p *Parser) ParseHCL(r io.Reader) error {
+	var filename string
+	if r, ok := r.(interface{ Name() string }); ok {
+		filename = r.Name()
+	}
+
+	// Parse HCL into a tree
+	tree, err := hcl.Parse(r, filename)
+	if err != nil {
+		return err
+	}
+
+	// Append the configurations
+	return p.parse(tree)
+}
+
+// Bind evaluates the configurations, and resolves the dependencies between
+// them. It returns an error if there is a circular dependency
+func (p *Parser) Bind() error {
+	// Create a dependency graph and a root node, which has an empty label
+	graph := dep.NewGraph()
+	root := types.Label("")
+
+	// Evaluate each value, and create dependencies
+	ctx := ast.NewContext(p.eval)
+	p.vars.Reset()
+
+	// Sort the labels so that the order of evaluation is consistent
+	labels := make([]types.Label, 0, len(p.configs))
+	for label := range p.configs {
+		labels = append(labels, label)
+	}
+	slices.Sort(labels)
+
+	// Traverse the nodes to evaluate values and create a dependency graph
+	var result error
+	p.deps = make(map[types.Label][]types.Label, len(labels))
+	p.values = make(map[types.Label]any, len(labels))
+	for _, label := range labels {
+		ctx.SetLabel(label)
+		if value, err := p.configs[label].Value(ctx); err != nil {
+			result = errors.Join(result, err)
+		} else {
+			p.values[label] = value
+		}
+		graph.AddNode(root, label)
+		for _, dep := range p.deps[label] {
+			graph.AddNode(label, dep)
+		}
+	}
+	if result != nil {
+		return result
+	}
+
+	// Resolve the dependency graph
+	resolved, err := graph.Resolve(root)
+	if err != nil {
+		return err
+	}
+
+	// Set the order in which tasks should be created, excluding the root node
+	p.order = make([]types.Label, 0, len(resolved))
+	for _, n := range resolved {
+		if label := n.(types.Label); label != root {
+			p.order = append(p.order, label)
+		}
+	}
+
+	// Return success
+	return nil
+}
+
+// Return the labels of the configurations, in the order in which tasks should
+// be created. Bind should be called before this method
+func (p *Parser) Labels() []types.Label {
+	return p.order
+}
+
+// Return the labels which a configuration depends on
+func (p *Parser) Deps(label types.Label) []types.Label {
+	return p.deps[label]
+}
+
+// Return true if the configuration for a label is the same in both parsers.
+// Bind should be called on both parsers before this method
+func (p *Parser) Equals(other *Parser, label types.Label) bool {
+	a, exists := p.values[label]
+	if !exists {
+		return false
+	}
+	b, exists := other.values[label]
+	if !exists {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Create a new task for a label, using the resolve function to return tasks
+// for any references in the configuration. Bind should be called before this
+// method
+func (p *Parser) New(label types.Label, resolve ResolveFunc) (server.Task, error) {
+	value, exists := p.values[label]
+	if !exists {
+		return nil, ErrNotFound.Withf("%q", label)
+	}
+	plugin, exists := p.plugin[label.Prefix()]
+	if !exists {
+		return nil, ErrNotFound.Withf("Plugin %q", label.Prefix())
+	}
+
+	// Make a new configuration from a copy of the plugin, and set the values
+	config := reflect.New(typeOf(plugin))
+	config.Elem().Set(reflect.Indirect(reflect.ValueOf(plugin)))
+	if err := decode(label, config, value, p.pos, resolve, false); err != nil {
+		return nil, err
+	}
+
+	// Create the task
+	return config.Interface().(server.Plugin).New()
+}
+
+// Validate checks each configuration without creating any tasks, and returns
+// any type errors, unknown fields, missing required fields and undefined
+// references. Bind should be called before this method
+func (p *Parser) Validate() error {
+	var result error
+	for _, label := range p.order {
+		plugin, exists := p.plugin[label.Prefix()]
+		if !exists {
+			result = errors.Join(result, ErrNotFound.Withf("Plugin %q", label.Prefix()))
+			continue
+		}
+
+		// Decode into a copy of the plugin, checking references exist
+		config := reflect.New(typeOf(plugin))
+		config.Elem().Set(reflect.Indirect(reflect.ValueOf(plugin)))
+		if err := decode(label, config, p.values[label], p.pos, func(ref types.Label) (server.Task, error) {
+			if _, exists := p.values[ref]; !exists {
+				return nil, ErrNotFound.Withf("%s: undefined reference %q", label, ref)
+			}
+			return nil, nil
+		}, true); err != nil {
+			result = errors.Join(result, err)
+		}
+	}
+
+	// Return any errors
+	return result
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Append configurations from a tree
+func (p *Parser) parse(tree ast.Node) error {
+	var result error
+
 	// Get the plugin configurations with no evaluation
 	configs := tree.Children()
 	for _, config := range configs {
@@ -68,7 +251,9 @@ func (p *Parser) ParseJSON(r io.Reader) error {
 		// Handle var block
 		label := config.Key()
 		if label == labelVar {
-			fmt.Println("TODO", label, config)
+			if err := p.vars.Add(config); err != nil {
+				result = errors.Join(result, err)
+			}
 			continue
 		}
 
@@ -81,6 +266,7 @@ func (p *Parser) ParseJSON(r io.Reader) error {
 			result = errors.Join(result, ErrNotFound.Withf("Plugin %q", label.Prefix()))
 		} else {
 			p.configs[label] = config
+			p.setPos(string(label), config)
 		}
 	}
 
@@ -88,71 +274,88 @@ func (p *Parser) ParseJSON(r io.Reader) error {
 	return result
 }
 
-type node struct {
-	label types.Label
-	path  types.Label
+// Record the positions of a node and its children, keyed by path
+func (p *Parser) setPos(path string, node ast.Node) {
+	if pos, ok := node.(ast.Positioner); ok && pos.Pos() != "" {
+		p.pos[path] = pos.Pos()
+	}
+	for i, child := range node.Children() {
+		switch node.Type() {
+		case ast.Map:
+			p.setPos(path+types.LabelSeparator+child.Key(), child)
+		case ast.Array:
+			p.setPos(path+"["+strconv.Itoa(i)+"]", child)
+		default:
+			p.setPos(path, child)
+		}
+	}
 }
 
-// Bind values
-func (p *Parser) Bind() error {
-	// Create a dependency graph and a root node
-	dep := dep.NewGraph()
-	root := &node{}
+// Evaluate a value, expanding any variables and recording any references
+// to other configurations as dependencies
+func (p *Parser) eval(ctx *ast.Context, value any) (any, error) {
+	switch value := value.(type) {
+	case string:
+		return expand(value, func(expr string) (any, error) {
+			return p.resolve(ctx, expr)
+		})
+	default:
+		return value, nil
+	}
+}
 
-	// Evaluate each value, and create dependencies
-	ctx := ast.NewContext(func(ctx *ast.Context, value any) (any, error) {
-		if node, others, err := eval(ctx, value); err != nil {
-			return nil, err
+// Resolve an expression, which is a variable, an environment variable or
+// a reference to another configuration. Variables and environment variables
+// can have a default value, which follows a :- separator
+func (p *Parser) resolve(ctx *ast.Context, expr string) (any, error) {
+	label := ctx.Label()
+	path := ctx.Path()
+	name, value, hasDefault := splitDefault(expr)
+	switch {
+	case strings.HasPrefix(name, labelEnvPrefix):
+		if env, exists := env(strings.TrimPrefix(name, labelEnvPrefix)); exists {
+			return env, nil
+		} else if hasDefault {
+			return value, nil
 		} else {
-			dep.AddNode(root, node)
-			dep.AddNode(node, others...)
+			return nil, ErrNotFound.Withf("%s%s: undefined environment variable %q", label, path, name)
 		}
-		return nil, nil
-	})
-
-	// Traverse the nodes to create a dependency graph
-	var result error
-	for label, config := range p.configs {
-		ctx.SetLabel(label)
-		if _, err := config.Value(ctx); err != nil {
-			result = errors.Join(result, err)
+	case strings.HasPrefix(name, labelVarPrefix):
+		if v, err := p.vars.Get(strings.TrimPrefix(name, labelVarPrefix), p.eval); err == nil {
+			return v, nil
+		} else if hasDefault && errors.Is(err, ErrNotFound) {
+			return value, nil
+		} else {
+			return nil, fmt.Errorf("%s%s: %w", label, path, err)
 		}
 	}
-	if result != nil {
-		return result
-	}
 
-	// Resolve the dependency graph
-	resolved, err := dep.Resolve(root)
-	if err != nil {
-		return err
+	// Reference to another configuration
+	ref, exists := p.reference(name)
+	if !exists {
+		return nil, ErrNotFound.Withf("%s%s: undefined reference %q", label, path, name)
+	} else if ref == label {
+		return nil, ErrBadParameter.Withf("%s%s: %q cannot reference itself", label, path, ref)
+	} else if strings.HasPrefix(string(label), labelVarPrefix) {
+		return nil, ErrBadParameter.Withf("%s%s: variables cannot reference %q", label, path, ref)
 	}
 
-	// Print out the resolved nodes
-	for _, n := range resolved {
-		fmt.Println(n.(*node).label, n.(*node).path, "=>")
+	// Add the dependency
+	if !slices.Contains(p.deps[label], ref) {
+		p.deps[label] = append(p.deps[label], ref)
 	}
 
-	// Return any errors
-	return result
+	// Return the reference
+	return ref, nil
 }
 
-////////////////////////////////////////////////////////////////////////////////
-// PRIVATE METHODS
-
-// evaluate value
-func eval(ctx *ast.Context, value any) (dep.Node, []dep.Node, error) {
-	label := ctx.Label()
-	path := ctx.Path()
-	switch value := value.(type) {
-	case string:
-		result := []dep.Node{}
-		Expand(value, func(label string) string {
-			fmt.Println("label", label)
-			return ""
-		})
-		return &node{label, path}, result, nil
-	default:
-		return &node{label, path}, nil, nil
+// Return a reference to another configuration, if it exists
+func (p *Parser) reference(name string) (types.Label, bool) {
+	if label, err := types.ParseLabel(name); err != nil {
+		return "", false
+	} else if _, exists := p.configs[label]; !exists {
+		return "", false
+	} else {
+		return label, true
 	}
 }
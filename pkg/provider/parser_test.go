@@ -2,9 +2,12 @@ package provider_test
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	// Packages
+	server "github.com/mutablelogic/go-server"
 	"github.com/mutablelogic/go-server/pkg/handler/auth"
 	"github.com/mutablelogic/go-server/pkg/handler/logger"
 	"github.com/mutablelogic/go-server/pkg/handler/nginx"
@@ -12,7 +15,11 @@ import (
 	"github.com/mutablelogic/go-server/pkg/handler/tokenjar"
 	"github.com/mutablelogic/go-server/pkg/httpserver"
 	"github.com/mutablelogic/go-server/pkg/provider"
+	"github.com/mutablelogic/go-server/pkg/types"
 	"github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
 )
 
 func Test_parser_001(t *testing.T) {
@@ -42,3 +49,186 @@ func Test_parser_001(t *testing.T) {
 		t.SkipNow()
 	}
 }
+
+func Test_parser_002(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("TEST_PARSER_002", "env")
+
+	// Parse and bind a configuration, and return the value for test.a
+	value := func(config string) (string, error) {
+		parser, err := provider.NewParser(testConfig{})
+		if err != nil {
+			return "", err
+		} else if err := parser.ParseJSON(strings.NewReader(config)); err != nil {
+			return "", err
+		} else if err := parser.Bind(); err != nil {
+			return "", err
+		} else if task, err := parser.New("test.a", nil); err != nil {
+			return "", err
+		} else {
+			return task.(*testTask).Value, nil
+		}
+	}
+
+	t.Run("Var", func(t *testing.T) {
+		v, err := value(`{ "var": { "a": "var" }, "test.a": { "value": "${ var.a }" } }`)
+		assert.NoError(err)
+		assert.Equal("var", v)
+	})
+	t.Run("VarOfVar", func(t *testing.T) {
+		v, err := value(`{ "var": { "a": "${var.b}-a", "b": "b" }, "test.a": { "value": "${ var.a }" } }`)
+		assert.NoError(err)
+		assert.Equal("b-a", v)
+	})
+	t.Run("Env", func(t *testing.T) {
+		v, err := value(`{ "test.a": { "value": "${ env.TEST_PARSER_002 }" } }`)
+		assert.NoError(err)
+		assert.Equal("env", v)
+	})
+	t.Run("EnvDefault", func(t *testing.T) {
+		v, err := value(`{ "test.a": { "value": "${ env.TEST_PARSER_002_UNDEFINED :- default }" } }`)
+		assert.NoError(err)
+		assert.Equal("default", v)
+	})
+	t.Run("Interpolation", func(t *testing.T) {
+		v, err := value(`{ "var": { "n": 1 }, "test.a": { "value": "${env.TEST_PARSER_002}/${var.n}/$${var.n}/$remote_addr" } }`)
+		assert.NoError(err)
+		assert.Equal("env/1/${var.n}/$remote_addr", v)
+	})
+	t.Run("UndefinedVar", func(t *testing.T) {
+		_, err := value(`{ "test.a": { "value": "${ var.a }" } }`)
+		assert.ErrorContains(err, "var.a")
+	})
+	t.Run("UndefinedEnv", func(t *testing.T) {
+		_, err := value(`{ "test.a": { "value": "${ env.TEST_PARSER_002_UNDEFINED }" } }`)
+		assert.ErrorContains(err, "env.TEST_PARSER_002_UNDEFINED")
+	})
+	t.Run("CircularVar", func(t *testing.T) {
+		_, err := value(`{ "var": { "a": "${var.b}", "b": "${var.a}" }, "test.a": { "value": "${ var.a }" } }`)
+		assert.ErrorContains(err, "circular")
+	})
+}
+
+func Test_parser_003(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := os.Open("../../etc/hcl/nginx-proxy.hcl")
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+	defer r.Close()
+
+	// Make a parser
+	parser, err := provider.NewParser(
+		logger.Config{}, nginx.Config{}, tokenjar.Config{}, auth.Config{}, router.Config{}, httpserver.Config{},
+	)
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Parse HCL file
+	if err := parser.ParseHCL(r); !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	// Bind
+	if err := parser.Bind(); !assert.NoError(err) {
+		t.SkipNow()
+	}
+	assert.ElementsMatch([]string{"auth-handler.main", "httpserver", "logger.main", "nginx-handler.main", "router.main", "tokenjar-handler.main"}, labels(parser.Labels()))
+}
+
+func Test_parser_004(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("TEST_PARSER_004", "env")
+
+	// Parse and bind a configuration, and return the value for test.a
+	value := func(config string) (string, error) {
+		parser, err := provider.NewParser(testConfig{})
+		if err != nil {
+			return "", err
+		} else if err := parser.ParseHCL(strings.NewReader(config)); err != nil {
+			return "", err
+		} else if err := parser.Bind(); err != nil {
+			return "", err
+		} else if task, err := parser.New("test.a", nil); err != nil {
+			return "", err
+		} else {
+			return task.(*testTask).Value, nil
+		}
+	}
+
+	t.Run("Literal", func(t *testing.T) {
+		v, err := value(`test "a" { value = "literal" }`)
+		assert.NoError(err)
+		assert.Equal("literal", v)
+	})
+	t.Run("Var", func(t *testing.T) {
+		v, err := value("var { a = \"var\" }\ntest \"a\" { value = var.a }")
+		assert.NoError(err)
+		assert.Equal("var", v)
+	})
+	t.Run("EnvDefault", func(t *testing.T) {
+		v, err := value(`test "a" { value = default(env.TEST_PARSER_004_UNDEFINED, "default") }`)
+		assert.NoError(err)
+		assert.Equal("default", v)
+	})
+	t.Run("Interpolation", func(t *testing.T) {
+		v, err := value("var { n = 1 }\ntest \"a\" { value = \"${env.TEST_PARSER_004}/${var.n}/$${var.n}/$remote_addr\" }")
+		assert.NoError(err)
+		assert.Equal("env/1/${var.n}/$remote_addr", v)
+	})
+	t.Run("UndefinedVar", func(t *testing.T) {
+		_, err := value(`test "a" { value = var.a }`)
+		assert.ErrorContains(err, "var.a")
+	})
+}
+
+func Test_parser_005(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "test.hcl")
+
+	// Decode errors refer to the file and line of the attribute
+	assert.NoError(os.WriteFile(path, []byte("test \"a\" {\n  value = true\n}\n"), 0600))
+	r, err := os.Open(path)
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	defer r.Close()
+	parser, err := provider.NewParser(testConfig{})
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	assert.NoError(parser.ParseHCL(r))
+	assert.NoError(parser.Bind())
+	_, err = parser.New("test.a", nil)
+	assert.ErrorContains(err, path+":2,3-15: test.a.value: expected string")
+	assert.ErrorContains(parser.Validate(), path+":2,3-15")
+}
+
+func Test_parser_006(t *testing.T) {
+	assert := assert.New(t)
+	parser, err := provider.NewParser(testConfig{})
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	assert.NoError(parser.ParseJSON(strings.NewReader(`{ "test.a": {}, "test.b": { "ref": "${ test.a }" } }`)))
+	assert.NoError(parser.Bind())
+
+	// A reference which resolves to no task is checked when validating,
+	// but is not found when creating the task
+	assert.NoError(parser.Validate())
+	_, err = parser.New("test.b", func(types.Label) (server.Task, error) {
+		return nil, nil
+	})
+	assert.ErrorIs(err, ErrNotFound)
+	assert.ErrorContains(err, `test.b.ref: "test.a"`)
+}
+
+func labels(v []types.Label) []string {
+	result := make([]string, 0, len(v))
+	for _, label := range v {
+		result = append(result, string(label))
+	}
+	return result
+}
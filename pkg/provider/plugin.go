@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"plugin"
 	"reflect"
+	"slices"
 	"strings"
 
 	// Packages
@@ -106,6 +107,18 @@ func (p *pluginProvider) LoadPluginsForPattern(pattern string) error {
 	return nil
 }
 
+// Return all the plugins, sorted by name
+func (p *pluginProvider) Plugins() []server.Plugin {
+	result := make([]server.Plugin, 0, len(p.plugins))
+	for _, plugin := range p.plugins {
+		result = append(result, plugin.plugin)
+	}
+	slices.SortFunc(result, func(a, b server.Plugin) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return result
+}
+
 // Create a configuration object for a plugin with label parts
 func (p *pluginProvider) New(name string, suffix ...string) (server.Plugin, error) {
 	// Get the plugin
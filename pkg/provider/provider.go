@@ -4,11 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"slices"
 	"sync"
 
 	// Packages
 	server "github.com/mutablelogic/go-server"
+	types "github.com/mutablelogic/go-server/pkg/types"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
 )
 
 ////////////////////////////////////////////////////////////////////////////
@@ -16,11 +22,33 @@ import (
 
 // provider implements the server.Provider interface which runs a set of tasks
 type provider struct {
+	sync.Mutex
+
 	// All the tasks in order, with their current state
-	tasks []state
+	tasks []*state
 
 	// All the loggers
-	loggers []server.Logger
+	loggers    []server.Logger
+	loggerLock sync.RWMutex
+
+	// All the collectors, from the tasks and those which have been
+	// registered
+	collectors    []collector
+	registered    []server.Collector
+	collectorLock sync.RWMutex
+
+	// Function to load the configuration, and the configuration which
+	// the tasks were created from
+	load   LoadFunc
+	parser *Parser
+
+	// Context and cancel function for running tasks, and any errors
+	// returned by tasks
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	result     error
+	resultLock sync.Mutex
 }
 
 // state is the state of a task
@@ -33,8 +61,25 @@ type state struct {
 	Label   string
 }
 
-// Ensure that provider implements the server.Provider interface
+// replacement is a task which has been replaced in a running task
+type replacement struct {
+	server.Replacer
+	from, to server.Task
+}
+
+// collector is a task which is a collector, and the label of the task
+type collector struct {
+	server.Collector
+	Label string
+}
+
+// LoadFunc returns a configuration which has been parsed and bound
+type LoadFunc func() (*Parser, error)
+
+// Ensure that provider implements the server.Provider and
+// server.Registry interfaces
 var _ server.Provider = (*provider)(nil)
+var _ server.Registry = (*provider)(nil)
 
 ////////////////////////////////////////////////////////////////////////////
 // GLOBALS
@@ -52,58 +97,51 @@ func NewProvider(tasks ...server.Task) server.Provider {
 
 	// Enumerate all the tasks
 	for _, task := range tasks {
-		p.tasks = append(p.tasks, state{Task: task, Label: task.Label()})
-
-		// If it's a logger, then add to the list of loggers
-		if logger, ok := task.(server.Logger); ok {
-			p.loggers = append(p.loggers, logger)
-		}
+		p.tasks = append(p.tasks, &state{Task: task, Label: task.Label()})
 	}
 
+	// Set the loggers and collectors
+	p.setLoggers()
+	p.setCollectors()
+
 	// Return success
 	return p
 }
 
+// Create a new provider which creates tasks from a configuration. The load
+// function is called when the provider is run, and again each time the
+// configuration is reloaded.
+func NewProviderWithLoader(fn LoadFunc) *provider {
+	p := new(provider)
+	p.load = fn
+	return p
+}
+
 ////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS - TASK
 
 // Run all the tasks in parallel, and cancel them all in the reverse order
 // when the context is cancelled.
 func (p *provider) Run(ctx context.Context) error {
-	var result error
-	var wg sync.WaitGroup
-
 	// Create a child context which will allow us to cancel all the tasks
 	// prematurely if any of them fail
 	child, prematureCancel := context.WithCancel(ctx)
 	defer prematureCancel()
 
 	// Run all the tasks in parallel
-	for i := range p.tasks {
-		// Create a context for each task
-		ctx, cancel := context.WithCancel(WithLabel(context.Background(), p.tasks[i].Label))
-		ctx = WithLogger(ctx, p)
-
-		// Set the context and cancel function
-		p.tasks[i].Context = ctx
-		p.tasks[i].Cancel = cancel
-		p.tasks[i].Add(1)
-
-		// Run the task in a goroutine
-		wg.Add(1)
-		go func(i int) {
-			defer p.tasks[i].Done()
-			defer p.tasks[i].Cancel()
-			defer wg.Done()
-
-			p.Print(ctx, "Running")
-			if err := p.tasks[i].Run(ctx); err != nil {
-				result = errors.Join(result, fmt.Errorf("[%s] %w", Label(ctx), err))
+	p.Lock()
+	p.ctx, p.cancel = child, prematureCancel
+	for _, task := range p.tasks {
+		p.start(task)
+	}
+	p.Unlock()
 
-				// We indicate we should cancel
-				prematureCancel()
-			}
-		}(i)
+	// Create tasks from the configuration
+	if p.load != nil {
+		if err := p.Reload(child); err != nil {
+			p.setError(err)
+			prematureCancel()
+		}
 	}
 
 	// Wait for the cancel
@@ -111,32 +149,146 @@ func (p *provider) Run(ctx context.Context) error {
 
 	// Cancel all the tasks in reverse order, waiting for each to complete
 	// before cancelling the next
+	p.Lock()
 	for i := len(p.tasks) - 1; i >= 0; i-- {
-		p.Print(p.tasks[i].Context, "Stopping")
-		p.tasks[i].Cancel()
-		p.tasks[i].Wait()
-
-		// TODO: If the task is in the set of loggers, then remove it
-		// from the list of loggers
+		p.stop(p.tasks[i])
 	}
+	p.ctx, p.cancel = nil, nil
+	p.Unlock()
 
 	// Wait for all the tasks to complete
-	wg.Wait()
+	p.wg.Wait()
 
 	// Return any errors
-	return result
+	return p.result
 }
 
 func (p *provider) Label() string {
 	return defaultName
 }
 
+// Reload the configuration while the provider is running. Tasks are created
+// for any new configurations, and tasks are stopped for any configurations
+// which have been removed. Tasks are restarted when their configuration, or
+// any configuration they depend on, has changed, unless they can replace the
+// tasks they depend on while running. All other tasks continue running. If
+// any new task cannot be created, then no tasks are stopped, the tasks which
+// were replaced are restored, and the new tasks which were created are
+// closed.
+func (p *provider) Reload(ctx context.Context) error {
+	p.Lock()
+	defer p.Unlock()
+
+	// Check for a loader, and that the provider is running
+	if p.load == nil {
+		return ErrNotImplemented.With("no configuration loader")
+	} else if p.ctx == nil {
+		return ErrOutOfOrder.With("provider is not running")
+	}
+	ctx = WithLabel(ctx, defaultName)
+
+	// Load the configuration
+	parser, err := p.load()
+	if err != nil {
+		return err
+	}
+
+	// Determine which configurations have changed
+	labels := parser.Labels()
+	changed := make(map[types.Label]bool, len(labels))
+	for _, label := range labels {
+		if p.parser == nil || !p.parser.Equals(parser, label) {
+			changed[label] = true
+		}
+	}
+
+	// Create new tasks for configurations which have changed, or which depend
+	// on tasks which have changed, resolving references to either new or
+	// running tasks. As the labels are in dependency order, the dependencies
+	// are always created first, and are replaced in running tasks which
+	// support it
+	running := make(map[string]*state, len(p.tasks))
+	for _, task := range p.tasks {
+		running[task.Label] = task
+	}
+	tasks := make(map[types.Label]server.Task, len(changed))
+	var replaced []replacement
+	for _, label := range labels {
+		if !changed[label] {
+			var deps []types.Label
+			for _, dep := range parser.Deps(label) {
+				if changed[dep] {
+					deps = append(deps, dep)
+				}
+			}
+			if len(deps) == 0 {
+				continue
+			} else if r, ok := replace(running[string(label)], deps, running, tasks); ok {
+				replaced = append(replaced, r...)
+				continue
+			}
+			changed[label] = true
+		}
+		task, err := parser.New(label, func(ref types.Label) (server.Task, error) {
+			if task, exists := tasks[ref]; exists {
+				return task, nil
+			} else if task, exists := running[string(ref)]; exists {
+				return task.Task, nil
+			} else {
+				return nil, ErrNotFound.Withf("%q", ref)
+			}
+		})
+		if err != nil {
+			restore(replaced)
+			return errors.Join(fmt.Errorf("[%s] %w", label, err), closeTasks(tasks))
+		}
+		tasks[label] = task
+	}
+
+	// Stop tasks which have changed or been removed, in reverse order
+	var stopped int
+	for i := len(p.tasks) - 1; i >= 0; i-- {
+		label := types.Label(p.tasks[i].Label)
+		if changed[label] || !slices.Contains(labels, label) {
+			p.stop(p.tasks[i])
+			stopped++
+		}
+	}
+
+	// Start new tasks in order, retaining tasks which have not changed
+	result := make([]*state, 0, len(labels))
+	for _, label := range labels {
+		if task, exists := tasks[label]; exists {
+			result = append(result, &state{Task: task, Label: string(label)})
+		} else {
+			result = append(result, running[string(label)])
+		}
+	}
+	p.tasks, p.parser = result, parser
+	p.setLoggers()
+	p.setCollectors()
+	for _, task := range p.tasks {
+		if _, exists := tasks[types.Label(task.Label)]; exists {
+			p.start(task)
+		}
+	}
+
+	// Report on the reload
+	p.Printf(ctx, "Configuration loaded: %d task(s) started, %d task(s) stopped, %d task(s) replaced", len(tasks), stopped, len(replaced))
+
+	// Return success
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS - LOGGER
 
 func (p *provider) Print(ctx context.Context, args ...any) {
 	var wg sync.WaitGroup
 
+	p.loggerLock.RLock()
+	defer p.loggerLock.RUnlock()
+
 	// With no loggers, just print to stdout
 	if len(p.loggers) == 0 {
 		log.Print(args...)
@@ -163,6 +315,9 @@ func (p *provider) Print(ctx context.Context, args ...any) {
 func (p *provider) Printf(ctx context.Context, fmt string, args ...any) {
 	var wg sync.WaitGroup
 
+	p.loggerLock.RLock()
+	defer p.loggerLock.RUnlock()
+
 	// With no loggers, just print to stdout
 	if len(p.loggers) == 0 {
 		log.Printf(fmt, args...)
@@ -185,3 +340,182 @@ func (p *provider) Printf(ctx context.Context, fmt string, args ...any) {
 	}
 	wg.Wait()
 }
+
+////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - REGISTRY
+
+// Register a collector, which is not a task
+func (p *provider) Register(collector server.Collector) {
+	p.collectorLock.Lock()
+	defer p.collectorLock.Unlock()
+	if !slices.Contains(p.registered, collector) {
+		p.registered = append(p.registered, collector)
+	}
+}
+
+// Unregister a collector
+func (p *provider) Unregister(collector server.Collector) {
+	p.collectorLock.Lock()
+	defer p.collectorLock.Unlock()
+	p.registered = slices.DeleteFunc(p.registered, func(c server.Collector) bool {
+		return c == collector
+	})
+}
+
+// Gather metrics from the tasks which are collectors, with the label of the
+// task in the context, and then from the registered collectors
+func (p *provider) Gather(ctx context.Context, w server.MetricWriter) {
+	p.collectorLock.RLock()
+	collectors := slices.Clone(p.collectors)
+	registered := slices.Clone(p.registered)
+	p.collectorLock.RUnlock()
+
+	// Collect outside the lock, so collectors can register and unregister
+	for _, collector := range collectors {
+		collector.Collect(WithLabel(ctx, collector.Label), w)
+	}
+	for _, collector := range registered {
+		collector.Collect(ctx, w)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Start a task in the background. The provider should be locked
+func (p *provider) start(task *state) {
+	// Create a context for the task
+	ctx, cancel := context.WithCancel(WithLabel(context.Background(), task.Label))
+	ctx = WithProvider(WithLogger(ctx, p), p)
+
+	// Set the context and cancel function
+	task.Context = ctx
+	task.Cancel = cancel
+	task.Add(1)
+
+	// Run the task in a goroutine
+	p.wg.Add(1)
+	go func(prematureCancel context.CancelFunc) {
+		defer p.wg.Done()
+		defer task.Done()
+		defer task.Cancel()
+
+		p.Print(ctx, "Running")
+		if err := task.Run(ctx); err != nil {
+			p.setError(fmt.Errorf("[%s] %w", Label(ctx), err))
+
+			// We indicate we should cancel
+			prematureCancel()
+		}
+	}(p.cancel)
+}
+
+// Replace the dependencies of a running task with the new tasks, when the
+// running task is a replacer. Returns false if the task is not a replacer,
+// or any dependency cannot be replaced, in which case the task should be
+// restarted
+func replace(task *state, deps []types.Label, running map[string]*state, tasks map[types.Label]server.Task) ([]replacement, bool) {
+	if task == nil {
+		return nil, false
+	}
+	replacer, ok := task.Task.(server.Replacer)
+	if !ok {
+		return nil, false
+	}
+	var result []replacement
+	for _, dep := range deps {
+		from, exists := running[string(dep)]
+		if !exists {
+			restore(result)
+			return nil, false
+		}
+		to := tasks[dep]
+		if !replacer.Replace(from.Task, to) {
+			restore(result)
+			return nil, false
+		}
+		result = append(result, replacement{replacer, from.Task, to})
+	}
+	return result, true
+}
+
+// Restore the tasks which were replaced, in reverse order
+func restore(replaced []replacement) {
+	for i := len(replaced) - 1; i >= 0; i-- {
+		replaced[i].Replace(replaced[i].to, replaced[i].from)
+	}
+}
+
+// Close tasks which were created but not run, when they hold resources
+func closeTasks(tasks map[types.Label]server.Task) error {
+	var result error
+	for label, task := range tasks {
+		if closer, ok := task.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				result = errors.Join(result, fmt.Errorf("[%s] %w", label, err))
+			}
+		}
+	}
+	return result
+}
+
+// Stop a task and wait for it to complete. The provider should be locked
+func (p *provider) stop(task *state) {
+	if task.Cancel == nil {
+		return
+	}
+	p.Print(task.Context, "Stopping")
+	task.Cancel()
+	task.Wait()
+
+	// If the task is in the set of loggers, then remove it
+	// from the list of loggers
+	if logger, ok := task.Task.(server.Logger); ok {
+		p.loggerLock.Lock()
+		p.loggers = slices.DeleteFunc(p.loggers, func(l server.Logger) bool {
+			return l == logger
+		})
+		p.loggerLock.Unlock()
+	}
+
+	// If the task is in the set of collectors, then remove it
+	// from the list of collectors
+	if c, ok := task.Task.(server.Collector); ok {
+		p.collectorLock.Lock()
+		p.collectors = slices.DeleteFunc(p.collectors, func(other collector) bool {
+			return other.Collector == c
+		})
+		p.collectorLock.Unlock()
+	}
+}
+
+// Set the loggers from the tasks
+func (p *provider) setLoggers() {
+	p.loggerLock.Lock()
+	defer p.loggerLock.Unlock()
+	p.loggers = p.loggers[:0]
+	for _, task := range p.tasks {
+		if logger, ok := task.Task.(server.Logger); ok {
+			p.loggers = append(p.loggers, logger)
+		}
+	}
+}
+
+// Set the collectors from the tasks
+func (p *provider) setCollectors() {
+	p.collectorLock.Lock()
+	defer p.collectorLock.Unlock()
+	p.collectors = p.collectors[:0]
+	for _, task := range p.tasks {
+		if c, ok := task.Task.(server.Collector); ok {
+			p.collectors = append(p.collectors, collector{c, task.Label})
+		}
+	}
+}
+
+// Append an error returned from a task
+func (p *provider) setError(err error) {
+	p.resultLock.Lock()
+	defer p.resultLock.Unlock()
+	p.result = errors.Join(p.result, err)
+}
@@ -1,9 +1,19 @@
 package provider_test
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	// Packages
+	server "github.com/mutablelogic/go-server"
 	"github.com/mutablelogic/go-server/pkg/handler/logger"
 	"github.com/mutablelogic/go-server/pkg/handler/router"
 	"github.com/mutablelogic/go-server/pkg/httpserver"
@@ -19,3 +29,348 @@ func Test_provider_001(t *testing.T) {
 
 	t.Log(provider)
 }
+
+func Test_provider_002(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "config.json")
+	runs := new(testRuns)
+
+	// Write the configuration, and create a provider which loads it
+	write := func(config string) {
+		assert.NoError(os.WriteFile(path, []byte(config), 0600))
+	}
+	provider := provider.NewProviderWithLoader(func() (*provider.Parser, error) {
+		r, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		parser, err := provider.NewParser(testConfig{runs: runs})
+		if err != nil {
+			return nil, err
+		} else if err := parser.ParseJSON(r); err != nil {
+			return nil, err
+		} else if err := parser.Bind(); err != nil {
+			return nil, err
+		}
+		return parser, nil
+	})
+
+	// Run the provider
+	write(`{
+		"test.a": { "value": "a" },
+		"test.b": { "value": "b", "ref": "${ test.a }" },
+		"test.c": { "value": "c" }
+	}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(provider.Run(ctx))
+	}()
+	assert.Eventually(func() bool {
+		return runs.Get("test.a") == 1 && runs.Get("test.b") == 1 && runs.Get("test.c") == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// Change a, which b depends on, and add d
+	write(`{
+		"test.a": { "value": "a2" },
+		"test.b": { "value": "b", "ref": "${ test.a }" },
+		"test.c": { "value": "c" },
+		"test.d": { "value": "d" }
+	}`)
+	assert.NoError(provider.Reload(ctx))
+	assert.Eventually(func() bool {
+		return runs.Get("test.a") == 2 && runs.Get("test.b") == 2 && runs.Get("test.d") == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(1, runs.Get("test.c"))
+	assert.True(runs.Running("test.c"))
+
+	// Remove c, and a configuration error leaves the tasks running
+	write(`{
+		"test.a": { "value": "a2" },
+		"test.b": { "value": "b", "ref": "${ test.a }" },
+		"test.d": { "value": "d" }
+	}`)
+	assert.NoError(provider.Reload(ctx))
+	assert.False(runs.Running("test.c"))
+	write(`{ "test.a": { "unknown": "a" } }`)
+	assert.Error(provider.Reload(ctx))
+	assert.True(runs.Running("test.a"))
+	assert.Equal(2, runs.Get("test.a"))
+
+	// Tasks created before a task which cannot be created are closed
+	write(`{
+		"test.a": { "value": "a3" },
+		"test.b": { "value": "fail", "ref": "${ test.a }" },
+		"test.d": { "value": "d" }
+	}`)
+	assert.Error(provider.Reload(ctx))
+	assert.Equal(1, runs.Closed("a3"))
+	assert.True(runs.Running("test.a"))
+
+	// Stop the provider
+	cancel()
+	wg.Wait()
+	assert.False(runs.Running("test.a"))
+}
+
+func Test_provider_003(t *testing.T) {
+	assert := assert.New(t)
+
+	// Tasks which are collectors are gathered
+	p := provider.NewProvider(&testTask{testConfig{Value: "a"}}, &testTask{testConfig{Value: "b"}})
+	registry, ok := p.(server.Registry)
+	if !assert.True(ok) {
+		t.SkipNow()
+	}
+	w := make(testMetrics)
+	registry.Gather(context.Background(), w)
+	assert.Equal(testMetrics{"a": 1, "b": 1}, w)
+
+	// Register and unregister a collector
+	c := &testTask{testConfig{Value: "c"}}
+	registry.Register(c)
+	registry.Register(c)
+	registry.Gather(context.Background(), w)
+	assert.Equal(testMetrics{"a": 2, "b": 2, "c": 1}, w)
+	registry.Unregister(c)
+	registry.Gather(context.Background(), w)
+	assert.Equal(testMetrics{"a": 3, "b": 3, "c": 1}, w)
+}
+
+func Test_provider_004(t *testing.T) {
+	assert := assert.New(t)
+
+	// Tasks which are collectors have their label in the context, and
+	// registered collectors do not
+	p := provider.NewProvider(&testTask{testConfig{Value: "a"}})
+	registry := p.(server.Registry)
+	registry.Register(&testTask{testConfig{Value: "b"}})
+	w := make(testLabels)
+	registry.Gather(context.Background(), w)
+	assert.Equal(testLabels{"a": {"task", "test"}, "b": {"task", ""}}, w)
+}
+
+func Test_provider_005(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "config.json")
+	runs, hold := new(testRuns), make(chan struct{})
+
+	// Reserve an address for the server
+	listener, err := net.Listen("tcp", "localhost:0")
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	// Write the configuration, and create a provider which loads it
+	write := func(value string) {
+		assert.NoError(os.WriteFile(path, []byte(`{
+			"test.a": { "value": "`+value+`" },
+			"router.main": { "services": { "test": { "service": "${ test.a }" } } },
+			"httpserver.main": { "listen": "`+addr+`", "drain": "100ms", "router": "${ router.main }" }
+		}`), 0600))
+	}
+	provider := provider.NewProviderWithLoader(func() (*provider.Parser, error) {
+		r, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		parser, err := provider.NewParser(testConfig{runs: runs, hold: hold}, router.Config{}, httpserver.Config{})
+		if err != nil {
+			return nil, err
+		} else if err := parser.ParseJSON(r); err != nil {
+			return nil, err
+		} else if err := parser.Bind(); err != nil {
+			return nil, err
+		}
+		return parser, nil
+	})
+	// Requests are not retried on a new connection when the connection is
+	// closed, as connections are not reused
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	get := func(query string) (string, error) {
+		resp, err := client.Get("http://" + addr + "/test/" + query)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return string(data), err
+	}
+
+	// Run the provider
+	write("a")
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(provider.Run(ctx))
+	}()
+	assert.Eventually(func() bool {
+		value, err := get("")
+		return err == nil && value == "a"
+	}, time.Second, 10*time.Millisecond)
+
+	// Change the service while a request is in progress, which completes
+	// as the server is not restarted
+	result := make(chan string)
+	go func() {
+		value, err := get("?hold")
+		assert.NoError(err)
+		result <- value
+	}()
+	<-hold
+	write("a2")
+	assert.NoError(provider.Reload(ctx))
+	hold <- struct{}{}
+	assert.Equal("a", <-result)
+
+	// The service has been replaced in the router
+	value, err := get("")
+	assert.NoError(err)
+	assert.Equal("a2", value)
+	assert.Equal(2, runs.Get("test.a"))
+
+	// Stop the provider
+	cancel()
+	wg.Wait()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// TEST PLUGIN
+
+type testConfig struct {
+	Value string      `hcl:"value"`
+	Ref   server.Task `hcl:"ref"`
+
+	runs *testRuns
+	hold chan struct{}
+}
+
+type testTask struct {
+	testConfig
+}
+
+// testMetrics counts the samples written for each metric
+type testMetrics map[string]int
+
+// testLabels records the labels written for each metric
+type testLabels map[string][]string
+
+type testRuns struct {
+	sync.Mutex
+	runs    map[string]int
+	running map[string]bool
+	closed  map[string]int
+}
+
+func (testConfig) Name() string {
+	return "test"
+}
+
+func (testConfig) Description() string {
+	return "test plugin"
+}
+
+func (c testConfig) New() (server.Task, error) {
+	if c.Value == "fail" {
+		return nil, errors.New("cannot create task")
+	}
+	return &testTask{c}, nil
+}
+
+func (*testTask) Label() string {
+	return "test"
+}
+
+func (t *testTask) Run(ctx context.Context) error {
+	label := provider.Label(ctx)
+	t.runs.Set(label, true)
+	<-ctx.Done()
+	t.runs.Set(label, false)
+	return nil
+}
+
+// Add an endpoint which responds with the value, and holds requests with a
+// hold parameter until released
+func (t *testTask) AddEndpoints(ctx context.Context, r server.Router) {
+	r.AddHandlerFunc(ctx, "/", func(w http.ResponseWriter, req *http.Request) {
+		if t.hold != nil && req.URL.Query().Has("hold") {
+			t.hold <- struct{}{}
+			<-t.hold
+		}
+		w.Write([]byte(t.Value))
+	})
+}
+
+func (t *testTask) Close() error {
+	if t.runs != nil {
+		t.runs.Close(t.Value)
+	}
+	return nil
+}
+
+func (r *testRuns) Close(value string) {
+	r.Lock()
+	defer r.Unlock()
+	if r.closed == nil {
+		r.closed = make(map[string]int)
+	}
+	r.closed[value]++
+}
+
+func (r *testRuns) Closed(value string) int {
+	r.Lock()
+	defer r.Unlock()
+	return r.closed[value]
+}
+
+func (r *testRuns) Set(label string, running bool) {
+	r.Lock()
+	defer r.Unlock()
+	if r.runs == nil {
+		r.runs, r.running = make(map[string]int), make(map[string]bool)
+	}
+	if running {
+		r.runs[label]++
+	}
+	r.running[label] = running
+}
+
+func (r *testRuns) Get(label string) int {
+	r.Lock()
+	defer r.Unlock()
+	return r.runs[label]
+}
+
+func (r *testRuns) Running(label string) bool {
+	r.Lock()
+	defer r.Unlock()
+	return r.running[label]
+}
+
+func (t *testTask) Collect(ctx context.Context, w server.MetricWriter) {
+	w.Gauge(t.Value, "", 1, "task", provider.Label(ctx))
+}
+
+func (m testMetrics) Counter(name, help string, value float64, labels ...string) {
+	m[name]++
+}
+
+func (m testMetrics) Gauge(name, help string, value float64, labels ...string) {
+	m[name]++
+}
+
+func (m testLabels) Counter(name, help string, value float64, labels ...string) {
+	m[name] = labels
+}
+
+func (m testLabels) Gauge(name, help string, value float64, labels ...string) {
+	m[name] = labels
+}
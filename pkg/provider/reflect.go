@@ -44,6 +44,9 @@ type metafield struct {
 	// Field index
 	Index []int
 
+	// Field is required
+	Required bool
+
 	// Field children, if any
 	Children []metafield
 }
@@ -55,6 +58,7 @@ const (
 	// Field names from hcl or json tags
 	tagNames       = "hcl,json"
 	tagDescription = "description"
+	tagRequired    = "required"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -103,6 +107,7 @@ func (m *metafield) MarshalJSON() ([]byte, error) {
 		Type        string      `json:"type"`
 		Elem        string      `json:"elem,omitempty"`
 		Index       []int       `json:"index"`
+		Required    bool        `json:"required,omitempty"`
 		Children    []metafield `json:"children,omitempty"`
 	}
 	typeToString := func(t reflect.Type) string {
@@ -117,6 +122,7 @@ func (m *metafield) MarshalJSON() ([]byte, error) {
 		Type:        typeToString(m.Type),
 		Elem:        typeToString(m.Elem),
 		Index:       m.Index,
+		Required:    m.Required,
 		Children:    m.Children,
 	})
 }
@@ -239,6 +245,7 @@ func enumerate_field(index []int, field meta.Field) (metafield, error) {
 		Description: field.Tag(tagDescription),
 		Type:        field.Type(),
 		Index:       append(index, field.Index()...),
+		Required:    field.Is(tagRequired),
 	}
 
 	switch result.Type.Kind() {
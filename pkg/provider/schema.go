@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/types"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Schema is a JSON Schema which describes a plugin configuration, or a
+// configuration file with many plugin configurations
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	PatternProperties    map[string]*Schema `json:"patternProperties,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	schemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+	// Formats for values which are strings
+	schemaFormatDuration  = "duration"
+	schemaFormatReference = "reference"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// NewSchema returns a JSON Schema for a configuration file which contains
+// configurations for the plugins, and a var block
+func NewSchema(plugins ...*PluginMeta) *Schema {
+	schema := &Schema{
+		Schema:               schemaVersion,
+		Type:                 "object",
+		Properties:           make(map[string]*Schema, 1),
+		PatternProperties:    make(map[string]*Schema, len(plugins)),
+		AdditionalProperties: false,
+		Defs:                 make(map[string]*Schema, len(plugins)),
+	}
+
+	// The var block can contain any values
+	schema.Properties[labelVar] = &Schema{
+		Description: "Variables",
+		Type:        "object",
+	}
+
+	// Each plugin configuration is keyed by the plugin name, and an
+	// optional label suffix
+	for _, plugin := range plugins {
+		def := plugin.Schema()
+		def.Schema = ""
+		schema.Defs[plugin.Name] = def
+		schema.PatternProperties["^"+regexp.QuoteMeta(plugin.Name)+`(\`+types.LabelSeparator+".+)?$"] = &Schema{
+			Ref: "#/$defs/" + plugin.Name,
+		}
+	}
+
+	// Return the schema
+	return schema
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (s *Schema) String() string {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Schema returns a JSON Schema for the plugin configuration
+func (m *PluginMeta) Schema() *Schema {
+	schema := schemaForFields(m.Fields)
+	schema.Schema = schemaVersion
+	schema.Title = m.Name
+	schema.Description = m.Description
+	return schema
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return a schema for a block with fields
+func schemaForFields(fields []metafield) *Schema {
+	schema := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema, len(fields)),
+		AdditionalProperties: false,
+	}
+	for _, field := range fields {
+		var property *Schema
+		if field.Type.Kind() == reflect.Struct {
+			property = schemaForFields(field.Children)
+		} else {
+			property = schemaForType(field.Type)
+		}
+		property.Description = field.Description
+		schema.Properties[field.Key] = property
+		if field.Required {
+			schema.Required = append(schema.Required, field.Key)
+		}
+	}
+	return schema
+}
+
+// Return a schema for a type
+func schemaForType(rt reflect.Type) *Schema {
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt == typeDuration {
+		return &Schema{Type: "string", Format: schemaFormatDuration}
+	}
+	switch rt.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaForType(rt.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaForType(rt.Elem())}
+	case reflect.Struct:
+		if fields, err := enumerate(nil, rt); err == nil {
+			return schemaForFields(fields)
+		}
+	case reflect.Interface:
+		// References to other configurations are tasks, which satisfy
+		// an interface
+		if rt.NumMethod() != 0 {
+			return &Schema{Type: "string", Format: schemaFormatReference}
+		}
+	}
+
+	// Any value
+	return &Schema{}
+}
@@ -0,0 +1,126 @@
+package provider_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	"github.com/mutablelogic/go-server/pkg/provider"
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaConfig struct {
+	Title   string            `hcl:"name,required" description:"Name"`
+	Timeout time.Duration     `hcl:"timeout" description:"Timeout"`
+	Tags    []string          `hcl:"tags"`
+	Env     map[string]string `hcl:"env"`
+	TLS     struct {
+		Key  string `hcl:"key,required"`
+		Cert string `hcl:"cert"`
+	} `hcl:"tls"`
+	Ref server.Task `hcl:"ref"`
+}
+
+func (schemaConfig) Name() string {
+	return "schema"
+}
+
+func (schemaConfig) Description() string {
+	return "schema plugin"
+}
+
+func (c schemaConfig) New() (server.Task, error) {
+	return nil, nil
+}
+
+func Test_schema_001(t *testing.T) {
+	assert := assert.New(t)
+
+	meta, err := provider.NewPluginMeta(schemaConfig{})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	schema := meta.Schema()
+	assert.Equal("schema", schema.Title)
+	assert.Equal("object", schema.Type)
+	assert.Equal([]string{"name"}, schema.Required)
+	assert.Equal("string", schema.Properties["name"].Type)
+	assert.Equal("Name", schema.Properties["name"].Description)
+	assert.Equal("duration", schema.Properties["timeout"].Format)
+	assert.Equal("array", schema.Properties["tags"].Type)
+	assert.Equal("string", schema.Properties["tags"].Items.Type)
+	assert.Equal("object", schema.Properties["env"].Type)
+	assert.Equal("object", schema.Properties["tls"].Type)
+	assert.Equal([]string{"key"}, schema.Properties["tls"].Required)
+	assert.Equal("reference", schema.Properties["ref"].Format)
+	t.Log(schema)
+}
+
+func Test_schema_002(t *testing.T) {
+	assert := assert.New(t)
+
+	meta, err := provider.NewPluginMeta(schemaConfig{})
+	if !assert.NoError(err) {
+		t.SkipNow()
+	}
+
+	schema := provider.NewSchema(meta)
+	assert.Contains(schema.Defs, "schema")
+	assert.Contains(schema.Properties, "var")
+	assert.Len(schema.PatternProperties, 1)
+	t.Log(schema)
+}
+
+func Test_schema_003(t *testing.T) {
+	assert := assert.New(t)
+
+	// Parse, bind and validate a configuration
+	validate := func(config string) error {
+		parser, err := provider.NewParser(schemaConfig{}, testConfig{})
+		if err != nil {
+			return err
+		} else if err := parser.ParseJSON(strings.NewReader(config)); err != nil {
+			return err
+		} else if err := parser.Bind(); err != nil {
+			return err
+		} else {
+			return parser.Validate()
+		}
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		assert.NoError(validate(`{ "test.a": {}, "schema.a": { "name": "a", "timeout": "1s", "tls": { "key": "k" }, "ref": "${ test.a }" } }`))
+	})
+	t.Run("UnknownField", func(t *testing.T) {
+		assert.ErrorContains(validate(`{ "schema.a": { "name": "a", "unknown": true } }`), `schema.a: unknown field "unknown"`)
+	})
+	t.Run("TypeError", func(t *testing.T) {
+		assert.ErrorContains(validate(`{ "schema.a": { "name": 1 } }`), "schema.a.name: expected string")
+	})
+	t.Run("Duration", func(t *testing.T) {
+		assert.ErrorContains(validate(`{ "schema.a": { "name": "a", "timeout": "1 minute" } }`), "schema.a.timeout")
+	})
+	t.Run("Required", func(t *testing.T) {
+		assert.ErrorContains(validate(`{ "schema.a": { } }`), `schema.a: missing required field "name"`)
+	})
+	t.Run("RequiredNested", func(t *testing.T) {
+		assert.ErrorContains(validate(`{ "schema.a": { "name": "a", "tls": { } } }`), `schema.a.tls: missing required field "key"`)
+	})
+	t.Run("Reference", func(t *testing.T) {
+		assert.ErrorContains(validate(`{ "schema.a": { "name": "a", "ref": "${ test.b }" } }`), `undefined reference "test.b"`)
+	})
+	t.Run("ReferenceType", func(t *testing.T) {
+		assert.ErrorContains(validate(`{ "test.a": {}, "schema.a": { "name": "${ test.a }" } }`), `"test.a" cannot be used as string`)
+	})
+	t.Run("Circular", func(t *testing.T) {
+		assert.ErrorContains(validate(`{ "test.a": { "ref": "${ test.b }" }, "test.b": { "ref": "${ test.a }" } }`), "circular dependency")
+	})
+	t.Run("Many", func(t *testing.T) {
+		err := validate(`{ "schema.a": { "name": 1 }, "schema.b": { } }`)
+		assert.ErrorContains(err, "schema.a.name")
+		assert.ErrorContains(err, "schema.b")
+	})
+}
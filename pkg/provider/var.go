@@ -1,21 +1,126 @@
 package provider
 
+import (
+	"os"
+	"strings"
+
+	// Packages
+	"github.com/mutablelogic/go-server/pkg/provider/ast"
+	"github.com/mutablelogic/go-server/pkg/types"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
 /////////////////////////////////////////////////////////////////////
 // TYPES
 
+// variables are defined in var blocks, and evaluated when they are
+// first referenced
 type variables struct {
+	nodes     map[string]ast.Node
+	values    map[string]any
+	resolving map[string]bool
 }
 
 /////////////////////////////////////////////////////////////////////
 // GLOBALS
 
 const (
-	labelVar = "var"
+	labelVar       = "var"
+	labelEnv       = "env"
+	defaultVarSep  = ":-"
+	labelVarPrefix = labelVar + types.LabelSeparator
+	labelEnvPrefix = labelEnv + types.LabelSeparator
 )
 
 /////////////////////////////////////////////////////////////////////
 // LIFECYCLE
 
 func NewVariables() *variables {
-	return &variables{}
+	return &variables{
+		nodes:     make(map[string]ast.Node),
+		values:    make(map[string]any),
+		resolving: make(map[string]bool),
+	}
+}
+
+/////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Add variables from a var block, which should be a map
+func (v *variables) Add(block ast.Node) error {
+	if len(block.Children()) != 1 || block.Children()[0].Type() != ast.Map {
+		return ErrBadParameter.Withf("%q block should be a map", labelVar)
+	}
+	for _, node := range block.Children()[0].Children() {
+		name := node.Key()
+		if !types.IsIdentifier(name) {
+			return ErrBadParameter.Withf("invalid variable name %q", name)
+		} else if _, exists := v.nodes[name]; exists {
+			return ErrDuplicateEntry.Withf("duplicate variable %q", labelVarPrefix+name)
+		} else {
+			v.nodes[name] = node
+		}
+	}
+
+	// Return success
+	return nil
+}
+
+// Reset evaluated values, so that variables are evaluated again
+func (v *variables) Reset() {
+	clear(v.values)
+	clear(v.resolving)
+}
+
+// Get the value of a variable, evaluating it with the function if it has
+// not already been evaluated
+func (v *variables) Get(name string, fn ast.EvalFunc) (any, error) {
+	if value, exists := v.values[name]; exists {
+		return value, nil
+	}
+	node, exists := v.nodes[name]
+	if !exists {
+		return nil, ErrNotFound.Withf("undefined variable %q", labelVarPrefix+name)
+	} else if v.resolving[name] {
+		return nil, ErrBadParameter.Withf("circular reference to variable %q", labelVarPrefix+name)
+	}
+
+	// Evaluate the variable
+	v.resolving[name] = true
+	defer delete(v.resolving, name)
+	ctx := ast.NewContext(fn)
+	ctx.SetLabel(types.Label(labelVarPrefix + name))
+	value, err := node.Value(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache the value
+	v.values[name] = value
+
+	// Return success
+	return value, nil
+}
+
+/////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return the value of an environment variable
+func env(name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+	return os.LookupEnv(name)
+}
+
+// Split an expression into the name and the default value, which
+// follows a :- separator
+func splitDefault(expr string) (string, string, bool) {
+	if name, value, found := strings.Cut(expr, defaultVarSep); found {
+		return strings.TrimSpace(name), strings.TrimSpace(value), true
+	} else {
+		return expr, "", false
+	}
 }
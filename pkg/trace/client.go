@@ -0,0 +1,31 @@
+package trace
+
+import (
+	"context"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Do calls a function within a client span, such as a call to another
+// service or command, and records any error. Attributes are pairs of keys
+// and values
+func Do(ctx context.Context, name string, fn func() error, attrs ...any) error {
+	_, span := Start(ctx, name, KindClient, attrs...)
+	defer span.End()
+
+	err := fn()
+	span.SetError(err)
+	return err
+}
+
+// Call calls a function which returns a value within a client span, and
+// records any error. Attributes are pairs of keys and values
+func Call[T any](ctx context.Context, name string, fn func() (T, error), attrs ...any) (T, error) {
+	_, span := Start(ctx, name, KindClient, attrs...)
+	defer span.End()
+
+	result, err := fn()
+	span.SetError(err)
+	return result, err
+}
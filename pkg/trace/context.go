@@ -0,0 +1,93 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+type TraceContextKey int
+
+// parent is the trace and span identifier of a parent span, which may be
+// in another service
+type parent struct {
+	TraceId string
+	SpanId  string
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	_ TraceContextKey = iota
+	contextExporter
+	contextSpan
+	contextRemote
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// WithExporter returns a context with an exporter, which receives the spans
+// started from the context when they end
+func WithExporter(ctx context.Context, exporter Exporter) context.Context {
+	if exporter == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextExporter, exporter)
+}
+
+// Extract the parent span from the traceparent header of a request, and
+// return a context in which spans are children of the parent. If the header
+// is missing or invalid, the context is returned unchanged
+func Extract(ctx context.Context, header http.Header) context.Context {
+	traceId, spanId, err := Parse(header.Get(HeaderTraceParent))
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextRemote, parent{traceId, spanId})
+}
+
+// Inject the traceparent header for the span in the context, so that an
+// outbound request continues the trace
+func Inject(ctx context.Context, header http.Header) {
+	if span := FromContext(ctx); span != nil {
+		header.Set(HeaderTraceParent, Format(span.TraceId, span.SpanId))
+	}
+}
+
+// FromContext returns the current span from the context, or nil
+func FromContext(ctx context.Context) *Span {
+	if span, ok := ctx.Value(contextSpan).(*Span); ok {
+		return span
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func withSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, contextSpan, span)
+}
+
+func exporterFromContext(ctx context.Context) Exporter {
+	if exporter, ok := ctx.Value(contextExporter).(Exporter); ok {
+		return exporter
+	}
+	return nil
+}
+
+// Return the parent for a new span, which is the current span or else
+// a remote parent
+func parentFromContext(ctx context.Context) parent {
+	if span := FromContext(ctx); span != nil {
+		return parent{span.TraceId, span.SpanId}
+	}
+	if remote, ok := ctx.Value(contextRemote).(parent); ok {
+		return remote
+	}
+	return parent{}
+}
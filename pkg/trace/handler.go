@@ -0,0 +1,23 @@
+package trace
+
+import (
+	"net/http"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Handler wraps a handler function with a span, which is started when there
+// is an exporter in the request context. Attributes are pairs of keys and
+// values
+func Handler(name string, next http.HandlerFunc, attrs ...any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := Start(r.Context(), name, KindInternal, attrs...)
+		if span == nil {
+			next(w, r)
+			return
+		}
+		defer span.End()
+		next(w, r.WithContext(ctx))
+	}
+}
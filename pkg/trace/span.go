@@ -0,0 +1,136 @@
+/*
+implements spans for distributed tracing, which are started from a context
+and propagated between services with the W3C traceparent header. Spans are
+sent to an exporter in the context when they end
+*/
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Span is an operation within a trace. A span is not safe for concurrent use
+type Span struct {
+	TraceId    string         `json:"trace_id"`
+	SpanId     string         `json:"span_id"`
+	ParentId   string         `json:"parent_span_id,omitempty"`
+	Name       string         `json:"name"`
+	Kind       Kind           `json:"kind"`
+	StartTime  time.Time      `json:"start_time"`
+	EndTime    time.Time      `json:"end_time"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Error      string         `json:"error,omitempty"`
+
+	// The exporter which receives the span when it ends
+	exporter Exporter
+}
+
+// Kind is the relationship of a span to its parent and children
+type Kind string
+
+// Exporter receives spans when they end
+type Exporter interface {
+	Export(Span)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	KindInternal Kind = "internal"
+	KindServer   Kind = "server"
+	KindClient   Kind = "client"
+)
+
+const (
+	traceIdBytes = 16
+	spanIdBytes  = 8
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Start a span with a name and kind, which is a child of the span in the
+// context, or of a remote parent. Returns a context with the new span and
+// the span, or the context and nil if there is no exporter in the context.
+// Attributes are pairs of keys and values
+func Start(ctx context.Context, name string, kind Kind, attrs ...any) (context.Context, *Span) {
+	exporter := exporterFromContext(ctx)
+	if exporter == nil {
+		return ctx, nil
+	}
+
+	// Create the span, which continues the trace of the parent
+	span := &Span{
+		SpanId:    newId(spanIdBytes),
+		Name:      name,
+		Kind:      kind,
+		StartTime: time.Now(),
+		exporter:  exporter,
+	}
+	if parent := parentFromContext(ctx); parent.TraceId != "" {
+		span.TraceId, span.ParentId = parent.TraceId, parent.SpanId
+	} else {
+		span.TraceId = newId(traceIdBytes)
+	}
+
+	// Set the attributes
+	for i := 0; i+1 < len(attrs); i += 2 {
+		if key, ok := attrs[i].(string); ok {
+			span.Set(key, attrs[i+1])
+		}
+	}
+
+	// Return the context with the span
+	return withSpan(ctx, span), span
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Set an attribute on the span
+func (span *Span) Set(key string, value any) {
+	if span == nil {
+		return
+	}
+	if span.Attributes == nil {
+		span.Attributes = make(map[string]any)
+	}
+	span.Attributes[key] = value
+}
+
+// Record an error on the span, if the error is not nil
+func (span *Span) SetError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+	span.Error = err.Error()
+}
+
+// End the span and send it to the exporter. Calling End more than once
+// has no effect
+func (span *Span) End() {
+	if span == nil || !span.EndTime.IsZero() {
+		return
+	}
+	span.EndTime = time.Now()
+	span.exporter.Export(*span)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// Return a random identifier as a hex string
+func newId(n int) string {
+	id := make([]byte, n)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,93 @@
+package trace_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	// Packages
+	trace "github.com/mutablelogic/go-server/pkg/trace"
+	assert "github.com/stretchr/testify/assert"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+func Test_trace_001(t *testing.T) {
+	assert := assert.New(t)
+
+	// Parse a traceparent
+	traceId, spanId, err := trace.Parse("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.NoError(err)
+	assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", traceId)
+	assert.Equal("00f067aa0ba902b7", spanId)
+	assert.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", trace.Format(traceId, spanId))
+
+	// Invalid traceparent
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+	} {
+		_, _, err := trace.Parse(value)
+		assert.ErrorIs(err, ErrBadParameter, value)
+	}
+}
+
+func Test_trace_002(t *testing.T) {
+	assert := assert.New(t)
+
+	// Without an exporter there is no span
+	ctx, span := trace.Start(context.Background(), "test", trace.KindInternal)
+	assert.Nil(span)
+	assert.Nil(trace.FromContext(ctx))
+	span.Set("key", "value")
+	span.End()
+
+	// With an exporter, spans continue the trace of a remote parent
+	var spans testExporter
+	header := http.Header{}
+	header.Set(trace.HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx = trace.Extract(trace.WithExporter(context.Background(), &spans), header)
+	ctx, parent := trace.Start(ctx, "parent", trace.KindServer, "key", "value")
+	if !assert.NotNil(parent) {
+		t.SkipNow()
+	}
+	err := trace.Do(ctx, "child", func() error {
+		return errors.New("failed")
+	})
+	assert.EqualError(err, "failed")
+	parent.End()
+	parent.End()
+
+	// The child ends first
+	if assert.Len(spans, 2) {
+		assert.Equal("child", spans[0].Name)
+		assert.Equal(trace.KindClient, spans[0].Kind)
+		assert.Equal(parent.SpanId, spans[0].ParentId)
+		assert.Equal("failed", spans[0].Error)
+		assert.Equal("parent", spans[1].Name)
+		assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", spans[1].TraceId)
+		assert.Equal("00f067aa0ba902b7", spans[1].ParentId)
+		assert.Equal("value", spans[1].Attributes["key"])
+		assert.False(spans[1].EndTime.Before(spans[1].StartTime))
+	}
+
+	// Inject the traceparent into an outbound request
+	header = http.Header{}
+	trace.Inject(ctx, header)
+	assert.Equal(trace.Format(parent.TraceId, parent.SpanId), header.Get(trace.HeaderTraceParent))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// EXPORTER
+
+type testExporter []trace.Span
+
+func (e *testExporter) Export(span trace.Span) {
+	*e = append(*e, span)
+}
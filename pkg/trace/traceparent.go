@@ -0,0 +1,55 @@
+package trace
+
+import (
+	"regexp"
+	"strings"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	HeaderTraceParent = "traceparent"
+	traceVersion      = "00"
+	traceSampled      = "01"
+)
+
+var (
+	reTraceParent = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Parse a traceparent header value and return the trace and parent span
+// identifiers
+func Parse(value string) (string, string, error) {
+	parts := reTraceParent.FindStringSubmatch(strings.TrimSpace(value))
+	if parts == nil {
+		return "", "", ErrBadParameter.Withf("traceparent: %q", value)
+	}
+	version, traceId, spanId := parts[1], parts[2], parts[3]
+	if version == "ff" {
+		return "", "", ErrBadParameter.Withf("traceparent: unsupported version %q", version)
+	}
+	if isZero(traceId) || isZero(spanId) {
+		return "", "", ErrBadParameter.With("traceparent: invalid identifier")
+	}
+	return traceId, spanId, nil
+}
+
+// Format a traceparent header value for a trace and span identifier. All
+// spans are sampled
+func Format(traceId, spanId string) string {
+	return traceVersion + "-" + traceId + "-" + spanId + "-" + traceSampled
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func isZero(id string) bool {
+	return strings.Trim(id, "0") == ""
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	compress "github.com/mutablelogic/go-server/pkg/handler/compress"
+)
+
+func Plugin() server.Plugin {
+	return compress.Config{}
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	metrics "github.com/mutablelogic/go-server/pkg/handler/metrics"
+)
+
+func Plugin() server.Plugin {
+	return metrics.Config{}
+}
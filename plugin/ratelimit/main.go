@@ -0,0 +1,11 @@
+package main
+
+import (
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	ratelimit "github.com/mutablelogic/go-server/pkg/handler/ratelimit"
+)
+
+func Plugin() server.Plugin {
+	return ratelimit.Config{}
+}
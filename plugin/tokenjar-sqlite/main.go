@@ -0,0 +1,11 @@
+package main
+
+import (
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	sqlite "github.com/mutablelogic/go-server/pkg/handler/tokenjar/sqlite"
+)
+
+func Plugin() server.Plugin {
+	return sqlite.Config{}
+}
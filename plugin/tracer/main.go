@@ -0,0 +1,11 @@
+package main
+
+import (
+	// Packages
+	server "github.com/mutablelogic/go-server"
+	tracer "github.com/mutablelogic/go-server/pkg/handler/tracer"
+)
+
+func Plugin() server.Plugin {
+	return tracer.Config{}
+}
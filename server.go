@@ -39,3 +39,41 @@ type Provider interface {
 	Task
 	Logger
 }
+
+// Collector interface is implemented by tasks which report metrics
+type Collector interface {
+	// Write metric samples, each time metrics are gathered. For tasks,
+	// the context contains the label of the task
+	Collect(context.Context, MetricWriter)
+}
+
+// Replacer interface is implemented by tasks which can replace a task they
+// refer to while running, so they are not restarted when it changes
+type Replacer interface {
+	// Replace references to a task with another task. Returns false if the
+	// task cannot be replaced, and the replacer should be restarted instead
+	Replace(from, to Task) bool
+}
+
+// MetricWriter interface receives metric samples from collectors. Labels
+// are pairs of label names and values
+type MetricWriter interface {
+	// Write a counter, which only ever increases
+	Counter(name, help string, value float64, labels ...string)
+
+	// Write a gauge, which can increase or decrease
+	Gauge(name, help string, value float64, labels ...string)
+}
+
+// Registry interface is implemented by a provider which gathers metrics
+// from the tasks which implement Collector, and any registered collectors
+type Registry interface {
+	// Register a collector
+	Register(Collector)
+
+	// Unregister a collector
+	Unregister(Collector)
+
+	// Gather metrics from all collectors
+	Gather(context.Context, MetricWriter)
+}